@@ -1,26 +1,56 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 	"remediation-engine/internal/api"
+	"remediation-engine/internal/config"
 	"remediation-engine/internal/core"
 	"remediation-engine/internal/database"
+	"remediation-engine/internal/events"
+	"remediation-engine/internal/logging"
+	"remediation-engine/internal/metrics"
 	"remediation-engine/internal/tenancy"
+	"remediation-engine/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	configFlag := flag.String("config", "", "path to the YAML configuration file (default: $CONFIG_FILE or ./config.yaml)")
+	flag.Parse()
+
 	// 1. Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	// Typed configuration: defaults < config file < environment
+	configPath := config.ResolvePath(*configFlag)
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	config.Activate(cfg, configPath)
+	if configPath != "" {
+		log.Printf("[Server] Loaded configuration from %s", configPath)
+	}
+
+	if err := applyLogging(nil, cfg.Logging); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	go reloadOnSIGHUP(configPath)
+
 	// Validate mandatory security configurations
 	if os.Getenv("JWT_SECRET") == "" {
 		log.Fatal("CRITICAL: JWT_SECRET environment variable is not set. Application cannot start securely.")
@@ -35,13 +65,52 @@ func main() {
 	}
 	log.Printf("Starting Integration & Remediation Engine (%s Mode)...", modeStr)
 
+	// Deferred cleanup only runs when main returns, so failures after this point set the
+	// exit code instead of calling log.Fatal
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// Tracing (exports only when an OTLP endpoint is configured)
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("[Server] Failed to flush traces: %v", err)
+		}
+	}()
+	if tracing.Enabled() {
+		log.Println("[Server] OpenTelemetry tracing enabled")
+	}
+
+	// SIEM event forwarding (only when events.sinks is configured)
+	stopEvents, err := events.Init(cfg.Events)
+	if err != nil {
+		log.Fatalf("Failed to start event sinks: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopEvents(ctx)
+	}()
+	if len(cfg.Events.Sinks) > 0 {
+		log.Printf("[Server] Forwarding job events to %d SIEM sink(s)", len(cfg.Events.Sinks))
+	}
+
 	// 2. Initialize Database
 
-	database.InitDB("data/remediation.db")
+	database.InitDB(cfg.Database.Path)
 
 	// 3. Seed and Sync Data (from filesystem JSON)
 
-	database.SeedDatabase(database.DB, "data/seeds")
+	database.SeedDatabase(database.DB, cfg.Database.SeedDir)
 
 	// 4. Start Workflow Engine in background
 
@@ -51,19 +120,14 @@ func main() {
 
 	// 5. Setup Web Server
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.Recovery(), api.RequestID(), api.RequestLogger(), api.MetricsMiddleware())
 
 	// CORS Middleware
 
 	r.Use(func(c *gin.Context) {
 
-		allowedOrigin := os.Getenv("ALLOWED_ORIGIN")
-
-		if allowedOrigin == "" {
-
-			allowedOrigin = "http://localhost:5173" // Default Vite dev port
-
-		}
+		allowedOrigin := config.Current().Server.AllowedOrigin // Reloadable
 
 		origin := c.Request.Header.Get("Origin")
 
@@ -77,7 +141,7 @@ func main() {
 
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Tenant-ID, X-API-Key")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Tenant-ID, X-API-Key, X-Request-ID")
 
 		if c.Request.Method == "OPTIONS" {
 
@@ -91,6 +155,12 @@ func main() {
 
 	})
 
+	// Probes and metrics live outside /api so load balancers and scrapers need no user token
+	// (/metrics checks METRICS_TOKEN when it is set)
+	r.GET("/healthz", api.Healthz)
+	r.GET("/readyz", api.Readyz)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// API Routes
 
 	apiRoutes := r.Group("/api")
@@ -109,6 +179,7 @@ func main() {
 		apiRoutes.POST("/integrations", api.RBACMiddleware("integrator"), api.CreateIntegration)
 		apiRoutes.PUT("/integrations", api.RBACMiddleware("integrator"), api.UpdateIntegration)
 		apiRoutes.PUT("/integrations/:id/reset", api.RBACMiddleware("integrator"), api.ResetIntegrationCircuitBreaker)
+		apiRoutes.GET("/integrations/:id/circuit", api.GetIntegrationCircuit)
 		
 		// Action Templates
 		apiRoutes.GET("/actions", api.GetActionDefinitions)
@@ -126,6 +197,20 @@ func main() {
 		apiRoutes.GET("/jobs", api.GetJobs)
 		apiRoutes.POST("/jobs/:id/rerun", api.RBACMiddleware("workflow_editor", "admin"), api.RerunJob)
 		apiRoutes.GET("/jobs/:id/logs", api.GetJobLogs)
+		apiRoutes.GET("/jobs/:id/events", api.GetJobEvents)
+		apiRoutes.POST("/jobs/:id/cancel", api.RBACMiddleware("workflow_editor", "admin"), api.CancelJob)
+		apiRoutes.POST("/jobs/rerun", api.RBACMiddleware("workflow_editor", "admin"), api.BulkRerunJobs)
+		apiRoutes.GET("/jobs/rerun-batches", api.GetRerunBatches)
+		apiRoutes.GET("/jobs/rerun-batches/:id", api.GetRerunBatch)
+		apiRoutes.POST("/jobs/rerun-batches/:id/cancel", api.RBACMiddleware("workflow_editor", "admin"), api.CancelRerunBatch)
+
+		// Dead-letter triage
+		apiRoutes.GET("/dead-letter", api.GetDeadLetterGroups)
+		apiRoutes.GET("/dead-letter/jobs", api.GetDeadLetterJobs)
+		apiRoutes.POST("/dead-letter/acknowledge", api.RBACMiddleware("workflow_editor", "admin"), api.AcknowledgeDeadLetterJobs)
+		apiRoutes.POST("/dead-letter/assign", api.RBACMiddleware("workflow_editor", "admin"), api.AssignDeadLetterJobs)
+		apiRoutes.POST("/dead-letter/rerun", api.RBACMiddleware("workflow_editor", "admin"), api.RerunDeadLetterJobs)
+		apiRoutes.POST("/dead-letter/resolve", api.RBACMiddleware("workflow_editor", "admin"), api.ResolveDeadLetterJobs)
 		
 		apiRoutes.GET("/stats", api.GetDashboardStats)
 		apiRoutes.GET("/settings", api.GetSettings)
@@ -141,6 +226,9 @@ func main() {
 			adminRoutes.DELETE("/tenants/:id", api.DeleteTenant)
 			adminRoutes.POST("/tenants/:id/bootstrap", api.BootstrapTenant)
 			adminRoutes.GET("/stats", api.GetAggregateStats)
+			adminRoutes.GET("/log-levels", api.GetLogLevels)
+			adminRoutes.PUT("/log-levels", api.UpdateLogLevel)
+			adminRoutes.GET("/config", api.GetEffectiveConfig)
 		}
 	}
 
@@ -186,12 +274,83 @@ func main() {
 
 	log.Println("Starting Integration & Remediation Engine...")
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// SIGINT or SIGTERM stops accepting requests and lets in-flight ones finish; the deferred
+	// cleanup then drains the event sinks and flushes traces
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: ":" + strconv.Itoa(cfg.Server.Port), Handler: r}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
+		log.Printf("Server failed to start: %v", err)
+		exitCode = 1
+	case <-ctx.Done():
+		stop()
+		log.Println("[Server] Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[Server] Graceful shutdown failed: %v", err)
+			exitCode = 1
+		}
 	}
+}
 
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Server failed to start:", err)
+// applyLogging applies the log format and levels from the configuration. On a reload, prev
+// is the configuration being replaced and only the levels that changed in it are applied,
+// so levels set through PUT /api/admin/log-levels survive reloads that do not touch them.
+// A component removed from logging.levels follows the default level again.
+func applyLogging(prev *config.LoggingConfig, l config.LoggingConfig) error {
+	if prev == nil {
+		for _, component := range logging.Components() {
+			logging.ResetLevel(component)
+		}
+		return logging.Configure(os.Stderr, l.Format, l.Level, l.LevelsString(), false)
+	}
+
+	level := l.Level
+	if level == prev.Level {
+		level = ""
+	}
+	changed := config.LoggingConfig{Levels: map[string]string{}}
+	for component, lvl := range l.Levels {
+		if prev.Levels[component] != lvl {
+			changed.Levels[component] = lvl
+		}
+	}
+	for component := range prev.Levels {
+		if _, ok := l.Levels[component]; !ok {
+			logging.ResetLevel(component)
+		}
+	}
+	return logging.Configure(os.Stderr, l.Format, level, changed.LevelsString(), false)
+}
+
+// reloadOnSIGHUP re-reads the configuration on SIGHUP and applies the settings that are safe
+// to change at runtime. An invalid file is rejected and the running configuration is kept.
+func reloadOnSIGHUP(path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	serverLog := logging.For(logging.ComponentServer)
+
+	for range hup {
+		prev := config.Current().Logging
+		applied, restart, err := config.Reload(path)
+		if err != nil {
+			serverLog.Error("Configuration reload rejected", "error", err)
+			continue
+		}
+		for _, field := range applied {
+			if strings.HasPrefix(field, "logging.") {
+				if err := applyLogging(&prev, config.Current().Logging); err != nil {
+					serverLog.Error("Failed to apply logging configuration", "error", err)
+				}
+				break
+			}
+		}
+		serverLog.Info("Configuration reloaded", "applied", applied, "restart_required", restart)
 	}
 }
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+	"remediation-engine/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEffectiveConfig returns the running configuration with secrets masked, the file it was
+// loaded from and any changed settings that only take effect after a restart
+func GetEffectiveConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, config.Effective())
+}
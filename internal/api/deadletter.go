@@ -0,0 +1,332 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"remediation-engine/internal/core"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/events"
+	"remediation-engine/internal/tenancy"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxTriageBatch bounds how many jobs a single bulk triage request may touch
+const maxTriageBatch = 500
+
+// triageRequest is the body of the dead-letter bulk operations
+type triageRequest struct {
+	JobIDs   []uint `json:"job_ids" binding:"required"`
+	Assignee string `json:"assignee"` // assign only; empty clears the assignment
+	Reason   string `json:"reason"`   // resolve only
+}
+
+// deadLetterQuery selects failed or interrupted jobs that have not been rerun,
+// scoped to the caller's tenant and narrowed by the optional query filters
+func deadLetterQuery(c *gin.Context) *gorm.DB {
+	tenantID := tenancy.ResolveTenantID(c)
+
+	query := database.DB.Model(&database.Job{}).
+		Where("jobs.status IN ? AND jobs.rerun_at IS NULL", database.DeadLetterStatuses())
+	if !(tenancy.IsMultiTenant && tenantID == 0) {
+		query = query.Where("jobs.tenant_id = ?", tenantID)
+	}
+
+	if v := c.Query("integration_id"); v != "" {
+		query = query.Where("jobs.failed_integration_id = ?", v)
+	}
+	if v, ok := c.GetQuery("action"); ok {
+		query = query.Where("jobs.failed_action = ?", v)
+	}
+	if v, ok := c.GetQuery("error_class"); ok {
+		query = query.Where("jobs.error_class = ?", v)
+	}
+	if v, ok := c.GetQuery("assigned_to"); ok {
+		query = query.Where("jobs.assigned_to = ?", v)
+	}
+	switch c.Query("acknowledged") {
+	case "true":
+		query = query.Where("jobs.acknowledged_at IS NOT NULL")
+	case "false":
+		query = query.Where("jobs.acknowledged_at IS NULL")
+	}
+	return query
+}
+
+// GetDeadLetterGroups summarises unhandled failures by integration, action and error class
+func GetDeadLetterGroups(c *gin.Context) {
+	type group struct {
+		IntegrationID   uint      `json:"integration_id"`
+		IntegrationName string    `json:"integration_name"`
+		Action          string    `json:"action"`
+		ErrorClass      string    `json:"error_class"`
+		Count           int64     `json:"count"`
+		Unacknowledged  int64     `json:"unacknowledged"`
+		OldestJobID     uint      `json:"oldest_job_id"`
+		LatestJobID     uint      `json:"latest_job_id"`
+		LatestAt        time.Time `json:"latest_at"`
+	}
+
+	var groups []group
+	err := deadLetterQuery(c).
+		Select(`jobs.failed_integration_id AS integration_id, jobs.failed_action AS action, jobs.error_class AS error_class,
+			COUNT(*) AS count, SUM(CASE WHEN jobs.acknowledged_at IS NULL THEN 1 ELSE 0 END) AS unacknowledged,
+			MIN(jobs.id) AS oldest_job_id, MAX(jobs.id) AS latest_job_id`).
+		Group("jobs.failed_integration_id, jobs.failed_action, jobs.error_class").
+		Order("count desc").
+		Scan(&groups).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Resolve display names and timestamps with two small lookups instead of per-group queries
+	integrationIDs := make([]uint, 0, len(groups))
+	latestIDs := make([]uint, 0, len(groups))
+	for _, g := range groups {
+		integrationIDs = append(integrationIDs, g.IntegrationID)
+		latestIDs = append(latestIDs, g.LatestJobID)
+	}
+
+	var integrations []database.Integration
+	database.DB.Select("id", "name").Where("id IN ?", integrationIDs).Find(&integrations)
+	names := make(map[uint]string, len(integrations))
+	for _, i := range integrations {
+		names[i.ID] = i.Name
+	}
+
+	var latest []database.Job
+	database.DB.Select("id", "created_at").Where("id IN ?", latestIDs).Find(&latest)
+	latestAt := make(map[uint]time.Time, len(latest))
+	for _, j := range latest {
+		latestAt[j.ID] = j.CreatedAt
+	}
+
+	for i := range groups {
+		groups[i].IntegrationName = names[groups[i].IntegrationID]
+		groups[i].LatestAt = latestAt[groups[i].LatestJobID]
+	}
+
+	if groups == nil {
+		groups = []group{}
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": groups, "error_classes": core.ErrorClasses()})
+}
+
+// GetDeadLetterJobs lists unhandled failed jobs, optionally narrowed to a single group
+func GetDeadLetterJobs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "25"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 25
+	}
+
+	var total int64
+	deadLetterQuery(c).Count(&total)
+
+	var jobs []database.Job
+	deadLetterQuery(c).Joins("Workflow").Order("jobs.created_at desc").
+		Limit(pageSize).Offset((page - 1) * pageSize).Find(&jobs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     jobs,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// bindTriage parses a bulk request and loads the matching dead-letter jobs.
+// IDs that are not (or no longer) in the caller's dead-letter queue are returned as skipped.
+func bindTriage(c *gin.Context) (triageRequest, []database.Job, []uint, bool) {
+	var req triageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, nil, nil, false
+	}
+	if len(req.JobIDs) == 0 || len(req.JobIDs) > maxTriageBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("job_ids must contain between 1 and %d jobs", maxTriageBatch)})
+		return req, nil, nil, false
+	}
+
+	var jobs []database.Job
+	deadLetterQuery(c).Preload("Workflow").Where("jobs.id IN ?", req.JobIDs).Find(&jobs)
+
+	found := make(map[uint]bool, len(jobs))
+	for _, j := range jobs {
+		found[j.ID] = true
+	}
+	skipped := []uint{}
+	for _, id := range req.JobIDs {
+		if !found[id] {
+			skipped = append(skipped, id)
+		}
+	}
+	return req, jobs, skipped, true
+}
+
+func jobIDs(jobs []database.Job) []uint {
+	ids := make([]uint, len(jobs))
+	for i, j := range jobs {
+		ids[i] = j.ID
+	}
+	return ids
+}
+
+func auditTriage(c *gin.Context, operation string, jobs []database.Job, details gin.H) {
+	userID, _ := c.Get("user_id")
+	uid, _ := userID.(uint)
+
+	// One entry per tenant so tenant-scoped audit views see their own jobs
+	byTenant := make(map[uint][]uint)
+	for _, j := range jobs {
+		byTenant[j.TenantID] = append(byTenant[j.TenantID], j.ID)
+	}
+	for tenantID, ids := range byTenant {
+		entry := gin.H{"operation": operation, "job_ids": ids}
+		for k, v := range details {
+			entry[k] = v
+		}
+		LogAudit(c, uid, tenantID, "UPDATE", "JOB", "dead-letter", entry)
+	}
+}
+
+// AcknowledgeDeadLetterJobs marks failures as seen so they stand out from new ones
+func AcknowledgeDeadLetterJobs(c *gin.Context) {
+	_, jobs, skipped, ok := bindTriage(c)
+	if !ok {
+		return
+	}
+
+	var updated int64
+	if len(jobs) > 0 {
+		updated = database.DB.Model(&database.Job{}).
+			Where("id IN ? AND acknowledged_at IS NULL", jobIDs(jobs)).
+			UpdateColumns(map[string]interface{}{"acknowledged_at": time.Now(), "acknowledged_by": auditActor(c)}).RowsAffected
+		auditTriage(c, "acknowledge", jobs, nil)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated, "skipped": skipped})
+}
+
+// AssignDeadLetterJobs sets (or clears) the owner of a set of failures
+func AssignDeadLetterJobs(c *gin.Context) {
+	req, jobs, skipped, ok := bindTriage(c)
+	if !ok {
+		return
+	}
+
+	var updated int64
+	if len(jobs) > 0 {
+		updated = database.DB.Model(&database.Job{}).Where("id IN ?", jobIDs(jobs)).
+			UpdateColumn("assigned_to", req.Assignee).RowsAffected
+		auditTriage(c, "assign", jobs, gin.H{"assignee": req.Assignee})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated, "skipped": skipped})
+}
+
+// ResolveDeadLetterJobs closes failures that an operator decided not to retry
+func ResolveDeadLetterJobs(c *gin.Context) {
+	req, jobs, skipped, ok := bindTriage(c)
+	if !ok {
+		return
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "resolved manually from the dead-letter queue"
+	}
+
+	actor := auditActor(c)
+	var resolved []database.Job
+	for i := range jobs {
+		from := jobs[i].Status
+		if err := database.TransitionJob(database.DB, &jobs[i], database.JobStatusResolvedManually, actor, reason); err != nil {
+			if !errors.Is(err, database.ErrStaleJobState) && !errors.Is(err, database.ErrInvalidJobTransition) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			skipped = append(skipped, jobs[i].ID)
+			continue
+		}
+		events.Emit(events.JobStatusChanged(&jobs[i], from, actor, reason))
+		resolved = append(resolved, jobs[i])
+	}
+	if len(resolved) > 0 {
+		auditTriage(c, "resolve", resolved, gin.H{"reason": reason})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": len(resolved), "skipped": skipped})
+}
+
+// RerunDeadLetterJobs retries a set of failures as a tracked batch, so at most
+// core.DefaultRerunConcurrency reruns are in flight. Each job leaves the queue once it is claimed.
+func RerunDeadLetterJobs(c *gin.Context) {
+	if core.GlobalEngine == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "engine not initialized"})
+		return
+	}
+
+	_, jobs, skipped, ok := bindTriage(c)
+	if !ok {
+		return
+	}
+
+	var rerun []database.Job
+	var ids []uint
+	for _, job := range jobs {
+		// Claim the job first so concurrent bulk reruns cannot start it twice
+		claimed := database.DB.Model(&database.Job{}).Where("id = ? AND rerun_at IS NULL", job.ID).
+			UpdateColumn("rerun_at", time.Now()).RowsAffected
+		if claimed == 0 {
+			skipped = append(skipped, job.ID)
+			continue
+		}
+		rerun = append(rerun, job)
+		ids = append(ids, job.ID)
+	}
+	if len(rerun) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "skipped": skipped})
+		return
+	}
+
+	actor := auditActor(c)
+	filterJSON, _ := json.Marshal(gin.H{"dead_letter_job_ids": ids})
+	batch := database.RerunBatch{
+		TenantID:    tenancy.ResolveTenantID(c),
+		RequestedBy: actor,
+		Filter:      string(filterJSON),
+		Concurrency: core.DefaultRerunConcurrency,
+		Status:      database.RerunBatchRunning,
+		Total:       len(ids),
+	}
+	if err := database.DB.Create(&batch).Error; err != nil {
+		// Put the claimed jobs back in the queue
+		database.DB.Model(&database.Job{}).Where("id IN ?", ids).UpdateColumn("rerun_at", nil)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	auditTriage(c, "rerun", rerun, gin.H{"batch_id": batch.ID})
+
+	go core.GlobalEngine.RunRerunBatch(batch, ids, actor)
+
+	c.JSON(http.StatusOK, gin.H{"updated": len(rerun), "skipped": skipped, "batch_id": batch.ID})
+}
+
+// rerunContext rebuilds the trigger context of a previous job for a manual rerun by the caller.
+// The rerun outlives the request, so it keeps the request's log fields but not its cancellation.
+func rerunContext(c *gin.Context, oldJob database.Job) map[string]interface{} {
+	ctx := core.RerunContext(oldJob, auditActor(c))
+	ctx["_ctx"] = context.WithoutCancel(c.Request.Context())
+	return ctx
+}
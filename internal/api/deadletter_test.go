@@ -0,0 +1,225 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"remediation-engine/internal/core"
+	"remediation-engine/internal/database"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedDeadLetter(t *testing.T) (database.Integration, []database.Job) {
+	integ := database.Integration{Name: "Okta", TenantID: 1, IsAvailable: true}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	wf := database.Workflow{Name: "Suspend User", Enabled: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&wf).Error)
+
+	seed := []struct {
+		status, action, class string
+	}{
+		{database.JobStatusFailed, "Suspend Okta User", core.ErrorClassAuth},
+		{database.JobStatusFailed, "Suspend Okta User", core.ErrorClassAuth},
+		{database.JobStatusInterrupted, "Suspend Okta User", core.ErrorClassServerError},
+		{database.JobStatusSucceeded, "Suspend Okta User", ""},
+	}
+	var jobs []database.Job
+	for i, s := range seed {
+		job := database.Job{
+			TenantID: 1, WorkflowID: wf.ID, AuthMindIssueID: fmt.Sprintf("dl-%d", i), Status: s.status,
+			FailedIntegrationID: integ.ID, FailedAction: s.action, ErrorClass: s.class,
+			TriggerContext: fmt.Sprintf(`{"IssueID":"dl-%d"}`, i),
+		}
+		require.NoError(t, database.DB.Create(&job).Error)
+		jobs = append(jobs, job)
+	}
+	return integ, jobs
+}
+
+func triage(router *gin.Engine, op string, body gin.H) (*httptest.ResponseRecorder, map[string]interface{}) {
+	payload, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/dead-letter/"+op, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+func TestDeadLetter_GroupsUnhandledFailures(t *testing.T) {
+	router := setupRouter()
+	integ, _ := seedDeadLetter(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/dead-letter", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Groups []struct {
+			IntegrationID   uint   `json:"integration_id"`
+			IntegrationName string `json:"integration_name"`
+			Action          string `json:"action"`
+			ErrorClass      string `json:"error_class"`
+			Count           int64  `json:"count"`
+			Unacknowledged  int64  `json:"unacknowledged"`
+		} `json:"groups"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Groups, 2)
+	assert.Equal(t, integ.ID, resp.Groups[0].IntegrationID)
+	assert.Equal(t, "Okta", resp.Groups[0].IntegrationName)
+	assert.Equal(t, core.ErrorClassAuth, resp.Groups[0].ErrorClass)
+	assert.Equal(t, int64(2), resp.Groups[0].Count)
+	assert.Equal(t, int64(2), resp.Groups[0].Unacknowledged)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/dead-letter/jobs?error_class=server_error", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+}
+
+func TestDeadLetter_AcknowledgeAssignResolve(t *testing.T) {
+	router := setupRouter()
+	_, jobs := seedDeadLetter(t)
+
+	// The succeeded job is not in the queue and is reported as skipped
+	w, resp := triage(router, "acknowledge", gin.H{"job_ids": []uint{jobs[0].ID, jobs[3].ID}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(1), resp["updated"])
+	assert.Equal(t, []interface{}{float64(jobs[3].ID)}, resp["skipped"])
+
+	w, _ = triage(router, "assign", gin.H{"job_ids": []uint{jobs[0].ID, jobs[1].ID}, "assignee": "alice@example.com"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, resp = triage(router, "resolve", gin.H{"job_ids": []uint{jobs[1].ID}, "reason": "user already offboarded"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(1), resp["updated"])
+
+	var first, second database.Job
+	database.DB.First(&first, jobs[0].ID)
+	database.DB.First(&second, jobs[1].ID)
+	assert.NotNil(t, first.AcknowledgedAt)
+	assert.Equal(t, "api", first.AcknowledgedBy)
+	assert.Equal(t, "alice@example.com", first.AssignedTo)
+	assert.Equal(t, database.JobStatusResolvedManually, second.Status)
+
+	var event database.JobEvent
+	database.DB.Where("job_id = ?", second.ID).Last(&event)
+	assert.Equal(t, "user already offboarded", event.Reason)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/dead-letter/jobs?acknowledged=true", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+
+	var audits int64
+	database.DB.Model(&database.AuditLog{}).Where("target_id = ?", "dead-letter").Count(&audits)
+	assert.Equal(t, int64(3), audits)
+}
+
+func TestDeadLetter_BulkRerun(t *testing.T) {
+	router := setupRouter()
+	_, jobs := seedDeadLetter(t)
+
+	w, resp := triage(router, "rerun", gin.H{"job_ids": []uint{jobs[0].ID, jobs[1].ID}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(2), resp["updated"])
+
+	// A second request finds nothing left to rerun
+	_, resp = triage(router, "rerun", gin.H{"job_ids": []uint{jobs[0].ID}})
+	assert.Equal(t, float64(0), resp["updated"])
+
+	assert.Eventually(t, func() bool {
+		var reruns int64
+		database.DB.Model(&database.Job{}).Where("auth_mind_issue_id LIKE ?", "%-rerun-%").Count(&reruns)
+		return reruns == 2
+	}, 2*time.Second, 20*time.Millisecond)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/dead-letter/jobs", nil)
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+
+	w, _ = triage(router, "rerun", gin.H{"job_ids": []uint{}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// slowExecutor holds each call briefly and records the most calls seen in flight at once
+type slowExecutor struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (e *slowExecutor) Execute(integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, int, error) {
+	e.mu.Lock()
+	e.inFlight++
+	if e.inFlight > e.peak {
+		e.peak = e.inFlight
+	}
+	e.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	e.mu.Lock()
+	e.inFlight--
+	e.mu.Unlock()
+	return []byte("ok"), 200, nil
+}
+
+func TestDeadLetter_RerunIsBoundedBatch(t *testing.T) {
+	router := setupRouter()
+	exec := &slowExecutor{}
+	core.NewExecutorFunc = func() core.Executor { return exec }
+
+	integ := database.Integration{Name: "Okta", TenantID: 1, Enabled: true, IsAvailable: true}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	action := database.ActionDefinition{Name: "Suspend Okta User", IntegrationID: integ.ID, TenantID: 1}
+	require.NoError(t, database.DB.Create(&action).Error)
+	wf := database.Workflow{Name: "Suspend User", Enabled: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&wf).Error)
+	require.NoError(t, database.DB.Create(&database.WorkflowStep{WorkflowID: wf.ID, ActionDefinitionID: action.ID, Order: 1, ParameterMapping: "{}"}).Error)
+
+	var ids []uint
+	for i := 0; i < 3*core.DefaultRerunConcurrency; i++ {
+		job := database.Job{
+			TenantID: 1, WorkflowID: wf.ID, AuthMindIssueID: fmt.Sprintf("dl-%d", i), Status: database.JobStatusFailed,
+			FailedIntegrationID: integ.ID, FailedAction: action.Name, ErrorClass: core.ErrorClassServerError,
+			TriggerContext: fmt.Sprintf(`{"IssueID":"dl-%d"}`, i),
+		}
+		require.NoError(t, database.DB.Create(&job).Error)
+		ids = append(ids, job.ID)
+	}
+
+	w, resp := triage(router, "rerun", gin.H{"job_ids": ids})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, float64(len(ids)), resp["updated"])
+	require.NotNil(t, resp["batch_id"])
+
+	var batch database.RerunBatch
+	require.NoError(t, database.DB.First(&batch, uint(resp["batch_id"].(float64))).Error)
+	assert.Equal(t, len(ids), batch.Total)
+	assert.Equal(t, core.DefaultRerunConcurrency, batch.Concurrency)
+
+	assert.Eventually(t, func() bool {
+		database.DB.First(&batch, batch.ID)
+		return batch.Status == database.RerunBatchCompleted
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, len(ids), batch.Succeeded)
+
+	exec.mu.Lock()
+	defer exec.mu.Unlock()
+	assert.Greater(t, exec.peak, 1)
+	assert.LessOrEqual(t, exec.peak, core.DefaultRerunConcurrency)
+}
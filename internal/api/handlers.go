@@ -1,13 +1,15 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"remediation-engine/internal/database"
+	"remediation-engine/internal/events"
     "remediation-engine/internal/core"
     "remediation-engine/internal/tenancy"
     "strconv"
+    "strings"
     "time"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +34,10 @@ func GetIntegrations(c *gin.Context) {
         if integrations[i].Credentials != "" {
             integrations[i].Credentials = "******"
         }
+        if integrations[i].ClientKey != "" {
+            integrations[i].ClientKey = "******"
+        }
+        integrations[i].ProxyURL = maskProxyURL(integrations[i].ProxyURL)
         integrations[i].OAuthToken = ""
     }
 
@@ -46,6 +52,11 @@ func CreateIntegration(c *gin.Context) {
 		return
 	}
 
+    if err := core.ValidateTransportSettings(input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
     input.TenantID = tenancy.ResolveTenantID(c)
     input.Enabled = false // Disabled by default for security
 
@@ -85,6 +96,11 @@ func CreateActionDefinition(c *gin.Context) {
 
     input.TenantID = tenancy.ResolveTenantID(c)
 
+	if err := lintActionTemplate(database.DB, &input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := database.DB.Create(&input).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -109,6 +125,11 @@ func ImportConfiguration(c *gin.Context) {
 
 	for _, it := range input.Integrations {
         it.TenantID = tenantID
+		if err := core.ValidateTransportSettings(it); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("integration %s: %v", it.Name, err)})
+			return
+		}
 		if err := tx.Create(&it).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create integration %s: %v", it.Name, err)})
@@ -116,8 +137,17 @@ func ImportConfiguration(c *gin.Context) {
 		}
 	}
 
+	var warnings []string
 	for _, ac := range input.Actions {
         ac.TenantID = tenantID
+		if err := lintActionTemplate(tx, &ac); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("action %s: %v", ac.Name, err)})
+			return
+		}
+		for _, w := range ac.Warnings {
+			warnings = append(warnings, fmt.Sprintf("action %s: %s", ac.Name, w))
+		}
 		if err := tx.Create(&ac).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to create action %s: %v", ac.Name, err)})
@@ -126,7 +156,59 @@ func ImportConfiguration(c *gin.Context) {
 	}
 
 	tx.Commit()
-	c.JSON(http.StatusOK, gin.H{"status": "success", "message": "Imported configuration successfully"})
+	resp := gin.H{"status": "success", "message": "Imported configuration successfully"}
+	if len(warnings) > 0 {
+		resp["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// lintActionTemplate checks the body template of an action for its output format (PowerShell
+// script, shell command, email or request body) and records any lint warnings on the definition.
+// Templates that cannot be auto-escaped are rejected, as are invalid headers and query
+// parameters of HTTP actions.
+func lintActionTemplate(db *gorm.DB, def *database.ActionDefinition) error {
+	var warnings []string
+	var err error
+	switch integrationType := actionIntegrationType(db, def); {
+	case strings.EqualFold(def.Method, "POWERSHELL") || integrationType == "WINRM":
+		if warnings, err = core.LintPowerShellTemplate(def.BodyTemplate); err != nil {
+			return fmt.Errorf("invalid PowerShell template: %v", err)
+		}
+	case integrationType == "SSH":
+		if warnings, err = core.LintShellTemplate(def.BodyTemplate); err != nil {
+			return fmt.Errorf("invalid shell command template: %v", err)
+		}
+	case integrationType == "LDAP":
+		if warnings, err = core.LintLDAPTemplate(def.BodyTemplate); err != nil {
+			return fmt.Errorf("invalid LDAP request template: %v", err)
+		}
+	case integrationType == "EMAIL":
+		if warnings, err = core.LintEmailTemplate(def.BodyTemplate); err != nil {
+			return fmt.Errorf("invalid email template: %v", err)
+		}
+	default:
+		if warnings, err = core.LintBodyTemplate(def.ContentType, def.BodyTemplate); err != nil {
+			return fmt.Errorf("invalid body template: %v", err)
+		}
+		if err = core.LintRequestTemplates(def.Headers, def.QueryParams); err != nil {
+			return fmt.Errorf("invalid request template: %v", err)
+		}
+	}
+	def.Warnings = warnings
+	return nil
+}
+
+// actionIntegrationType returns the upper-cased type of the action's integration, if any
+func actionIntegrationType(db *gorm.DB, def *database.ActionDefinition) string {
+	if def.IntegrationID == 0 {
+		return ""
+	}
+	var integration database.Integration
+	if err := db.Select("type").First(&integration, def.IntegrationID).Error; err != nil {
+		return ""
+	}
+	return strings.ToUpper(integration.Type)
 }
 
 // GetActionDefinitions returns all action templates
@@ -186,6 +268,11 @@ func UpdateActionDefinition(c *gin.Context) {
         input.TenantID = tenantID
     }
 
+	if err := lintActionTemplate(database.DB, &input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	database.DB.Save(&input)
 	c.JSON(http.StatusOK, input)
 }
@@ -216,6 +303,22 @@ func UpdateIntegration(c *gin.Context) {
     if input.Credentials == "******" {
         input.Credentials = existing.Credentials 
     }
+    if input.ClientKey == "******" {
+        input.ClientKey = existing.ClientKey
+    }
+    if input.ProxyURL != "" && input.ProxyURL == maskProxyURL(existing.ProxyURL) {
+        input.ProxyURL = existing.ProxyURL
+    }
+    // Keep the issued OAuth2 tokens unless the client they belong to changed; a rotated
+    // refresh token cannot be recovered from the credentials
+    if input.Credentials == existing.Credentials && input.TokenEndpoint == existing.TokenEndpoint {
+        input.OAuthToken, input.OAuthExpiresAt, input.OAuthRefreshToken = existing.OAuthToken, existing.OAuthExpiresAt, existing.OAuthRefreshToken
+    }
+
+    if err := core.ValidateTransportSettings(input); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
 
     // Preserve existing TenantID if updating via global view
     if tenantID == 0 {
@@ -225,10 +328,19 @@ func UpdateIntegration(c *gin.Context) {
     }
 
     // Protect circuit breaker state from being overwritten by UI updates
-	database.DB.Model(&input).Omit("is_available", "consecutive_failures").Save(&input)
+	database.DB.Model(&input).Omit("is_available", "consecutive_failures", "circuit_state", "circuit_changed_at", "effective_rate_limit", "throttled_until").Save(&input)
 	c.JSON(http.StatusOK, input)
 }
 
+// maskProxyURL hides the password of a proxy URL so it can be shown and sent back unchanged
+func maskProxyURL(raw string) string {
+    u, err := url.Parse(raw)
+    if err != nil {
+        return raw
+    }
+    return u.Redacted()
+}
+
 // ResetIntegrationCircuitBreaker manually resets the circuit breaker state
 func ResetIntegrationCircuitBreaker(c *gin.Context) {
     id := c.Param("id")
@@ -246,10 +358,17 @@ func ResetIntegrationCircuitBreaker(c *gin.Context) {
         return
     }
 
-    if err := database.DB.Model(&integration).Updates(map[string]interface{}{
-        "is_available":         true,
-        "consecutive_failures": 0,
-    }).Error; err != nil {
+    if integration.EffectiveCircuitState() != database.CircuitClosed {
+        won, err := database.SetCircuitState(database.DB, &integration, database.CircuitClosed, "manual reset by "+auditActor(c))
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset circuit breaker"})
+            return
+        }
+        if !won {
+            c.JSON(http.StatusConflict, gin.H{"error": "circuit breaker state changed concurrently, retry"})
+            return
+        }
+    } else if err := database.DB.Model(&integration).UpdateColumn("consecutive_failures", 0).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset circuit breaker"})
         return
     }
@@ -257,6 +376,43 @@ func ResetIntegrationCircuitBreaker(c *gin.Context) {
     c.JSON(http.StatusOK, gin.H{"status": "circuit breaker reset"})
 }
 
+// GetIntegrationCircuit returns the circuit breaker state and its recent transitions
+func GetIntegrationCircuit(c *gin.Context) {
+    id := c.Param("id")
+    tenantID := tenancy.ResolveTenantID(c)
+
+    var integration database.Integration
+    query := database.DB.Where("id = ?", id)
+    if tenantID != 0 {
+        query = query.Where("tenant_id = ?", tenantID)
+    }
+
+    if err := query.First(&integration).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "integration not found"})
+        return
+    }
+
+    var events []database.CircuitEvent
+    database.DB.Where("integration_id = ?", integration.ID).Order("timestamp desc, id desc").Limit(50).Find(&events)
+
+    var nextProbeAt *time.Time
+    if integration.EffectiveCircuitState() != database.CircuitClosed && integration.CircuitChangedAt != nil {
+        t := integration.CircuitChangedAt.Add(integration.CircuitCooldown())
+        nextProbeAt = &t
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "integration_id":       integration.ID,
+        "state":                integration.EffectiveCircuitState(),
+        "consecutive_failures": integration.ConsecutiveFailures,
+        "failure_threshold":    integration.CircuitThreshold(),
+        "cooldown_seconds":     int(integration.CircuitCooldown().Seconds()),
+        "changed_at":           integration.CircuitChangedAt,
+        "next_probe_at":        nextProbeAt,
+        "events":               events,
+    })
+}
+
 // GetWorkflows returns all workflows and their steps
 func GetWorkflows(c *gin.Context) {
     tenantID := tenancy.ResolveTenantID(c)
@@ -381,8 +537,11 @@ func DeleteWorkflow(c *gin.Context) {
 func GetJobs(c *gin.Context) {
     page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
     pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
-    search := c.Query("search")
-    status := c.Query("status")
+    filter, err := jobFilterFromQuery(c)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
 
     if page < 1 { page = 1 }
     if pageSize < 1 { pageSize = 10 }
@@ -392,33 +551,10 @@ func GetJobs(c *gin.Context) {
 
     var jobs []database.Job
     var total int64
-    tenantID := tenancy.ResolveTenantID(c)
-
-    // Base queries
-    countQuery := database.DB.Model(&database.Job{})
-    // Use Joins("Workflow") for dataQuery to allow sorting and filtering by workflow fields
-    // This also preloads Workflow into the Job struct
-    dataQuery := database.DB.Preload("Tenant").Joins("Workflow").Order("jobs.created_at desc").Limit(pageSize).Offset(offset)
-
-    if tenancy.IsMultiTenant && tenantID == 0 {
-        // Global view - no extra filtering
-    } else {
-        countQuery = countQuery.Where("jobs.tenant_id = ?", tenantID)
-        dataQuery = dataQuery.Where("jobs.tenant_id = ?", tenantID)
-    }
-
-    if search != "" {
-        searchTerm := "%" + search + "%"
-        filter := "jobs.authmind_issue_id LIKE ? OR Workflow.name LIKE ?"
-        // countQuery needs the Join to filter by Workflow.name
-        countQuery = countQuery.Joins("Workflow").Where(filter, searchTerm, searchTerm)
-        dataQuery = dataQuery.Where(filter, searchTerm, searchTerm)
-    }
 
-    if status != "" {
-        countQuery = countQuery.Where("jobs.status = ?", status)
-        dataQuery = dataQuery.Where("jobs.status = ?", status)
-    }
+    // Same filter for the count and the page; Joins("Workflow") preloads Workflow into the Job struct
+    countQuery := filter.apply(c, database.DB.Model(&database.Job{}))
+    dataQuery := filter.apply(c, database.DB.Preload("Tenant").Joins("Workflow").Order("jobs.created_at desc").Limit(pageSize).Offset(offset))
 
     countQuery.Count(&total)
     dataQuery.Find(&jobs)
@@ -453,6 +589,78 @@ func GetJobLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, logs)
 }
 
+// GetJobEvents returns the lifecycle history of a job and the time spent in each state
+func GetJobEvents(c *gin.Context) {
+	id := c.Param("id")
+    tenantID := tenancy.ResolveTenantID(c)
+
+    var job database.Job
+    query := database.DB.Where("id = ?", id)
+    if tenantID != 0 {
+        query = query.Where("tenant_id = ?", tenantID)
+    }
+
+    if err := query.First(&job).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+        return
+    }
+
+	var events []database.JobEvent
+	database.DB.Where("job_id = ?", job.ID).Order("timestamp asc, id asc").Find(&events)
+
+    durations := make(map[string]float64)
+    for state, d := range database.JobStateDurations(events, time.Now()) {
+        durations[state] = d.Seconds()
+    }
+
+	c.JSON(http.StatusOK, gin.H{
+        "job_id":           job.ID,
+        "status":           job.Status,
+        "events":           events,
+        "duration_seconds": durations,
+    })
+}
+
+// CancelJob stops a job that has not reached a terminal state
+func CancelJob(c *gin.Context) {
+	id := c.Param("id")
+    tenantID := tenancy.ResolveTenantID(c)
+
+    var job database.Job
+    query := database.DB.Preload("Workflow").Where("id = ?", id)
+    if tenantID != 0 {
+        query = query.Where("tenant_id = ?", tenantID)
+    }
+
+    if err := query.First(&job).Error; err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+        return
+    }
+
+    from, actor := job.Status, auditActor(c)
+    if err := database.TransitionJob(database.DB, &job, database.JobStatusCancelled, actor, "cancelled via API"); err != nil {
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+        return
+    }
+    events.Emit(events.JobStatusChanged(&job, from, actor, "cancelled via API"))
+
+    userID, _ := c.Get("user_id")
+    uid, _ := userID.(uint)
+    LogAudit(c, uid, job.TenantID, "UPDATE", "JOB", fmt.Sprintf("%d", job.ID), gin.H{"status": job.Status})
+
+	c.JSON(http.StatusOK, gin.H{"status": job.Status})
+}
+
+// auditActor identifies the caller for job history entries
+func auditActor(c *gin.Context) string {
+    if userID, ok := c.Get("user_id"); ok {
+        if uid, ok := userID.(uint); ok && uid != 0 {
+            return fmt.Sprintf("user:%d", uid)
+        }
+    }
+    return "api"
+}
+
 // RerunJob triggers a manual execution of a previous job's workflow
 func RerunJob(c *gin.Context) {
 	id := c.Param("id")
@@ -469,23 +677,13 @@ func RerunJob(c *gin.Context) {
 		return
 	}
 
-	// Prepare context from old job
-	contextData := make(map[string]interface{})
-	if oldJob.TriggerContext != "" {
-		json.Unmarshal([]byte(oldJob.TriggerContext), &contextData)
-	} else {
-		// Fallback for older jobs without stored context
-		contextData["IssueID"] = oldJob.AuthMindIssueID
-		contextData["UserEmail"] = "rerun-task@example.com"
-	}
-	
-	contextData["Timestamp"] = time.Now().Format(time.RFC3339)
-	contextData["ManualRerun"] = true
-    contextData["TenantID"] = oldJob.TenantID // Use the original tenant ID from the job
+	contextData := rerunContext(c, oldJob)
 
 	// To get the real UserEmail, we try to find it in the logs or state
 	// For now, we use the engine's exported RunWorkflow
 	if core.GlobalEngine != nil {
+		// A rerun takes the job out of the dead-letter queue
+		database.DB.Model(&database.Job{}).Where("id = ? AND rerun_at IS NULL", oldJob.ID).UpdateColumn("rerun_at", time.Now())
 		go core.GlobalEngine.RunWorkflow(oldJob.Workflow, contextData)
 		c.JSON(http.StatusOK, gin.H{"status": "rerun triggered"})
 	} else {
@@ -493,6 +691,12 @@ func RerunJob(c *gin.Context) {
 	}
 }
 
+// Status groups used by the dashboard counters
+var (
+    successStatuses    = []string{database.JobStatusSucceeded, database.JobStatusSucceededWithWarnings}
+    inProgressStatuses = []string{database.JobStatusRunning}
+)
+
 // GetDashboardStats calculates metrics for the UI
 func GetDashboardStats(c *gin.Context) {
 	var stats struct {
@@ -509,9 +713,9 @@ func GetDashboardStats(c *gin.Context) {
     tenantID := tenancy.ResolveTenantID(c)
 
 	database.DB.Model(&database.Job{}).Where("tenant_id = ?", tenantID).Count(&stats.TotalJobs)
-	database.DB.Model(&database.Job{}).Where("tenant_id = ? AND status = ?", tenantID, "completed").Count(&stats.SuccessJobs)
-	database.DB.Model(&database.Job{}).Where("tenant_id = ? AND status = ?", tenantID, "failed").Count(&stats.FailedJobs)
-	database.DB.Model(&database.Job{}).Where("tenant_id = ? AND status = ?", tenantID, "running").Count(&stats.RunningJobs)
+	database.DB.Model(&database.Job{}).Where("tenant_id = ? AND status IN ?", tenantID, successStatuses).Count(&stats.SuccessJobs)
+	database.DB.Model(&database.Job{}).Where("tenant_id = ? AND status = ?", tenantID, database.JobStatusFailed).Count(&stats.FailedJobs)
+	database.DB.Model(&database.Job{}).Where("tenant_id = ? AND status IN ?", tenantID, inProgressStatuses).Count(&stats.RunningJobs)
 	database.DB.Model(&database.Workflow{}).Where("tenant_id = ? AND enabled = ?", tenantID, true).Count(&stats.ActiveWorkflows)
     database.DB.Model(&database.ProcessedEvent{}).Where("tenant_id = ?", tenantID).Count(&stats.ProcessedEvents)
 
@@ -789,9 +993,9 @@ func GetAggregateStats(c *gin.Context) {
 	}
 
 	database.DB.Model(&database.Job{}).Count(&stats.TotalJobs)
-	database.DB.Model(&database.Job{}).Where("status = ?", "completed").Count(&stats.SuccessJobs)
-	database.DB.Model(&database.Job{}).Where("status = ?", "failed").Count(&stats.FailedJobs)
-	database.DB.Model(&database.Job{}).Where("status = ?", "running").Count(&stats.RunningJobs)
+	database.DB.Model(&database.Job{}).Where("status IN ?", successStatuses).Count(&stats.SuccessJobs)
+	database.DB.Model(&database.Job{}).Where("status = ?", database.JobStatusFailed).Count(&stats.FailedJobs)
+	database.DB.Model(&database.Job{}).Where("status IN ?", inProgressStatuses).Count(&stats.RunningJobs)
 	database.DB.Model(&database.Tenant{}).Count(&stats.TotalTenants)
 	database.DB.Model(&database.Workflow{}).Where("enabled = ?", true).Count(&stats.ActiveWorkflows)
     database.DB.Model(&database.ProcessedEvent{}).Count(&stats.ProcessedEvents)
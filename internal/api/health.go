@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"remediation-engine/internal/core"
+	"remediation-engine/internal/database"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dbPingTimeout bounds the database check so a hung connection fails readiness instead of blocking it
+const dbPingTimeout = 2 * time.Second
+
+// Healthz is the liveness probe: the process is serving requests and the engine has started
+func Healthz(c *gin.Context) {
+	if core.GlobalEngine == nil || !core.GlobalEngine.Alive() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "starting"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz is the readiness probe. It returns 503 with the failing checks when the
+// database, the engine loop, the workers or the task queue is unhealthy. Failing advisory
+// checks, such as a stale poller of one tenant, report "degraded" with 200.
+func Readyz(c *gin.Context) {
+	checks := []core.ReadinessCheck{databaseCheck(c.Request.Context())}
+	if core.GlobalEngine == nil {
+		checks = append(checks, core.ReadinessCheck{Name: "engine_loop", Reason: "engine not initialized"})
+	} else {
+		checks = append(checks, core.GlobalEngine.ReadinessChecks(time.Now())...)
+	}
+
+	status, code := readiness(checks)
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+// readiness summarizes the checks into the /readyz status and HTTP code
+func readiness(checks []core.ReadinessCheck) (string, int) {
+	status, code := "ready", http.StatusOK
+	for _, check := range checks {
+		switch {
+		case check.OK:
+		case !check.Advisory:
+			return "not_ready", http.StatusServiceUnavailable
+		default:
+			status = "degraded"
+		}
+	}
+	return status, code
+}
+
+func databaseCheck(ctx context.Context) core.ReadinessCheck {
+	check := core.ReadinessCheck{Name: "database"}
+	if database.DB == nil {
+		check.Reason = "database not initialized"
+		return check
+	}
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		check.Reason = err.Error()
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dbPingTimeout)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		check.Reason = "ping failed: " + err.Error()
+		return check
+	}
+	check.OK = true
+	return check
+}
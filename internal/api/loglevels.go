@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"remediation-engine/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLogLevels returns the default log level, per-component overrides and known components
+func GetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"levels": logging.Levels(), "components": logging.Components()})
+}
+
+// UpdateLogLevel changes a component's log level at runtime. An empty level removes the
+// override so the component follows the default level again.
+func UpdateLogLevel(c *gin.Context) {
+	var req struct {
+		Component string `json:"component"` // "" or "default" for the default level
+		Level     string `json:"level"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !knownLogComponent(req.Component) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown component " + req.Component, "components": logging.Components()})
+		return
+	}
+
+	if req.Level == "" && req.Component != "" && req.Component != "default" {
+		logging.ResetLevel(req.Component)
+	} else if err := logging.SetLevel(req.Component, req.Level); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := userID.(uint)
+	LogAudit(c, uid, 0, "UPDATE", "LOG_LEVEL", req.Component, gin.H{"level": req.Level})
+
+	c.JSON(http.StatusOK, gin.H{"levels": logging.Levels()})
+}
+
+func knownLogComponent(component string) bool {
+	if component == "" || component == "default" {
+		return true
+	}
+	for _, known := range logging.Components() {
+		if component == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"remediation-engine/internal/logging"
+	"remediation-engine/internal/metrics"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateLogLevel(t *testing.T) {
+	router := setupRouter()
+	defer logging.ResetLevel(logging.ComponentExecutor)
+
+	put := func(body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/admin/log-levels", bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := put(map[string]string{"component": "executor", "level": "debug"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "debug", logging.Levels()["executor"])
+
+	assert.Equal(t, http.StatusBadRequest, put(map[string]string{"component": "executor", "level": "chatty"}).Code)
+	assert.Equal(t, http.StatusBadRequest, put(map[string]string{"component": "nope", "level": "info"}).Code)
+
+	// An empty level drops the override
+	require.Equal(t, http.StatusOK, put(map[string]string{"component": "executor"}).Code)
+	assert.NotContains(t, logging.Levels(), "executor")
+}
+
+func TestRequestID_EchoedAndGenerated(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/log-levels", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Header().Get("X-Request-ID"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/admin/log-levels", nil)
+	router.ServeHTTP(w, req)
+	assert.Len(t, w.Header().Get("X-Request-ID"), 16)
+}
+
+func TestGetEffectiveConfig(t *testing.T) {
+	router := setupRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/config", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Config struct {
+			Engine struct {
+				Workers int `json:"workers"`
+			} `json:"engine"`
+		} `json:"config"`
+		Secrets map[string]string `json:"secrets"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 20, resp.Config.Engine.Workers)
+	assert.Equal(t, "******", resp.Secrets["ENCRYPTION_KEY"])
+	assert.NotContains(t, w.Body.String(), os.Getenv("ENCRYPTION_KEY"))
+}
+
+func TestMetricsMiddleware_TenantLabel(t *testing.T) {
+	setupRouter()
+	t.Setenv("ADMIN_API_KEY", "admin-key")
+
+	r := gin.New()
+	r.Use(MetricsMiddleware())
+	r.GET("/open", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/secure", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/tenant/:id", func(c *gin.Context) {
+		// Stands in for auth resolving a tenant from the request
+		id, _ := strconv.Atoi(c.Param("id"))
+		c.Set("tenant_id", uint(id))
+		c.Status(http.StatusOK)
+	})
+
+	count := func(tenant, route, status string) float64 {
+		var m dto.Metric
+		require.NoError(t, metrics.APIRequestDuration.WithLabelValues(tenant, "GET", route, status).(prometheus.Histogram).Write(&m))
+		return float64(m.GetHistogram().GetSampleCount())
+	}
+	get := func(path string, header ...string) {
+		req, _ := http.NewRequest("GET", path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Unauthenticated requests cannot choose a tenant label
+	before := count(metrics.NoTenantLabel, "/secure", "401")
+	get("/secure", "X-Tenant-ID", "987654")
+	assert.Equal(t, before+1, count(metrics.NoTenantLabel, "/secure", "401"))
+	before = count(metrics.NoTenantLabel, "/open", "200")
+	get("/open", "X-Tenant-ID", "987654")
+	assert.Equal(t, before+1, count(metrics.NoTenantLabel, "/open", "200"))
+
+	before = count(metrics.Tenant(1), "/secure", "200")
+	get("/secure", "X-API-Key", "admin-key")
+	assert.Equal(t, before+1, count(metrics.Tenant(1), "/secure", "200"))
+
+	// Tenants that do not exist share the fixed label
+	before = count(metrics.NoTenantLabel, "/tenant/:id", "200")
+	get("/tenant/987654")
+	assert.Equal(t, before+1, count(metrics.NoTenantLabel, "/tenant/:id", "200"))
+}
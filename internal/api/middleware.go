@@ -1,10 +1,20 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/logging"
+	"remediation-engine/internal/metrics"
+	"remediation-engine/internal/tenancy"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -24,6 +34,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			if adminKey != "" && tokenString == adminKey {
 				c.Set("user_id", uint(0)) // System/Admin user
 				c.Set("user_role", "admin")
+				setAuthTenant(c)
 				c.Next()
 				return
 			}
@@ -40,6 +51,7 @@ func AuthMiddleware() gin.HandlerFunc {
 				if claims, ok := token.Claims.(*Claims); ok {
 					c.Set("user_id", claims.UserID)
 					c.Set("user_role", claims.Role)
+					setAuthTenant(c)
 					c.Next()
 					return
 				}
@@ -50,12 +62,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		if adminKey != "" && c.GetHeader("X-API-Key") == adminKey {
 			c.Set("user_id", uint(0))
 			c.Set("user_role", "admin")
+			setAuthTenant(c)
 			c.Next()
 			return
 		}
 
 		// 3. Fallback: If no auth is required yet (dev mode)
 		if adminKey == "" {
+			setAuthTenant(c)
 			c.Next()
 			return
 		}
@@ -64,6 +78,11 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// setAuthTenant records the tenant of a request that passed authentication
+func setAuthTenant(c *gin.Context) {
+	c.Set("tenant_id", tenancy.ResolveTenantID(c))
+}
+
 // RBACMiddleware checks if the authenticated user has one of the required roles
 func RBACMiddleware(requiredRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -92,3 +111,90 @@ func RBACMiddleware(requiredRoles ...string) gin.HandlerFunc {
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access denied: insufficient permissions"})
 	}
 }
+
+// MetricsMiddleware records request latency by route template. Unmatched paths share one
+// label so scanners cannot create a series per URL. The middleware runs before
+// authentication, so the tenant label is only taken from authenticated requests for a
+// tenant that exists; other requests share metrics.NoTenantLabel.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.APIRequestDuration.WithLabelValues(
+			metricsTenant(c),
+			c.Request.Method,
+			route,
+			strconv.Itoa(c.Writer.Status()),
+		).Observe(time.Since(start).Seconds())
+	}
+}
+
+// knownTenants caches the tenant IDs found by metricsTenant. Unknown IDs are not cached so
+// callers cannot grow the cache.
+var knownTenants sync.Map
+
+// metricsTenant returns the tenant label for a finished request
+func metricsTenant(c *gin.Context) string {
+	value, ok := c.Get("tenant_id")
+	if !ok {
+		return metrics.NoTenantLabel
+	}
+	id := value.(uint)
+	if _, known := knownTenants.Load(id); !known {
+		var count int64
+		if database.DB == nil || database.DB.Model(&database.Tenant{}).Where("id = ?", id).Count(&count).Error != nil || count == 0 {
+			return metrics.NoTenantLabel
+		}
+		knownTenants.Store(id, struct{}{})
+	}
+	return metrics.Tenant(id)
+}
+
+var apiLog = logging.For(logging.ComponentAPI)
+
+// RequestID tags each request with an ID (taken from X-Request-ID when the caller sends one)
+// that is echoed in the response and attached to every log record of the request
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			buf := make([]byte, 8)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		c.Set("request_id", id)
+		c.Writer.Header().Set("X-Request-ID", id)
+		c.Request = c.Request.WithContext(logging.WithFields(c.Request.Context(), logging.FieldRequestID, id))
+		c.Next()
+	}
+}
+
+// RequestLogger logs one structured line per request in place of gin's text logger
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		apiLog.Log(c.Request.Context(), level, "HTTP request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency", time.Since(start),
+			"client_ip", c.ClientIP(),
+			logging.FieldTenantID, tenancy.ResolveTenantID(c),
+		)
+	}
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"remediation-engine/internal/core"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/tenancy"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxRerunBatch bounds how many jobs a single bulk rerun may select
+const maxRerunBatch = 1000
+
+// jobFilter selects jobs for GetJobs and bulk reruns
+type jobFilter struct {
+	Status     string     `json:"status,omitempty"`
+	Search     string     `json:"search,omitempty"` // Issue ID or workflow name
+	WorkflowID uint       `json:"workflow_id,omitempty"`
+	From       *time.Time `json:"from,omitempty"` // Created at or after
+	To         *time.Time `json:"to,omitempty"`   // Created before
+}
+
+// jobFilterFromQuery reads the filter from GetJobs-style query parameters
+func jobFilterFromQuery(c *gin.Context) (jobFilter, error) {
+	f := jobFilter{Status: c.Query("status"), Search: c.Query("search")}
+	if v := c.Query("workflow_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid workflow_id: %v", v)
+		}
+		f.WorkflowID = uint(id)
+	}
+
+	var err error
+	if f.From, err = parseFilterTime(c.Query("from")); err != nil {
+		return f, fmt.Errorf("invalid from: %v", err)
+	}
+	if f.To, err = parseFilterTime(c.Query("to")); err != nil {
+		return f, fmt.Errorf("invalid to: %v", err)
+	}
+	return f, nil
+}
+
+// parseFilterTime accepts RFC 3339 timestamps and plain dates (YYYY-MM-DD)
+func parseFilterTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("%q is not an RFC 3339 timestamp or YYYY-MM-DD date", v)
+}
+
+func (f jobFilter) isEmpty() bool {
+	return f.Status == "" && f.Search == "" && f.WorkflowID == 0 && f.From == nil && f.To == nil
+}
+
+// apply narrows a jobs query to the caller's tenant and the filter
+func (f jobFilter) apply(c *gin.Context, query *gorm.DB) *gorm.DB {
+	tenantID := tenancy.ResolveTenantID(c)
+	if tenancy.IsMultiTenant && tenantID == 0 {
+		// Global view - no extra filtering
+	} else {
+		query = query.Where("jobs.tenant_id = ?", tenantID)
+	}
+
+	if f.Search != "" {
+		searchTerm := "%" + f.Search + "%"
+		query = query.Where("jobs.auth_mind_issue_id LIKE ? OR jobs.workflow_id IN (SELECT id FROM workflows WHERE name LIKE ? AND deleted_at IS NULL)", searchTerm, searchTerm)
+	}
+	if f.Status != "" {
+		query = query.Where("jobs.status = ?", f.Status)
+	}
+	if f.WorkflowID != 0 {
+		query = query.Where("jobs.workflow_id = ?", f.WorkflowID)
+	}
+	if f.From != nil {
+		query = query.Where("jobs.created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("jobs.created_at < ?", *f.To)
+	}
+	return query
+}
+
+// bulkRerunRequest is the body of POST /jobs/rerun
+type bulkRerunRequest struct {
+	jobFilter
+	DryRun      bool `json:"dry_run"`
+	Concurrency int  `json:"concurrency"` // Reruns in flight at once (default core.DefaultRerunConcurrency)
+}
+
+// BulkRerunJobs reruns every job matching a filter as a tracked batch.
+// With dry_run it only reports how many jobs would be rerun.
+func BulkRerunJobs(c *gin.Context) {
+	var req bulkRerunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.isEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one filter (status, search, workflow_id, from, to) is required"})
+		return
+	}
+	if req.Concurrency < 0 || req.Concurrency > core.MaxRerunConcurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("concurrency must be between 1 and %d", core.MaxRerunConcurrency)})
+		return
+	}
+
+	var ids []uint
+	req.apply(c, database.DB.Model(&database.Job{})).Order("jobs.id asc").Pluck("jobs.id", &ids)
+
+	if req.DryRun {
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "count": len(ids), "max_batch_size": maxRerunBatch})
+		return
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no jobs match the filter"})
+		return
+	}
+	if len(ids) > maxRerunBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d jobs match the filter; narrow it to at most %d", len(ids), maxRerunBatch)})
+		return
+	}
+	if core.GlobalEngine == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "engine not initialized"})
+		return
+	}
+
+	concurrency := req.Concurrency
+	if concurrency == 0 {
+		concurrency = core.DefaultRerunConcurrency
+	}
+	filterJSON, _ := json.Marshal(req.jobFilter)
+	actor := auditActor(c)
+
+	batch := database.RerunBatch{
+		TenantID:    tenancy.ResolveTenantID(c),
+		RequestedBy: actor,
+		Filter:      string(filterJSON),
+		Concurrency: concurrency,
+		Status:      database.RerunBatchRunning,
+		Total:       len(ids),
+	}
+	if err := database.DB.Create(&batch).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := userID.(uint)
+	LogAudit(c, uid, batch.TenantID, "EXECUTE", "RERUN_BATCH", fmt.Sprintf("%d", batch.ID), gin.H{"filter": req.jobFilter, "total": batch.Total, "concurrency": concurrency})
+
+	go core.GlobalEngine.RunRerunBatch(batch, ids, actor)
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// findRerunBatch loads a batch visible to the caller
+func findRerunBatch(c *gin.Context) (database.RerunBatch, bool) {
+	var batch database.RerunBatch
+	tenantID := tenancy.ResolveTenantID(c)
+
+	query := database.DB.Where("id = ?", c.Param("id"))
+	if !(tenancy.IsMultiTenant && tenantID == 0) {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	if err := query.First(&batch).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "rerun batch not found"})
+		return batch, false
+	}
+	return batch, true
+}
+
+// GetRerunBatches lists recent bulk reruns
+func GetRerunBatches(c *gin.Context) {
+	tenantID := tenancy.ResolveTenantID(c)
+
+	var batches []database.RerunBatch
+	query := database.DB.Order("created_at desc").Limit(50)
+	if !(tenancy.IsMultiTenant && tenantID == 0) {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	query.Find(&batches)
+	c.JSON(http.StatusOK, batches)
+}
+
+// GetRerunBatch returns the progress of a bulk rerun
+func GetRerunBatch(c *gin.Context) {
+	batch, ok := findRerunBatch(c)
+	if !ok {
+		return
+	}
+
+	done := batch.Succeeded + batch.Failed + batch.Skipped
+	progress := 0.0
+	if batch.Total > 0 {
+		progress = float64(done) / float64(batch.Total)
+	}
+
+	var jobs []database.Job
+	database.DB.Select("id", "status", "auth_mind_issue_id", "created_at").
+		Where("rerun_batch_id = ?", batch.ID).Order("id desc").Limit(100).Find(&jobs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"batch":    batch,
+		"progress": progress,
+		"jobs":     jobs,
+	})
+}
+
+// CancelRerunBatch stops a running batch. Reruns already in flight finish; the rest are skipped.
+func CancelRerunBatch(c *gin.Context) {
+	batch, ok := findRerunBatch(c)
+	if !ok {
+		return
+	}
+
+	result := database.DB.Model(&database.RerunBatch{}).
+		Where("id = ? AND status = ?", batch.ID, database.RerunBatchRunning).
+		UpdateColumns(map[string]interface{}{"status": database.RerunBatchCancelled, "completed_at": time.Now()})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("rerun batch is %s", batch.Status)})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	uid, _ := userID.(uint)
+	LogAudit(c, uid, batch.TenantID, "UPDATE", "RERUN_BATCH", fmt.Sprintf("%d", batch.ID), gin.H{"status": database.RerunBatchCancelled})
+
+	c.JSON(http.StatusOK, gin.H{"status": database.RerunBatchCancelled})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"remediation-engine/internal/database"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postJSON(router *gin.Engine, path string, body interface{}) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func seedRerunJobs(t *testing.T, n int) database.Workflow {
+	wf := database.Workflow{Name: "Okta Outage WF", Enabled: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&wf).Error)
+	for i := 0; i < n; i++ {
+		job := database.Job{
+			TenantID: 1, WorkflowID: wf.ID, AuthMindIssueID: fmt.Sprintf("okta-%d", i), Status: database.JobStatusFailed,
+			TriggerContext: fmt.Sprintf(`{"IssueID":"okta-%d"}`, i),
+		}
+		require.NoError(t, database.DB.Create(&job).Error)
+	}
+	require.NoError(t, database.DB.Create(&database.Job{TenantID: 1, WorkflowID: wf.ID, AuthMindIssueID: "ok", Status: database.JobStatusSucceeded}).Error)
+	return wf
+}
+
+func TestBulkRerun_DryRunAndValidation(t *testing.T) {
+	router := setupRouter()
+	wf := seedRerunJobs(t, 3)
+
+	w := postJSON(router, "/api/jobs/rerun", gin.H{"status": "failed", "workflow_id": wf.ID, "dry_run": true})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":3`)
+
+	w = postJSON(router, "/api/jobs/rerun", gin.H{"status": "failed", "from": time.Now().Add(time.Hour).Format(time.RFC3339), "dry_run": true})
+	assert.Contains(t, w.Body.String(), `"count":0`)
+
+	// An unfiltered rerun of every job is refused
+	w = postJSON(router, "/api/jobs/rerun", gin.H{"dry_run": true})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(router, "/api/jobs/rerun", gin.H{"status": "failed", "concurrency": 100})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Nothing was rerun
+	var batches int64
+	database.DB.Model(&database.RerunBatch{}).Count(&batches)
+	assert.Equal(t, int64(0), batches)
+}
+
+func TestBulkRerun_TrackedBatch(t *testing.T) {
+	router := setupRouter()
+	seedRerunJobs(t, 4)
+
+	w := postJSON(router, "/api/jobs/rerun", gin.H{"status": "failed", "search": "okta-", "concurrency": 2})
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var batch database.RerunBatch
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &batch))
+	assert.Equal(t, 4, batch.Total)
+	assert.Equal(t, 2, batch.Concurrency)
+
+	assert.Eventually(t, func() bool {
+		database.DB.First(&batch, batch.ID)
+		return batch.Status == database.RerunBatchCompleted
+	}, 3*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 4, batch.Succeeded)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/jobs/rerun-batches/%d", batch.ID), nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var progress struct {
+		Progress float64        `json:"progress"`
+		Jobs     []database.Job `json:"jobs"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &progress))
+	assert.Equal(t, 1.0, progress.Progress)
+	assert.Len(t, progress.Jobs, 4)
+
+	var audit database.AuditLog
+	require.NoError(t, database.DB.Where("resource = ?", "RERUN_BATCH").First(&audit).Error)
+	assert.Contains(t, audit.Details, `"total":4`)
+
+	// A finished batch cannot be cancelled
+	w = postJSON(router, fmt.Sprintf("/api/jobs/rerun-batches/%d/cancel", batch.ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestGetJobs_Filters(t *testing.T) {
+	router := setupRouter()
+	wf := seedRerunJobs(t, 2)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/jobs?status=failed&workflow_id=%d&search=okta-1", wf.ID), nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/jobs?from=yesterday", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,407 @@
+// Package config holds the typed server and engine configuration.
+//
+// Values are resolved in order: built-in defaults, the YAML config file, then environment
+// variables. Secrets are never read from the file; they stay in the environment and are
+// only reported (masked) by the admin endpoint.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is used when neither -config nor CONFIG_FILE is given and the file exists
+const DefaultPath = "config.yaml"
+
+// Duration is a time.Duration written as "10s" in YAML and JSON
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Config is the full configuration. Fields tagged reload:"true" are applied on SIGHUP;
+// changing any other field requires a restart.
+type Config struct {
+	Server   ServerConfig   `yaml:"server" json:"server"`
+	Database DatabaseConfig `yaml:"database" json:"database"`
+	Engine   EngineConfig   `yaml:"engine" json:"engine"`
+	Executor ExecutorConfig `yaml:"executor" json:"executor"`
+	Logging  LoggingConfig  `yaml:"logging" json:"logging"`
+	Events   EventsConfig   `yaml:"events" json:"events"`
+}
+
+type ServerConfig struct {
+	Port          int    `yaml:"port" json:"port" env:"PORT"`
+	AllowedOrigin string `yaml:"allowed_origin" json:"allowed_origin" env:"ALLOWED_ORIGIN" reload:"true"`
+}
+
+type DatabaseConfig struct {
+	Path    string `yaml:"path" json:"path" env:"DB_PATH"`
+	SeedDir string `yaml:"seed_dir" json:"seed_dir" env:"SEED_DIR"`
+}
+
+type EngineConfig struct {
+	Workers           int      `yaml:"workers" json:"workers" env:"ENGINE_WORKERS"`
+	QueueSize         int      `yaml:"queue_size" json:"queue_size" env:"ENGINE_QUEUE_SIZE"`
+	SchedulerInterval Duration `yaml:"scheduler_interval" json:"scheduler_interval" env:"ENGINE_SCHEDULER_INTERVAL" reload:"true"`
+	RetentionInterval Duration `yaml:"retention_interval" json:"retention_interval" env:"ENGINE_RETENTION_INTERVAL" reload:"true"`
+	// PollersGateReadiness makes a stale AuthMind poller fail /readyz instead of only being reported
+	PollersGateReadiness bool `yaml:"pollers_gate_readiness" json:"pollers_gate_readiness" env:"ENGINE_POLLERS_GATE_READINESS" reload:"true"`
+}
+
+type ExecutorConfig struct {
+	HTTPTimeout Duration `yaml:"http_timeout" json:"http_timeout" env:"EXECUTOR_HTTP_TIMEOUT" reload:"true"`
+}
+
+type LoggingConfig struct {
+	Format string            `yaml:"format" json:"format" env:"LOG_FORMAT" reload:"true"`
+	Level  string            `yaml:"level" json:"level" env:"LOG_LEVEL" reload:"true"`
+	Levels map[string]string `yaml:"levels" json:"levels" reload:"true"` // Per component; LOG_LEVELS overrides
+}
+
+// EventsConfig lists the SIEM destinations that receive job and step events
+type EventsConfig struct {
+	Sinks []SinkConfig `yaml:"sinks" json:"sinks"`
+}
+
+// SinkConfig is one event destination. Tenants limits it to those tenants (empty means all).
+type SinkConfig struct {
+	Name               string `yaml:"name" json:"name"`
+	Format             string `yaml:"format" json:"format"`       // cef, leef or json
+	Transport          string `yaml:"transport" json:"transport"` // udp, tcp, tls or file
+	Address            string `yaml:"address" json:"address"`     // host:port for network transports
+	Path               string `yaml:"path" json:"path"`           // file transport
+	Framing            string `yaml:"framing" json:"framing"`     // TCP/TLS: newline (default) or octet
+	Facility           string `yaml:"facility" json:"facility"`   // Syslog facility (default local0)
+	CAFile             string `yaml:"ca_file" json:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" json:"insecure_skip_verify"`
+	Tenants            []uint `yaml:"tenants" json:"tenants"`
+	Buffer             int    `yaml:"buffer" json:"buffer"` // Events held while the destination is down (default 1000)
+}
+
+// SyslogFacilities maps facility names to their codes
+var SyslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "daemon": 3, "auth": 4, "syslog": 5, "authpriv": 10,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Default returns the built-in configuration
+func Default() Config {
+	return Config{
+		Server:   ServerConfig{Port: 8080, AllowedOrigin: "http://localhost:5173"},
+		Database: DatabaseConfig{Path: "data/remediation.db", SeedDir: "data/seeds"},
+		Engine: EngineConfig{
+			Workers:           20,
+			QueueSize:         1000,
+			SchedulerInterval: Duration(10 * time.Second),
+			RetentionInterval: Duration(24 * time.Hour),
+		},
+		Executor: ExecutorConfig{HTTPTimeout: Duration(15 * time.Second)},
+		Logging:  LoggingConfig{Format: "text", Level: "info"},
+	}
+}
+
+var (
+	current  atomic.Pointer[Config]
+	loadedMu sync.Mutex
+	loaded   struct {
+		path           string
+		at             time.Time
+		pendingRestart []string
+	}
+)
+
+func init() {
+	cfg := Default()
+	current.Store(&cfg)
+}
+
+// Current returns the active configuration. Callers must not modify it.
+func Current() *Config {
+	return current.Load()
+}
+
+// Set replaces the active configuration (used at startup and in tests)
+func Set(cfg Config) {
+	current.Store(&cfg)
+}
+
+// ResolvePath picks the config file: the flag value, then CONFIG_FILE, then DefaultPath if it exists
+func ResolvePath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv("CONFIG_FILE"); env != "" {
+		return env
+	}
+	if _, err := os.Stat(DefaultPath); err == nil {
+		return DefaultPath
+	}
+	return ""
+}
+
+// Load builds and validates a configuration from defaults, the file at path ("" for none)
+// and the environment. It does not activate it.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read config file: %v", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true) // Typos in keys fail instead of being ignored
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return cfg, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("LOG_LEVELS"); v != "" {
+		levels, err := parseLevels(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Logging.Levels = levels
+	}
+	if os.Getenv("LOG_LEVEL") == "" && os.Getenv("DEBUG") == "true" {
+		cfg.Logging.Level = "debug"
+	}
+	return cfg, cfg.Validate()
+}
+
+// Activate makes cfg current and records where it came from
+func Activate(cfg Config, path string) {
+	Set(cfg)
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	loaded.path, loaded.at, loaded.pendingRestart = path, time.Now(), nil
+}
+
+// Reload re-reads the configuration and applies the reloadable fields. Changed fields that
+// need a restart keep their running values and are returned so the caller can report them.
+func Reload(path string) (applied []string, restart []string, err error) {
+	next, err := Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	running := *Current()
+	applied, restart = merge(reflect.ValueOf(&running).Elem(), reflect.ValueOf(next), "")
+	Set(running)
+
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	loaded.path, loaded.at = path, time.Now()
+	loaded.pendingRestart = restart
+	return applied, restart, nil
+}
+
+// merge copies reloadable fields from next into running and lists what changed
+func merge(running reflect.Value, next reflect.Value, prefix string) (applied []string, restart []string) {
+	t := running.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := prefix + yamlName(f)
+		rv, nv := running.Field(i), next.Field(i)
+
+		if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(Duration(0)) {
+			a, r := merge(rv, nv, name+".")
+			applied, restart = append(applied, a...), append(restart, r...)
+			continue
+		}
+		if reflect.DeepEqual(rv.Interface(), nv.Interface()) {
+			continue
+		}
+		if f.Tag.Get("reload") == "true" {
+			rv.Set(nv)
+			applied = append(applied, name)
+		} else {
+			restart = append(restart, name)
+		}
+	}
+	return applied, restart
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// applyEnv overrides fields tagged env:"NAME" when the variable is set
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			if err := applyEnv(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		key := f.Tag.Get("env")
+		raw, ok := os.LookupEnv(key)
+		if key == "" || !ok || raw == "" {
+			continue
+		}
+
+		switch {
+		case f.Type == reflect.TypeOf(Duration(0)):
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid duration %q", key, raw)
+			}
+			fv.SetInt(int64(d))
+		case f.Type.Kind() == reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid number %q", key, raw)
+			}
+			fv.SetInt(int64(n))
+		case f.Type.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid boolean %q", key, raw)
+			}
+			fv.SetBool(b)
+		case f.Type.Kind() == reflect.String:
+			fv.SetString(raw)
+		}
+	}
+	return nil
+}
+
+func parseLevels(v string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		component, level, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("LOG_LEVELS: invalid entry %q (expected component=level)", pair)
+		}
+		levels[strings.TrimSpace(component)] = strings.TrimSpace(level)
+	}
+	return levels, nil
+}
+
+// Validate checks ranges and enumerations and reports every problem at once
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535")
+	check(c.Database.Path != "", "database.path is required")
+	check(c.Engine.Workers >= 1 && c.Engine.Workers <= 500, "engine.workers must be between 1 and 500")
+	check(c.Engine.QueueSize >= 1 && c.Engine.QueueSize <= 100000, "engine.queue_size must be between 1 and 100000")
+	check(c.Engine.SchedulerInterval.Std() >= time.Second, "engine.scheduler_interval must be at least 1s")
+	check(c.Engine.RetentionInterval.Std() >= time.Minute, "engine.retention_interval must be at least 1m")
+	check(c.Executor.HTTPTimeout.Std() > 0 && c.Executor.HTTPTimeout.Std() <= 10*time.Minute, "executor.http_timeout must be between 0 and 10m")
+	check(c.Logging.Format == "text" || c.Logging.Format == "json", "logging.format must be text or json")
+	check(validLevel(c.Logging.Level), "logging.level %q is not one of debug, info, warn, error", c.Logging.Level)
+	for component, level := range c.Logging.Levels {
+		check(validLevel(level), "logging.levels.%s %q is not one of debug, info, warn, error", component, level)
+	}
+	names := make(map[string]bool)
+	for i, sink := range c.Events.Sinks {
+		field := fmt.Sprintf("events.sinks[%d]", i)
+		check(sink.Name != "" && !names[sink.Name], "%s.name is required and must be unique", field)
+		names[sink.Name] = true
+		check(sink.Format == "cef" || sink.Format == "leef" || sink.Format == "json", "%s.format must be cef, leef or json", field)
+		switch sink.Transport {
+		case "udp", "tcp", "tls":
+			check(sink.Address != "", "%s.address is required for %s", field, sink.Transport)
+		case "file":
+			check(sink.Path != "", "%s.path is required for file", field)
+		default:
+			check(false, "%s.transport must be udp, tcp, tls or file", field)
+		}
+		check(sink.Framing == "" || sink.Framing == "newline" || sink.Framing == "octet", "%s.framing must be newline or octet", field)
+		_, ok := SyslogFacilities[sink.Facility]
+		check(sink.Facility == "" || ok, "%s.facility %q is not a syslog facility such as local0", field, sink.Facility)
+		check(sink.Buffer >= 0 && sink.Buffer <= 1000000, "%s.buffer must be between 0 and 1000000", field)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func validLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+// LevelsString renders the per-component levels in LOG_LEVELS form
+func (l LoggingConfig) LevelsString() string {
+	pairs := make([]string, 0, len(l.Levels))
+	for component, level := range l.Levels {
+		pairs = append(pairs, component+"="+level)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// secretEnv lists the secrets the admin endpoint reports as set or unset
+var secretEnv = []string{"JWT_SECRET", "ENCRYPTION_KEY", "ADMIN_API_KEY", "METRICS_TOKEN"}
+
+// Effective describes the running configuration for the admin endpoint, with secrets masked
+func Effective() map[string]interface{} {
+	secrets := make(map[string]string, len(secretEnv))
+	for _, key := range secretEnv {
+		if os.Getenv(key) != "" {
+			secrets[key] = "******"
+		} else {
+			secrets[key] = ""
+		}
+	}
+
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	pending := loaded.pendingRestart
+	if pending == nil {
+		pending = []string{}
+	}
+	return map[string]interface{}{
+		"config":          Current(),
+		"secrets":         secrets,
+		"source":          loaded.path,
+		"loaded_at":       loaded.at,
+		"pending_restart": pending,
+	}
+}
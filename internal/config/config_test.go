@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+	return path
+}
+
+func TestLoad_DefaultsFileAndEnv(t *testing.T) {
+	path := writeConfig(t, `
+engine:
+  workers: 5
+  scheduler_interval: 30s
+executor:
+  http_timeout: 45s
+logging:
+  levels:
+    executor: debug
+`)
+	t.Setenv("ENGINE_WORKERS", "8")
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 8, cfg.Engine.Workers, "environment overrides the file")
+	assert.Equal(t, 30*time.Second, cfg.Engine.SchedulerInterval.Std())
+	assert.Equal(t, 45*time.Second, cfg.Executor.HTTPTimeout.Std())
+	assert.Equal(t, 1000, cfg.Engine.QueueSize, "unset values keep their defaults")
+	assert.Equal(t, "data/remediation.db", cfg.Database.Path)
+	assert.Equal(t, "debug", cfg.Logging.Levels["executor"])
+
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Equal(t, 8, cfg.Engine.Workers)
+}
+
+func TestLoad_Rejects(t *testing.T) {
+	_, err := Load(writeConfig(t, "engine:\n  wokers: 5\n"))
+	assert.Error(t, err, "unknown keys are rejected")
+
+	_, err = Load(writeConfig(t, "engine:\n  workers: 0\n  scheduler_interval: 10ms\nlogging:\n  format: xml\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "engine.workers")
+	assert.Contains(t, err.Error(), "engine.scheduler_interval")
+	assert.Contains(t, err.Error(), "logging.format")
+
+	_, err = Load(writeConfig(t, "events:\n  sinks:\n    - {name: soc, format: cef, transport: tcp}\n    - {name: soc, format: xml, transport: file, path: e.log, facility: local9}\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "events.sinks[0].address is required for tcp")
+	assert.Contains(t, err.Error(), "events.sinks[1].name")
+	assert.Contains(t, err.Error(), "events.sinks[1].format")
+	assert.Contains(t, err.Error(), "events.sinks[1].facility")
+
+	t.Setenv("EXECUTOR_HTTP_TIMEOUT", "soon")
+	_, err = Load("")
+	assert.ErrorContains(t, err, "EXECUTOR_HTTP_TIMEOUT")
+}
+
+func TestReload_AppliesOnlyReloadableFields(t *testing.T) {
+	defer Activate(Default(), "")
+	path := writeConfig(t, "engine:\n  workers: 5\n")
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	Activate(cfg, path)
+
+	require.NoError(t, os.WriteFile(path, []byte("engine:\n  workers: 10\n  scheduler_interval: 20s\n"), 0o600))
+	applied, restart, err := Reload(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"engine.scheduler_interval"}, applied)
+	assert.Equal(t, []string{"engine.workers"}, restart)
+	assert.Equal(t, 20*time.Second, Current().Engine.SchedulerInterval.Std())
+	assert.Equal(t, 5, Current().Engine.Workers, "restart-only values keep running")
+
+	// An invalid file leaves the running configuration alone
+	require.NoError(t, os.WriteFile(path, []byte("engine:\n  scheduler_interval: 0s\n"), 0o600))
+	_, _, err = Reload(path)
+	assert.Error(t, err)
+	assert.Equal(t, 20*time.Second, Current().Engine.SchedulerInterval.Std())
+}
+
+func TestEffective_MasksSecrets(t *testing.T) {
+	t.Setenv("JWT_SECRET", "super-secret-value")
+	t.Setenv("ADMIN_API_KEY", "")
+
+	out, err := json.Marshal(Effective())
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "super-secret-value")
+	assert.Contains(t, string(out), `"JWT_SECRET":"******"`)
+	assert.Contains(t, string(out), `"ADMIN_API_KEY":""`)
+	assert.Contains(t, string(out), `"scheduler_interval":"10s"`)
+}
+
+func TestLoad_ExampleFileMatchesDefaults(t *testing.T) {
+	cfg, err := Load("../../config.example.yaml")
+	require.NoError(t, err)
+	cfg.Logging.Levels = nil
+	assert.Equal(t, Default(), cfg)
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/json"
+	"remediation-engine/internal/database"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Bounds for RerunBatch.Concurrency
+const (
+	DefaultRerunConcurrency = 5
+	MaxRerunConcurrency     = 20
+)
+
+// RerunContext rebuilds the trigger context of a previous job for a manual rerun
+func RerunContext(oldJob database.Job, actor string) map[string]interface{} {
+	contextData := make(map[string]interface{})
+	if oldJob.TriggerContext != "" {
+		json.Unmarshal([]byte(oldJob.TriggerContext), &contextData)
+	} else {
+		// Fallback for older jobs without stored context
+		contextData["IssueID"] = oldJob.AuthMindIssueID
+		contextData["UserEmail"] = "rerun-task@example.com"
+	}
+	delete(contextData, "RerunBatchID")
+
+	contextData["Timestamp"] = time.Now().Format(time.RFC3339)
+	contextData["ManualRerun"] = true
+	contextData["TriggeredBy"] = actor
+	contextData["TenantID"] = oldJob.TenantID // Use the original tenant ID from the job
+	return contextData
+}
+
+// RunRerunBatch reruns the given jobs with at most batch.Concurrency in flight and
+// records progress on the batch. It blocks until the batch finishes or is cancelled.
+func (e *Engine) RunRerunBatch(batch database.RerunBatch, jobIDs []uint, actor string) {
+	concurrency := batch.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultRerunConcurrency
+	}
+	if concurrency > MaxRerunConcurrency {
+		concurrency = MaxRerunConcurrency
+	}
+
+	engineLog.Info("Rerun batch started", "batch_id", batch.ID, "jobs", len(jobIDs), "concurrency", concurrency)
+
+	ids := make(chan uint)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				e.rerunBatchItem(batch.ID, id, actor)
+			}
+		}()
+	}
+	for _, id := range jobIDs {
+		ids <- id
+	}
+	close(ids)
+	wg.Wait()
+
+	now := time.Now()
+	database.DB.Model(&database.RerunBatch{}).Where("id = ? AND status = ?", batch.ID, database.RerunBatchRunning).
+		UpdateColumns(map[string]interface{}{"status": database.RerunBatchCompleted, "completed_at": now})
+
+	var done database.RerunBatch
+	database.DB.First(&done, batch.ID)
+	engineLog.Info("Rerun batch finished", "batch_id", batch.ID, "status", done.Status, "succeeded", done.Succeeded, "failed", done.Failed, "skipped", done.Skipped)
+}
+
+func (e *Engine) rerunBatchItem(batchID uint, jobID uint, actor string) {
+	var batch database.RerunBatch
+	if err := database.DB.Select("id", "status").First(&batch, batchID).Error; err != nil || batch.Status != database.RerunBatchRunning {
+		e.countBatchOutcome(batchID, "skipped")
+		return
+	}
+
+	var oldJob database.Job
+	if err := database.DB.Preload("Workflow").Preload("Workflow.Steps.ActionDefinition").First(&oldJob, jobID).Error; err != nil {
+		e.countBatchOutcome(batchID, "skipped")
+		return
+	}
+
+	// A rerun takes the job out of the dead-letter queue
+	database.DB.Model(&database.Job{}).Where("id = ?", oldJob.ID).UpdateColumn("rerun_at", time.Now())
+
+	contextData := RerunContext(oldJob, actor)
+	contextData["RerunBatchID"] = batchID
+
+	job := e.ExecuteWorkflow(oldJob.Workflow, contextData)
+	switch {
+	case job == nil:
+		e.countBatchOutcome(batchID, "failed")
+	case job.Status == database.JobStatusSucceeded || job.Status == database.JobStatusSucceededWithWarnings:
+		e.countBatchOutcome(batchID, "succeeded")
+	default:
+		e.countBatchOutcome(batchID, "failed")
+	}
+}
+
+// countBatchOutcome increments one of the batch progress counters ("succeeded", "failed", "skipped")
+func (e *Engine) countBatchOutcome(batchID uint, column string) {
+	database.DB.Model(&database.RerunBatch{}).Where("id = ?", batchID).UpdateColumn(column, gorm.Expr(column+" + 1"))
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"remediation-engine/internal/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRerunBatch_CountsOutcomesAndHonorsCancel(t *testing.T) {
+	setupTestDB()
+
+	originalFunc := NewExecutorFunc
+	defer func() { NewExecutorFunc = originalFunc }()
+	NewExecutorFunc = func() Executor { return &MockExecutor{} }
+
+	wf := database.Workflow{Name: "Batch WF", Enabled: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&wf).Error)
+	var ids []uint
+	for _, issue := range []string{"b-1", "b-2", "b-3"} {
+		job := database.Job{TenantID: 1, WorkflowID: wf.ID, AuthMindIssueID: issue, Status: database.JobStatusFailed, TriggerContext: `{"IssueID":"` + issue + `"}`}
+		require.NoError(t, database.DB.Create(&job).Error)
+		ids = append(ids, job.ID)
+	}
+
+	engine := NewEngine()
+	batch := database.RerunBatch{TenantID: 1, Status: database.RerunBatchRunning, Total: 4, Concurrency: 2}
+	require.NoError(t, database.DB.Create(&batch).Error)
+	engine.RunRerunBatch(batch, append(ids, 9999), "user:1")
+
+	database.DB.First(&batch, batch.ID)
+	assert.Equal(t, database.RerunBatchCompleted, batch.Status)
+	assert.Equal(t, 3, batch.Succeeded)
+	assert.Equal(t, 1, batch.Skipped) // Unknown job ID
+	assert.NotNil(t, batch.CompletedAt)
+
+	var reruns []database.Job
+	database.DB.Where("rerun_batch_id = ?", batch.ID).Find(&reruns)
+	assert.Len(t, reruns, 3)
+
+	var source database.Job
+	database.DB.First(&source, ids[0])
+	assert.NotNil(t, source.RerunAt)
+
+	// A cancelled batch skips everything that has not started
+	cancelled := database.RerunBatch{TenantID: 1, Status: database.RerunBatchCancelled, Total: 3}
+	require.NoError(t, database.DB.Create(&cancelled).Error)
+	engine.RunRerunBatch(cancelled, ids, "user:1")
+	database.DB.First(&cancelled, cancelled.ID)
+	assert.Equal(t, 3, cancelled.Skipped)
+	assert.Equal(t, database.RerunBatchCancelled, cancelled.Status)
+}
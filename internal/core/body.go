@@ -0,0 +1,407 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// Request body templates are escaped for the action's content type:
+//
+//	JSON   "{{.X}}" is escaped as string content; {{.X}} outside a string is encoded as a
+//	       JSON value ("text", 42, null, {...})
+//	form   every value is URL-encoded
+//	XML    values are entity-escaped; inside CDATA sections "]]>" is split
+//
+// Other content types are rendered as before. The rendered body is checked to be well-formed
+// before the request is sent.
+
+// DefaultContentType is used for actions that do not declare one
+const DefaultContentType = "application/json"
+
+// Escapers for request bodies
+const (
+	jsonStringEscaper = "_json_string"
+	jsonValueEscaper  = "_json_value"
+	formEscaper       = "_form"
+	xmlEscaper        = "_xml"
+	xmlCDATAEscaper   = "_xml_cdata"
+)
+
+// bodyFormat is a content type family with its own escaping and validation
+type bodyFormat struct {
+	name     string
+	lang     templateLanguage
+	validate func(body string) error
+}
+
+var (
+	jsonFormat = &bodyFormat{name: "JSON", lang: jsonLanguage{}, validate: validateJSON}
+	formFormat = &bodyFormat{name: "form", lang: formLanguage{}, validate: validateForm}
+	xmlFormat  = &bodyFormat{name: "XML", lang: xmlLanguage{}, validate: validateXML}
+)
+
+// bodyFormatFor maps a content type to its format; nil means the body is sent as rendered
+func bodyFormatFor(contentType string) *bodyFormat {
+	if strings.TrimSpace(contentType) == "" {
+		contentType = DefaultContentType
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return jsonFormat
+	case mediaType == "application/x-www-form-urlencoded":
+		return formFormat
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return xmlFormat
+	}
+	return nil
+}
+
+// contentTypeOf returns the content type declared by an action, or the default
+func contentTypeOf(contentType string) string {
+	if strings.TrimSpace(contentType) == "" {
+		return DefaultContentType
+	}
+	return contentType
+}
+
+// renderBody renders a request body template escaped for contentType and checks that the
+// result is well-formed. Empty bodies are not validated.
+func (e *ActionExecutor) renderBody(contentType, tplStr string, data interface{}) (string, []string, error) {
+	format := bodyFormatFor(contentType)
+	if format == nil {
+		body, err := e.renderTemplate(tplStr, data)
+		return body, nil, err
+	}
+	body, warnings, err := executeEscaped(tplStr, format.lang, data)
+	if err != nil {
+		return "", warnings, err
+	}
+	if strings.TrimSpace(body) == "" {
+		return body, warnings, nil
+	}
+	if err := format.validate(body); err != nil {
+		return "", warnings, fmt.Errorf("rendered body is not valid %s: %v", format.name, err)
+	}
+	return body, warnings, nil
+}
+
+// LintBodyTemplate reports values that reach a request body unescaped. An error means the
+// template cannot be rendered for that content type.
+func LintBodyTemplate(contentType, tplStr string) ([]string, error) {
+	format := bodyFormatFor(contentType)
+	if format == nil {
+		return nil, nil
+	}
+	_, warnings, err := parseEscapedTemplate(tplStr, format.lang)
+	return warnings, err
+}
+
+// jsonEscapeString escapes a value for use inside a JSON string, like jsonescape
+func jsonEscapeString(value interface{}) string {
+	b, _ := json.Marshal(templateString(value))
+	return string(b[1 : len(b)-1])
+}
+
+// jsonValue encodes a value as a JSON token; values that cannot be encoded become strings
+func jsonValue(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		b, _ = json.Marshal(templateString(value))
+	}
+	return string(b)
+}
+
+// JSON states
+const (
+	jsonValueState = iota
+	jsonStringState
+)
+
+type jsonLanguage struct{}
+
+func (jsonLanguage) noun() string { return "body" }
+
+func (jsonLanguage) describe(state int) string {
+	if state == jsonStringState {
+		return "a JSON string"
+	}
+	return "JSON outside a string"
+}
+
+func (jsonLanguage) scan(text []byte, state int) int {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case state == jsonStringState && text[i] == '\\':
+			i++
+		case text[i] == '"' && state == jsonStringState:
+			state = jsonValueState
+		case text[i] == '"':
+			state = jsonStringState
+		}
+	}
+	return state
+}
+
+func (jsonLanguage) escaper(state int, last string) (string, string) {
+	if state == jsonStringState {
+		if last == "jsonescape" {
+			return "", ""
+		}
+		return jsonStringEscaper, ""
+	}
+	switch last {
+	case "marshal":
+		return "", ""
+	case "jsonescape":
+		return jsonValueEscaper, "jsonescape used outside a JSON string; the value is encoded as a JSON string instead"
+	}
+	return jsonValueEscaper, ""
+}
+
+// jsonField is a group of keys whose string values need escaping beyond JSON, such as LDAP
+// filters or HTML
+type jsonField struct {
+	keys     []string
+	name     string // Describes the field's strings in lint messages
+	escaper  string // Escapes for the field and then for the JSON string
+	explicit string // Helper that escapes for the field inside the template
+}
+
+// keyedJSON is a JSON language where string values of some keys are escaped further. Keys
+// are only recognised when the whole key is literal text. After the JSON states, field i
+// has the state 2+2i inside its strings and 3+2i between its key and value.
+type keyedJSON struct {
+	output string
+	fields []jsonField
+}
+
+func (l keyedJSON) noun() string { return l.output }
+
+// field returns the field whose strings state belongs to, if any
+func (l keyedJSON) field(state int) (jsonField, bool) {
+	if state < 2 || state%2 != 0 {
+		return jsonField{}, false
+	}
+	return l.fields[(state-2)/2], true
+}
+
+func (l keyedJSON) describe(state int) string {
+	if f, ok := l.field(state); ok {
+		return f.name
+	}
+	return jsonLanguage{}.describe(state)
+}
+
+func (l keyedJSON) scan(text []byte, state int) int {
+	start := -1 // Start of the current string within text, if it began here
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if _, ok := l.field(state); ok || state == jsonStringState {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				next := jsonValueState
+				if state == jsonStringState && start >= 0 {
+					next = l.keyState(string(text[start:i]))
+				}
+				state = next
+			}
+			continue
+		}
+		switch {
+		case c == '"' && state > jsonStringState:
+			state-- // Into the string of the key just read
+		case c == '"':
+			state, start = jsonStringState, i+1
+		case c == ':' || c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			state = jsonValueState
+		}
+	}
+	return state
+}
+
+// keyState is the state after a string that may be one of the fields' keys
+func (l keyedJSON) keyState(key string) int {
+	for i, f := range l.fields {
+		for _, k := range f.keys {
+			if k == key {
+				return 3 + 2*i
+			}
+		}
+	}
+	return jsonValueState
+}
+
+func (l keyedJSON) escaper(state int, last string) (string, string) {
+	f, ok := l.field(state)
+	switch {
+	case state == jsonStringState:
+		return jsonLanguage{}.escaper(jsonStringState, last)
+	case !ok:
+		return jsonLanguage{}.escaper(jsonValueState, last)
+	case last == f.explicit:
+		return jsonStringEscaper, ""
+	case last == "jsonescape":
+		return f.escaper, "jsonescape inside " + f.name + " escapes the value twice; drop it"
+	}
+	for _, other := range l.fields {
+		if last == other.explicit {
+			return f.escaper, last + " used inside " + f.name + "; the value is escaped twice"
+		}
+	}
+	return f.escaper, ""
+}
+
+func validateJSON(body string) error {
+	var v interface{}
+	err := json.Unmarshal([]byte(body), &v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("%v at offset %d", err, syntaxErr.Offset)
+	}
+	return err
+}
+
+// formLanguage has a single state: every value is a key or value of a urlencoded pair
+type formLanguage struct{}
+
+func (formLanguage) noun() string { return "body" }
+
+func (formLanguage) describe(int) string { return "form data" }
+
+func (formLanguage) scan(text []byte, state int) int { return state }
+
+func (formLanguage) escaper(state int, last string) (string, string) {
+	if last == "urlquery" {
+		return "", ""
+	}
+	return formEscaper, ""
+}
+
+func validateForm(body string) error {
+	_, err := url.ParseQuery(strings.TrimSpace(body))
+	return err
+}
+
+// XML states
+const (
+	xmlTextState = iota
+	xmlTagState
+	xmlDoubleQuotedState
+	xmlSingleQuotedState
+	xmlCDATAState
+	xmlCommentState
+)
+
+type xmlLanguage struct{}
+
+func (xmlLanguage) noun() string { return "body" }
+
+func (xmlLanguage) describe(state int) string {
+	switch state {
+	case xmlTagState:
+		return "an XML tag"
+	case xmlDoubleQuotedState, xmlSingleQuotedState:
+		return "an XML attribute value"
+	case xmlCDATAState:
+		return "a CDATA section"
+	case xmlCommentState:
+		return "an XML comment"
+	}
+	return "XML text"
+}
+
+func (xmlLanguage) scan(text []byte, state int) int {
+	for i := 0; i < len(text); i++ {
+		rest := text[i:]
+		switch state {
+		case xmlTextState:
+			switch {
+			case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+				state, i = xmlCDATAState, i+len("<![CDATA[")-1
+			case bytes.HasPrefix(rest, []byte("<!--")):
+				state, i = xmlCommentState, i+len("<!--")-1
+			case text[i] == '<':
+				state = xmlTagState
+			}
+		case xmlTagState:
+			switch text[i] {
+			case '"':
+				state = xmlDoubleQuotedState
+			case '\'':
+				state = xmlSingleQuotedState
+			case '>':
+				state = xmlTextState
+			}
+		case xmlDoubleQuotedState:
+			if text[i] == '"' {
+				state = xmlTagState
+			}
+		case xmlSingleQuotedState:
+			if text[i] == '\'' {
+				state = xmlTagState
+			}
+		case xmlCDATAState:
+			if bytes.HasPrefix(rest, []byte("]]>")) {
+				state, i = xmlTextState, i+len("]]>")-1
+			}
+		case xmlCommentState:
+			if bytes.HasPrefix(rest, []byte("-->")) {
+				state, i = xmlTextState, i+len("-->")-1
+			}
+		}
+	}
+	return state
+}
+
+func (l xmlLanguage) escaper(state int, last string) (string, string) {
+	switch state {
+	case xmlCDATAState:
+		return xmlCDATAEscaper, ""
+	case xmlTagState, xmlCommentState:
+		return xmlEscaper, "value inside " + l.describe(state) + " is escaped as text and may not produce valid XML"
+	}
+	return xmlEscaper, ""
+}
+
+func formEscape(value interface{}) string {
+	return url.QueryEscape(templateString(value))
+}
+
+// xmlEscapeCDATA splits "]]>" so the value cannot end the CDATA section
+func xmlEscapeCDATA(value interface{}) string {
+	return strings.ReplaceAll(templateString(value), "]]>", "]]]]><![CDATA[>")
+}
+
+// xmlEscape escapes a value for XML text and quoted attribute values
+func xmlEscape(value interface{}) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(templateString(value)))
+	return b.String()
+}
+
+func validateXML(body string) error {
+	dec := xml.NewDecoder(strings.NewReader(body))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"remediation-engine/internal/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderBody_JSON(t *testing.T) {
+	executor := NewActionExecutor()
+	data := map[string]interface{}{
+		"Message":  "bad\", \"assigned_to\": \"admin",
+		"Severity": 3,
+		"Keys":     map[string]interface{}{"domain": "corp"},
+	}
+
+	body, warnings, err := executor.renderBody("", `{"msg": "Issue: {{.Message}}", "sev": {{.Severity}}, "keys": {{.Keys}}, "missing": {{.Nope}}}`, data)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, "Issue: bad\", \"assigned_to\": \"admin", got["msg"])
+	assert.Equal(t, float64(3), got["sev"])
+	assert.Equal(t, map[string]interface{}{"domain": "corp"}, got["keys"])
+	assert.Nil(t, got["missing"])
+	assert.NotContains(t, got, "assigned_to")
+
+	// Hand-escaped templates are not escaped twice
+	body, _, err = executor.renderBody("application/json", `{"msg": "{{.Message | jsonescape}}", "keys": "{{.Keys | marshal}}"}`, data)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, "bad\", \"assigned_to\": \"admin", got["msg"])
+	assert.Equal(t, `{"domain":"corp"}`, got["keys"])
+
+	// raw is the escape hatch, and the result must still be valid JSON
+	_, warnings, err = executor.renderBody("application/json", `{"msg": "{{raw .Message}}"}`, map[string]interface{}{"Message": `say "hi`})
+	assert.ErrorContains(t, err, "rendered body is not valid JSON")
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "raw value reaches the body unescaped")
+}
+
+func TestRenderBody_Form(t *testing.T) {
+	body, warnings, err := NewActionExecutor().renderBody("application/x-www-form-urlencoded; charset=utf-8",
+		`user={{.User}}&comment={{.Comment}}`, map[string]interface{}{"User": "a&admin=1", "Comment": "50% off #1"})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	values, err := url.ParseQuery(body)
+	require.NoError(t, err)
+	assert.Equal(t, "a&admin=1", values.Get("user"))
+	assert.Equal(t, "50% off #1", values.Get("comment"))
+	assert.Empty(t, values.Get("admin"))
+}
+
+func TestRenderBody_XML(t *testing.T) {
+	executor := NewActionExecutor()
+	tpl := `<ticket id="{{.ID}}"><title>{{.Title}}</title><note><![CDATA[{{.Note}}]]></note></ticket>`
+	data := map[string]interface{}{
+		"ID":    `1" admin="true`,
+		"Title": "</title><admin>true</admin>",
+		"Note":  "x]]><admin/>",
+	}
+
+	body, warnings, err := executor.renderBody("application/xml", tpl, data)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, `<ticket id="1&#34; admin=&#34;true"><title>&lt;/title&gt;&lt;admin&gt;true&lt;/admin&gt;</title><note><![CDATA[x]]]]><![CDATA[><admin/>]]></note></ticket>`, body)
+
+	_, warnings, err = executor.renderBody("text/xml", `<{{.Tag}}>x</{{.Tag}}>`, map[string]interface{}{"Tag": "a b"})
+	assert.ErrorContains(t, err, "not valid XML")
+	assert.Len(t, warnings, 2)
+}
+
+func TestRenderBody_OtherContentTypes(t *testing.T) {
+	body, warnings, err := NewActionExecutor().renderBody("text/plain", `hello "{{.Name}}"`, map[string]interface{}{"Name": `"x"`})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, `hello ""x""`, body)
+}
+
+func TestLintBodyTemplate(t *testing.T) {
+	warnings, err := LintBodyTemplate("", `{"a": {{.X | jsonescape}}}`)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "jsonescape used outside a JSON string")
+
+	_, err = LintBodyTemplate("application/json", `{"a": {{if .X}}"{{end}}}`)
+	assert.ErrorContains(t, err, "different quoting states")
+}
+
+// The seeded REST and SSF actions must lint clean and render valid JSON
+func TestSeedActionBodies(t *testing.T) {
+	raw, err := os.ReadFile("../../data/seeds/actions.json")
+	require.NoError(t, err)
+	var actions []database.ActionDefinition
+	require.NoError(t, json.Unmarshal(raw, &actions))
+
+	data := map[string]interface{}{
+		"UserEmail": `jdoe"@corp.example.com`,
+		"IssueID":   "42",
+		"IssueType": "Compromised User",
+		"Severity":  4,
+		"Risk":      "High",
+		"Title":     `Quote " and backslash \`,
+		"IssueKeys": map[string]interface{}{"domain": "corp"},
+		"Details": map[string]interface{}{
+			"Summary": "line 1\nline 2",
+			"Results": []map[string]interface{}{{"Message": "m", "Risk": 3, "Incidents": []int{1, 2}}},
+		},
+		"RemediationSteps": "1. Reset\n2. Review",
+		"RemediationURL":   "https://docs.example.com/r?a=1&b=2",
+	}
+
+	executor := NewActionExecutor()
+	for _, action := range actions {
+		if action.Method == "POWERSHELL" {
+			continue
+		}
+		warnings, err := LintBodyTemplate(action.ContentType, action.BodyTemplate)
+		require.NoError(t, err, action.Name)
+		assert.Empty(t, warnings, action.Name)
+
+		_, _, err = executor.renderBody(action.ContentType, action.BodyTemplate, data)
+		assert.NoError(t, err, action.Name)
+	}
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/logging"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var circuitLog = logging.For(logging.ComponentCircuit)
+
+// circuitColumns are the fields needed to evaluate the breaker without loading credentials
+var circuitColumns = []string{
+	"id", "tenant_id", "name", "is_available", "consecutive_failures",
+	"circuit_state", "circuit_changed_at", "circuit_failure_threshold", "circuit_cooldown_seconds",
+}
+
+// CircuitOpenError is returned when a call is rejected because the integration's breaker is not closed
+type CircuitOpenError struct {
+	Integration string
+	State       string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("integration %s is currently unavailable (circuit breaker %s)", e.Integration, e.State)
+}
+
+func loadCircuit(id uint) (database.Integration, error) {
+	var current database.Integration
+	err := database.DB.Select(circuitColumns).First(&current, id).Error
+	return current, err
+}
+
+// acquireCircuit decides whether a call to the integration may proceed.
+// probe is true when this call is the single trial request of a half-open breaker.
+func (e *ActionExecutor) acquireCircuit(integration database.Integration) (probe bool, err error) {
+	current, loadErr := loadCircuit(integration.ID)
+	if loadErr != nil {
+		// Unsaved integration (ad-hoc execution): fall back to the in-memory flag
+		if !integration.IsAvailable {
+			return false, &CircuitOpenError{Integration: integration.Name, State: "tripped"}
+		}
+		return false, nil
+	}
+
+	state := current.EffectiveCircuitState()
+	if state == database.CircuitClosed {
+		return false, nil
+	}
+
+	if current.CircuitProbeDue(time.Now()) {
+		won, err := e.setCircuitState(&current, database.CircuitHalfOpen, "cooldown elapsed, allowing trial request")
+		if err != nil {
+			return false, err
+		}
+		if won {
+			return true, nil
+		}
+	}
+
+	return false, &CircuitOpenError{Integration: integration.Name, State: state}
+}
+
+func (e *ActionExecutor) setCircuitState(integ *database.Integration, to string, reason string) (bool, error) {
+	from := integ.EffectiveCircuitState()
+	won, err := database.SetCircuitState(database.DB, integ, to, reason)
+	if err != nil {
+		circuitLog.Error("Failed to change circuit state", logging.FieldIntegration, integ.Name, "from", from, "to", to, "error", err)
+		return false, err
+	}
+	if won {
+		circuitLog.Warn("Circuit state changed", logging.FieldIntegration, integ.Name, "from", from, "to", to, "reason", reason)
+	}
+	return won, nil
+}
+
+func (e *ActionExecutor) handleCircuitFailure(integration database.Integration, probe bool, cause error) {
+	if probe {
+		current, err := loadCircuit(integration.ID)
+		if err == nil {
+			e.setCircuitState(&current, database.CircuitOpen, fmt.Sprintf("trial request failed: %v", cause))
+		}
+		return
+	}
+
+	database.DB.Model(&database.Integration{}).Where("id = ?", integration.ID).
+		UpdateColumn("consecutive_failures", gorm.Expr("consecutive_failures + 1"))
+
+	current, err := loadCircuit(integration.ID)
+	if err != nil {
+		return
+	}
+
+	if current.EffectiveCircuitState() == database.CircuitClosed && current.ConsecutiveFailures >= current.CircuitThreshold() {
+		e.setCircuitState(&current, database.CircuitOpen, fmt.Sprintf("tripped after %d consecutive failures", current.ConsecutiveFailures))
+	}
+}
+
+func (e *ActionExecutor) handleCircuitSuccess(integration database.Integration, probe bool) {
+	current, err := loadCircuit(integration.ID)
+	if err != nil {
+		return
+	}
+
+	if current.EffectiveCircuitState() != database.CircuitClosed {
+		reason := "request succeeded"
+		if probe {
+			reason = "trial request succeeded"
+		}
+		e.setCircuitState(&current, database.CircuitClosed, reason)
+		return
+	}
+
+	if current.ConsecutiveFailures > 0 {
+		database.DB.Model(&database.Integration{}).Where("id = ?", integration.ID).UpdateColumn("consecutive_failures", 0)
+	}
+}
+
+// ProbeOpenCircuits sends a health check to every open breaker whose cooldown has elapsed
+// and that declares a HealthCheckPath; LDAP and EMAIL integrations are always probed by
+// connecting and authenticating.
+// Success closes the breaker; failure re-opens it.
+func (e *ActionExecutor) ProbeOpenCircuits() {
+	var candidates []database.Integration
+	if err := database.DB.Where("circuit_state <> ? AND (health_check_path <> '' OR UPPER(type) IN ('LDAP', 'EMAIL'))", database.CircuitClosed).Find(&candidates).Error; err != nil {
+		circuitLog.Error("Failed to query open circuits", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range candidates {
+		integ := candidates[i]
+		if !integ.CircuitProbeDue(now) {
+			continue
+		}
+		won, err := e.setCircuitState(&integ, database.CircuitHalfOpen, "cooldown elapsed, sending health probe")
+		if err != nil || !won {
+			continue
+		}
+
+		if err := e.healthCheck(integ); err != nil {
+			e.setCircuitState(&integ, database.CircuitOpen, fmt.Sprintf("health probe failed: %v", err))
+			continue
+		}
+		e.setCircuitState(&integ, database.CircuitClosed, "health probe succeeded")
+	}
+}
+
+func (e *ActionExecutor) healthCheck(integration database.Integration) error {
+	switch strings.ToUpper(integration.Type) {
+	case "WINRM", "SSH":
+		return fmt.Errorf("health probes are not supported for %s integrations", integration.Type)
+	case "LDAP":
+		return e.healthCheckLDAP(integration)
+	case "EMAIL":
+		return e.healthCheckSMTP(integration)
+	}
+
+	req, err := http.NewRequest("GET", integration.BaseURL+integration.HealthCheckPath, nil)
+	if err != nil {
+		return err
+	}
+	if err := e.applyAuth(req, integration); err != nil {
+		return err
+	}
+
+	client, err := e.httpClient(integration)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
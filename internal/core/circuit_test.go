@@ -0,0 +1,126 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"remediation-engine/internal/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func statusTransport(code *int, hits *int) *MockTransport {
+	return &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			*hits++
+			return &http.Response{StatusCode: *code, Body: io.NopCloser(bytes.NewBufferString("{}")), Header: make(http.Header)}, nil
+		},
+	}
+}
+
+func TestCircuitBreaker_TripAndHalfOpenRecovery(t *testing.T) {
+	setupTestDB()
+
+	code, hits := http.StatusUnauthorized, 0
+	executor := NewActionExecutor()
+	executor.Client.Transport = statusTransport(&code, &hits)
+
+	integ := database.Integration{
+		Name: "Flaky", BaseURL: "http://flaky", IsAvailable: true, TenantID: 1,
+		CircuitFailureThreshold: 2, CircuitCooldownSeconds: 60,
+	}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	def := database.ActionDefinition{Name: "Call", Method: "GET"}
+
+	// 401 fails fast, so each Execute counts as one failure
+	for i := 0; i < 2; i++ {
+		_, _, err := executor.Execute(integ, def, map[string]interface{}{})
+		assert.Error(t, err)
+	}
+
+	var current database.Integration
+	database.DB.First(&current, integ.ID)
+	assert.Equal(t, database.CircuitOpen, current.CircuitState)
+	assert.False(t, current.IsAvailable)
+
+	// Open breaker rejects calls without touching the network
+	hits = 0
+	_, _, err := executor.Execute(integ, def, map[string]interface{}{})
+	assert.ErrorContains(t, err, "circuit breaker open")
+	assert.Equal(t, 0, hits)
+
+	// Cooldown elapsed: a failing trial re-opens the breaker
+	past := time.Now().Add(-2 * time.Minute)
+	database.DB.Model(&database.Integration{}).Where("id = ?", integ.ID).UpdateColumn("circuit_changed_at", past)
+	code = http.StatusInternalServerError
+	_, _, err = executor.Execute(integ, def, map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, hits, "half-open breaker must allow exactly one attempt")
+	database.DB.First(&current, integ.ID)
+	assert.Equal(t, database.CircuitOpen, current.CircuitState)
+
+	// A successful trial closes it again
+	database.DB.Model(&database.Integration{}).Where("id = ?", integ.ID).UpdateColumn("circuit_changed_at", past)
+	code = http.StatusOK
+	_, _, err = executor.Execute(integ, def, map[string]interface{}{})
+	assert.NoError(t, err)
+	database.DB.First(&current, integ.ID)
+	assert.Equal(t, database.CircuitClosed, current.CircuitState)
+	assert.True(t, current.IsAvailable)
+	assert.Equal(t, 0, current.ConsecutiveFailures)
+
+	var events []database.CircuitEvent
+	database.DB.Where("integration_id = ?", integ.ID).Order("id").Find(&events)
+	var path []string
+	for _, ev := range events {
+		path = append(path, ev.ToState)
+	}
+	assert.Equal(t, []string{
+		database.CircuitOpen, database.CircuitHalfOpen, database.CircuitOpen,
+		database.CircuitHalfOpen, database.CircuitClosed,
+	}, path)
+}
+
+func TestCircuitBreaker_OnlyOneProbeWins(t *testing.T) {
+	setupTestDB()
+
+	integ := database.Integration{Name: "Race", IsAvailable: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	database.DB.Model(&database.Integration{}).Where("id = ?", integ.ID).
+		UpdateColumns(map[string]interface{}{"circuit_state": database.CircuitOpen, "is_available": false, "circuit_changed_at": time.Now().Add(-time.Hour)})
+
+	var a, b database.Integration
+	database.DB.First(&a, integ.ID)
+	database.DB.First(&b, integ.ID)
+
+	wonA, err := database.SetCircuitState(database.DB, &a, database.CircuitHalfOpen, "probe a")
+	require.NoError(t, err)
+	wonB, err := database.SetCircuitState(database.DB, &b, database.CircuitHalfOpen, "probe b")
+	require.NoError(t, err)
+	assert.True(t, wonA)
+	assert.False(t, wonB)
+}
+
+func TestProbeOpenCircuits(t *testing.T) {
+	setupTestDB()
+
+	code, hits := http.StatusOK, 0
+	executor := NewActionExecutor()
+	executor.Client.Transport = statusTransport(&code, &hits)
+
+	integ := database.Integration{Name: "Probed", BaseURL: "http://svc", HealthCheckPath: "/health", IsAvailable: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	database.DB.Model(&database.Integration{}).Where("id = ?", integ.ID).
+		UpdateColumns(map[string]interface{}{"circuit_state": database.CircuitOpen, "is_available": false, "circuit_changed_at": time.Now().Add(-time.Hour)})
+
+	executor.ProbeOpenCircuits()
+
+	var current database.Integration
+	database.DB.First(&current, integ.ID)
+	assert.Equal(t, 1, hits)
+	assert.Equal(t, database.CircuitClosed, current.CircuitState)
+	assert.True(t, current.IsAvailable)
+}
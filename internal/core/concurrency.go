@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// queueWaitKey is the contextData key the executor uses to report how long a step
+// waited for a free concurrency slot (time.Duration). The engine records it on the step log.
+const queueWaitKey = "_queue_wait"
+
+var (
+	semaphores   = make(map[uint]chan struct{})
+	semaphoresMu sync.Mutex
+)
+
+// getSemaphore returns the slot pool shared by every worker calling the integration,
+// or nil when MaxConcurrency is 0 (unlimited). A changed limit replaces the pool;
+// calls holding a slot in the old pool release it there.
+func (e *ActionExecutor) getSemaphore(id uint, maxConcurrency int) chan struct{} {
+	if maxConcurrency <= 0 {
+		return nil
+	}
+
+	semaphoresMu.Lock()
+	defer semaphoresMu.Unlock()
+
+	if sem, ok := semaphores[id]; ok && cap(sem) == maxConcurrency {
+		return sem
+	}
+	sem := make(chan struct{}, maxConcurrency)
+	semaphores[id] = sem
+	return sem
+}
+
+// acquireSlot blocks until a slot is free and returns the release func and the time spent queued
+func acquireSlot(ctx context.Context, sem chan struct{}) (func(), time.Duration, error) {
+	if sem == nil {
+		return func() {}, 0, nil
+	}
+
+	start := time.Now()
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, time.Since(start), nil
+	case <-ctx.Done():
+		return nil, time.Since(start), ctx.Err()
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"remediation-engine/internal/database"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecute_MaxConcurrencyIsSharedAcrossExecutors(t *testing.T) {
+	setupTestDB()
+	integ := database.Integration{Name: "MID Server", BaseURL: "http://mid", IsAvailable: true, TenantID: 1, MaxConcurrency: 2}
+	require.NoError(t, database.DB.Create(&integ).Error)
+
+	var inFlight, peak int32
+	transport := &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewBufferString("{}")), Header: make(http.Header)}, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	var queuedSteps int32
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker builds its own executor, as RunWorkflow does
+			executor := NewActionExecutor()
+			executor.Client.Transport = transport
+			contextData := map[string]interface{}{}
+			_, _, err := executor.Execute(integ, database.ActionDefinition{Name: "Get", Method: "GET"}, contextData)
+			assert.NoError(t, err)
+			if wait, _ := contextData[queueWaitKey].(time.Duration); wait > 0 {
+				atomic.AddInt32(&queuedSteps, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&peak))
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&queuedSteps), int32(4))
+}
+
+func TestGetSemaphore_ResizesWhenLimitChanges(t *testing.T) {
+	setupTestDB()
+	executor := NewActionExecutor()
+
+	assert.Nil(t, executor.getSemaphore(7, 0))
+
+	sem := executor.getSemaphore(7, 3)
+	assert.Equal(t, 3, cap(sem))
+	assert.Equal(t, sem, executor.getSemaphore(7, 3))
+
+	resized := executor.getSemaphore(7, 1)
+	assert.Equal(t, 1, cap(resized))
+}
+
+func TestRunWorkflow_RecordsQueueWait(t *testing.T) {
+	setupTestDB()
+
+	mockExec := &MockExecutor{
+		ExecuteFunc: func(integ database.Integration, def database.ActionDefinition, ctx map[string]interface{}) ([]byte, int, error) {
+			ctx[queueWaitKey] = 1500 * time.Millisecond
+			return []byte("ok"), 200, nil
+		},
+	}
+	originalFunc := NewExecutorFunc
+	defer func() { NewExecutorFunc = originalFunc }()
+	NewExecutorFunc = func() Executor { return mockExec }
+
+	wf := database.Workflow{Name: "Queued Workflow", Enabled: true, TenantID: 1}
+	database.DB.Create(&wf)
+	integ := database.Integration{Name: "Queued Integ", Enabled: true, TenantID: 1, MaxConcurrency: 1}
+	database.DB.Create(&integ)
+	action := database.ActionDefinition{Name: "Queued Action", IntegrationID: integ.ID, TenantID: 1}
+	database.DB.Create(&action)
+	database.DB.Create(&database.WorkflowStep{WorkflowID: wf.ID, ActionDefinitionID: action.ID, Order: 1, ParameterMapping: "{}"})
+
+	var fullWf database.Workflow
+	database.DB.Preload("Steps").First(&fullWf, wf.ID)
+	NewEngine().RunWorkflow(fullWf, map[string]interface{}{"TenantID": uint(1), "IssueID": "q-1"})
+
+	var stepLog database.JobLog
+	require.NoError(t, database.DB.Where("step_name = ?", "Queued Action").First(&stepLog).Error)
+	assert.Equal(t, int64(1500), stepLog.QueueWaitMs)
+	assert.Contains(t, stepLog.Message, "after waiting 1.5s for an integration slot")
+}
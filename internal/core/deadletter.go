@@ -0,0 +1,74 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Error classes recorded on failed jobs so the dead-letter queue can group them by root cause
+const (
+	ErrorClassCircuitOpen   = "circuit_open"
+	ErrorClassThrottled     = "throttled"
+	ErrorClassAuth          = "auth"
+	ErrorClassNotFound      = "not_found"
+	ErrorClassClientError   = "client_error"
+	ErrorClassServerError   = "server_error"
+	ErrorClassNetwork       = "network"
+	ErrorClassTemplate      = "template"
+	ErrorClassConfiguration = "configuration"
+	ErrorClassUnknown       = "unknown"
+)
+
+// ErrorClasses returns every error class in display order
+func ErrorClasses() []string {
+	return []string{
+		ErrorClassCircuitOpen, ErrorClassThrottled, ErrorClassAuth, ErrorClassNotFound, ErrorClassClientError,
+		ErrorClassServerError, ErrorClassNetwork, ErrorClassTemplate, ErrorClassConfiguration, ErrorClassUnknown,
+	}
+}
+
+// ClassifyError maps a step failure to an error class
+func ClassifyError(code int, err error) string {
+	var circuitErr *CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		return ErrorClassCircuitOpen
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		code = statusErr.StatusCode
+	}
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrorClassThrottled
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrorClassAuth
+	case code == http.StatusNotFound:
+		return ErrorClassNotFound
+	case code >= 400 && code < 500:
+		return ErrorClassClientError
+	case code >= 500:
+		return ErrorClassServerError
+	}
+
+	if err == nil {
+		return ErrorClassUnknown
+	}
+	if isNetworkError(err) {
+		return ErrorClassNetwork
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "rate limit wait failed"), strings.Contains(msg, "throttled by the vendor"):
+		return ErrorClassThrottled
+	case strings.Contains(msg, "failed to render"), strings.Contains(msg, "failed to unmarshal ssf payload"):
+		return ErrorClassTemplate
+	case strings.Contains(msg, "oauth2"), strings.Contains(msg, "integration credentials"), strings.Contains(msg, "request signing failed"):
+		return ErrorClassAuth
+	case strings.Contains(msg, "invalid transport settings"):
+		return ErrorClassConfiguration
+	}
+	return ErrorClassUnknown
+}
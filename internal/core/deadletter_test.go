@@ -0,0 +1,32 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		code int
+		err  error
+		want string
+	}{
+		{0, &CircuitOpenError{Integration: "Okta", State: "open"}, ErrorClassCircuitOpen},
+		{429, fmt.Errorf("all 4 attempts failed. Last error: %w", &HTTPStatusError{StatusCode: 429}), ErrorClassThrottled},
+		{401, &HTTPStatusError{StatusCode: 401}, ErrorClassAuth},
+		{404, &HTTPStatusError{StatusCode: 404}, ErrorClassNotFound},
+		{422, &HTTPStatusError{StatusCode: 422}, ErrorClassClientError},
+		{503, errors.New("upstream"), ErrorClassServerError},
+		{0, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorClassNetwork},
+		{0, errors.New("failed to render body: template: action:1: unexpected EOF"), ErrorClassTemplate},
+		{0, errors.New("rate limit wait failed: context canceled"), ErrorClassThrottled},
+		{0, errors.New("something odd"), ErrorClassUnknown},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, ClassifyError(c.code, c.err), c.err.Error())
+	}
+}
@@ -0,0 +1,711 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/tracing"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultSMTPPort  = 587
+	defaultSMTPSPort = 465
+)
+
+// smtpTarget is the mail server an EMAIL integration's BaseURL points at
+type smtpTarget struct {
+	Host string
+	Port int
+	TLS  bool // smtps:// (TLS from the first byte)
+}
+
+func (t smtpTarget) Addr() string {
+	return net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+}
+
+func (t smtpTarget) String() string {
+	scheme := "smtp"
+	if t.TLS {
+		scheme = "smtps"
+	}
+	return scheme + "://" + t.Addr()
+}
+
+// parseSMTPTarget accepts "host", "host:port", "smtp://host[:port]" or "smtps://host[:port]".
+// Without a scheme, port 465 implies implicit TLS.
+func parseSMTPTarget(baseURL string) (smtpTarget, error) {
+	raw := strings.TrimSpace(baseURL)
+	if raw == "" {
+		return smtpTarget{}, fmt.Errorf("smtp: base URL (host) is empty")
+	}
+
+	var t smtpTarget
+	explicit := strings.Contains(raw, "://")
+	if explicit {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return smtpTarget{}, fmt.Errorf("smtp: invalid base URL %q: %v", baseURL, err)
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "smtp":
+		case "smtps":
+			t.TLS = true
+		default:
+			return smtpTarget{}, fmt.Errorf("smtp: unsupported scheme %q (use smtp or smtps)", u.Scheme)
+		}
+		raw = u.Host
+	}
+
+	host, portStr, err := net.SplitHostPort(raw)
+	if err != nil {
+		// No port given
+		t.Host = strings.Trim(raw, "[]")
+		t.Port = defaultSMTPPort
+		if t.TLS {
+			t.Port = defaultSMTPSPort
+		}
+		return t, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return smtpTarget{}, fmt.Errorf("smtp: invalid port %q", portStr)
+	}
+	t.Host, t.Port = host, port
+	if !explicit && port == defaultSMTPSPort {
+		t.TLS = true
+	}
+	return t, nil
+}
+
+// emailRequest is the rendered body of an EMAIL action. Subject and text default to the
+// workflow's message template (Title, Message and Footer).
+type emailRequest struct {
+	From        string            `json:"from"`
+	ReplyTo     string            `json:"reply_to"`
+	To          []string          `json:"to"`
+	Cc          []string          `json:"cc"`
+	Bcc         []string          `json:"bcc"`
+	Subject     string            `json:"subject"`
+	Text        string            `json:"text"`
+	HTML        string            `json:"html"`
+	Attachments []emailAttachment `json:"attachments"`
+}
+
+// emailAttachment carries text content, or binary content in content_base64
+type emailAttachment struct {
+	Filename      string `json:"filename"`
+	ContentType   string `json:"content_type"`
+	Content       string `json:"content"`
+	ContentBase64 string `json:"content_base64"`
+}
+
+// emailRejection is a recipient the server refused
+type emailRejection struct {
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+// emailDelivery is the step response, recorded on the job
+type emailDelivery struct {
+	MessageID   string           `json:"message_id"`
+	From        string           `json:"from"`
+	Accepted    []string         `json:"accepted"`
+	Rejected    []emailRejection `json:"rejected,omitempty"`
+	ServerReply string           `json:"server_reply"`
+}
+
+// emailAddresses parses recipient lists. Entries may hold several comma-separated
+// addresses; empty entries (an optional recipient that was not set) are skipped.
+func emailAddresses(field string, entries []string) ([]*mail.Address, error) {
+	var out []*mail.Address
+	for _, entry := range entries {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		list, err := mail.ParseAddressList(entry)
+		if err != nil {
+			return nil, fmt.Errorf("email: invalid %s address %q: %v", field, entry, err)
+		}
+		out = append(out, list...)
+	}
+	return out, nil
+}
+
+// emailMessage is a validated emailRequest ready to be written as MIME
+type emailMessage struct {
+	from     *mail.Address
+	replyTo  *mail.Address
+	to, cc   []*mail.Address
+	bcc      []*mail.Address
+	subject  string
+	text     string
+	html     string
+	attached []emailAttachment
+}
+
+// recipients is every envelope recipient, Bcc included
+func (m *emailMessage) recipients() []string {
+	var out []string
+	for _, list := range [][]*mail.Address{m.to, m.cc, m.bcc} {
+		for _, a := range list {
+			out = append(out, a.Address)
+		}
+	}
+	return out
+}
+
+// newEmailMessage validates a request, filling in the sender and the message template
+// defaults
+func newEmailMessage(req emailRequest, creds map[string]string, contextData map[string]interface{}) (*emailMessage, error) {
+	m := &emailMessage{subject: req.Subject, text: req.Text, html: req.HTML, attached: req.Attachments}
+	if m.subject == "" {
+		m.subject = templateString(contextData["Title"])
+	}
+	if m.text == "" && m.html == "" {
+		m.text = templateString(contextData["Message"])
+		if footer := templateString(contextData["Footer"]); footer != "" {
+			m.text += "\n\n" + footer
+		}
+	}
+	if strings.TrimSpace(m.text) == "" && strings.TrimSpace(m.html) == "" {
+		return nil, fmt.Errorf("email: the message has no text or html body and the workflow has no message template")
+	}
+	// A subject is a single header line
+	m.subject = strings.Join(strings.Fields(m.subject), " ")
+
+	from := req.From
+	if from == "" {
+		from = creds["from"]
+	}
+	if from == "" && strings.Contains(creds["username"], "@") {
+		from = creds["username"]
+	}
+	if from == "" {
+		return nil, fmt.Errorf("email: no sender; set from in the action or the integration credentials")
+	}
+	var err error
+	if m.from, err = mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("email: invalid from address %q: %v", from, err)
+	}
+	if req.ReplyTo != "" {
+		if m.replyTo, err = mail.ParseAddress(req.ReplyTo); err != nil {
+			return nil, fmt.Errorf("email: invalid reply_to address %q: %v", req.ReplyTo, err)
+		}
+	}
+	if m.to, err = emailAddresses("to", req.To); err != nil {
+		return nil, err
+	}
+	if m.cc, err = emailAddresses("cc", req.Cc); err != nil {
+		return nil, err
+	}
+	if m.bcc, err = emailAddresses("bcc", req.Bcc); err != nil {
+		return nil, err
+	}
+	if len(m.recipients()) == 0 {
+		return nil, fmt.Errorf("email: the message has no recipients")
+	}
+
+	for i, a := range m.attached {
+		if a.Filename == "" {
+			return nil, fmt.Errorf("email: attachment %d has no filename", i+1)
+		}
+		if a.ContentBase64 != "" {
+			if _, err := base64.StdEncoding.DecodeString(a.ContentBase64); err != nil {
+				return nil, fmt.Errorf("email: attachment %q has invalid content_base64: %v", a.Filename, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+func joinAddresses(list []*mail.Address) string {
+	parts := make([]string, len(list))
+	for i, a := range list {
+		parts[i] = a.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// newMessageID returns a unique Message-ID in the sender's domain
+func newMessageID(from *mail.Address) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	domain := "localhost"
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// bytes writes the message as MIME: text and HTML become multipart/alternative, and
+// attachments wrap it in multipart/mixed. Bcc recipients are not written.
+func (m *emailMessage) bytes(messageID string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		if value != "" {
+			buf.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	header("From", m.from.String())
+	header("To", joinAddresses(m.to))
+	header("Cc", joinAddresses(m.cc))
+	if m.replyTo != nil {
+		header("Reply-To", m.replyTo.String())
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", m.subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	header("MIME-Version", "1.0")
+
+	bodyHeader, body, err := m.body()
+	if err != nil {
+		return nil, err
+	}
+	if len(m.attached) == 0 {
+		for _, name := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			header(name, bodyHeader.Get(name))
+		}
+		buf.WriteString("\r\n")
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	buf.WriteString("\r\n")
+	part, err := mixed.CreatePart(bodyHeader)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(body)
+
+	for _, a := range m.attached {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(strings.ToLower(path.Ext(a.Filename)))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		data := []byte(a.Content)
+		if a.ContentBase64 != "" {
+			data, _ = base64.StdEncoding.DecodeString(a.ContentBase64)
+		}
+		writeBase64Lines(part, data)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// body returns the headers and content of the text and/or HTML body
+func (m *emailMessage) body() (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+	if m.text == "" || m.html == "" {
+		contentType, text := "text/plain", m.text
+		if m.html != "" {
+			contentType, text = "text/html", m.html
+		}
+		err := writeQuotedPrintable(&buf, text)
+		return textproto.MIMEHeader{
+			"Content-Type":              {contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		}, buf.Bytes(), err
+	}
+
+	alt := multipart.NewWriter(&buf)
+	for _, p := range []struct{ contentType, text string }{{"text/plain", m.text}, {"text/html", m.html}} {
+		part, err := alt.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := writeQuotedPrintable(part, p.text); err != nil {
+			return nil, nil, err
+		}
+	}
+	err := alt.Close()
+	return textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": alt.Boundary()})},
+	}, buf.Bytes(), err
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64Lines writes data as base64 in lines of 76 characters
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		io.WriteString(w, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(w, encoded+"\r\n")
+}
+
+func (e *ActionExecutor) executeEmail(ctx context.Context, integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, error) {
+	// 1. Resolve the message (BodyTemplate is JSON; values in "html" are HTML-escaped)
+	body, warnings, err := e.renderEmail(definition.BodyTemplate, contextData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render email: %v", err)
+	}
+	for _, w := range warnings {
+		executorLog.WarnContext(ctx, "Email template lint", "action", definition.Name, "warning", w)
+	}
+	var req emailRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return nil, fmt.Errorf("failed to render email: not valid JSON: %v", err)
+	}
+
+	// 2. Parse Credentials
+	var creds map[string]string
+	if integration.Credentials != "" {
+		if err := json.Unmarshal([]byte(integration.Credentials), &creds); err != nil {
+			return nil, fmt.Errorf("failed to parse integration credentials: %v", err)
+		}
+	}
+
+	msg, err := newEmailMessage(req, creds, contextData)
+	if err != nil {
+		return nil, err
+	}
+	target, err := parseSMTPTarget(integration.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	messageID := newMessageID(msg.from)
+	data, err := msg.bytes(messageID, e.now())
+	if err != nil {
+		return nil, fmt.Errorf("email: failed to build the message: %v", err)
+	}
+
+	// 3. Connect, authenticate and send within the integration's timeout
+	timeout := commandTimeout(integration)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, span := tracing.Start(ctx, "SMTP Send",
+		attribute.String("server.address", target.Host),
+		attribute.Int("server.port", target.Port),
+		attribute.Int("email.recipients", len(msg.recipients())))
+	delivery, err := sendEmail(runCtx, integration, target, creds, msg.from.Address, msg.recipients(), data)
+	if err != nil && runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	if err = describeSMTPError(err, target, timeout); err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	tracing.End(span, nil)
+
+	delivery.MessageID, delivery.From = messageID, msg.from.Address
+	for _, r := range delivery.Rejected {
+		executorLog.WarnContext(ctx, "Email recipient rejected", "action", definition.Name, "recipient", r.Address, "error", r.Error)
+	}
+	return json.Marshal(delivery)
+}
+
+// dialSMTP connects, upgrades to TLS (implicitly, or with STARTTLS when the server offers
+// it) and authenticates. Credentials are never sent over an unencrypted connection.
+func dialSMTP(ctx context.Context, integration database.Integration, target smtpTarget, creds map[string]string, timeout time.Duration) (*smtp.Client, error) {
+	var auth smtp.Auth
+	switch strings.ToLower(integration.AuthType) {
+	case "", "none":
+	case "basic", "plain":
+		auth = smtp.PlainAuth("", creds["username"], creds["password"], target.Host)
+	case "login":
+		auth = &loginAuth{username: creds["username"], password: creds["password"]}
+	default:
+		return nil, fmt.Errorf("smtp: unsupported auth type %q (use plain, login or none)", integration.AuthType)
+	}
+	if auth != nil && (creds["username"] == "" || creds["password"] == "") {
+		return nil, fmt.Errorf("smtp: %s auth needs a username and password in the credentials", integration.AuthType)
+	}
+
+	tlsConfig, err := transportSettings(integration).TLSConfig(target.Host)
+	if err != nil {
+		return nil, fmt.Errorf("smtp: %v", err)
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", target.Addr())
+	if err != nil {
+		return nil, err
+	}
+	// Closing the connection unblocks any command in flight on cancellation
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	fail := func(err error) (*smtp.Client, error) {
+		stop()
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	if target.TLS {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fail(err)
+		}
+		conn = tlsConn
+	}
+	c, err := smtp.NewClient(conn, target.Host)
+	if err != nil {
+		return fail(err)
+	}
+	if err := c.Hello(localHostname()); err != nil {
+		return fail(err)
+	}
+
+	encrypted := target.TLS
+	if !encrypted {
+		offered, _ := c.Extension("STARTTLS")
+		switch {
+		case offered:
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fail(err)
+			}
+			encrypted = true
+		case integration.StartTLS:
+			return fail(fmt.Errorf("smtp: %s does not offer STARTTLS, which start_tls requires", target))
+		}
+	}
+	if auth != nil {
+		if !encrypted {
+			return fail(fmt.Errorf("smtp: %s offers no TLS; refusing to send credentials in clear text (use smtps:// or a server with STARTTLS)", target))
+		}
+		if err := c.Auth(auth); err != nil {
+			return fail(err)
+		}
+	}
+	return c, nil
+}
+
+func localHostname() string {
+	if name, err := os.Hostname(); err == nil && name != "" && !strings.ContainsAny(name, " \r\n") {
+		return name
+	}
+	return "localhost"
+}
+
+// sendEmail delivers one message. Recipients the server refuses are reported in the
+// delivery; the send fails only when none are accepted.
+func sendEmail(ctx context.Context, integration database.Integration, target smtpTarget, creds map[string]string, from string, recipients []string, data []byte) (emailDelivery, error) {
+	var delivery emailDelivery
+	c, err := dialSMTP(ctx, integration, target, creds, commandTimeout(integration))
+	if err != nil {
+		return delivery, err
+	}
+	defer c.Close()
+
+	if err := c.Mail(from); err != nil {
+		return delivery, err
+	}
+	var firstErr error
+	for _, rcpt := range recipients {
+		if err := c.Rcpt(rcpt); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			delivery.Rejected = append(delivery.Rejected, emailRejection{Address: rcpt, Error: err.Error()})
+			continue
+		}
+		delivery.Accepted = append(delivery.Accepted, rcpt)
+	}
+	if len(delivery.Accepted) == 0 {
+		return delivery, firstErr
+	}
+
+	// DATA by hand to keep the server's reply, which usually carries its queue id
+	id, err := c.Text.Cmd("DATA")
+	if err != nil {
+		return delivery, err
+	}
+	c.Text.StartResponse(id)
+	_, _, err = c.Text.ReadResponse(354)
+	c.Text.EndResponse(id)
+	if err != nil {
+		return delivery, err
+	}
+	w := c.Text.DotWriter()
+	if _, err := w.Write(data); err != nil {
+		return delivery, err
+	}
+	if err := w.Close(); err != nil {
+		return delivery, err
+	}
+	code, reply, err := c.Text.ReadResponse(250)
+	if err != nil {
+		return delivery, err
+	}
+	delivery.ServerReply = strconv.Itoa(code) + " " + reply
+	c.Quit()
+	return delivery, nil
+}
+
+// loginAuth implements the LOGIN mechanism, which some servers (Exchange Online among
+// them) offer instead of PLAIN
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, errors.New("smtp: unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSuffix(string(fromServer), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("smtp: unexpected LOGIN prompt %q", fromServer)
+}
+
+// describeSMTPError turns connection and protocol failures into messages that name the
+// likely fix. Rejected credentials surface as a 401, temporary (4xx) replies as a 503 and
+// permanent (5xx) replies as a 400, so only the temporary ones are retried.
+func describeSMTPError(err error, target smtpTarget, timeout time.Duration) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		protoErr  *textproto.Error
+		authority x509.UnknownAuthorityError
+		hostname  x509.HostnameError
+		recordErr tls.RecordHeaderError
+		dnsErr    *net.DNSError
+		netErr    net.Error
+	)
+	msg := err.Error()
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return fmt.Errorf("smtp: %s did not answer within %s (raise timeout_seconds): %w", target, timeout, err)
+	case errors.As(err, &authority):
+		return fmt.Errorf("smtp: the certificate presented by %s is not signed by a trusted CA; add the issuing CA to ca_cert: %w", target, err)
+	case errors.As(err, &hostname):
+		return fmt.Errorf("smtp: the certificate presented by %s is not valid for host %q; connect using a name listed in the certificate: %w", target, target.Host, err)
+	case errors.As(err, &recordErr):
+		return fmt.Errorf("smtp: %s did not answer with TLS; use smtp:// (STARTTLS) or port 465 for smtps: %w", target, err)
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("smtp: cannot resolve host %q: %w", target.Host, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("smtp: connection refused by %s; check the port (587 for submission, 465 for smtps): %w", target, err)
+	case errors.As(err, &protoErr) && (protoErr.Code == 535 || protoErr.Code == 534 || protoErr.Code == 530):
+		return fmt.Errorf("smtp: %s rejected the credentials; check the username and password: %w", target,
+			&HTTPStatusError{StatusCode: http.StatusUnauthorized, Body: []byte(msg)})
+	case errors.As(err, &protoErr) && protoErr.Code >= 400 && protoErr.Code < 500:
+		return fmt.Errorf("smtp: %s deferred the message: %w", target,
+			&HTTPStatusError{StatusCode: http.StatusServiceUnavailable, Body: []byte(msg)})
+	case errors.As(err, &protoErr) && protoErr.Code >= 500:
+		return fmt.Errorf("smtp: %s refused the message: %w", target,
+			&HTTPStatusError{StatusCode: http.StatusBadRequest, Body: []byte(msg)})
+	}
+	return fmt.Errorf("smtp: sending to %s failed: %w", target, err)
+}
+
+// healthCheckSMTP connects and authenticates without sending anything
+func (e *ActionExecutor) healthCheckSMTP(integration database.Integration) error {
+	var creds map[string]string
+	if integration.Credentials != "" {
+		if err := json.Unmarshal([]byte(integration.Credentials), &creds); err != nil {
+			return fmt.Errorf("failed to parse integration credentials: %v", err)
+		}
+	}
+	target, err := parseSMTPTarget(integration.BaseURL)
+	if err != nil {
+		return err
+	}
+	timeout := commandTimeout(integration)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	c, err := dialSMTP(ctx, integration, target, creds, timeout)
+	if err != nil {
+		return describeSMTPError(err, target, timeout)
+	}
+	c.Quit()
+	c.Close()
+	return nil
+}
+
+// Email templates are JSON messages, escaped like other JSON bodies. Values inside the
+// "html" string are also HTML-escaped, so a crafted value cannot add markup or links:
+//
+//	"subject": "Account {{.UserEmail}} suspended"       JSON-escaped
+//	"html": "<p>Hello {{.UserName}}</p>"                 HTML-escaped, then JSON-escaped
+//
+// The html function escapes explicitly for other places.
+var emailLanguage = keyedJSON{output: "message", fields: []jsonField{
+	{keys: []string{"html"}, name: "an HTML string", escaper: htmlStringEscaper, explicit: "html"},
+}}
+
+const htmlStringEscaper = "_html_string"
+
+func htmlString(value interface{}) string {
+	return jsonEscapeString(html.EscapeString(templateString(value)))
+}
+
+// LintEmailTemplate reports values that reach an email without escaping. An error means
+// the template cannot be rendered at all.
+func LintEmailTemplate(tplStr string) ([]string, error) {
+	_, warnings, err := parseEscapedTemplate(tplStr, emailLanguage)
+	return warnings, err
+}
+
+// renderEmail renders an email template with auto-escaping
+func (e *ActionExecutor) renderEmail(tplStr string, data interface{}) (string, []string, error) {
+	return executeEscaped(tplStr, emailLanguage, data)
+}
@@ -0,0 +1,348 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"remediation-engine/internal/database"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSMTPTarget(t *testing.T) {
+	cases := []struct {
+		in   string
+		want smtpTarget
+	}{
+		{"mail.corp", smtpTarget{Host: "mail.corp", Port: 587}},
+		{"mail.corp:25", smtpTarget{Host: "mail.corp", Port: 25}},
+		{"mail.corp:465", smtpTarget{Host: "mail.corp", Port: 465, TLS: true}},
+		{"smtp://mail.corp:465", smtpTarget{Host: "mail.corp", Port: 465}},
+		{"smtps://mail.corp", smtpTarget{Host: "mail.corp", Port: 465, TLS: true}},
+	}
+	for _, c := range cases {
+		got, err := parseSMTPTarget(c.in)
+		require.NoError(t, err, c.in)
+		assert.Equal(t, c.want, got, c.in)
+	}
+	_, err := parseSMTPTarget("https://mail.corp")
+	assert.ErrorContains(t, err, "unsupported scheme")
+}
+
+func TestLintEmailTemplate(t *testing.T) {
+	warnings, err := LintEmailTemplate(`{"to": ["{{.UserEmail}}"], "html": "<b>{{.UserName}}</b>"}`)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	warnings, err = LintEmailTemplate(`{"html": "<b>{{.UserName | jsonescape}}</b>"}`)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "jsonescape inside an HTML string escapes the value twice")
+}
+
+// testSMTPServer is an SMTP sink with STARTTLS, AUTH PLAIN and LOGIN. Recipients in
+// the invalid.example domain are refused.
+type testSMTPServer struct {
+	addr   string
+	caCert string
+
+	mu       sync.Mutex
+	authed   []string
+	from     string
+	rcpts    []string
+	messages [][]byte
+}
+
+func startSMTPServer(t *testing.T, implicitTLS, startTLS bool) *testSMTPServer {
+	t.Helper()
+	// Borrow the test certificate for 127.0.0.1
+	certSrv := httptest.NewTLSServer(nil)
+	tlsConfig := certSrv.TLS.Clone()
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certSrv.Certificate().Raw}))
+	certSrv.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if implicitTLS {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &testSMTPServer{addr: ln.Addr().String(), caCert: caCert}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, tlsConfig, startTLS && !implicitTLS)
+		}
+	}()
+	return s
+}
+
+func (s *testSMTPServer) serve(conn net.Conn, tlsConfig *tls.Config, offerTLS bool) {
+	defer func() { conn.Close() }()
+	tc := textproto.NewConn(conn)
+	tc.PrintfLine("220 sink ESMTP")
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if offerTLS {
+				tc.PrintfLine("250-sink\r\n250-STARTTLS\r\n250 AUTH PLAIN LOGIN")
+			} else {
+				tc.PrintfLine("250-sink\r\n250 AUTH PLAIN LOGIN")
+			}
+		case "STARTTLS":
+			tc.PrintfLine("220 ready")
+			conn = tls.Server(conn, tlsConfig)
+			tc = textproto.NewConn(conn)
+			offerTLS = false
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			var user, pass string
+			if mech == "PLAIN" {
+				b, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(b), "\x00")
+				if len(parts) == 3 {
+					user, pass = parts[1], parts[2]
+				}
+			} else {
+				for _, prompt := range []*string{&user, &pass} {
+					label := "Username:"
+					if prompt == &pass {
+						label = "Password:"
+					}
+					tc.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(label)))
+					reply, _ := tc.ReadLine()
+					b, _ := base64.StdEncoding.DecodeString(reply)
+					*prompt = string(b)
+				}
+			}
+			if user != "alerts@corp.example.com" || pass != "pw" {
+				tc.PrintfLine("535 5.7.8 Authentication credentials invalid")
+				continue
+			}
+			s.mu.Lock()
+			s.authed = append(s.authed, mech)
+			s.mu.Unlock()
+			tc.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.mu.Lock()
+			s.from, s.rcpts = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>"), nil
+			s.mu.Unlock()
+			tc.PrintfLine("250 2.1.0 Ok")
+		case "RCPT":
+			rcpt := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if strings.HasSuffix(rcpt, "@invalid.example") {
+				tc.PrintfLine("550 5.1.1 <%s>: Recipient address rejected", rcpt)
+				continue
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, rcpt)
+			s.mu.Unlock()
+			tc.PrintfLine("250 2.1.5 Ok")
+		case "DATA":
+			tc.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(tc.DotReader())
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data)
+			s.mu.Unlock()
+			tc.PrintfLine("250 2.0.0 Ok: queued as Q1")
+		case "QUIT":
+			tc.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			tc.PrintfLine("502 5.5.2 Error: command not recognized")
+		}
+	}
+}
+
+func (s *testSMTPServer) integration(scheme string) database.Integration {
+	return database.Integration{
+		Name:        "Mail",
+		Type:        "EMAIL",
+		BaseURL:     scheme + "://" + s.addr,
+		AuthType:    "plain",
+		Credentials: `{"username":"alerts@corp.example.com","password":"pw"}`,
+		CACert:      s.caCert,
+	}
+}
+
+// readEmailParts returns the headers and the decoded parts of a delivered message by
+// content type (attachments by filename)
+func readEmailParts(t *testing.T, data []byte) (mail.Header, map[string]string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	parts := map[string]string{}
+	var walk func(contentType string, body io.Reader)
+	walk = func(contentType string, body io.Reader) {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		require.NoError(t, err)
+		if !strings.HasPrefix(mediaType, "multipart/") {
+			b, _ := io.ReadAll(body)
+			parts[mediaType] = string(b)
+			return
+		}
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return
+			}
+			require.NoError(t, err)
+			if name := p.FileName(); name != "" {
+				b, _ := io.ReadAll(p)
+				parts[name] = string(b)
+				continue
+			}
+			walk(p.Header.Get("Content-Type"), p)
+		}
+	}
+	walk(msg.Header.Get("Content-Type"), msg.Body)
+	return msg.Header, parts
+}
+
+func TestExecuteEmail_NotifyOverStartTLS(t *testing.T) {
+	srv := startSMTPServer(t, false, true)
+	executor := NewActionExecutor()
+	def := database.ActionDefinition{Name: "Notify user", BodyTemplate: `{
+		"to": ["{{.UserEmail}}", "nobody@invalid.example"],
+		"bcc": ["{{.ManagerEmail}}"],
+		"subject": "Sign-in blocked for {{.UserName}}",
+		"text": "Hello {{.UserName}},\nyour account was locked.",
+		"html": "<p>Hello {{.UserName}},</p><p>your account was locked.</p>",
+		"attachments": [{"filename": "alert.csv", "content": "user,risk\n{{.UserEmail}},{{.Risk}}\n"}]
+	}`}
+	data := map[string]interface{}{
+		"UserEmail": "jdoe@corp.example.com", "ManagerEmail": "", "UserName": `Jo <script>"x"</script>`,
+		"Risk": "high",
+	}
+
+	resp, err := executor.executeEmail(context.Background(), srv.integration("smtp"), def, data)
+	require.NoError(t, err)
+
+	var delivery emailDelivery
+	require.NoError(t, json.Unmarshal(resp, &delivery))
+	assert.Equal(t, []string{"jdoe@corp.example.com"}, delivery.Accepted)
+	require.Len(t, delivery.Rejected, 1)
+	assert.Equal(t, "nobody@invalid.example", delivery.Rejected[0].Address)
+	assert.Contains(t, delivery.Rejected[0].Error, "550")
+	assert.Equal(t, "250 2.0.0 Ok: queued as Q1", delivery.ServerReply)
+	assert.Equal(t, "alerts@corp.example.com", delivery.From)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, []string{"PLAIN"}, srv.authed)
+	require.Len(t, srv.messages, 1)
+	header, parts := readEmailParts(t, srv.messages[0])
+	subject, _ := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	assert.Equal(t, `Sign-in blocked for Jo <script>"x"</script>`, subject)
+	assert.Equal(t, delivery.MessageID, header.Get("Message-ID"))
+	assert.Equal(t, "<jdoe@corp.example.com>, <nobody@invalid.example>", header.Get("To"))
+	assert.Empty(t, header.Get("Bcc"))
+	assert.Contains(t, parts["text/plain"], "Hello Jo <script>\"x\"</script>,\nyour account")
+	assert.Equal(t, `<p>Hello Jo &lt;script&gt;&#34;x&#34;&lt;/script&gt;,</p><p>your account was locked.</p>`, parts["text/html"])
+	decoded, _ := base64.StdEncoding.DecodeString(parts["alert.csv"])
+	assert.Equal(t, "user,risk\njdoe@corp.example.com,high\n", string(decoded))
+}
+
+func TestExecuteEmail_MessageTemplateOverSMTPS(t *testing.T) {
+	srv := startSMTPServer(t, true, false)
+	executor := NewActionExecutor()
+	integ := srv.integration("smtps")
+	integ.AuthType = "login"
+	def := database.ActionDefinition{Name: "Notify manager", BodyTemplate: `{"to": ["Manager <{{.ManagerEmail}}>"]}`}
+	data := map[string]interface{}{
+		"ManagerEmail": "boss@corp.example.com",
+		"Title":        "Account locked",
+		"Message":      "jdoe was locked after a risky sign-in.",
+		"Footer":       "Security Operations",
+	}
+
+	_, err := executor.executeEmail(context.Background(), integ, def, data)
+	require.NoError(t, err)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, []string{"LOGIN"}, srv.authed)
+	assert.Equal(t, []string{"boss@corp.example.com"}, srv.rcpts)
+	header, parts := readEmailParts(t, srv.messages[0])
+	assert.Equal(t, "Account locked", header.Get("Subject"))
+	assert.Equal(t, "jdoe was locked after a risky sign-in.\n\nSecurity Operations", strings.TrimSpace(parts["text/plain"]))
+}
+
+func TestExecuteEmail_FailureModes(t *testing.T) {
+	executor := NewActionExecutor()
+	run := func(integ database.Integration, body string, data map[string]interface{}) error {
+		_, err := executor.executeEmail(context.Background(), integ, database.ActionDefinition{Name: "Notify", BodyTemplate: body}, data)
+		return err
+	}
+	body := `{"to": ["{{.UserEmail}}"], "subject": "Locked", "text": "Your account was locked."}`
+
+	// Header injection through an address is refused before connecting
+	srv := startSMTPServer(t, false, true)
+	err := run(srv.integration("smtp"), body, map[string]interface{}{"UserEmail": "a@corp.example.com\r\nBcc: evil@attacker.example"})
+	assert.ErrorContains(t, err, "invalid to address")
+
+	integ := srv.integration("smtp")
+	integ.Credentials = `{"username":"alerts@corp.example.com","password":"wrong"}`
+	err = run(integ, body, map[string]interface{}{"UserEmail": "jdoe@corp.example.com"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rejected the credentials")
+	assert.Equal(t, ErrorClassAuth, ClassifyError(0, err))
+
+	// Every recipient refused fails the step as a permanent client error
+	err = run(srv.integration("smtp"), body, map[string]interface{}{"UserEmail": "nobody@invalid.example"})
+	require.Error(t, err)
+	assert.Equal(t, ErrorClassClientError, ClassifyError(0, err))
+
+	// Credentials are never sent without TLS
+	plain := startSMTPServer(t, false, false)
+	err = run(plain.integration("smtp"), body, map[string]interface{}{"UserEmail": "jdoe@corp.example.com"})
+	assert.ErrorContains(t, err, "refusing to send credentials in clear text")
+	integ = plain.integration("smtp")
+	integ.AuthType, integ.StartTLS = "none", true
+	err = run(integ, body, map[string]interface{}{"UserEmail": "jdoe@corp.example.com"})
+	assert.ErrorContains(t, err, "does not offer STARTTLS")
+
+	integ = srv.integration("smtp")
+	integ.CACert = ""
+	err = run(integ, body, map[string]interface{}{"UserEmail": "jdoe@corp.example.com"})
+	assert.ErrorContains(t, err, "not signed by a trusted CA")
+
+	err = run(srv.integration("smtp"), `{"to": ["{{.UserEmail}}"]}`, map[string]interface{}{"UserEmail": "jdoe@corp.example.com"})
+	assert.ErrorContains(t, err, "no text or html body")
+}
+
+func TestHealthCheckSMTP(t *testing.T) {
+	srv := startSMTPServer(t, false, true)
+	executor := NewActionExecutor()
+	integ := srv.integration("smtp")
+	assert.NoError(t, executor.healthCheck(integ))
+
+	integ.Credentials = `{"username":"alerts@corp.example.com","password":"wrong"}`
+	assert.ErrorContains(t, executor.healthCheck(integ), "rejected the credentials")
+}
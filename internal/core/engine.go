@@ -1,21 +1,31 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
 	"sort"
+	"remediation-engine/internal/config"
 	"remediation-engine/internal/database"
+	"remediation-engine/internal/events"
 	"remediation-engine/internal/integrations"
+	"remediation-engine/internal/logging"
+	"remediation-engine/internal/metrics"
 	"remediation-engine/internal/security"
+	"remediation-engine/internal/tracing"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var GlobalEngine *Engine
 
+var engineLog = logging.For(logging.ComponentEngine)
+
 // PollingTask represents a unit of work for the worker pool
 type PollingTask struct {
     TenantID    uint
@@ -36,22 +46,33 @@ type Engine struct {
 
     // SyncMode forces synchronous execution for testing
     SyncMode bool
-    DebugMode bool
+
+    // probing guards against overlapping circuit breaker health probes
+    probing atomic.Bool
+
+    // Liveness state for /healthz and /readyz
+    lastTick    atomic.Int64 // UnixNano of the last loop iteration, 0 until Start
+    liveWorkers atomic.Int32
+    pollers     *pollerTracker
 }
 
 func NewEngine() *Engine {
+    cfg := config.Current().Engine
 	GlobalEngine = &Engine{
-        taskQueue:   make(chan PollingTask, 1000), // Buffered channel
-        workerCount: 20,                           // Default 20 workers
+        taskQueue:   make(chan PollingTask, cfg.QueueSize), // Buffered channel
+        workerCount: cfg.Workers,
 		lastRun:     make(map[uint]map[uint]time.Time),
-        DebugMode:   os.Getenv("DEBUG") == "true",
+        pollers:     newPollerTracker(),
 	}
+    queue := GlobalEngine.taskQueue
+    metrics.QueueDepth = func() float64 { return float64(len(queue)) }
     return GlobalEngine
 }
 
 func (e *Engine) Start() {
-	log.Printf("Starting Workflow Engine (Multi-Tenant Mode) with %d workers...", e.workerCount)
+	engineLog.Info("Starting workflow engine", "workers", e.workerCount)
     e.cleanupStaleJobs()
+    e.lastTick.Store(time.Now().UnixNano())
 
     // Start Workers
     for i := 0; i < e.workerCount; i++ {
@@ -59,30 +80,99 @@ func (e *Engine) Start() {
         go e.worker(i)
     }
 
-	ticker := time.NewTicker(10 * time.Second) // Check for work every 10s
-	retentionTicker := time.NewTicker(24 * time.Hour)
+	interval, retention := schedulerInterval(), retentionInterval()
+	ticker := time.NewTicker(interval)
+	retentionTicker := time.NewTicker(retention)
 	
 	for {
 		select {
 		case <-ticker.C:
+			e.lastTick.Store(time.Now().UnixNano())
 			e.schedulePollingTasks()
+			e.probeCircuits()
 		case <-retentionTicker.C:
 			e.runRetentionPolicy()
 		}
+
+		// Intervals are reloadable; pick up changes on the next tick
+		if next := schedulerInterval(); next != interval {
+			interval = next
+			ticker.Reset(interval)
+			engineLog.Info("Scheduler interval changed", "interval", interval)
+		}
+		if next := retentionInterval(); next != retention {
+			retention = next
+			retentionTicker.Reset(retention)
+			engineLog.Info("Retention interval changed", "interval", retention)
+		}
 	}
 }
 
+func retentionInterval() time.Duration {
+	return config.Current().Engine.RetentionInterval.Std()
+}
+
 func (e *Engine) cleanupStaleJobs() {
-    log.Println("[Engine] Cleaning up stale 'running' jobs from previous session...")
-    result := database.DB.Model(&database.Job{}).Where("status = ?", "running").Update("status", "failed")
+    engineLog.Info("Cleaning up in-progress jobs from previous session")
+    var stale []database.Job
+    if err := database.DB.Preload("Workflow").Where("status IN ?", database.ActiveJobStatuses()).Find(&stale).Error; err != nil {
+        engineLog.Error("Failed to query stale jobs", "error", err)
+        return
+    }
+
+    interrupted := 0
+    for i := range stale {
+        if e.transitionJob(&stale[i], database.JobStatusInterrupted, "system", "engine restarted while job was in progress") {
+            interrupted++
+        }
+    }
+    if interrupted > 0 {
+        engineLog.Warn("Marked stale jobs as interrupted", "jobs", interrupted)
+    }
+
+    // Rerun batches run in-process and do not survive a restart
+    result := database.DB.Model(&database.RerunBatch{}).Where("status = ?", database.RerunBatchRunning).
+        UpdateColumns(map[string]interface{}{"status": database.RerunBatchInterrupted, "completed_at": time.Now()})
     if result.RowsAffected > 0 {
-        log.Printf("[Engine] Marked %d stale jobs as failed.", result.RowsAffected)
+        engineLog.Warn("Marked unfinished rerun batches as interrupted", "batches", result.RowsAffected)
+    }
+}
+
+// probeCircuits runs health probes for open circuit breakers in the background
+func (e *Engine) probeCircuits() {
+    if !e.probing.CompareAndSwap(false, true) {
+        return
     }
+    go func() {
+        defer e.probing.Store(false)
+        NewActionExecutor().ProbeOpenCircuits()
+    }()
+}
+
+// transitionJob applies a lifecycle transition and logs (rather than propagates) failures.
+// Applied transitions are forwarded to the event sinks.
+func (e *Engine) transitionJob(job *database.Job, to string, actor string, reason string) bool {
+    from := job.Status
+    if err := database.TransitionJob(database.DB, job, to, actor, reason); err != nil {
+        engineLog.Warn("Job transition rejected", logging.FieldJobID, job.ID, logging.FieldTenantID, job.TenantID, "from", from, "to", to, "error", err)
+        return false
+    }
+    events.Emit(events.JobStatusChanged(job, from, actor, reason))
+    return true
+}
+
+// isJobCancelled re-reads the job status so cancellations from the API stop execution between steps
+func (e *Engine) isJobCancelled(jobID uint) bool {
+    var status string
+    database.DB.Model(&database.Job{}).Where("id = ?", jobID).Pluck("status", &status)
+    return status == database.JobStatusCancelled
 }
 
 // worker consumes tasks from the channel and executes polling
 func (e *Engine) worker(id int) {
     defer e.wg.Done()
+    e.liveWorkers.Add(1)
+    defer e.liveWorkers.Add(-1)
     for task := range e.taskQueue {
         e.pollAuthMind(task)
     }
@@ -93,7 +183,7 @@ func (e *Engine) schedulePollingTasks() {
     // 1. Fetch all tenants
     var tenants []database.Tenant
     if err := database.DB.Find(&tenants).Error; err != nil {
-        log.Printf("[Engine] Failed to fetch tenants: %v", err)
+        engineLog.Error("Failed to fetch tenants", "error", err)
         return
     }
 
@@ -142,6 +232,7 @@ func (e *Engine) scheduleForTenant(tenant database.Tenant) {
         lastRunTime, exists := e.lastRun[tenant.ID][poller.ID]
         if !exists || time.Since(lastRunTime) >= interval {
             e.lastRun[tenant.ID][poller.ID] = time.Now()
+            e.pollers.scheduled(tenant.ID, poller.ID, interval, time.Now())
             
             task := PollingTask{
                 TenantID:    tenant.ID,
@@ -154,11 +245,9 @@ func (e *Engine) scheduleForTenant(tenant database.Tenant) {
             } else {
                 select {
                 case e.taskQueue <- task:
-                    if e.DebugMode {
-                        log.Printf("[Engine] Added polling task for Tenant %d Poller %s to queue", tenant.ID, poller.Name)
-                    }
+                    engineLog.Debug("Queued polling task", logging.FieldTenantID, tenant.ID, logging.FieldIntegration, poller.Name)
                 default:
-                    log.Printf("[Engine] Task queue full, skipping poll for Tenant %d Poller %s", tenant.ID, poller.Name)
+                    engineLog.Warn("Task queue full, skipping poll", logging.FieldTenantID, tenant.ID, logging.FieldIntegration, poller.Name)
                 }
             }
         }
@@ -166,12 +255,25 @@ func (e *Engine) scheduleForTenant(tenant database.Tenant) {
 }
 
 func (e *Engine) pollAuthMind(task PollingTask) {
-	log.Printf("[Engine][Tenant:%d] Polling AuthMind via %s...", task.TenantID, task.Integration.Name)
+	ctx, span := tracing.Start(context.Background(), "pollAuthMind",
+		attribute.Int("tenant.id", int(task.TenantID)), attribute.String("integration.name", task.Integration.Name))
+	defer span.End()
+	ctx = logging.WithFields(ctx, logging.FieldTenantID, task.TenantID, logging.FieldIntegration, task.Integration.Name)
+	engineLog.InfoContext(ctx, "Polling AuthMind")
 	
 	var creds struct{ Token string `json:"token"` }
 	json.Unmarshal([]byte(task.Integration.Credentials), &creds)
 
 	sdk := integrations.NewAuthMindSDK(task.Integration.BaseURL, creds.Token)
+	if settings := transportSettings(task.Integration); !settings.IsZero() {
+		transport, err := integrations.TransportFor(task.Integration.ID, settings)
+		if err != nil {
+			engineLog.ErrorContext(ctx, "Invalid transport settings for AuthMind poller", "error", err)
+			tracing.RecordError(span, err)
+			return
+		}
+		sdk.Client.Transport = transport
+	}
 
 	// Check state for "last_id_tenant_{id}_integration_{id}"
 	stateKey := fmt.Sprintf("last_id_t%d_i%d", task.TenantID, task.Integration.ID)
@@ -182,18 +284,25 @@ func (e *Engine) pollAuthMind(task PollingTask) {
 	}
 
 	// Poll for EVERYTHING ("" for type) to be efficient
-	issues, err := sdk.GetIssues("", state.Value)
+	pollStart := time.Now()
+	issues, err := sdk.GetIssuesContext(ctx, "", state.Value)
+	e.pollers.polled(task.TenantID, task.Integration.ID, err, time.Now())
+	tenantLabel, pollerLabel := metrics.Tenant(task.TenantID), metrics.Integration(task.Integration.Name)
+	metrics.PollDuration.WithLabelValues(tenantLabel, pollerLabel, metrics.Outcome(err)).Observe(time.Since(pollStart).Seconds())
 	if err != nil {
-		log.Printf("[Engine][Tenant:%d] Failed to fetch issues from AuthMind: %v", task.TenantID, err)
+		engineLog.ErrorContext(ctx, "Failed to fetch issues from AuthMind", "error", err)
+		tracing.RecordError(span, err)
 		return
 	}
+	span.SetAttributes(attribute.Int("issues.fetched", len(issues)))
+	metrics.IssuesFetched.WithLabelValues(tenantLabel, pollerLabel).Add(float64(len(issues)))
 
 	if len(issues) == 0 {
-		// log.Printf("[Engine][Tenant:%d] No new issues found.", task.TenantID)
+		engineLog.DebugContext(ctx, "No new issues found")
 		return
 	}
 
-	log.Printf("[Engine][Tenant:%d] Fetched %d new issues from AuthMind (LastID: %s)", task.TenantID, len(issues), state.Value)
+	engineLog.InfoContext(ctx, "Fetched new issues from AuthMind", "issues", len(issues), "last_id", state.Value)
 
 	for _, issue := range issues {
 		issueIDStr := issue.IssueID
@@ -201,9 +310,7 @@ func (e *Engine) pollAuthMind(task PollingTask) {
 		// Initial event status
 		eventStatus := "no_workflow"
 
-		if e.DebugMode {
-			log.Printf("[Engine][Tenant:%d] Processing Issue %s (Type: %s, Severity: %d)", task.TenantID, issueIDStr, issue.IssueType, issue.Severity)
-		}
+		engineLog.DebugContext(ctx, "Processing issue", "issue_id", issueIDStr, "issue_type", issue.IssueType, "severity", issue.Severity)
     
     		userEmail := "Unknown"
     		if issue.IssueKeys != nil {
@@ -222,23 +329,18 @@ func (e *Engine) pollAuthMind(task PollingTask) {
     		        // Fallback: If integer severity is missing (0), try to derive from Risk string
     		        if issueSevScore == 0 && issue.Risk != "" {
     		            issueSevScore = e.severityStringToInt(issue.Risk)
-                        if e.DebugMode {
-    		                log.Printf("[Engine] Derived severity %d from Risk '%s'", issueSevScore, issue.Risk)
-                        }
+                        engineLog.DebugContext(ctx, "Derived severity from risk", "issue_id", issueIDStr, "severity", issueSevScore, "risk", issue.Risk)
     		        }
     		
     				for _, wf := range task.Workflows {    			// Check Name Match
                 if wf.Name != issue.IssueType && wf.Name != "All" {
-                    // log.Printf("[Engine] Skipping WF '%s' - Name mismatch", wf.Name)
                     continue
                 }
                 
                 // Check Severity
                 wfSevScore := e.severityStringToInt(wf.MinSeverity)
                 if issueSevScore > wfSevScore {
-                    if e.DebugMode {
-                        log.Printf("[Engine] Skipping WF '%s' - Severity too low (Issue: %d > WF: %d)", wf.Name, issueSevScore, wfSevScore)
-                    }
+                    engineLog.DebugContext(ctx, "Skipping workflow: severity too low", logging.FieldWorkflow, wf.Name, "issue_id", issueIDStr, "issue_severity", issueSevScore, "workflow_severity", wfSevScore)
                     if eventStatus == "no_workflow" {
                         eventStatus = "filtered_severity"
                     }
@@ -250,9 +352,9 @@ func (e *Engine) pollAuthMind(task PollingTask) {
     
     		if len(workflowsToRun) > 0 {
                 eventStatus = "triggered"
-    			details, err := sdk.GetIssueDetails(issueIDStr)
+    			details, err := sdk.GetIssueDetailsContext(ctx, issueIDStr)
     			if err != nil {
-    				log.Printf("[Engine] Warning: Failed to fetch details for issue %s: %v", issueIDStr, err)
+    				engineLog.WarnContext(ctx, "Failed to fetch issue details", "issue_id", issueIDStr, "error", err)
     				details = &integrations.IssueDetails{Results: []integrations.IssueDetailItem{{Message: "Details unavailable (API Error)", Risk: "Unknown"}}}
     			} else if details != nil && len(details.Results) > 0 {
                     // Enrichment: Try to improve fields if details are present
@@ -293,18 +395,15 @@ func (e *Engine) pollAuthMind(task PollingTask) {
     				"IssueType":     issue.IssueType,
     				"IssueKeys":     issue.IssueKeys,
     				"FirstSeen":     issue.IssueTime,
+    				"_ctx":          ctx, // Parent span for the workflow run; not persisted
     			}
     			
     			for _, runWf := range workflowsToRun {
-                    if e.DebugMode {
-                        log.Printf("[Engine] Tenant %d: Queuing execution for WF '%s' on Issue %s", task.TenantID, runWf.Name, issueIDStr)
-                    }
+                    engineLog.DebugContext(ctx, "Running workflow for issue", logging.FieldWorkflow, runWf.Name, "issue_id", issueIDStr)
     				e.RunWorkflow(runWf, contextData)
     			}
     		} else {
-                if e.DebugMode {
-                    log.Printf("[Engine] Tenant %d: No matching workflows found for Issue %s", task.TenantID, issueIDStr)
-                }
+                engineLog.DebugContext(ctx, "No matching workflows for issue", "issue_id", issueIDStr)
             }
 
             // Record processed event with final status (deduplicated)
@@ -332,43 +431,81 @@ func (e *Engine) pollAuthMind(task PollingTask) {
         default: return 4
         }
     }    
+    // RunWorkflow executes a workflow for a trigger context, creating and tracking a job
     func (e *Engine) RunWorkflow(wf database.Workflow, triggerContext map[string]interface{}) {
+        e.ExecuteWorkflow(wf, triggerContext)
+    }
+
+    // ExecuteWorkflow is RunWorkflow returning the job it ran, or nil if no job was created
+    func (e *Engine) ExecuteWorkflow(wf database.Workflow, triggerContext map[string]interface{}) *database.Job {
     	issueID := fmt.Sprintf("%v", triggerContext["IssueID"])
         tenantID := triggerContext["TenantID"].(uint)
     	
-    	contextJSON, _ := json.Marshal(triggerContext)
+    	contextJSON, _ := json.Marshal(persistedContext(triggerContext))
     
     	job := database.Job{
             TenantID:        tenantID,
     		WorkflowID:      wf.ID,
     		AuthMindIssueID: issueID,
-    		Status:          "running",
+    		Status:          database.JobStatusPending,
     		TriggerContext:  string(contextJSON),
     	}
+        if batchID, ok := triggerContext["RerunBatchID"].(uint); ok {
+            job.RerunBatchID = &batchID
+        }
     
+	parent, ok := triggerContext["_ctx"].(context.Context)
+	if !ok {
+		parent = context.Background()
+	}
+	parent = logging.WithFields(parent, logging.FieldTenantID, tenantID, logging.FieldWorkflow, wf.Name)
+
         var existing int64
         database.DB.Model(&database.Job{}).
             Where("tenant_id = ? AND workflow_id = ? AND auth_mind_issue_id = ?", tenantID, wf.ID, issueID).
             Count(&existing)
             
         if existing > 0 && triggerContext["ManualRerun"] != true {
-            if e.DebugMode {
-                log.Printf("[Engine] Job skipped: Duplicate execution for Tenant %d, WF %d, Issue %s", tenantID, wf.ID, issueID)
-            }
-            return
+            engineLog.DebugContext(parent, "Job skipped: duplicate execution", "issue_id", issueID)
+            return nil
         }
+
+	ctx, span := tracing.Start(parent, "RunWorkflow",
+		attribute.Int("tenant.id", int(tenantID)), attribute.String("workflow.name", wf.Name), attribute.String("issue.id", issueID))
+	defer span.End()
+	job.TraceID = tracing.TraceID(ctx)
+
 	if err := database.DB.Create(&job).Error; err != nil {
 		if triggerContext["ManualRerun"] == true {
-			job.AuthMindIssueID = fmt.Sprintf("%s-rerun-%d", issueID, time.Now().Unix())
-			database.DB.Create(&job)
+			// Nanosecond suffix: bulk reruns can start several reruns of the same issue within a second
+			job.AuthMindIssueID = fmt.Sprintf("%s-rerun-%d", issueID, time.Now().UnixNano())
+			if err := database.DB.Create(&job).Error; err != nil {
+				engineLog.ErrorContext(ctx, "Failed to create rerun job", "issue_id", issueID, "error", err)
+				tracing.RecordError(span, err)
+				return nil
+			}
 		} else {
-			return 
+			return nil
 		}
 	}
 
-    if e.DebugMode {
-	    log.Printf("[Tenant:%d][Workflow:%s] Executing Workflow for %v (Issue:%s) - Steps: %d", tenantID, wf.Name, triggerContext["UserEmail"], issueID, len(wf.Steps))
-    }
+	actor, reason := "engine", "triggered by AuthMind issue "+issueID
+	if triggerContext["ManualRerun"] == true {
+		reason = "manual rerun"
+		if by, ok := triggerContext["TriggeredBy"].(string); ok && by != "" {
+			actor = by
+		}
+	}
+	span.SetAttributes(attribute.Int("job.id", int(job.ID)))
+	ctx = logging.WithFields(ctx, logging.FieldJobID, job.ID)
+	job.Workflow = wf // For event sinks; not saved
+	database.RecordJobEvent(database.DB, job.ID, "", job.Status, actor, reason)
+	events.Emit(events.JobStatusChanged(&job, "", actor, reason))
+	if !e.transitionJob(&job, database.JobStatusRunning, "engine", "execution started") {
+		return &job
+	}
+
+    engineLog.DebugContext(ctx, "Executing workflow", "issue_id", issueID, "user", triggerContext["UserEmail"], "steps", len(wf.Steps))
 
 	lang := "en"
 	if val, ok := triggerContext["Language"].(string); ok {
@@ -398,6 +535,9 @@ func (e *Engine) pollAuthMind(task PollingTask) {
 
 	executor := NewExecutorFunc()
 	success := true
+	warnings := false
+	cancelled := false
+	stepOutputs := map[string]interface{}{} // Responses of completed steps, by action name
 
     // Ensure steps are executed in order
     sort.Slice(wf.Steps, func(i, j int) bool {
@@ -405,10 +545,17 @@ func (e *Engine) pollAuthMind(task PollingTask) {
     })
 
 	for _, step := range wf.Steps {
+		if e.isJobCancelled(job.ID) {
+			e.logToJob(job.ID, "WARN", "Job cancelled, remaining steps skipped")
+			cancelled = true
+			break
+		}
+
 		// 1. Fetch Action Definition (Scoped by Tenant)
 		var actionDef database.ActionDefinition
 		if err := database.DB.Where("id = ? AND tenant_id = ?", step.ActionDefinitionID, tenantID).First(&actionDef).Error; err != nil {
 			e.logToJob(job.ID, "ERROR", fmt.Sprintf("Failed to find action definition %d for tenant %d: %v", step.ActionDefinitionID, tenantID, err))
+			e.recordFailure(job.ID, 0, "", ErrorClassConfiguration)
 			success = false
 			break
 		}
@@ -417,12 +564,15 @@ func (e *Engine) pollAuthMind(task PollingTask) {
 		var integration database.Integration
 		if err := database.DB.Where("id = ? AND tenant_id = ?", actionDef.IntegrationID, tenantID).First(&integration).Error; err != nil {
 			e.logToJob(job.ID, "ERROR", fmt.Sprintf("Failed to find integration %d for tenant %d: %v", actionDef.IntegrationID, tenantID, err))
+			e.recordFailure(job.ID, actionDef.IntegrationID, actionDef.Name, ErrorClassConfiguration)
 			success = false
 			break
 		}
 
 		if !integration.Enabled {
 			e.logToJob(job.ID, "WARN", fmt.Sprintf("Integration %s is disabled, skipping step", integration.Name))
+			events.Emit(stepEvent(&job, actionDef.Name, integration.Name, events.OutcomeSkipped, 0, 0))
+			warnings = true
 			continue
 		}
 
@@ -443,35 +593,108 @@ func (e *Engine) pollAuthMind(task PollingTask) {
             contextData["RemediationURL"] = remediation.ReferenceURL
         }
 
+		contextData["Steps"] = stepOutputs
+
 		var stepParams map[string]interface{}
 		json.Unmarshal([]byte(step.ParameterMapping), &stepParams)
 		for k, v := range stepParams {
 			contextData[k] = v
 		}
 
+		stepStart := time.Now()
+		stepCtx, stepSpan := tracing.Start(ctx, "step "+actionDef.Name, attribute.Int("step.order", step.Order),
+			attribute.String("action.name", actionDef.Name), attribute.String("integration.name", integration.Name))
+		contextData["_ctx"] = logging.WithFields(stepCtx, logging.FieldStep, actionDef.Name, logging.FieldIntegration, integration.Name)
 		resp, code, err := executor.Execute(integration, actionDef, contextData)
+		tracing.End(stepSpan, err)
+		metrics.StepDuration.WithLabelValues(metrics.Tenant(tenantID), metrics.Integration(integration.Name), metrics.Action(actionDef.Name), metrics.Outcome(err)).
+			Observe(time.Since(stepStart).Seconds())
         redactedResp := security.Redact(string(resp))
+        queueWait, _ := contextData[queueWaitKey].(time.Duration)
+        queueNote := ""
+        if queueWait >= time.Millisecond {
+            queueNote = fmt.Sprintf(" after waiting %v for an integration slot", queueWait.Round(time.Millisecond))
+        }
 
 		if err != nil {
-            errMsg := fmt.Sprintf("Step %d (%s) failed (Status: %d): %v", step.Order, actionDef.Name, code, err)
-			e.logToJobStructured(job.ID, "ERROR", errMsg, actionDef.Name, code, redactedResp)
+            errMsg := fmt.Sprintf("Step %d (%s) failed (Status: %d)%s: %v", step.Order, actionDef.Name, code, queueNote, err)
+			e.logToJobStructured(job.ID, "ERROR", errMsg, actionDef.Name, code, redactedResp, queueWait)
+			errorClass := ClassifyError(code, err)
+			e.recordFailure(job.ID, integration.ID, actionDef.Name, errorClass)
+			ev := stepEvent(&job, actionDef.Name, integration.Name, events.OutcomeFailure, code, time.Since(stepStart))
+			ev.ErrorClass, ev.Error, ev.Response = errorClass, err.Error(), redactedResp
+			events.Emit(ev)
 			success = false
 			break
 		}
 		
         // Always log success for visibility
-        logMsg := fmt.Sprintf("Step %d (%s) completed successfully (Status: %d)", step.Order, actionDef.Name, code)
-        e.logToJobStructured(job.ID, "INFO", logMsg, actionDef.Name, code, redactedResp)
+        logMsg := fmt.Sprintf("Step %d (%s) completed successfully (Status: %d)%s", step.Order, actionDef.Name, code, queueNote)
+        e.logToJobStructured(job.ID, "INFO", logMsg, actionDef.Name, code, redactedResp, queueWait)
+		ev := stepEvent(&job, actionDef.Name, integration.Name, events.OutcomeSuccess, code, time.Since(stepStart))
+		ev.Response = redactedResp
+		events.Emit(ev)
+		stepOutputs[actionDef.Name] = stepOutput(resp)
+	}
+
+	if cancelled {
+		return &job
 	}
 
-	finalStatus := "completed"
+	finalStatus, finalReason := database.JobStatusSucceeded, "all steps completed"
+	if !success {
+		finalStatus, finalReason = database.JobStatusFailed, "step failed"
+	} else if warnings {
+		finalStatus, finalReason = database.JobStatusSucceededWithWarnings, "completed with skipped steps"
+	}
+	e.transitionJob(&job, finalStatus, "engine", finalReason)
+	span.SetAttributes(attribute.String("job.status", finalStatus))
 	if !success {
-		finalStatus = "failed"
+		tracing.RecordError(span, fmt.Errorf("job %d %s", job.ID, finalReason))
 	}
-	database.DB.Model(&job).Update("status", finalStatus)
+	return &job
+}
+
+// stepEvent describes a step result for the event sinks
+func stepEvent(job *database.Job, step, integration, outcome string, code int, duration time.Duration) events.Event {
+	ev := events.ForJob(job, events.TypeStepResult)
+	ev.Step, ev.Integration, ev.Outcome = step, integration, outcome
+	ev.StatusCode, ev.DurationMs = code, duration.Milliseconds()
+	return ev
+}
+
+// stepOutput is how a step's response is exposed to later steps, as in
+// {{index .Steps "Find SCIM User" "id"}}: parsed when it is JSON and text otherwise
+func stepOutput(resp []byte) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal(resp, &parsed); err == nil {
+		return parsed
+	}
+	return string(resp)
+}
+
+// persistedContext drops in-process values (keys starting with "_", such as the parent
+// span context) before the trigger context is stored on the job
+func persistedContext(triggerContext map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(triggerContext))
+	for k, v := range triggerContext {
+		if !strings.HasPrefix(k, "_") {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// recordFailure stores where a job broke so the dead-letter queue can group it
+func (e *Engine) recordFailure(jobID uint, integrationID uint, action string, errorClass string) {
+	database.DB.Model(&database.Job{}).Where("id = ?", jobID).UpdateColumns(map[string]interface{}{
+		"failed_integration_id": integrationID,
+		"failed_action":         action,
+		"error_class":           errorClass,
+	})
 }
 
-func (e *Engine) logToJobStructured(jobID uint, level string, msg string, stepName string, statusCode int, response string) {
+func (e *Engine) logToJobStructured(jobID uint, level string, msg string, stepName string, statusCode int, response string, queueWait time.Duration) {
 	database.DB.Create(&database.JobLog{
 		JobID:        jobID,
 		Timestamp:    time.Now(),
@@ -480,11 +703,12 @@ func (e *Engine) logToJobStructured(jobID uint, level string, msg string, stepNa
         StepName:     stepName,
         StatusCode:   statusCode,
         ResponseBody: response,
+        QueueWaitMs:  queueWait.Milliseconds(),
 	})
 }
 
 func (e *Engine) logToJob(jobID uint, level string, msg string) {
-    e.logToJobStructured(jobID, level, msg, "", 0, "")
+    e.logToJobStructured(jobID, level, msg, "", 0, "", 0)
 }
 
 func (e *Engine) runRetentionPolicy() {
@@ -499,10 +723,11 @@ func (e *Engine) runRetentionPolicy() {
 	}
 
 	cutoff := time.Now().AddDate(0, 0, -days)
-	log.Printf("[Retention] Running cleanup for data older than %d days (Cutoff: %v)...", days, cutoff)
+	engineLog.Info("Running retention cleanup", "days", days, "cutoff", cutoff)
 
 	database.DB.Exec("DELETE FROM job_logs WHERE job_id IN (SELECT id FROM jobs WHERE created_at < ?)", cutoff)
+	database.DB.Exec("DELETE FROM job_events WHERE job_id IN (SELECT id FROM jobs WHERE created_at < ?)", cutoff)
 	result := database.DB.Unscoped().Where("created_at < ?", cutoff).Delete(&database.Job{})
-	log.Printf("[Retention] Cleanup complete. Removed %d job records.", result.RowsAffected)
+	engineLog.Info("Retention cleanup complete", "jobs_removed", result.RowsAffected)
 	database.DB.Exec("VACUUM")
 }
@@ -2,14 +2,22 @@ package core
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"remediation-engine/internal/config"
 	"remediation-engine/internal/database"
+	"remediation-engine/internal/events"
 	"remediation-engine/internal/integrations"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // MockExecutor implements Executor interface
@@ -212,7 +220,7 @@ func TestRunWorkflow_Success(t *testing.T) {
 	// 4. Verify Job Status
 	var job database.Job
 	database.DB.First(&job, "workflow_id = ? AND auth_mind_issue_id = ?", wf.ID, "123")
-	assert.Equal(t, "completed", job.Status)
+	assert.Equal(t, database.JobStatusSucceeded, job.Status)
 }
 
 func TestRunWorkflow_Failure(t *testing.T) {
@@ -257,4 +265,154 @@ func TestRunWorkflow_Failure(t *testing.T) {
 	var job database.Job
 	database.DB.First(&job, "workflow_id = ? AND auth_mind_issue_id = ?", wf.ID, "999")
 	assert.Equal(t, "failed", job.Status)
-}
\ No newline at end of file
+
+	// Failure details feed the dead-letter queue
+	assert.Equal(t, integ.ID, job.FailedIntegrationID)
+	assert.Equal(t, "Test Action", job.FailedAction)
+	assert.Equal(t, ErrorClassServerError, job.ErrorClass)
+}
+// Responses of earlier steps are available to later ones, so IDs resolved by a lookup
+// can feed the next call
+func TestRunWorkflow_PassesStepOutputs(t *testing.T) {
+	setupTestDB()
+
+	var seen interface{}
+	originalFunc := NewExecutorFunc
+	defer func() { NewExecutorFunc = originalFunc }()
+	NewExecutorFunc = func() Executor {
+		return &MockExecutor{ExecuteFunc: func(integ database.Integration, def database.ActionDefinition, ctx map[string]interface{}) ([]byte, int, error) {
+			if def.Name == "Find User" {
+				return []byte(`{"id": "u1", "active": true}`), 200, nil
+			}
+			rendered, err := NewActionExecutor().renderTemplate(`{{index .Steps "Find User" "id"}}`, ctx)
+			seen = rendered
+			return nil, 204, err
+		}}
+	}
+
+	wf := database.Workflow{Name: "Chained Workflow", Enabled: true, TenantID: 1}
+	database.DB.Create(&wf)
+	integ := database.Integration{Name: "SCIM App", Enabled: true, TenantID: 1}
+	database.DB.Create(&integ)
+	for i, name := range []string{"Find User", "Deactivate User"} {
+		action := database.ActionDefinition{Name: name, IntegrationID: integ.ID, TenantID: 1}
+		database.DB.Create(&action)
+		database.DB.Create(&database.WorkflowStep{WorkflowID: wf.ID, ActionDefinitionID: action.ID, Order: i + 1, ParameterMapping: "{}"})
+	}
+
+	var fullWf database.Workflow
+	database.DB.Preload("Steps").First(&fullWf, wf.ID)
+	NewEngine().RunWorkflow(fullWf, map[string]interface{}{"TenantID": uint(1), "IssueID": "chain-1"})
+
+	assert.Equal(t, "u1", seen)
+}
+
+func TestRunWorkflow_RecordsJobEvents(t *testing.T) {
+	setupTestDB()
+
+	originalFunc := NewExecutorFunc
+	defer func() { NewExecutorFunc = originalFunc }()
+	NewExecutorFunc = func() Executor { return &MockExecutor{} }
+
+	wf := database.Workflow{Name: "Events Workflow", Enabled: true, TenantID: 1}
+	database.DB.Create(&wf)
+
+	disabled := database.Integration{Name: "Disabled Integ", TenantID: 1}
+	database.DB.Create(&disabled)
+	database.DB.Model(&disabled).Update("enabled", false)
+
+	action := database.ActionDefinition{Name: "Skipped Action", IntegrationID: disabled.ID, TenantID: 1}
+	database.DB.Create(&action)
+	database.DB.Create(&database.WorkflowStep{WorkflowID: wf.ID, ActionDefinitionID: action.ID, Order: 1, ParameterMapping: "{}"})
+
+	var fullWf database.Workflow
+	database.DB.Preload("Steps").First(&fullWf, wf.ID)
+
+	engine := NewEngine()
+	engine.RunWorkflow(fullWf, map[string]interface{}{"TenantID": uint(1), "IssueID": "evt-1"})
+
+	var job database.Job
+	database.DB.First(&job, "workflow_id = ? AND auth_mind_issue_id = ?", wf.ID, "evt-1")
+	assert.Equal(t, database.JobStatusSucceededWithWarnings, job.Status)
+
+	var events []database.JobEvent
+	database.DB.Where("job_id = ?", job.ID).Order("id").Find(&events)
+	var path []string
+	for _, ev := range events {
+		path = append(path, ev.ToStatus)
+	}
+	assert.Equal(t, []string{database.JobStatusPending, database.JobStatusRunning, database.JobStatusSucceededWithWarnings}, path)
+}
+
+func TestRunWorkflow_EmitsSIEMEvents(t *testing.T) {
+	setupTestDB()
+
+	originalFunc := NewExecutorFunc
+	defer func() { NewExecutorFunc = originalFunc }()
+	NewExecutorFunc = func() Executor {
+		return &MockExecutor{ExecuteFunc: func(integ database.Integration, def database.ActionDefinition, ctx map[string]interface{}) ([]byte, int, error) {
+			if def.Name == "Revoke Sessions" {
+				return []byte(`{"error": "invalid_token"}`), 401, &HTTPStatusError{StatusCode: 401}
+			}
+			return []byte(`{"ok": true}`), 200, nil
+		}}
+	}
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	stop, err := events.Init(config.EventsConfig{Sinks: []config.SinkConfig{{Name: "soc", Format: "json", Transport: "file", Path: path}}})
+	require.NoError(t, err)
+
+	wf := database.Workflow{Name: "SIEM Workflow", Enabled: true, TenantID: 1}
+	database.DB.Create(&wf)
+	integ := database.Integration{Name: "Okta", Enabled: true, TenantID: 1}
+	database.DB.Create(&integ)
+	for i, name := range []string{"Suspend User", "Revoke Sessions"} {
+		action := database.ActionDefinition{Name: name, IntegrationID: integ.ID, TenantID: 1}
+		database.DB.Create(&action)
+		database.DB.Create(&database.WorkflowStep{WorkflowID: wf.ID, ActionDefinitionID: action.ID, Order: i + 1, ParameterMapping: "{}"})
+	}
+	var fullWf database.Workflow
+	database.DB.Preload("Steps").First(&fullWf, wf.ID)
+	NewEngine().RunWorkflow(fullWf, map[string]interface{}{"TenantID": uint(1), "IssueID": "siem-1", "UserEmail": "jdoe@corp.example.com"})
+	require.NoError(t, stop(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var ev events.Event
+		require.NoError(t, json.Unmarshal([]byte(line), &ev))
+		assert.Equal(t, "SIEM Workflow", ev.Workflow)
+		assert.Equal(t, "jdoe@corp.example.com", ev.User)
+		got = append(got, ev.Type+" "+ev.Status+ev.Step+" "+ev.Outcome+ev.ErrorClass)
+	}
+	assert.Equal(t, []string{
+		"job.status pending ",
+		"job.status running ",
+		"step.result Suspend User success",
+		"step.result Revoke Sessions failureauth",
+		"job.status failed ",
+	}, got)
+}
+
+func TestEngine_CleanupStaleJobs(t *testing.T) {
+	setupTestDB()
+
+	wf := database.Workflow{Name: "Stale Workflow", TenantID: 1}
+	database.DB.Create(&wf)
+	running := database.Job{TenantID: 1, WorkflowID: wf.ID, AuthMindIssueID: "stale-1", Status: database.JobStatusRunning}
+	database.DB.Create(&running)
+	done := database.Job{TenantID: 1, WorkflowID: wf.ID, AuthMindIssueID: "stale-2", Status: database.JobStatusSucceeded}
+	database.DB.Create(&done)
+
+	NewEngine().cleanupStaleJobs()
+
+	database.DB.First(&running, running.ID)
+	database.DB.First(&done, done.ID)
+	assert.Equal(t, database.JobStatusInterrupted, running.Status)
+	assert.Equal(t, database.JobStatusSucceeded, done.Status)
+
+	var ev database.JobEvent
+	database.DB.Where("job_id = ?", running.ID).First(&ev)
+	assert.Equal(t, "system", ev.Actor)
+}
@@ -0,0 +1,673 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Action templates are escaped automatically: every {{...}} that prints a value gets an
+// escaper appended to its pipeline, chosen by the output format and by where the value lands.
+// For PowerShell scripts:
+//
+//	'{{.UserEmail}}'   single-quoted literal: quotes are doubled
+//	"{{.UserEmail}}"   expandable string: ` " and $ are backtick-escaped
+//	{{.UserEmail}}     bare: the value is emitted as a single-quoted literal
+//	# {{.UserEmail}}   comment: emitted as a literal; values with line breaks (or #> in
+//	                   <# ... #>) are rejected so they cannot end the comment
+//	@'...'@, @"..."@   here-strings: values may not start a line with the closing quote
+//	"... '{{.X}}' ..." single quotes nested in an expandable string, as in -Filter
+//	                   arguments: escaped for the string, and quotes are doubled
+//
+// Values inside a $(...) subexpression of an expandable string are rejected: the
+// subexpression is script, but the string's escaping would be applied.
+//
+// Request bodies are handled in body.go, SSH commands in shell.go and LDAP requests in ldap.go. {{raw .X}} opts a value out and is reported by
+// the linter.
+
+// psContext is the quoting state at a point in a PowerShell script
+type psContext int
+
+const (
+	psBare psContext = iota
+	psSingleQuoted
+	psDoubleQuoted
+	psLineComment
+	psBlockComment
+	psSingleHereString
+	psDoubleHereString
+	psDoubleNestedSingle     // '...' inside "...", which the string passes on as text
+	psDoubleHereNestedSingle // '...' inside @"..."@
+
+	// psSubexpression and above are inside $(...) in an expandable string; see subexpression
+	psSubexpression psContext = 16
+)
+
+// Quoting inside a subexpression
+const (
+	psSubUnquoted = iota
+	psSubSingleQuoted
+	psSubDoubleQuoted
+)
+
+// psExpandable lists the contexts that expand $(...); subexpressions record which one they
+// return to
+var psExpandable = []psContext{psDoubleQuoted, psDoubleHereString, psDoubleNestedSingle, psDoubleHereNestedSingle}
+
+// psNestedToggle maps an expandable string to its nested single-quoted section and back
+var psNestedToggle = map[psContext]psContext{
+	psDoubleQuoted:           psDoubleNestedSingle,
+	psDoubleNestedSingle:     psDoubleQuoted,
+	psDoubleHereString:       psDoubleHereNestedSingle,
+	psDoubleHereNestedSingle: psDoubleHereString,
+}
+
+// subexpression encodes the nesting depth (from 1) of the parentheses, the context it was
+// opened in and the quoting inside the subexpression
+func subexpression(depth int, parent psContext, quote int) psContext {
+	i := 0
+	for i < len(psExpandable)-1 && psExpandable[i] != parent {
+		i++
+	}
+	return psSubexpression + psContext((depth-1)<<4|i<<2|quote)
+}
+
+func (c psContext) subexpression() (depth int, parent psContext, quote int) {
+	v := int(c - psSubexpression)
+	return v>>4 + 1, psExpandable[v>>2&3], v & 3
+}
+
+func (c psContext) String() string {
+	switch {
+	case c == psSingleQuoted:
+		return "a single-quoted string"
+	case c == psDoubleQuoted:
+		return "a double-quoted string"
+	case c == psLineComment:
+		return "a comment"
+	case c == psBlockComment:
+		return "a block comment"
+	case c == psSingleHereString:
+		return "a single-quoted here-string"
+	case c == psDoubleHereString:
+		return "a double-quoted here-string"
+	case c == psDoubleNestedSingle:
+		return "a single-quoted section of a double-quoted string"
+	case c == psDoubleHereNestedSingle:
+		return "a single-quoted section of a double-quoted here-string"
+	case c >= psSubexpression:
+		return "a $(...) subexpression of an expandable string"
+	}
+	return "bare script"
+}
+
+// Escapers inserted by the auto-escaper
+const (
+	psSingleEscaper     = "_ps_single"
+	psDoubleEscaper     = "_ps_double"
+	psBareEscaper       = "_ps_bare"
+	psCommentEscaper    = "_ps_comment"
+	psSingleHereEscaper = "_ps_single_here"
+	psNestedEscaper     = "_ps_double_single"
+)
+
+// templateString renders a template value the way text/template prints it, with nil as ""
+func templateString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// PowerShell treats the typographic quotes as quote characters too
+func isPSSingleQuote(r rune) bool {
+	return r == '\'' || r == '‘' || r == '’' || r == '‚' || r == '‛'
+}
+
+func isPSDoubleQuote(r rune) bool {
+	return r == '"' || r == '“' || r == '”' || r == '„'
+}
+
+// psEscapeSingle escapes a value for use inside '...' by doubling every quote character
+func psEscapeSingle(value interface{}) string {
+	s := templateString(value)
+	var b strings.Builder
+	for _, r := range s {
+		if isPSSingleQuote(r) {
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// psEscapeDouble escapes a value for use inside "..." so nothing expands or ends the string
+func psEscapeDouble(value interface{}) string {
+	s := templateString(value)
+	var b strings.Builder
+	for _, r := range s {
+		if r == '`' || r == '$' || isPSDoubleQuote(r) {
+			b.WriteRune('`')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// psEscapeNested escapes a value for '...' nested in an expandable string. The string's
+// escaping keeps it from expanding or ending the string, and doubled quotes keep it inside
+// the single-quoted section when the text is parsed again, for example as an AD filter.
+func psEscapeNested(value interface{}) string {
+	s := psEscapeDouble(value)
+	var b strings.Builder
+	for _, r := range s {
+		if isPSSingleQuote(r) {
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// psQuote returns the value as a complete single-quoted PowerShell literal
+func psQuote(value interface{}) string {
+	return "'" + psEscapeSingle(value) + "'"
+}
+
+// psComment renders a value inside a comment as a single-quoted literal, so it stays inert
+// even if the text is not really a comment, and fails for values that could end the comment
+func psComment(value interface{}) (string, error) {
+	s := templateString(value)
+	if strings.ContainsAny(s, "\r\n\u0085\u2028\u2029") {
+		return "", fmt.Errorf("a value in a script comment cannot contain a line break")
+	}
+	if strings.Contains(s, "#>") {
+		return "", fmt.Errorf("a value in a script comment cannot contain #>")
+	}
+	return psQuote(s), nil
+}
+
+// psSingleHere checks a value for @'...'@, where nothing is escaped and only a line that
+// starts with '@ ends the string
+func psSingleHere(value interface{}) (string, error) {
+	s := templateString(value)
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		if r, size := utf8.DecodeRuneInString(line); isPSSingleQuote(r) && strings.HasPrefix(line[size:], "@") {
+			return "", fmt.Errorf("a value in a here-string cannot start a line with '@")
+		}
+	}
+	return s, nil
+}
+
+// samAccountName extracts and validates a pre-Windows 2000 logon name. It accepts
+// "jdoe", "CORP\jdoe" or "jdoe@corp.example.com" and fails the render for anything that
+// cannot be a sAMAccountName, so a crafted identity never reaches the script.
+func samAccountName(value interface{}) (string, error) {
+	name := strings.TrimSpace(templateString(value))
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+
+	switch {
+	case name == "":
+		return "", fmt.Errorf("samaccountname: empty account name")
+	case utf8.RuneCountInString(name) > 20:
+		return "", fmt.Errorf("samaccountname: %q is longer than 20 characters", name)
+	case strings.Trim(name, ". ") == "":
+		return "", fmt.Errorf("samaccountname: %q consists only of periods and spaces", name)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) || strings.ContainsRune(`"/\[]:;|=,+*?<>@`, r) {
+			return "", fmt.Errorf("samaccountname: %q contains the invalid character %q", name, r)
+		}
+	}
+	return name, nil
+}
+
+// templateLanguage describes how the auto-escaper follows one output format. States are
+// language specific; state 0 is where a template starts and should end.
+type templateLanguage interface {
+	// noun names the rendered output in lint messages ("script", "body")
+	noun() string
+	// describe names a state in lint messages
+	describe(state int) string
+	// scan returns the state after literal template text
+	scan(text []byte, state int) int
+	// escaper picks the escaper for an action printing in state whose pipeline ends in
+	// the function last. An empty name leaves the pipeline as it is.
+	escaper(state int, last string) (name string, warning string)
+}
+
+// placementChecker is implemented by languages with states where no escaper makes a value
+// safe. reject returns why a value cannot be printed in state, or "".
+type placementChecker interface {
+	reject(state int) string
+}
+
+// endChecker is implemented by languages where templates may end in states other than 0,
+// such as a trailing line comment
+type endChecker interface {
+	canEnd(state int) bool
+}
+
+// stateMerger is implemented by languages whose states carry details that may differ
+// between the branches of a block without changing the quoting. merge returns the state
+// to continue in, or false if the states conflict.
+type stateMerger interface {
+	merge(a, b int) (int, bool)
+}
+
+// autoEscapers are appended to template pipelines by the auto-escaper
+var autoEscapers = template.FuncMap{
+	psSingleEscaper:     psEscapeSingle,
+	psDoubleEscaper:     psEscapeDouble,
+	psBareEscaper:       psQuote,
+	psCommentEscaper:    psComment,
+	psSingleHereEscaper: psSingleHere,
+	psNestedEscaper:     psEscapeNested,
+
+	jsonStringEscaper: jsonEscapeString,
+	jsonValueEscaper:  jsonValue,
+	formEscaper:       formEscape,
+	xmlEscaper:        xmlEscape,
+	xmlCDATAEscaper:   xmlEscapeCDATA,
+
+	shSingleEscaper:  shEscapeSingle,
+	shDoubleEscaper:  shEscapeDouble,
+	shBareEscaper:    shQuote,
+	shCommentEscaper: shCommentValue,
+	shHereDocEscaper: shHereDocValue,
+	shLiteralEscaper: shHereDocLiteral,
+
+	ldapDNEscaper:     ldapDNString,
+	ldapFilterEscaper: ldapFilterString,
+
+	htmlStringEscaper: htmlString,
+
+	textEscaper: templateString,
+}
+
+// autoEscaper rewrites a parsed template in place and collects lint warnings
+type autoEscaper struct {
+	tree     *parse.Tree
+	lang     templateLanguage
+	warnings []string
+}
+
+// parseEscapedTemplate parses a template and adds the language's escapers to every action
+func parseEscapedTemplate(tplStr string, lang templateLanguage) (*template.Template, []string, error) {
+	tmpl, err := template.New("action").Funcs(templateFuncs()).Funcs(autoEscapers).Parse(tplStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return tmpl, nil, nil
+	}
+
+	esc := &autoEscaper{tree: tmpl.Tree, lang: lang}
+	end, err := esc.walk(tmpl.Tree.Root, 0)
+	if err != nil {
+		return nil, esc.warnings, err
+	}
+	if ender, ok := lang.(endChecker); end != 0 && !(ok && ender.canEnd(end)) {
+		esc.warn(tmpl.Tree.Root, fmt.Sprintf("%s ends inside %s", lang.noun(), lang.describe(end)))
+	}
+	return tmpl, esc.warnings, nil
+}
+
+// executeEscaped renders a template with auto-escaping; lint warnings are returned alongside
+// the output so the caller can log them
+func executeEscaped(tplStr string, lang templateLanguage, data interface{}) (string, []string, error) {
+	tmpl, warnings, err := parseEscapedTemplate(tplStr, lang)
+	if err != nil {
+		return "", warnings, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", warnings, err
+	}
+	return out.String(), warnings, nil
+}
+
+func (esc *autoEscaper) warn(node parse.Node, msg string) {
+	location, context := esc.tree.ErrorContext(node)
+	if context != "" {
+		msg = fmt.Sprintf("%s: %s: %s", location, context, msg)
+	} else {
+		msg = fmt.Sprintf("%s: %s", location, msg)
+	}
+	esc.warnings = append(esc.warnings, msg)
+}
+
+// walk escapes the actions in list, starting in state, and returns the state at its end
+func (esc *autoEscaper) walk(list *parse.ListNode, state int) (int, error) {
+	if list == nil {
+		return state, nil
+	}
+	for _, node := range list.Nodes {
+		var err error
+		switch n := node.(type) {
+		case *parse.TextNode:
+			state = esc.lang.scan(n.Text, state)
+		case *parse.ActionNode:
+			err = esc.escapeAction(n, state)
+		case *parse.IfNode:
+			state, err = esc.walkBranch(&n.BranchNode, state, "if")
+		case *parse.WithNode:
+			state, err = esc.walkBranch(&n.BranchNode, state, "with")
+		case *parse.RangeNode:
+			state, err = esc.walkBranch(&n.BranchNode, state, "range")
+		case *parse.TemplateNode:
+			err = fmt.Errorf("{{template %q}} is not supported in auto-escaped templates", n.Name)
+		}
+		if err != nil {
+			return state, err
+		}
+	}
+	return state, nil
+}
+
+// walkBranch requires all branches of a block to end in the same state; otherwise the
+// state after the block would depend on the data
+func (esc *autoEscaper) walkBranch(n *parse.BranchNode, state int, kind string) (int, error) {
+	end, err := esc.walk(n.List, state)
+	if err != nil {
+		return state, err
+	}
+	// The body of a range may run again, so it must end in a state it was escaped for
+	if merged, ok := esc.merge(state, end); kind == "range" && (!ok || merged != state) {
+		return state, fmt.Errorf("{{range}} body changes quoting from %s to %s", esc.lang.describe(state), esc.lang.describe(end))
+	}
+	elseEnd, err := esc.walk(n.ElseList, state)
+	if err != nil {
+		return state, err
+	}
+	merged, ok := esc.merge(end, elseEnd)
+	if !ok {
+		return state, fmt.Errorf("{{%s}} branches end in different quoting states (%s and %s)", kind, esc.lang.describe(end), esc.lang.describe(elseEnd))
+	}
+	return merged, nil
+}
+
+// merge combines the states at the end of two branches
+func (esc *autoEscaper) merge(a, b int) (int, bool) {
+	if a == b {
+		return a, true
+	}
+	if merger, ok := esc.lang.(stateMerger); ok {
+		return merger.merge(a, b)
+	}
+	return a, false
+}
+
+// escapeAction appends the escaper chosen by the language to a printing action. Values in
+// places the language rejects are an error, even with raw.
+func (esc *autoEscaper) escapeAction(n *parse.ActionNode, state int) error {
+	pipe := n.Pipe
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+		return nil // Assignments print nothing
+	}
+
+	if checker, ok := esc.lang.(placementChecker); ok {
+		if reason := checker.reject(state); reason != "" {
+			location, _ := esc.tree.ErrorContext(n)
+			return fmt.Errorf("%s: %s", location, reason)
+		}
+	}
+	last := lastFunc(pipe)
+	if last == "raw" {
+		esc.warn(n, "raw value reaches the "+esc.lang.noun()+" unescaped")
+		return nil
+	}
+	name, warning := esc.lang.escaper(state, last)
+	if warning != "" {
+		esc.warn(n, warning)
+	}
+	if name == "" {
+		return nil
+	}
+	pipe.Cmds = append(pipe.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      pipe.Position(),
+		Args:     []parse.Node{parse.NewIdentifier(name).SetTree(nil).SetPos(pipe.Position())},
+	})
+	return nil
+}
+
+// lastFunc returns the function called by the last command of a pipeline, if any
+func lastFunc(pipe *parse.PipeNode) string {
+	cmd := pipe.Cmds[len(pipe.Cmds)-1]
+	if len(cmd.Args) == 0 {
+		return ""
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		return ident.Ident
+	}
+	return ""
+}
+
+// powerShell is the templateLanguage of WinRM scripts
+type powerShell struct{}
+
+func (powerShell) noun() string { return "script" }
+
+func (powerShell) describe(state int) string { return psContext(state).String() }
+
+func (powerShell) scan(text []byte, state int) int {
+	return int(scanPowerShell(text, psContext(state)))
+}
+
+func (powerShell) escaper(state int, last string) (string, string) {
+	ctx := psContext(state)
+	var warning string
+	switch last {
+	case "psquote":
+		if ctx == psBare {
+			return "", ""
+		}
+		warning = "psquote used inside " + ctx.String() + "; the value will carry extra quotes"
+	case "psescape":
+		if ctx == psSingleQuoted {
+			return "", ""
+		}
+		warning = "psescape only escapes for single-quoted strings; the value is in " + ctx.String()
+	}
+
+	switch ctx {
+	case psSingleQuoted:
+		return psSingleEscaper, warning
+	case psDoubleQuoted, psDoubleHereString:
+		return psDoubleEscaper, warning
+	case psLineComment, psBlockComment:
+		return psCommentEscaper, warning
+	case psSingleHereString:
+		return psSingleHereEscaper, warning
+	case psDoubleNestedSingle, psDoubleHereNestedSingle:
+		return psNestedEscaper, warning
+	}
+	return psBareEscaper, warning
+}
+
+func (powerShell) reject(state int) string {
+	if psContext(state) >= psSubexpression {
+		return "a value cannot be placed inside a $(...) subexpression of an expandable string; assign it to a variable before the string"
+	}
+	return ""
+}
+
+// A script may end in a line comment
+func (powerShell) canEnd(state int) bool {
+	return psContext(state) == psLineComment
+}
+
+// LintPowerShellTemplate reports values that reach a script without escaping and other
+// quoting mistakes. An error means the template cannot be rendered at all.
+func LintPowerShellTemplate(tplStr string) ([]string, error) {
+	_, warnings, err := parseEscapedTemplate(tplStr, powerShell{})
+	return warnings, err
+}
+
+// renderPowerShell renders a script template with auto-escaping; lint warnings are returned
+// alongside the script so the caller can log them
+func (e *ActionExecutor) renderPowerShell(tplStr string, data interface{}) (string, []string, error) {
+	return executeEscaped(tplStr, powerShell{}, data)
+}
+
+// scanPowerShell tracks strings, comments and subexpressions through literal script text.
+// Doubled quotes inside a string and backtick escapes do not end the string.
+func scanPowerShell(text []byte, ctx psContext) psContext {
+	runes := []rune(string(text))
+	next := func(i int) rune {
+		if i+1 < len(runes) {
+			return runes[i+1]
+		}
+		return 0
+	}
+	// hereStringEnds reports whether the line break at i is followed by the closing quote
+	hereStringEnds := func(i int, isQuote func(rune) bool) bool {
+		return runes[i] == '\n' && i+2 < len(runes) && isQuote(runes[i+1]) && runes[i+2] == '@'
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case ctx == psBare:
+			switch {
+			case r == '`':
+				i++
+			case r == '<' && next(i) == '#':
+				ctx, i = psBlockComment, i+1
+			case r == '#' && psTokenStart(runes, i):
+				ctx = psLineComment
+			case r == '@' && (isPSSingleQuote(next(i)) || isPSDoubleQuote(next(i))):
+				if end := psHereStringOpener(runes, i+2); end >= 0 {
+					ctx = psDoubleHereString
+					if isPSSingleQuote(next(i)) {
+						ctx = psSingleHereString
+					}
+					i = end - 1 // The line break is scanned as part of the here-string
+				}
+			case isPSSingleQuote(r):
+				ctx = psSingleQuoted
+			case isPSDoubleQuote(r):
+				ctx = psDoubleQuoted
+			}
+		case ctx == psLineComment:
+			if r == '\n' || r == '\r' {
+				ctx = psBare
+			}
+		case ctx == psBlockComment:
+			if r == '#' && next(i) == '>' {
+				ctx, i = psBare, i+1
+			}
+		case ctx == psSingleQuoted:
+			if isPSSingleQuote(r) {
+				if isPSSingleQuote(next(i)) {
+					i++
+				} else {
+					ctx = psBare
+				}
+			}
+		case ctx == psSingleHereString:
+			if hereStringEnds(i, isPSSingleQuote) {
+				ctx, i = psBare, i+2
+			}
+		case ctx < psSubexpression:
+			// Expandable strings; single quotes in them are text, but open a nested section
+			// for values, which are often parsed again as a filter or query
+			hereString := ctx == psDoubleHereString || ctx == psDoubleHereNestedSingle
+			nested := ctx == psDoubleNestedSingle || ctx == psDoubleHereNestedSingle
+			switch {
+			case r == '`':
+				i++
+			case r == '$' && next(i) == '(':
+				ctx, i = subexpression(1, ctx, psSubUnquoted), i+1
+			case hereString && hereStringEnds(i, isPSDoubleQuote):
+				ctx, i = psBare, i+2
+			case !hereString && isPSDoubleQuote(r):
+				if isPSDoubleQuote(next(i)) {
+					i++
+				} else {
+					ctx = psBare
+				}
+			case isPSSingleQuote(r) && nested && isPSSingleQuote(next(i)):
+				i++
+			case isPSSingleQuote(r):
+				ctx = psNestedToggle[ctx]
+			}
+		default:
+			depth, parent, quote := ctx.subexpression()
+			switch quote {
+			case psSubSingleQuoted:
+				if isPSSingleQuote(r) {
+					if isPSSingleQuote(next(i)) {
+						i++
+					} else {
+						quote = psSubUnquoted
+					}
+				}
+			case psSubDoubleQuoted:
+				if r == '`' {
+					i++
+				} else if isPSDoubleQuote(r) {
+					if isPSDoubleQuote(next(i)) {
+						i++
+					} else {
+						quote = psSubUnquoted
+					}
+				}
+			default:
+				switch {
+				case r == '`':
+					i++
+				case isPSSingleQuote(r):
+					quote = psSubSingleQuoted
+				case isPSDoubleQuote(r):
+					quote = psSubDoubleQuoted
+				case r == '(':
+					depth++
+				case r == ')':
+					depth--
+				}
+			}
+			ctx = parent
+			if depth > 0 {
+				ctx = subexpression(depth, parent, quote)
+			}
+		}
+	}
+	return ctx
+}
+
+// psTokenStart reports whether the rune at i starts a token, where # begins a comment. A
+// # inside a word, as in a#b, does not. Text after a value starts a token, since bare
+// values are emitted as complete literals.
+func psTokenStart(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := runes[i-1]
+	return unicode.IsSpace(prev) || strings.ContainsRune(";(){}|&,=", prev) || isPSSingleQuote(prev) || isPSDoubleQuote(prev)
+}
+
+// psHereStringOpener returns the index of the line break that must follow @' or @" (after
+// optional blanks), or -1 if there is none
+func psHereStringOpener(runes []rune, i int) int {
+	for ; i < len(runes); i++ {
+		switch runes[i] {
+		case '\n', '\r':
+			return i
+		case ' ', '\t':
+		default:
+			return -1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderPowerShell_EscapesByContext(t *testing.T) {
+	executor := NewActionExecutor()
+	data := map[string]interface{}{"UserEmail": "x'; Remove-Item C:\\ -Recurse; '"}
+
+	cases := []struct {
+		tpl  string
+		want string
+	}{
+		{`Disable-ADAccount -Identity '{{.UserEmail}}'`, `Disable-ADAccount -Identity 'x''; Remove-Item C:\ -Recurse; '''`},
+		{`Write-Output "{{.UserEmail}}"`, `Write-Output "x'; Remove-Item C:\ -Recurse; '"`},
+		{`Disable-ADAccount -Identity {{.UserEmail}}`, `Disable-ADAccount -Identity 'x''; Remove-Item C:\ -Recurse; '''`},
+		{`Disable-ADAccount -Identity {{psquote .UserEmail}}`, `Disable-ADAccount -Identity 'x''; Remove-Item C:\ -Recurse; '''`},
+		// Quotes already closed by the template text are tracked
+		{`$a = 'it''s'; $b = '{{.UserEmail}}'`, `$a = 'it''s'; $b = 'x''; Remove-Item C:\ -Recurse; '''`},
+	}
+	for _, c := range cases {
+		got, warnings, err := executor.renderPowerShell(c.tpl, data)
+		require.NoError(t, err, c.tpl)
+		assert.Empty(t, warnings, c.tpl)
+		assert.Equal(t, c.want, got, c.tpl)
+	}
+
+	got, _, err := executor.renderPowerShell(`Write-Output "{{.V}}"`, map[string]interface{}{"V": "$(Stop-Computer) `\" "})
+	require.NoError(t, err)
+	assert.Equal(t, "Write-Output \"`$(Stop-Computer) ```\" \"", got)
+}
+
+func TestRenderPowerShell_SamAccountName(t *testing.T) {
+	executor := NewActionExecutor()
+	tpl := `Disable-ADAccount -Identity '{{samaccountname .UserEmail}}'`
+
+	for in, want := range map[string]string{
+		"jdoe@corp.example.com": "jdoe",
+		`CORP\jdoe`:             "jdoe",
+		"o'brien":               "o''brien",
+	} {
+		got, _, err := executor.renderPowerShell(tpl, map[string]interface{}{"UserEmail": in})
+		require.NoError(t, err, in)
+		assert.Equal(t, "Disable-ADAccount -Identity '"+want+"'", got, in)
+	}
+
+	for _, bad := range []string{"", "a;b", "j|doe", "x*", "...", "averyveryverylongaccountname"} {
+		_, _, err := executor.renderPowerShell(tpl, map[string]interface{}{"UserEmail": bad})
+		assert.ErrorContains(t, err, "samaccountname", bad)
+	}
+}
+
+func TestLintPowerShellTemplate(t *testing.T) {
+	warnings, err := LintPowerShellTemplate(`Set-ADUser -Identity '{{.UserEmail}}' -ChangePasswordAtLogon $true`)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	warnings, err = LintPowerShellTemplate(`Invoke-Expression {{raw .Script}}`)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "raw value reaches the script unescaped")
+
+	warnings, err = LintPowerShellTemplate(`Write-Output "{{psquote .X}}"; Write-Output "{{psescape .X}}"`)
+	require.NoError(t, err)
+	assert.Len(t, warnings, 2)
+
+	warnings, err = LintPowerShellTemplate(`Write-Output '{{.X}}`)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "script ends inside a single-quoted string")
+
+	_, err = LintPowerShellTemplate(`Set-ADUser {{if .X}}'{{end}}`)
+	assert.ErrorContains(t, err, "different quoting states")
+
+	_, err = LintPowerShellTemplate(`{{define "x"}}y{{end}}{{template "x"}}`)
+	assert.ErrorContains(t, err, "not supported")
+}
+
+func TestRenderPowerShell_CommentsAndHereStrings(t *testing.T) {
+	executor := NewActionExecutor()
+
+	// A line break in a commented value would start a live command on the next line
+	tpl := "# disabling {{.U}}\nDisable-ADAccount -Identity {{.U}}"
+	_, _, err := executor.renderPowerShell(tpl, map[string]interface{}{"U": "x\nRemove-ADUser admin #"})
+	assert.ErrorContains(t, err, "cannot contain a line break")
+	got, warnings, err := executor.renderPowerShell(tpl, map[string]interface{}{"U": "jdoe"})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, "# disabling 'jdoe'\nDisable-ADAccount -Identity 'jdoe'", got)
+
+	_, _, err = executor.renderPowerShell("<# disabling {{.U}} #>\nDisable-ADAccount -Identity {{.U}}", map[string]interface{}{"U": "x #> Remove-ADUser admin <#"})
+	assert.ErrorContains(t, err, "cannot contain #>")
+
+	// A # inside a word is not a comment, and a comment may end the script
+	got, _, err = executor.renderPowerShell("Get-Item a#b {{.U}} # for {{.U}}", map[string]interface{}{"U": "jdoe"})
+	require.NoError(t, err)
+	assert.Equal(t, "Get-Item a#b 'jdoe' # for 'jdoe'", got)
+
+	single := "$body = @'\nuser: {{.U}}\n'@\nSet-Content notes.txt $body {{.U}}"
+	got, _, err = executor.renderPowerShell(single, map[string]interface{}{"U": "o'brien $(x)"})
+	require.NoError(t, err)
+	assert.Equal(t, "$body = @'\nuser: o'brien $(x)\n'@\nSet-Content notes.txt $body 'o''brien $(x)'", got)
+	_, _, err = executor.renderPowerShell(single, map[string]interface{}{"U": "x\n'@\nRemove-ADUser admin\n@'"})
+	assert.ErrorContains(t, err, "cannot start a line with '@")
+
+	got, _, err = executor.renderPowerShell("$body = @\"\nuser: {{.U}}\n\"@\n{{.U}}", map[string]interface{}{"U": "x\n\"@\n$(Stop-Computer)"})
+	require.NoError(t, err)
+	assert.Equal(t, "$body = @\"\nuser: x\n`\"@\n`$(Stop-Computer)\n\"@\n'x\n\"@\n$(Stop-Computer)'", got)
+}
+
+func TestLintPowerShellTemplate_Subexpressions(t *testing.T) {
+	for _, tpl := range []string{
+		`Write-Output "$( {{.U}} )"`,
+		`Write-Output "$(Get-Name ({{.U}}))"`,
+		`Write-Output "$(Get-Name ')' {{.U}})"`,
+		"$body = @\"\n$(Get-User {{.U}})\n\"@",
+	} {
+		_, err := LintPowerShellTemplate(tpl)
+		assert.ErrorContains(t, err, "cannot be placed inside a $(...) subexpression", tpl)
+	}
+
+	// Values after the subexpression closes are back in the string
+	warnings, err := LintPowerShellTemplate(`Write-Output "$(Get-Date) {{.U}}"; Write-Output {{.U}}`)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	got, _, err := NewActionExecutor().renderPowerShell(`Write-Output "$((1 + 2)) {{.U}}"`, map[string]interface{}{"U": "$x"})
+	require.NoError(t, err)
+	assert.Equal(t, "Write-Output \"$((1 + 2)) `$x\"", got)
+
+	// Escaped $ does not start a subexpression
+	_, err = LintPowerShellTemplate("Write-Output \"`$( {{.U}} )\"")
+	assert.NoError(t, err)
+
+	// raw does not bypass the check
+	_, err = LintPowerShellTemplate(`Write-Output "$( {{raw .U}} )"`)
+	assert.Error(t, err)
+}
+
+func TestRenderPowerShell_QuotesNestedInExpandableStrings(t *testing.T) {
+	executor := NewActionExecutor()
+	data := map[string]interface{}{"U": "x' -or Name -like '*"}
+
+	// The filter string is parsed again by the AD cmdlet, so quotes must stay doubled
+	got, warnings, err := executor.renderPowerShell(`Get-ADUser -Filter "SamAccountName -eq '{{.U}}'"`, data)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, `Get-ADUser -Filter "SamAccountName -eq 'x'' -or Name -like ''*'"`, got)
+
+	// Every single-quote character PowerShell accepts is doubled, on top of the string's escaping
+	got, _, err = executor.renderPowerShell(`Get-ADUser -Filter "Name -eq '{{.U}}'"`, map[string]interface{}{"U": "a‘b’c‚d‛e\"$f"})
+	require.NoError(t, err)
+	assert.Equal(t, "Get-ADUser -Filter \"Name -eq 'a‘‘b’’c‚‚d‛‛e`\"`$f'\"", got)
+
+	// Doubled quotes in the text and closed sections are tracked
+	got, _, err = executor.renderPowerShell(`Write-Output "it''s '$(Get-Date)' {{.U}}"`, data)
+	require.NoError(t, err)
+	assert.Equal(t, `Write-Output "it''s '$(Get-Date)' x' -or Name -like '*"`, got)
+
+	got, _, err = executor.renderPowerShell("$f = @\"\nName -eq '{{.U}}'\n\"@\nGet-ADUser -Filter $f", data)
+	require.NoError(t, err)
+	assert.Equal(t, "$f = @\"\nName -eq 'x'' -or Name -like ''*'\n\"@\nGet-ADUser -Filter $f", got)
+
+	// The string ends at its closing quote even inside a nested section
+	got, _, err = executor.renderPowerShell(`Write-Output "it's" {{.U}}`, data)
+	require.NoError(t, err)
+	assert.Equal(t, `Write-Output "it's" 'x'' -or Name -like ''*'`, got)
+
+	_, err = LintPowerShellTemplate(`Get-ADUser -Filter "Name -eq '$( {{.U}} )'"`)
+	assert.ErrorContains(t, err, "cannot be placed inside a $(...) subexpression")
+}
@@ -6,28 +6,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"net/url"
-	"os"
 	"remediation-engine/internal/database"
 	"remediation-engine/internal/integrations"
+	"remediation-engine/internal/logging"
+	"remediation-engine/internal/metrics"
     "remediation-engine/internal/security"
-	"strconv"
+	"remediation-engine/internal/tracing"
 	"strings"
-	"sync"
 	"text/template"
 	"time"
 
-	"golang.org/x/time/rate"
-	"github.com/masterzen/winrm"
-	"gorm.io/gorm"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-var (
-	limiters   = make(map[uint]*rate.Limiter)
-	limitersMu sync.Mutex
-)
+var executorLog = logging.For(logging.ComponentExecutor)
 
 // Executor interface for mocking
 type Executor interface {
@@ -40,217 +33,211 @@ var NewExecutorFunc = func() Executor {
 }
 
 type ActionExecutor struct {
-	Client    *http.Client
-	DebugMode bool
+	// Client makes HTTP calls. Its Timeout is left at zero so executor.http_timeout applies.
+	Client *http.Client
+
+	// sleep and now are swapped in tests to avoid real backoff delays
+	sleep func(context.Context, time.Duration) error
+	now   func() time.Time
 }
 
 func NewActionExecutor() *ActionExecutor {
 	return &ActionExecutor{
-		Client:    &http.Client{Timeout: 15 * time.Second},
-		DebugMode: os.Getenv("DEBUG") == "true",
+		Client: &http.Client{},
+		sleep:  sleepContext,
+		now:    time.Now,
 	}
 }
 
-// Execute performs a generic action (REST or WINRM) based on a definition and context data
+// Execute performs a generic action (REST, SCIM, WINRM, SSH or LDAP) based on a definition and context data
 func (e *ActionExecutor) Execute(integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, int, error) {
-	if !integration.IsAvailable {
-		return nil, 0, fmt.Errorf("integration %s is currently unavailable (circuit breaker tripped)", integration.Name)
+	probe, err := e.acquireCircuit(integration)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// 0. Handle Rate Limiting
-	if integration.RateLimit > 0 {
-		limiter := e.getLimiter(integration.ID, integration.RateLimit)
-		if e.DebugMode {
-			log.Printf("[Executor] Throttling enabled for %s (%.1f req/sec).", integration.Name, integration.RateLimit)
-		}
-
-		ctx := context.Background() // Default context
-		if val, ok := contextData["_ctx"].(context.Context); ok {
-			ctx = val
-		}
-
-		if err := limiter.Wait(ctx); err != nil {
-			return nil, 0, fmt.Errorf("rate limit wait failed: %v", err)
-		}
+	ctx := context.Background() // Default context
+	if val, ok := contextData["_ctx"].(context.Context); ok {
+		ctx = val
 	}
+	limiter := e.getLimiter(integration.ID, integration.RateLimit)
+	sem := e.getSemaphore(integration.ID, integration.MaxConcurrency)
 
 	maxRetries := definition.RetryCount
 	if maxRetries <= 0 {
 		maxRetries = 3 // Default retries
 	}
+	if probe {
+		maxRetries = 0 // A half-open breaker only lets a single trial request through
+	}
+
+	policy := resolveRetryPolicy(definition.RetryPolicy)
+	start := e.now()
 
 	var lastErr error
 	var resp []byte
 	var code int
+	var queueWait time.Duration
+	attempts := 0
+
+	for {
+		attemptCtx, attemptSpan := tracing.Start(ctx, "Execute attempt", attribute.Int("attempt", attempts+1),
+			attribute.String("integration.name", integration.Name), attribute.String("action.name", definition.Name))
+
+		// 0. Handle Rate Limiting (configured rate, lowered while the vendor is throttling us)
+		_, waitSpan := tracing.Start(attemptCtx, "rate limit wait")
+		waited, err := limiter.wait(ctx, e.now(), e.sleep)
+		waitSpan.SetAttributes(attribute.Int64("wait.ms", waited.Milliseconds()))
+		tracing.End(waitSpan, err)
+		if err != nil {
+			tracing.End(attemptSpan, err)
+			return resp, code, fmt.Errorf("rate limit wait failed: %v", err)
+		}
+		metrics.RateLimitWait.WithLabelValues(metrics.Tenant(integration.TenantID), metrics.Integration(integration.Name)).Observe(waited.Seconds())
+		if waited > 0 {
+			effective, _ := limiter.snapshot()
+			executorLog.DebugContext(ctx, "Throttled by rate limiter", logging.FieldIntegration, integration.Name, "waited", waited, "effective_rate", formatRate(effective))
+		}
 
-	for i := 0; i <= maxRetries; i++ {
-		if i > 0 {
-			// Exponential backoff: 1s, 2s, 4s...
-			backoff := time.Duration(1<<uint(i-1)) * time.Second
-			if e.DebugMode {
-				log.Printf("[Executor] Retrying action %s (attempt %d/%d) after %v...", definition.Name, i, maxRetries, backoff)
-			}
-			time.Sleep(backoff)
+		// Cap in-flight calls to the integration across all workers; the slot is not held during backoff
+		_, slotSpan := tracing.Start(attemptCtx, "concurrency slot wait")
+		release, queued, err := acquireSlot(ctx, sem)
+		tracing.End(slotSpan, err)
+		queueWait += queued
+		contextData[queueWaitKey] = queueWait
+		if err != nil {
+			tracing.End(attemptSpan, err)
+			return resp, code, fmt.Errorf("concurrency slot wait failed: %v", err)
+		}
+		if queued > time.Millisecond {
+			executorLog.DebugContext(ctx, "Waited for a concurrency slot", logging.FieldIntegration, integration.Name, "waited", queued, "max_concurrency", integration.MaxConcurrency)
 		}
 
+		attempts++
 		if strings.ToUpper(integration.Type) == "WINRM" {
-			resp, lastErr = e.executeWinRM(integration, definition, contextData)
+			resp, lastErr = e.executeWinRM(attemptCtx, integration, definition, contextData)
 			code = 0 // WinRM doesn't have HTTP codes
+		} else if strings.ToUpper(integration.Type) == "SSH" {
+			resp, lastErr = e.executeSSH(attemptCtx, integration, definition, contextData)
+			code = 0
+		} else if strings.ToUpper(integration.Type) == "LDAP" {
+			resp, lastErr = e.executeLDAP(attemptCtx, integration, definition, contextData)
+			code = 0
+		} else if strings.ToUpper(integration.Type) == "EMAIL" {
+			resp, lastErr = e.executeEmail(attemptCtx, integration, definition, contextData)
+			code = 0
+		} else if strings.ToUpper(integration.Type) == "SCIM" {
+			resp, code, lastErr = e.executeSCIM(attemptCtx, integration, definition, contextData)
 		} else if strings.ToUpper(integration.Type) == "SSF" {
-			resp, code, lastErr = e.executeSSF(integration, definition, contextData)
+			resp, code, lastErr = e.executeSSF(attemptCtx, integration, definition, contextData)
 		} else {
-			resp, code, lastErr = e.executeREST(integration, definition, contextData)
+			resp, code, lastErr = e.executeREST(attemptCtx, integration, definition, contextData)
 		}
+		release()
+		tracing.End(attemptSpan, lastErr)
 
 		if lastErr == nil {
 			// Success: Reset circuit breaker
-			e.handleCircuitSuccess(integration)
+			e.handleCircuitSuccess(integration, probe)
 			return resp, code, nil
 		}
 
-		// Fail fast on Auth errors
-		if code == 401 || code == 403 {
-			if e.DebugMode {
-				log.Printf("[Executor] Aborting retries for %s due to HTTP %d (Auth Error)", definition.Name, code)
-			}
+		if attempts > maxRetries {
 			break
 		}
-	}
-
-	// All retries failed: Increment circuit breaker
-	e.handleCircuitFailure(integration)
-	return resp, code, fmt.Errorf("all %d attempts failed. Last error: %v", maxRetries+1, lastErr)
-}
-
-func (e *ActionExecutor) getLimiter(id uint, rateLimit float64) *rate.Limiter {
-	limitersMu.Lock()
-	defer limitersMu.Unlock()
-
-	if l, ok := limiters[id]; ok {
-		// Update limit if it changed in DB
-		if float64(l.Limit()) != rateLimit {
-			l.SetLimit(rate.Limit(rateLimit))
+		if !policy.retryable(definition.Method, code, lastErr) {
+			executorLog.DebugContext(ctx, "Not retrying: failure is not retryable under its policy", "action", definition.Name, "method", definition.Method, "status", code)
+			break
 		}
-		return l
-	}
 
-	// Create new limiter: rateLimit req/sec, burst size of 1
-	l := rate.NewLimiter(rate.Limit(rateLimit), 1)
-	limiters[id] = l
-	return l
-}
+		delay := policy.backoff(attempts)
+		if wait, ok := retryAfter(lastErr, e.now()); ok {
+			if wait > maxRetryAfter {
+				executorLog.WarnContext(ctx, "Retry-After exceeds the limit; giving up", logging.FieldIntegration, integration.Name, "retry_after", wait, "limit", maxRetryAfter)
+				break
+			}
+			if wait > delay {
+				delay = wait
+			}
+		}
+		if policy.maxElapsed > 0 && e.now().Sub(start)+delay > policy.maxElapsed {
+			executorLog.DebugContext(ctx, "Retry budget exhausted", "action", definition.Name, "budget", policy.maxElapsed)
+			break
+		}
 
-func (e *ActionExecutor) handleCircuitFailure(integration database.Integration) {
-	database.DB.Model(&integration).UpdateColumn("consecutive_failures", gorm.Expr("consecutive_failures + 1"))
-	
-	// Reload to check count (or we can do it in one query if preferred)
-	var updated database.Integration
-	database.DB.First(&updated, integration.ID)
-	
-	if updated.ConsecutiveFailures >= 5 {
-		log.Printf("[CircuitBreaker] TRIP! Integration %s disabled after 5 failures.", integration.Name)
-		database.DB.Model(&updated).Update("is_available", false)
+		executorLog.DebugContext(ctx, "Retrying action", "action", definition.Name, "attempt", attempts+1, "max_attempts", maxRetries+1, "delay", delay)
+		metrics.Retries.WithLabelValues(metrics.Tenant(integration.TenantID), metrics.Integration(integration.Name), metrics.Action(definition.Name)).Inc()
+		_, backoffSpan := tracing.Start(ctx, "retry backoff", attribute.Int64("delay.ms", delay.Milliseconds()))
+		err = e.sleep(ctx, delay)
+		tracing.End(backoffSpan, err)
+		if err != nil {
+			return resp, code, fmt.Errorf("retry wait cancelled: %v", err)
+		}
 	}
-}
 
-func (e *ActionExecutor) handleCircuitSuccess(integration database.Integration) {
-	if integration.ConsecutiveFailures > 0 || !integration.IsAvailable {
-		database.DB.Model(&integration).Updates(map[string]interface{}{
-			"consecutive_failures": 0,
-			"is_available":         true,
-		})
-	}
+	// All retries failed: Increment circuit breaker
+	e.handleCircuitFailure(integration, probe, lastErr)
+	return resp, code, fmt.Errorf("all %d attempts failed. Last error: %w", attempts, lastErr)
 }
 
-func (e *ActionExecutor) executeREST(integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, int, error) {
+func (e *ActionExecutor) executeREST(ctx context.Context, integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, int, error) {
 	// 1. Resolve URL Path
 	path, err := e.renderTemplate(definition.PathTemplate, contextData)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to render path: %v", err)
 	}
-	fullURL := integration.BaseURL + path
+	fullURL, err := e.renderQuery(integration.BaseURL+path, definition.QueryParams, contextData)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	// 2. Resolve Body
-	body, err := e.renderTemplate(definition.BodyTemplate, contextData)
+	// 2. Resolve Body, escaping every value for the content type
+	body, warnings, err := e.renderBody(definition.ContentType, definition.BodyTemplate, contextData)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to render body: %v", err)
 	}
-	if e.DebugMode {
-		log.Printf("[Executor] Request Payload (%s %s):\n%s", definition.Method, fullURL, security.Redact(body))
+	for _, w := range warnings {
+		executorLog.WarnContext(ctx, "Body template lint", "action", definition.Name, "warning", w)
 	}
+	executorLog.DebugContext(ctx, "Request payload", "method", definition.Method, "url", fullURL, "body", security.Redact(body))
 
 	// 3. Create Request
-	req, err := http.NewRequest(definition.Method, fullURL, bytes.NewBuffer([]byte(body)))
+	req, err := http.NewRequestWithContext(ctx, definition.Method, fullURL, bytes.NewBuffer([]byte(body)))
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// 4. Set Content Type
-	req.Header.Set("Content-Type", "application/json")
+	// 4. Set Content Type and the action's headers
+	req.Header.Set("Content-Type", contentTypeOf(definition.ContentType))
+	if err := e.renderHeaders(req, definition.Headers, contextData); err != nil {
+		return nil, 0, err
+	}
 
-	// 5. Handle Authentication
+	// 5. Handle Authentication, last so signatures cover the headers
 	if err := e.applyAuth(req, integration); err != nil {
 		return nil, 0, err
 	}
 
 	// 6. Execute
-	resp, err := e.Client.Do(req)
+	resp, err := e.do(ctx, integration, req)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer resp.Body.Close()
+	e.observeRateLimit(integration, resp)
 
 	respBody, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode >= 400 {
-		return respBody, resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+		return respBody, resp.StatusCode, &HTTPStatusError{StatusCode: resp.StatusCode, Body: respBody, Header: resp.Header}
 	}
 
 	return respBody, resp.StatusCode, nil
 }
 
-func (e *ActionExecutor) executeWinRM(integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, error) {
-    // 1. Resolve PowerShell Script (BodyTemplate serves as the Script Template)
-    script, err := e.renderTemplate(definition.BodyTemplate, contextData)
-    if err != nil {
-        return nil, fmt.Errorf("failed to render ps script: %v", err)
-    }
-
-    // 2. Parse Credentials
-    var creds map[string]string
-    json.Unmarshal([]byte(integration.Credentials), &creds)
-
-    // Parse Port from URL or default
-    port := 5985
-    host := integration.BaseURL // In WinRM mode, BaseURL is just the host
-    if parts := strings.Split(host, ":"); len(parts) > 1 {
-        host = parts[0]
-        p, _ := strconv.Atoi(parts[1])
-        port = p
-    }
-
-    // 3. Create WinRM Endpoint
-    endpoint := winrm.NewEndpoint(host, port, false, false, nil, nil, nil, 0)
-    
-    client, err := winrm.NewClient(endpoint, creds["username"], creds["password"])
-    if err != nil {
-        return nil, err
-    }
-
-    // 4. Run PowerShell
-    var stdout, stderr bytes.Buffer
-    _, err = client.Run(winrm.Powershell(script), &stdout, &stderr)
-    
-    if err != nil {
-        return nil, fmt.Errorf("winrm execution failed: %v, stderr: %s", err, stderr.String())
-    }
-
-    return stdout.Bytes(), nil
-}
-
-func (e *ActionExecutor) executeSSF(integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, int, error) {
+func (e *ActionExecutor) executeSSF(ctx context.Context, integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, int, error) {
 	// 1. Resolve Payload (BodyTemplate serves as the SSF Payload Template)
-	payloadJSON, err := e.renderTemplate(definition.BodyTemplate, contextData)
+	payloadJSON, _, err := e.renderBody("application/json", definition.BodyTemplate, contextData)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to render ssf payload: %v", err)
 	}
@@ -281,9 +268,7 @@ func (e *ActionExecutor) executeSSF(integration database.Integration, definition
 		return nil, 0, fmt.Errorf("signing failed: %v", err)
 	}
 
-	if e.DebugMode {
-		log.Printf("[Executor] Generated SET for %s: %s", integration.Name, signedToken)
-	}
+	executorLog.DebugContext(ctx, "Generated SET", logging.FieldIntegration, integration.Name, "events", len(payload.Events))
 
 	// 4. Send Request
 	// Note: RFC 8417/8935 uses Content-Type: application/secevent+jwt
@@ -305,7 +290,7 @@ func (e *ActionExecutor) executeSSF(integration database.Integration, definition
 		fullURL += path
 	}
 
-	req, err := http.NewRequest(definition.Method, fullURL, bytes.NewBuffer([]byte(signedToken)))
+	req, err := http.NewRequestWithContext(ctx, definition.Method, fullURL, bytes.NewBuffer([]byte(signedToken)))
 	if err != nil {
 		return nil, 0, err
 	}
@@ -319,22 +304,64 @@ func (e *ActionExecutor) executeSSF(integration database.Integration, definition
 		return nil, 0, err
 	}
 
-	resp, err := e.Client.Do(req)
+	resp, err := e.do(ctx, integration, req)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer resp.Body.Close()
+	e.observeRateLimit(integration, resp)
 
 	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		return respBody, resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+		return respBody, resp.StatusCode, &HTTPStatusError{StatusCode: resp.StatusCode, Body: respBody, Header: resp.Header}
 	}
 
 	return respBody, resp.StatusCode, nil
 }
 
-func (e *ActionExecutor) renderTemplate(tplStr string, data interface{}) (string, error) {
-	tmpl, err := template.New("action").Funcs(template.FuncMap{
+// do sends an outbound request to the integration inside a client span carrying the trace
+// context headers. An OAuth2 token rejected with 401 is dropped and the request is sent
+// once more with a new one, since vendors revoke tokens before they expire.
+func (e *ActionExecutor) do(ctx context.Context, integration database.Integration, req *http.Request) (*http.Response, error) {
+	client, err := e.httpClient(integration)
+	if err != nil {
+		return nil, err
+	}
+	send := func(req *http.Request) (*http.Response, error) {
+		req, span := tracing.StartHTTP(ctx, req)
+		resp, err := client.Do(req)
+		tracing.EndHTTP(span, resp, err)
+		return resp, err
+	}
+
+	resp, err := send(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || integration.AuthType != "oauth2" {
+		return resp, err
+	}
+	rejected := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	invalidateOAuthToken(integration, rejected)
+	retry := req.Clone(ctx)
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		if retry.Body, err = req.GetBody(); err != nil {
+			return resp, nil
+		}
+	}
+	if err := e.applyAuth(retry, integration); err != nil {
+		executorLog.WarnContext(ctx, "Could not replace the rejected OAuth2 token", logging.FieldIntegration, integration.Name, "error", err)
+		return resp, nil
+	}
+	executorLog.InfoContext(ctx, "OAuth2 token rejected, retrying with a new token", logging.FieldIntegration, integration.Name)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return send(retry)
+}
+
+// templateFuncs are the helpers available to every action template
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
 		"default": func(defaultValue string, value interface{}) string {
 			if value == nil {
 				return defaultValue
@@ -415,7 +442,19 @@ func (e *ActionExecutor) renderTemplate(tplStr string, data interface{}) (string
 				return caepPrefix + "session-revoked"
 			}
 		},
-	}).Parse(tplStr)
+		"psquote":        psQuote,
+		"psescape":       psEscapeSingle,
+		"samaccountname": samAccountName,
+		"shquote":        shQuote,
+		"unixuser":       unixUser,
+		"ldapdn":         ldapEscapeDN,
+		"ldapfilter":     ldapEscapeFilter,
+		"raw":            func(value interface{}) string { return templateString(value) },
+	}
+}
+
+func (e *ActionExecutor) renderTemplate(tplStr string, data interface{}) (string, error) {
+	tmpl, err := template.New("action").Funcs(templateFuncs()).Parse(tplStr)
 	if err != nil {
 		return "", err
 	}
@@ -442,58 +481,19 @@ func (e *ActionExecutor) applyAuth(req *http.Request, integration database.Integ
 		}
 		req.Header.Set(headerName, creds["api_key"])
 	case "oauth2":
-		token := integration.OAuthToken
-		// Check if expired (with 1-min buffer)
-		if token == "" || integration.OAuthExpiresAt == nil || time.Now().Add(1*time.Minute).After(*integration.OAuthExpiresAt) {
-			var err error
-			token, err = e.refreshOAuth2Token(&integration, creds)
-			if err != nil {
-				return fmt.Errorf("oauth2 refresh failed: %v", err)
-			}
+		token, err := e.oauthAccessToken(req.Context(), integration, creds)
+		if err != nil {
+			return fmt.Errorf("oauth2 refresh failed: %v", err)
 		}
 		req.Header.Set("Authorization", "Bearer "+token)
+	case "aws_sigv4":
+		if err := signAWSV4(req, creds, e.now()); err != nil {
+			return fmt.Errorf("request signing failed: %v", err)
+		}
+	case "hmac":
+		if err := signHMAC(req, creds, e.now()); err != nil {
+			return fmt.Errorf("request signing failed: %v", err)
+		}
 	}
 	return nil
 }
-
-func (e *ActionExecutor) refreshOAuth2Token(integ *database.Integration, creds map[string]string) (string, error) {
-	if e.DebugMode {
-		log.Printf("[Executor] Refreshing OAuth2 token for %s...", integ.Name)
-	}
-	
-	form := url.Values{}
-	form.Add("grant_type", "client_credentials")
-	form.Add("client_id", creds["client_id"])
-	form.Add("client_secret", creds["client_secret"])
-
-	resp, err := e.Client.PostForm(integ.TokenEndpoint, form)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("auth server returned %d: %s", resp.StatusCode, string(body))
-	}
-
-	var data struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int    `json:"expires_in"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "", err
-	}
-
-	// Update Cache in Memory and DB
-	expiresAt := time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
-	integ.OAuthToken = data.AccessToken
-	integ.OAuthExpiresAt = &expiresAt
-
-	database.DB.Model(integ).Updates(map[string]interface{}{
-		"o_auth_token":      data.AccessToken,
-		"o_auth_expires_at": expiresAt,
-	})
-
-	return data.AccessToken, nil
-}
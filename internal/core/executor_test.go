@@ -22,6 +22,21 @@ func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 func setupTestDB() {
 	// Initialize in-memory database for testing
 	database.InitDB(":memory:")
+	// Each pooled connection to :memory: opens its own empty database, so concurrent
+	// batches must share a single connection
+	if sqlDB, err := database.DB.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1)
+	}
+	// IDs restart with every in-memory DB, so drop limiter and semaphore state from earlier tests
+	limitersMu.Lock()
+	limiters = make(map[uint]*adaptiveLimiter)
+	limitersMu.Unlock()
+	semaphoresMu.Lock()
+	semaphores = make(map[uint]chan struct{})
+	semaphoresMu.Unlock()
+	oauthTokensMu.Lock()
+	oauthTokens = make(map[uint]*oauthToken)
+	oauthTokensMu.Unlock()
     // Create default tenant for tests
     database.DB.FirstOrCreate(&database.Tenant{ID: 1, Name: "Default Tenant"})
 }
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"fmt"
+	"remediation-engine/internal/config"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// loopStaleTicks is how many scheduler ticks the loop may miss before readiness fails
+	loopStaleTicks = 3
+	// queueSaturation is the task queue fill ratio at which readiness fails
+	queueSaturation = 0.9
+	// pollerMissedIntervals is how many polling intervals a poller may go without a successful GetIssues
+	pollerMissedIntervals = 3
+)
+
+// schedulerInterval is how often the engine loop schedules polls and probes circuits
+func schedulerInterval() time.Duration {
+	return config.Current().Engine.SchedulerInterval.Std()
+}
+
+// ReadinessCheck is one entry of the /readyz report. An advisory check is reported but does
+// not make the pod unready.
+type ReadinessCheck struct {
+	Name          string `json:"name"`
+	OK            bool   `json:"ok"`
+	Advisory      bool   `json:"advisory,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	TenantID      uint   `json:"tenant_id,omitempty"`
+	IntegrationID uint   `json:"integration_id,omitempty"`
+}
+
+type pollerKey struct {
+	TenantID      uint
+	IntegrationID uint
+}
+
+// pollerHealth tracks one AuthMind poller from the first time it is scheduled
+type pollerHealth struct {
+	interval      time.Duration
+	firstSeen     time.Time
+	lastScheduled time.Time
+	lastSuccess   time.Time
+	lastFailed    bool
+}
+
+// pollerTracker records scheduling and GetIssues outcomes per tenant poller
+type pollerTracker struct {
+	mu      sync.Mutex
+	pollers map[pollerKey]*pollerHealth
+}
+
+func newPollerTracker() *pollerTracker {
+	return &pollerTracker{pollers: make(map[pollerKey]*pollerHealth)}
+}
+
+func (t *pollerTracker) scheduled(tenantID, integrationID uint, interval time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := pollerKey{tenantID, integrationID}
+	p, ok := t.pollers[key]
+	if !ok {
+		p = &pollerHealth{firstSeen: now}
+		t.pollers[key] = p
+	}
+	p.interval = interval
+	p.lastScheduled = now
+}
+
+func (t *pollerTracker) polled(tenantID, integrationID uint, err error, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.pollers[pollerKey{tenantID, integrationID}]
+	if !ok {
+		// Polled without going through the scheduler (e.g. directly in tests)
+		p = &pollerHealth{firstSeen: now, lastScheduled: now}
+		t.pollers[pollerKey{tenantID, integrationID}] = p
+	}
+	p.lastFailed = err != nil
+	if err == nil {
+		p.lastSuccess = now
+	}
+}
+
+// checks reports freshness for pollers that are still being scheduled.
+// A poller that stops being scheduled (disabled, or no workflows left) is dropped.
+// One tenant's AuthMind outage should not take the pod out of service for every tenant,
+// so the checks are advisory unless engine.pollers_gate_readiness is set.
+func (t *pollerTracker) checks(now time.Time) []ReadinessCheck {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	advisory := !config.Current().Engine.PollersGateReadiness
+
+	var checks []ReadinessCheck
+	for key, p := range t.pollers {
+		staleAfter := time.Duration(pollerMissedIntervals)*p.interval + schedulerInterval()
+		if now.Sub(p.lastScheduled) > staleAfter {
+			delete(t.pollers, key)
+			continue
+		}
+
+		check := ReadinessCheck{Name: "poller", OK: true, Advisory: advisory, TenantID: key.TenantID, IntegrationID: key.IntegrationID}
+		since := p.lastSuccess
+		if since.IsZero() {
+			since = p.firstSeen
+		}
+		if age := now.Sub(since); age > staleAfter {
+			check.OK = false
+			if p.lastSuccess.IsZero() {
+				check.Reason = fmt.Sprintf("no successful poll since the poller was scheduled %s ago", age.Round(time.Second))
+			} else {
+				check.Reason = fmt.Sprintf("last successful poll was %s ago (limit %s)", age.Round(time.Second), staleAfter)
+			}
+			if p.lastFailed {
+				check.Reason += "; the last attempt failed"
+			}
+		}
+		checks = append(checks, check)
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].TenantID != checks[j].TenantID {
+			return checks[i].TenantID < checks[j].TenantID
+		}
+		return checks[i].IntegrationID < checks[j].IntegrationID
+	})
+	return checks
+}
+
+// Alive reports whether the engine has been started. It backs /healthz.
+func (e *Engine) Alive() bool {
+	return e.lastTick.Load() != 0
+}
+
+// ReadinessChecks reports the state of the engine loop, the worker pool, the task queue
+// and every active poller. The database check is left to the caller.
+func (e *Engine) ReadinessChecks(now time.Time) []ReadinessCheck {
+	checks := make([]ReadinessCheck, 0, 3)
+
+	loop := ReadinessCheck{Name: "engine_loop", OK: true}
+	loopStaleAfter := loopStaleTicks * schedulerInterval()
+	if tick := e.lastTick.Load(); tick == 0 {
+		loop.OK = false
+		loop.Reason = "engine loop has not started"
+	} else if age := now.Sub(time.Unix(0, tick)); age > loopStaleAfter {
+		loop.OK = false
+		loop.Reason = fmt.Sprintf("engine loop last ran %s ago (limit %s)", age.Round(time.Second), loopStaleAfter)
+	}
+	checks = append(checks, loop)
+
+	workers := ReadinessCheck{Name: "workers", OK: true}
+	if alive := int(e.liveWorkers.Load()); alive < e.workerCount {
+		workers.OK = false
+		workers.Reason = fmt.Sprintf("%d of %d workers running", alive, e.workerCount)
+	}
+	checks = append(checks, workers)
+
+	queue := ReadinessCheck{Name: "task_queue", OK: true}
+	if depth, capacity := len(e.taskQueue), cap(e.taskQueue); float64(depth) >= queueSaturation*float64(capacity) {
+		queue.OK = false
+		queue.Reason = fmt.Sprintf("task queue is saturated (%d of %d)", depth, capacity)
+	}
+	checks = append(checks, queue)
+
+	return append(checks, e.pollers.checks(now)...)
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"errors"
+	"remediation-engine/internal/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkByName(checks []ReadinessCheck, name string) ReadinessCheck {
+	for _, c := range checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	return ReadinessCheck{Name: "missing"}
+}
+
+func TestReadinessChecks_EngineState(t *testing.T) {
+	setupTestDB()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := NewEngine()
+
+	checks := e.ReadinessChecks(now)
+	assert.False(t, e.Alive())
+	assert.Equal(t, "engine loop has not started", checkByName(checks, "engine_loop").Reason)
+	assert.Equal(t, "0 of 20 workers running", checkByName(checks, "workers").Reason)
+	assert.True(t, checkByName(checks, "task_queue").OK)
+
+	e.lastTick.Store(now.UnixNano())
+	e.liveWorkers.Store(int32(e.workerCount))
+	for _, c := range e.ReadinessChecks(now.Add(time.Second)) {
+		assert.True(t, c.OK, c.Name)
+	}
+
+	// A stuck loop and a full queue both fail readiness
+	for i := 0; i < cap(e.taskQueue); i++ {
+		e.taskQueue <- PollingTask{}
+	}
+	checks = e.ReadinessChecks(now.Add(time.Minute))
+	assert.Equal(t, "engine loop last ran 1m0s ago (limit 30s)", checkByName(checks, "engine_loop").Reason)
+	assert.Equal(t, "task queue is saturated (1000 of 1000)", checkByName(checks, "task_queue").Reason)
+}
+
+func TestPollerTracker_Freshness(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tr := newPollerTracker()
+
+	// Tenant 1 polls successfully every minute; tenant 2 keeps failing
+	for i := 0; i < 5; i++ {
+		at := now.Add(time.Duration(i) * time.Minute)
+		tr.scheduled(1, 10, time.Minute, at)
+		tr.polled(1, 10, nil, at)
+		tr.scheduled(2, 20, time.Minute, at)
+		tr.polled(2, 20, errors.New("connection refused"), at)
+	}
+
+	checks := tr.checks(now.Add(4 * time.Minute))
+	require.Len(t, checks, 2)
+	assert.True(t, checks[0].OK)
+	assert.Equal(t, uint(1), checks[0].TenantID)
+	assert.False(t, checks[1].OK)
+	assert.Equal(t, uint(20), checks[1].IntegrationID)
+	assert.Equal(t, "no successful poll since the poller was scheduled 4m0s ago; the last attempt failed", checks[1].Reason)
+
+	// Tenant 1 stops polling while still scheduled; tenant 2 is no longer scheduled and drops out
+	tr.scheduled(1, 10, time.Minute, now.Add(8*time.Minute))
+	checks = tr.checks(now.Add(8 * time.Minute))
+	require.Len(t, checks, 1)
+	assert.Equal(t, "last successful poll was 4m0s ago (limit 3m10s)", checks[0].Reason)
+
+	assert.Empty(t, tr.checks(now.Add(time.Hour)))
+}
+
+func TestPollerTracker_AdvisoryUnlessGated(t *testing.T) {
+	defer config.Set(*config.Current())
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tr := newPollerTracker()
+	tr.scheduled(2, 20, time.Minute, now)
+	tr.polled(2, 20, errors.New("connection refused"), now)
+	tr.scheduled(2, 20, time.Minute, now.Add(4*time.Minute))
+
+	checks := tr.checks(now.Add(4 * time.Minute))
+	require.Len(t, checks, 1)
+	assert.False(t, checks[0].OK)
+	assert.True(t, checks[0].Advisory, "stale pollers are reported without failing readiness")
+
+	cfg := *config.Current()
+	cfg.Engine.PollersGateReadiness = true
+	config.Set(cfg)
+	checks = tr.checks(now.Add(4 * time.Minute))
+	require.Len(t, checks, 1)
+	assert.False(t, checks[0].Advisory)
+}
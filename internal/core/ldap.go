@@ -0,0 +1,593 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/tracing"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultLDAPPort  = 389
+	defaultLDAPSPort = 636
+)
+
+// LDAP operations, selected by the action definition's Method
+const (
+	ldapOpSearch       = "SEARCH"
+	ldapOpModify       = "MODIFY"
+	ldapOpRemoveMember = "REMOVE_MEMBER"
+)
+
+// ldapTarget is the directory server an LDAP integration's BaseURL points at
+type ldapTarget struct {
+	Host string
+	Port int
+	TLS  bool // ldaps:// (TLS from the first byte)
+}
+
+func (t ldapTarget) Addr() string {
+	return net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+}
+
+func (t ldapTarget) String() string {
+	scheme := "ldap"
+	if t.TLS {
+		scheme = "ldaps"
+	}
+	return scheme + "://" + t.Addr()
+}
+
+// parseLDAPTarget accepts "host", "host:port", "ldap://host[:port]" or "ldaps://host[:port]".
+// Without a scheme, port 636 implies LDAPS.
+func parseLDAPTarget(baseURL string) (ldapTarget, error) {
+	raw := strings.TrimSpace(baseURL)
+	if raw == "" {
+		return ldapTarget{}, fmt.Errorf("ldap: base URL (host) is empty")
+	}
+
+	var t ldapTarget
+	explicit := strings.Contains(raw, "://")
+	if explicit {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return ldapTarget{}, fmt.Errorf("ldap: invalid base URL %q: %v", baseURL, err)
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "ldap":
+		case "ldaps":
+			t.TLS = true
+		default:
+			return ldapTarget{}, fmt.Errorf("ldap: unsupported scheme %q (use ldap or ldaps)", u.Scheme)
+		}
+		raw = u.Host
+	}
+
+	host, portStr, err := net.SplitHostPort(raw)
+	if err != nil {
+		// No port given
+		t.Host = strings.Trim(raw, "[]")
+		t.Port = defaultLDAPPort
+		if t.TLS {
+			t.Port = defaultLDAPSPort
+		}
+		return t, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return ldapTarget{}, fmt.Errorf("ldap: invalid port %q", portStr)
+	}
+	t.Host, t.Port = host, port
+	if !explicit && port == defaultLDAPSPort {
+		t.TLS = true
+	}
+	return t, nil
+}
+
+// ldapTLSConfig verifies the server against ca_cert, or the system roots when it is empty,
+// and presents the integration's client certificate if it has one
+func ldapTLSConfig(integration database.Integration, target ldapTarget) (*tls.Config, error) {
+	cfg, err := transportSettings(integration).TLSConfig(target.Host)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: %v", err)
+	}
+	return cfg, nil
+}
+
+// ldapRequest is the rendered body of an LDAP action. The entry is addressed by dn, or
+// looked up with filter under base_dn, which must match exactly one entry.
+type ldapRequest struct {
+	DN     string `json:"dn"`
+	BaseDN string `json:"base_dn"`
+	Filter string `json:"filter"`
+	Scope  string `json:"scope"` // "sub" (default), "one" or "base"
+
+	// SEARCH
+	Attributes []string `json:"attributes"`
+	SizeLimit  int      `json:"size_limit"`
+
+	// MODIFY
+	Changes []ldapChange `json:"changes"`
+
+	// REMOVE_MEMBER
+	GroupDN string `json:"group_dn"`
+}
+
+// ldapChange is one modification. Besides add, delete and replace, set_bits and clear_bits
+// update a flag attribute such as userAccountControl from its current value.
+type ldapChange struct {
+	Op        string   `json:"op"`
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+}
+
+// ldapEntry is how search results are returned to the workflow
+type ldapEntry struct {
+	DN         string              `json:"dn"`
+	Attributes map[string][]string `json:"attributes"`
+}
+
+func ldapScope(scope string) (int, error) {
+	switch strings.ToLower(scope) {
+	case "", "sub", "subtree":
+		return ldap.ScopeWholeSubtree, nil
+	case "one", "onelevel":
+		return ldap.ScopeSingleLevel, nil
+	case "base":
+		return ldap.ScopeBaseObject, nil
+	}
+	return 0, fmt.Errorf("ldap: unsupported scope %q (use sub, one or base)", scope)
+}
+
+// validate checks the rendered request for the operation before connecting
+func (r ldapRequest) validate(op string) error {
+	for name, dn := range map[string]string{"dn": r.DN, "base_dn": r.BaseDN, "group_dn": r.GroupDN} {
+		if dn == "" {
+			continue
+		}
+		if _, err := ldap.ParseDN(dn); err != nil {
+			return fmt.Errorf("ldap: invalid %s %q: %v", name, dn, err)
+		}
+	}
+	if r.Filter != "" {
+		if _, err := ldap.CompileFilter(r.Filter); err != nil {
+			return fmt.Errorf("ldap: invalid filter %q: %v", r.Filter, err)
+		}
+	}
+	if _, err := ldapScope(r.Scope); err != nil {
+		return err
+	}
+
+	switch op {
+	case ldapOpSearch:
+		if r.BaseDN == "" && r.DN == "" {
+			return fmt.Errorf("ldap: search needs a base_dn")
+		}
+		return nil
+	case ldapOpModify:
+		if len(r.Changes) == 0 {
+			return fmt.Errorf("ldap: modify needs at least one change")
+		}
+		for _, c := range r.Changes {
+			if c.Attribute == "" {
+				return fmt.Errorf("ldap: change %q has no attribute", c.Op)
+			}
+			switch strings.ToLower(c.Op) {
+			case "add", "replace", "delete":
+			case "set_bits", "clear_bits":
+				if _, err := ldapFlags(c.Values); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("ldap: unsupported change op %q (use add, delete, replace, set_bits or clear_bits)", c.Op)
+			}
+		}
+	case ldapOpRemoveMember:
+		if r.GroupDN == "" {
+			return fmt.Errorf("ldap: remove_member needs a group_dn")
+		}
+	default:
+		return fmt.Errorf("ldap: unsupported operation %q (use SEARCH, MODIFY or REMOVE_MEMBER)", op)
+	}
+	if r.DN == "" && (r.BaseDN == "" || r.Filter == "") {
+		return fmt.Errorf("ldap: %s needs a dn, or a base_dn and filter that find the entry", strings.ToLower(op))
+	}
+	return nil
+}
+
+// ldapFlags ORs the values of a set_bits or clear_bits change
+func ldapFlags(values []string) (int64, error) {
+	if len(values) == 0 {
+		return 0, fmt.Errorf("ldap: set_bits and clear_bits need the flag values")
+	}
+	var flags int64
+	for _, v := range values {
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("ldap: invalid flag value %q", v)
+		}
+		flags |= n
+	}
+	return flags, nil
+}
+
+func (e *ActionExecutor) executeLDAP(ctx context.Context, integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, error) {
+	op := strings.ToUpper(definition.Method)
+
+	// 1. Resolve the request (BodyTemplate is JSON; DNs and filters are escaped for LDAP)
+	body, warnings, err := e.renderLDAP(definition.BodyTemplate, contextData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render ldap request: %v", err)
+	}
+	for _, w := range warnings {
+		executorLog.WarnContext(ctx, "LDAP template lint", "action", definition.Name, "warning", w)
+	}
+	var req ldapRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return nil, fmt.Errorf("failed to render ldap request: not valid JSON: %v", err)
+	}
+	if err := req.validate(op); err != nil {
+		return nil, err
+	}
+
+	// 2. Parse Credentials
+	var creds map[string]string
+	if integration.Credentials != "" {
+		if err := json.Unmarshal([]byte(integration.Credentials), &creds); err != nil {
+			return nil, fmt.Errorf("failed to parse integration credentials: %v", err)
+		}
+	}
+
+	target, err := parseLDAPTarget(integration.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Connect, bind and run within the integration's timeout
+	timeout := commandTimeout(integration)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, span := tracing.Start(ctx, "LDAP "+op,
+		attribute.String("server.address", target.Host),
+		attribute.Int("server.port", target.Port))
+	conn, err := dialLDAP(runCtx, integration, target, creds, timeout)
+	if err == nil {
+		defer conn.Close()
+		var resp interface{}
+		resp, err = runLDAP(conn, op, req)
+		if err == nil {
+			tracing.End(span, nil)
+			return json.Marshal(resp)
+		}
+	}
+	if runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	err = describeLDAPError(err, target, timeout)
+	tracing.End(span, err)
+	return nil, err
+}
+
+// dialLDAP connects, upgrades with StartTLS when configured and binds. Closing on ctx
+// cancellation unblocks any request in flight.
+func dialLDAP(ctx context.Context, integration database.Integration, target ldapTarget, creds map[string]string, timeout time.Duration) (*ldap.Conn, error) {
+	if target.TLS && integration.StartTLS {
+		return nil, fmt.Errorf("ldap: start_tls cannot be combined with ldaps://; use ldap:// on port 389")
+	}
+	encrypted := target.TLS || integration.StartTLS
+
+	var username, password string
+	switch strings.ToLower(integration.AuthType) {
+	case "", "basic", "simple", "password":
+		username, password = creds["username"], creds["password"]
+		if username == "" || password == "" {
+			return nil, fmt.Errorf("ldap: simple bind needs a username and password in the credentials")
+		}
+		if !encrypted {
+			return nil, fmt.Errorf("ldap: simple bind over %s would send the password in clear text; use ldaps:// or enable start_tls", target)
+		}
+	case "none":
+	default:
+		return nil, fmt.Errorf("ldap: unsupported auth type %q (use basic or none)", integration.AuthType)
+	}
+
+	tlsConfig, err := ldapTLSConfig(integration, target)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ldap.DialURL(target.String(),
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	fail := func(err error) (*ldap.Conn, error) {
+		stop()
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if integration.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			return fail(err)
+		}
+	}
+	if username != "" {
+		if err := conn.Bind(username, password); err != nil {
+			return fail(err)
+		}
+	}
+	return conn, nil
+}
+
+// runLDAP performs one operation on a bound connection and returns the step response
+func runLDAP(conn *ldap.Conn, op string, req ldapRequest) (interface{}, error) {
+	if op == ldapOpSearch {
+		base, filter := req.BaseDN, req.Filter
+		if base == "" {
+			base = req.DN
+		}
+		if filter == "" {
+			filter = "(objectClass=*)"
+		}
+		scope, _ := ldapScope(req.Scope)
+		result, err := conn.Search(ldap.NewSearchRequest(base, scope, ldap.NeverDerefAliases,
+			req.SizeLimit, 0, false, filter, req.Attributes, nil))
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]ldapEntry, 0, len(result.Entries))
+		for _, entry := range result.Entries {
+			attrs := make(map[string][]string, len(entry.Attributes))
+			for _, a := range entry.Attributes {
+				attrs[a.Name] = a.Values
+			}
+			entries = append(entries, ldapEntry{DN: entry.DN, Attributes: attrs})
+		}
+		return map[string]interface{}{"entries": entries}, nil
+	}
+
+	dn, err := resolveLDAPEntry(conn, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if op == ldapOpRemoveMember {
+		modify := ldap.NewModifyRequest(req.GroupDN, nil)
+		modify.Delete("member", []string{dn})
+		err := conn.Modify(modify)
+		// Not a member any more is the desired end state
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchAttribute) {
+			return map[string]interface{}{"group_dn": req.GroupDN, "member": dn, "removed": false}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"group_dn": req.GroupDN, "member": dn, "removed": true}, nil
+	}
+
+	modify := ldap.NewModifyRequest(dn, nil)
+	for _, c := range req.Changes {
+		switch strings.ToLower(c.Op) {
+		case "add":
+			modify.Add(c.Attribute, c.Values)
+		case "delete":
+			modify.Delete(c.Attribute, c.Values)
+		case "replace":
+			modify.Replace(c.Attribute, c.Values)
+		case "set_bits", "clear_bits":
+			value, err := updateLDAPFlags(conn, dn, c)
+			if err != nil {
+				return nil, err
+			}
+			modify.Replace(c.Attribute, []string{value})
+		}
+	}
+	if err := conn.Modify(modify); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"dn": dn, "changes": len(req.Changes)}, nil
+}
+
+// resolveLDAPEntry returns the request's dn, or the single entry found by its filter
+func resolveLDAPEntry(conn *ldap.Conn, req ldapRequest) (string, error) {
+	if req.DN != "" {
+		return req.DN, nil
+	}
+	scope, _ := ldapScope(req.Scope)
+	result, err := conn.Search(ldap.NewSearchRequest(req.BaseDN, scope, ldap.NeverDerefAliases,
+		2, 0, false, req.Filter, []string{"1.1"}, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return "", err
+	}
+	switch {
+	case result == nil || len(result.Entries) == 0:
+		msg := fmt.Sprintf("no entry under %q matches %s", req.BaseDN, req.Filter)
+		return "", fmt.Errorf("ldap: %s: %w", msg, &HTTPStatusError{StatusCode: http.StatusNotFound, Body: []byte(msg)})
+	case len(result.Entries) > 1:
+		msg := fmt.Sprintf("more than one entry under %q matches %s", req.BaseDN, req.Filter)
+		return "", fmt.Errorf("ldap: %s; narrow the filter: %w", msg, &HTTPStatusError{StatusCode: http.StatusConflict, Body: []byte(msg)})
+	}
+	return result.Entries[0].DN, nil
+}
+
+// updateLDAPFlags reads a flag attribute and returns it with the change's bits set or cleared
+func updateLDAPFlags(conn *ldap.Conn, dn string, c ldapChange) (string, error) {
+	flags, _ := ldapFlags(c.Values)
+	result, err := conn.Search(ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases,
+		1, 0, false, "(objectClass=*)", []string{c.Attribute}, nil))
+	if err != nil {
+		return "", err
+	}
+	var current int64
+	if len(result.Entries) > 0 {
+		if v := result.Entries[0].GetAttributeValue(c.Attribute); v != "" {
+			if current, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return "", fmt.Errorf("ldap: %s of %q is not a number: %q", c.Attribute, dn, v)
+			}
+		}
+	}
+	if strings.EqualFold(c.Op, "set_bits") {
+		current |= flags
+	} else {
+		current &^= flags
+	}
+	return strconv.FormatInt(current, 10), nil
+}
+
+// ldapStatuses maps LDAP result codes onto the HTTP statuses used for retry and
+// dead-letter classification
+var ldapStatuses = map[uint16]int{
+	ldap.LDAPResultInvalidCredentials:       http.StatusUnauthorized,
+	ldap.LDAPResultStrongAuthRequired:       http.StatusUnauthorized,
+	ldap.LDAPResultConfidentialityRequired:  http.StatusUnauthorized,
+	ldap.LDAPResultInsufficientAccessRights: http.StatusForbidden,
+	ldap.LDAPResultNoSuchObject:             http.StatusNotFound,
+	ldap.LDAPResultAttributeOrValueExists:   http.StatusConflict,
+	ldap.LDAPResultEntryAlreadyExists:       http.StatusConflict,
+	ldap.LDAPResultTimeLimitExceeded:        http.StatusGatewayTimeout,
+	ldap.LDAPResultAdminLimitExceeded:       http.StatusServiceUnavailable,
+	ldap.LDAPResultBusy:                     http.StatusServiceUnavailable,
+	ldap.LDAPResultUnavailable:              http.StatusServiceUnavailable,
+	ldap.LDAPResultOther:                    http.StatusInternalServerError,
+}
+
+// describeLDAPError turns connection and directory failures into messages that name the
+// likely fix. Result codes are surfaced as HTTP statuses so credential and permission
+// failures are not retried and a busy server is.
+func describeLDAPError(err error, target ldapTarget, timeout time.Duration) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		ldapErr   *ldap.Error
+		dnsErr    *net.DNSError
+		netErr    net.Error
+		statusErr *HTTPStatusError
+		authority x509.UnknownAuthorityError
+		hostname  x509.HostnameError
+		recordErr tls.RecordHeaderError
+	)
+	msg := err.Error()
+
+	switch {
+	case errors.As(err, &statusErr):
+		return err
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return fmt.Errorf("ldap: %s did not answer within %s (raise timeout_seconds): %w", target, timeout, err)
+	case errors.As(err, &authority) || strings.Contains(msg, "certificate signed by unknown authority"):
+		return fmt.Errorf("ldap: the certificate presented by %s is not signed by a trusted CA; add the issuing CA to ca_cert: %w", target, err)
+	case errors.As(err, &hostname) || strings.Contains(msg, "certificate is valid for"):
+		return fmt.Errorf("ldap: the certificate presented by %s is not valid for host %q; connect using a name listed in the certificate: %w", target, target.Host, err)
+	case errors.As(err, &recordErr) || strings.Contains(msg, "first record does not look like a TLS handshake"):
+		return fmt.Errorf("ldap: %s did not answer with TLS; use ldap:// with start_tls or port 636 for ldaps: %w", target, err)
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("ldap: cannot resolve host %q: %w", target.Host, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("ldap: connection refused by %s; check the port (389 for ldap, 636 for ldaps): %w", target, err)
+	case errors.As(err, &ldapErr) && ldapErr.ResultCode == ldap.LDAPResultInvalidCredentials:
+		return fmt.Errorf("ldap: %s rejected the bind credentials; check the username (DN or user@domain) and password: %w", target,
+			&HTTPStatusError{StatusCode: http.StatusUnauthorized, Body: []byte(msg)})
+	case errors.As(err, &ldapErr) && ldapErr.ResultCode < ldap.ErrorNetwork:
+		status, ok := ldapStatuses[ldapErr.ResultCode]
+		if !ok {
+			status = http.StatusBadRequest
+		}
+		return fmt.Errorf("ldap: %s: %w", target, &HTTPStatusError{StatusCode: status, Body: []byte(msg)})
+	}
+	return fmt.Errorf("ldap execution failed: %w", err)
+}
+
+// healthCheckLDAP connects and binds, which is what every LDAP action does first
+func (e *ActionExecutor) healthCheckLDAP(integration database.Integration) error {
+	var creds map[string]string
+	if integration.Credentials != "" {
+		if err := json.Unmarshal([]byte(integration.Credentials), &creds); err != nil {
+			return fmt.Errorf("failed to parse integration credentials: %v", err)
+		}
+	}
+	target, err := parseLDAPTarget(integration.BaseURL)
+	if err != nil {
+		return err
+	}
+	timeout := commandTimeout(integration)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := dialLDAP(ctx, integration, target, creds, timeout)
+	if err != nil {
+		return describeLDAPError(err, target, timeout)
+	}
+	conn.Close()
+	return nil
+}
+
+// LDAP request templates are JSON bodies, escaped like other JSON bodies. Values inside the
+// strings of DN keys (dn, base_dn, group_dn) are also escaped as DN attribute values, and
+// values inside a "filter" string as filter assertion values:
+//
+//	"dn": "CN={{.Name}},OU=Users,DC=corp,DC=example"   ,+"\<>; are backslash-escaped
+//	"filter": "(mail={{.UserEmail}})"                   ()*\ are hex-escaped
+//	"dn": {{.UserDN}}                                   a complete DN, only JSON-encoded
+//
+// ldapdn and ldapfilter escape explicitly for other places.
+var ldapRequestLanguage = keyedJSON{output: "request", fields: []jsonField{
+	{keys: []string{"dn", "base_dn", "group_dn"}, name: "a DN string", escaper: ldapDNEscaper, explicit: "ldapdn"},
+	{keys: []string{"filter"}, name: "a filter string", escaper: ldapFilterEscaper, explicit: "ldapfilter"},
+}}
+
+// Escapers inserted by the auto-escaper
+const (
+	ldapDNEscaper     = "_ldap_dn"
+	ldapFilterEscaper = "_ldap_filter"
+)
+
+// ldapEscapeDN escapes a value as a DN attribute value, like ldapdn
+func ldapEscapeDN(value interface{}) string {
+	return ldap.EscapeDN(templateString(value))
+}
+
+// ldapEscapeFilter escapes a value as a filter assertion value, like ldapfilter
+func ldapEscapeFilter(value interface{}) string {
+	return ldap.EscapeFilter(templateString(value))
+}
+
+func ldapDNString(value interface{}) string {
+	return jsonEscapeString(ldapEscapeDN(value))
+}
+
+func ldapFilterString(value interface{}) string {
+	return jsonEscapeString(ldapEscapeFilter(value))
+}
+
+// LintLDAPTemplate reports values that reach an LDAP request without escaping. An error
+// means the template cannot be rendered at all.
+func LintLDAPTemplate(tplStr string) ([]string, error) {
+	_, warnings, err := parseEscapedTemplate(tplStr, ldapRequestLanguage)
+	return warnings, err
+}
+
+// renderLDAP renders an LDAP request template with auto-escaping
+func (e *ActionExecutor) renderLDAP(tplStr string, data interface{}) (string, []string, error) {
+	return executeEscaped(tplStr, ldapRequestLanguage, data)
+}
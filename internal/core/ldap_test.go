@@ -0,0 +1,396 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http/httptest"
+	"remediation-engine/internal/database"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLDAPTarget(t *testing.T) {
+	cases := []struct {
+		in   string
+		want ldapTarget
+	}{
+		{"dc01", ldapTarget{Host: "dc01", Port: 389}},
+		{"dc01:636", ldapTarget{Host: "dc01", Port: 636, TLS: true}},
+		{"ldaps://dc01", ldapTarget{Host: "dc01", Port: 636, TLS: true}},
+		{"ldap://dc01:3268", ldapTarget{Host: "dc01", Port: 3268}},
+		{"ldap://dc01:636", ldapTarget{Host: "dc01", Port: 636}},
+	}
+	for _, c := range cases {
+		got, err := parseLDAPTarget(c.in)
+		require.NoError(t, err, c.in)
+		assert.Equal(t, c.want, got, c.in)
+	}
+
+	for _, bad := range []string{"", "https://dc01", "dc01:0"} {
+		_, err := parseLDAPTarget(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRenderLDAP_EscapesDNsAndFilters(t *testing.T) {
+	executor := NewActionExecutor()
+	data := map[string]interface{}{"Name": `Doe, John"`, "Mail": `*)(uid=*`, "UserDN": "CN=Doe\\, John,OU=Users,DC=corp"}
+
+	body, warnings, err := executor.renderLDAP(`{"dn": "CN={{.Name}},OU=Users,DC=corp", "base_dn": {{.UserDN}}, "filter": "(mail={{.Mail}})", "changes": [{"op": "replace", "attribute": "description", "values": ["{{.Mail}}"]}]}`, data)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	var req ldapRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &req))
+	assert.Equal(t, `CN=Doe\, John\",OU=Users,DC=corp`, req.DN)
+	assert.Equal(t, "CN=Doe\\, John,OU=Users,DC=corp", req.BaseDN)
+	assert.Equal(t, `(mail=\2a\29\28uid=\2a)`, req.Filter)
+	assert.Equal(t, []string{`*)(uid=*`}, req.Changes[0].Values)
+
+	// Explicitly escaped values are not escaped twice
+	body, _, err = executor.renderLDAP(`{"filter": "(mail={{ldapfilter .Mail}})"}`, data)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(body), &req))
+	assert.Equal(t, `(mail=\2a\29\28uid=\2a)`, req.Filter)
+}
+
+func TestLintLDAPTemplate(t *testing.T) {
+	warnings, err := LintLDAPTemplate(`{"base_dn": "DC=corp", "filter": "(mail={{.UserEmail}})"}`)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	warnings, err = LintLDAPTemplate(`{"filter": "(mail={{.UserEmail | jsonescape}})", "dn": "CN={{ldapfilter .Name}}"}`)
+	require.NoError(t, err)
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "jsonescape inside a filter string escapes the value twice")
+	assert.Contains(t, warnings[1], "ldapfilter used inside a DN string")
+}
+
+// testLDAPServer is an in-process directory that understands simple bind, StartTLS,
+// equality and presence filters, and modify
+type testLDAPServer struct {
+	addr   string
+	caCert string
+
+	mu      sync.Mutex
+	entries map[string]map[string][]string // dn -> attribute -> values
+}
+
+const testLDAPBindDN = "CN=svc,DC=corp"
+
+func startLDAPServer(t *testing.T, ldaps bool) *testLDAPServer {
+	t.Helper()
+	// Borrow the test certificate for 127.0.0.1
+	certSrv := httptest.NewTLSServer(nil)
+	tlsConfig := certSrv.TLS.Clone()
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certSrv.Certificate().Raw}))
+	certSrv.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if ldaps {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &testLDAPServer{addr: ln.Addr().String(), caCert: caCert, entries: map[string]map[string][]string{
+		"CN=John Doe,OU=Users,DC=corp": {"mail": {"jdoe@corp.example.com"}, "userAccountControl": {"512"}, "pwdLastSet": {"133500000000000000"}},
+		"CN=Jane Roe,OU=Users,DC=corp": {"mail": {"jroe@corp.example.com"}, "userAccountControl": {"512"}},
+		"CN=Admins,OU=Groups,DC=corp":  {"member": {"CN=John Doe,OU=Users,DC=corp", "CN=Jane Roe,OU=Users,DC=corp"}},
+	}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, tlsConfig)
+		}
+	}()
+	return s
+}
+
+func (s *testLDAPServer) serve(conn net.Conn, tlsConfig *tls.Config) {
+	defer func() { conn.Close() }()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		msgID := packet.Children[0].Value.(int64)
+		req := packet.Children[1]
+
+		switch req.Tag {
+		case ldap.ApplicationBindRequest:
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if req.Children[1].Value == testLDAPBindDN && req.Children[2].Data.String() == "pw" {
+				code = ldap.LDAPResultSuccess
+			}
+			writeLDAPResult(conn, msgID, ldap.ApplicationBindResponse, code)
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationExtendedRequest:
+			writeLDAPResult(conn, msgID, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess)
+			conn = tls.Server(conn, tlsConfig)
+		case ldap.ApplicationSearchRequest:
+			s.search(conn, msgID, req)
+		case ldap.ApplicationModifyRequest:
+			writeLDAPResult(conn, msgID, ldap.ApplicationModifyResponse, s.modify(req))
+		default:
+			return
+		}
+	}
+}
+
+func (s *testLDAPServer) search(w io.Writer, msgID int64, req *ber.Packet) {
+	base := req.Children[0].Value.(string)
+	scope := req.Children[1].Value.(int64)
+	sizeLimit := int(req.Children[3].Value.(int64))
+	filter := req.Children[6]
+	var attrs []string
+	for _, a := range req.Children[7].Children {
+		attrs = append(attrs, a.Value.(string))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := 0
+	for dn, entry := range s.entries {
+		inScope := strings.EqualFold(dn, base)
+		if scope != ldap.ScopeBaseObject {
+			inScope = strings.HasSuffix(strings.ToLower(dn), ","+strings.ToLower(base))
+		}
+		if !inScope || !matchLDAPFilter(filter, entry) {
+			continue
+		}
+		if sizeLimit > 0 && found == sizeLimit {
+			writeLDAPResult(w, msgID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSizeLimitExceeded)
+			return
+		}
+		found++
+
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, ""))
+		attributes := ber.NewSequence("")
+		for _, name := range attrs {
+			if values, ok := entry[name]; ok {
+				attr := ber.NewSequence("")
+				attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+				set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+				for _, v := range values {
+					set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+				}
+				attr.AppendChild(set)
+				attributes.AppendChild(attr)
+			}
+		}
+		result.AppendChild(attributes)
+		writeLDAPMessage(w, msgID, result)
+	}
+	writeLDAPResult(w, msgID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)
+}
+
+// matchLDAPFilter evaluates and, equality and presence filters
+func matchLDAPFilter(filter *ber.Packet, entry map[string][]string) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchLDAPFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterEqualityMatch:
+		for _, v := range entry[filter.Children[0].Value.(string)] {
+			if strings.EqualFold(v, filter.Children[1].Value.(string)) {
+				return true
+			}
+		}
+	case ldap.FilterPresent:
+		name := filter.Data.String()
+		return strings.EqualFold(name, "objectClass") || len(entry[name]) > 0
+	}
+	return false
+}
+
+func (s *testLDAPServer) modify(req *ber.Packet) uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[req.Children[0].Value.(string)]
+	if !ok {
+		return ldap.LDAPResultNoSuchObject
+	}
+	for _, change := range req.Children[1].Children {
+		op := change.Children[0].Value.(int64)
+		name := change.Children[1].Children[0].Value.(string)
+		var values []string
+		for _, v := range change.Children[1].Children[1].Children {
+			values = append(values, v.Value.(string))
+		}
+		switch op {
+		case ldap.AddAttribute:
+			entry[name] = append(entry[name], values...)
+		case ldap.ReplaceAttribute:
+			entry[name] = values
+		case ldap.DeleteAttribute:
+			for _, v := range values {
+				kept := entry[name][:0]
+				for _, existing := range entry[name] {
+					if existing != v {
+						kept = append(kept, existing)
+					}
+				}
+				if len(kept) == len(entry[name]) {
+					return ldap.LDAPResultNoSuchAttribute
+				}
+				entry[name] = kept
+			}
+		}
+	}
+	return ldap.LDAPResultSuccess
+}
+
+func writeLDAPResult(w io.Writer, msgID int64, tag ber.Tag, code uint16) {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	writeLDAPMessage(w, msgID, result)
+}
+
+func writeLDAPMessage(w io.Writer, msgID int64, op *ber.Packet) {
+	envelope := ber.NewSequence("")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, ""))
+	envelope.AppendChild(op)
+	w.Write(envelope.Bytes())
+}
+
+func (s *testLDAPServer) attr(dn, name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.entries[dn][name]...)
+}
+
+func (s *testLDAPServer) integration(scheme string) database.Integration {
+	return database.Integration{
+		Name:        "Directory",
+		Type:        "LDAP",
+		BaseURL:     scheme + "://" + s.addr,
+		AuthType:    "basic",
+		Credentials: `{"username":"` + testLDAPBindDN + `","password":"pw"}`,
+		CACert:      s.caCert,
+	}
+}
+
+func TestExecuteLDAP_DisableUserOverLDAPS(t *testing.T) {
+	srv := startLDAPServer(t, true)
+	executor := NewActionExecutor()
+	integ := srv.integration("ldaps")
+	data := map[string]interface{}{"UserEmail": "jdoe@corp.example.com"}
+	john := "CN=John Doe,OU=Users,DC=corp"
+
+	disable := database.ActionDefinition{Name: "Disable AD User", Method: "MODIFY", BodyTemplate: `{
+		"base_dn": "OU=Users,DC=corp", "filter": "(mail={{.UserEmail}})",
+		"changes": [
+			{"op": "set_bits", "attribute": "userAccountControl", "values": ["0x2"]},
+			{"op": "replace", "attribute": "pwdLastSet", "values": ["0"]}
+		]}`}
+	resp, err := executor.executeLDAP(context.Background(), integ, disable, data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"dn": "`+john+`", "changes": 2}`, string(resp))
+	assert.Equal(t, []string{"514"}, srv.attr(john, "userAccountControl"))
+	assert.Equal(t, []string{"0"}, srv.attr(john, "pwdLastSet"))
+
+	// Running it again leaves the same state
+	_, err = executor.executeLDAP(context.Background(), integ, disable, data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"514"}, srv.attr(john, "userAccountControl"))
+
+	enable := database.ActionDefinition{Name: "Enable AD User", Method: "MODIFY", BodyTemplate: `{"dn": "CN={{.Name}},OU=Users,DC=corp",
+		"changes": [{"op": "clear_bits", "attribute": "userAccountControl", "values": ["2"]}]}`}
+	_, err = executor.executeLDAP(context.Background(), integ, enable, map[string]interface{}{"Name": "John Doe"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"512"}, srv.attr(john, "userAccountControl"))
+
+	search := database.ActionDefinition{Name: "Find User", Method: "SEARCH",
+		BodyTemplate: `{"base_dn": "DC=corp", "filter": "(mail={{.UserEmail}})", "attributes": ["mail", "userAccountControl"]}`}
+	resp, err = executor.executeLDAP(context.Background(), integ, search, data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"entries": [{"dn": "`+john+`", "attributes": {"mail": ["jdoe@corp.example.com"], "userAccountControl": ["512"]}}]}`, string(resp))
+}
+
+func TestExecuteLDAP_RemoveMemberOverStartTLS(t *testing.T) {
+	srv := startLDAPServer(t, false)
+	executor := NewActionExecutor()
+	integ := srv.integration("ldap")
+	integ.StartTLS = true
+	def := database.ActionDefinition{Name: "Remove From Admins", Method: "REMOVE_MEMBER",
+		BodyTemplate: `{"group_dn": "CN=Admins,OU=Groups,DC=corp", "base_dn": "DC=corp", "filter": "(mail={{.UserEmail}})"}`}
+	data := map[string]interface{}{"UserEmail": "jdoe@corp.example.com"}
+
+	resp, err := executor.executeLDAP(context.Background(), integ, def, data)
+	require.NoError(t, err)
+	assert.Contains(t, string(resp), `"removed":true`)
+	assert.Equal(t, []string{"CN=Jane Roe,OU=Users,DC=corp"}, srv.attr("CN=Admins,OU=Groups,DC=corp", "member"))
+
+	// Not a member any more is success
+	resp, err = executor.executeLDAP(context.Background(), integ, def, data)
+	require.NoError(t, err)
+	assert.Contains(t, string(resp), `"removed":false`)
+
+	// Simple bind without TLS is refused before connecting
+	integ.StartTLS = false
+	_, err = executor.executeLDAP(context.Background(), integ, def, data)
+	assert.ErrorContains(t, err, "clear text")
+}
+
+func TestExecuteLDAP_FailureModes(t *testing.T) {
+	srv := startLDAPServer(t, true)
+	executor := NewActionExecutor()
+	def := database.ActionDefinition{Name: "Reset pwdLastSet", Method: "MODIFY", BodyTemplate: `{"base_dn": "DC=corp", "filter": "(mail={{.UserEmail}})",
+		"changes": [{"op": "replace", "attribute": "pwdLastSet", "values": ["0"]}]}`}
+	run := func(integ database.Integration, email string) error {
+		_, err := executor.executeLDAP(context.Background(), integ, def, map[string]interface{}{"UserEmail": email})
+		return err
+	}
+
+	// A wildcard in the identity is escaped and matches nobody
+	err := run(srv.integration("ldaps"), "*")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no entry under")
+	assert.Equal(t, ErrorClassNotFound, ClassifyError(0, err))
+
+	integ := srv.integration("ldaps")
+	integ.Credentials = `{"username":"` + testLDAPBindDN + `","password":"wrong"}`
+	err = run(integ, "jdoe@corp.example.com")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rejected the bind credentials")
+	assert.Equal(t, ErrorClassAuth, ClassifyError(0, err))
+
+	integ = srv.integration("ldaps")
+	integ.CACert = ""
+	assert.ErrorContains(t, run(integ, "jdoe@corp.example.com"), "not signed by a trusted CA")
+
+	def.Method = "DELETE_USER"
+	assert.ErrorContains(t, run(srv.integration("ldaps"), "jdoe@corp.example.com"), "unsupported operation")
+}
+
+func TestHealthCheckLDAP(t *testing.T) {
+	srv := startLDAPServer(t, true)
+	executor := NewActionExecutor()
+	integ := srv.integration("ldaps")
+	assert.NoError(t, executor.healthCheck(integ))
+
+	integ.Credentials = `{"username":"` + testLDAPBindDN + `","password":"wrong"}`
+	assert.ErrorContains(t, executor.healthCheck(integ), "rejected the bind credentials")
+}
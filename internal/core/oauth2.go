@@ -0,0 +1,344 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/logging"
+	"remediation-engine/internal/metrics"
+	"remediation-engine/internal/security"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// OAuth2 grants and client authentication methods, set in the credentials as grant_type
+// and client_auth
+const (
+	oauthGrantClientCredentials = "client_credentials"
+	oauthGrantRefreshToken      = "refresh_token"
+	oauthGrantJWTBearer         = "jwt_bearer"
+
+	oauthAuthSecretPost  = "client_secret_post"
+	oauthAuthSecretBasic = "client_secret_basic"
+	oauthAuthPrivateKey  = "private_key_jwt"
+	oauthAuthNone        = "none"
+
+	jwtBearerGrantType     = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	jwtBearerAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+const (
+	tokenExpiryLeeway    = time.Minute
+	defaultTokenLifetime = time.Hour // When the server sends no expires_in; a 401 still forces a refresh
+	assertionLifetime    = 5 * time.Minute
+)
+
+// oauthToken is the cached token of one integration. lock is held while refreshing so that
+// concurrent workers wait for a single token request instead of each sending their own.
+type oauthToken struct {
+	lock chan struct{}
+
+	fingerprint  string // Token endpoint and credentials the token was issued for
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time
+}
+
+var (
+	oauthTokensMu sync.Mutex
+	oauthTokens   = make(map[uint]*oauthToken)
+)
+
+func oauthTokenFor(integrationID uint) *oauthToken {
+	oauthTokensMu.Lock()
+	defer oauthTokensMu.Unlock()
+	t, ok := oauthTokens[integrationID]
+	if !ok {
+		t = &oauthToken{lock: make(chan struct{}, 1)}
+		oauthTokens[integrationID] = t
+	}
+	return t
+}
+
+// invalidateOAuthToken drops the cached access token after the vendor rejected it, unless
+// another worker has already replaced it
+func invalidateOAuthToken(integration database.Integration, rejected string) {
+	t := oauthTokenFor(integration.ID)
+	t.lock <- struct{}{}
+	defer func() { <-t.lock }()
+	if t.accessToken != rejected {
+		return
+	}
+	t.accessToken, t.expiresAt = "", time.Time{}
+	database.DB.Model(&database.Integration{ID: integration.ID}).UpdateColumns(map[string]interface{}{
+		"o_auth_token":      "",
+		"o_auth_expires_at": nil,
+	})
+}
+
+func oauthFingerprint(integration database.Integration) string {
+	sum := sha256.Sum256([]byte(integration.TokenEndpoint + "\n" + integration.Credentials))
+	return hex.EncodeToString(sum[:])
+}
+
+// oauthAccessToken returns a valid access token for the integration, requesting a new one
+// when the cached token is missing or about to expire
+func (e *ActionExecutor) oauthAccessToken(ctx context.Context, integration database.Integration, creds map[string]string) (string, error) {
+	t := oauthTokenFor(integration.ID)
+	select {
+	case t.lock <- struct{}{}:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	defer func() { <-t.lock }()
+
+	// Start from the stored token after a restart, or after the credentials changed
+	if fp := oauthFingerprint(integration); t.fingerprint != fp {
+		t.fingerprint, t.accessToken, t.refreshToken, t.expiresAt = fp, integration.OAuthToken, integration.OAuthRefreshToken, time.Time{}
+		if integration.OAuthExpiresAt != nil {
+			t.expiresAt = *integration.OAuthExpiresAt
+		}
+	}
+	if t.accessToken != "" && e.now().Add(tokenExpiryLeeway).Before(t.expiresAt) {
+		return t.accessToken, nil
+	}
+
+	token, err := e.requestOAuth2Token(ctx, integration, creds, t.refreshToken)
+	metrics.TokenRefreshes.WithLabelValues(metrics.Tenant(integration.TenantID), metrics.Integration(integration.Name), metrics.Outcome(err)).Inc()
+	if err != nil {
+		return "", err
+	}
+
+	t.accessToken, t.expiresAt = token.AccessToken, e.now().Add(token.lifetime())
+	updates := map[string]interface{}{
+		"o_auth_token":      t.accessToken,
+		"o_auth_expires_at": t.expiresAt,
+	}
+	if token.RefreshToken != "" && token.RefreshToken != t.refreshToken {
+		// Servers that rotate refresh tokens invalidate the previous one
+		t.refreshToken = token.RefreshToken
+		encrypted, err := security.Encrypt(token.RefreshToken)
+		if err != nil {
+			return "", err
+		}
+		updates["o_auth_refresh_token"] = encrypted
+	}
+	database.DB.Model(&database.Integration{ID: integration.ID}).UpdateColumns(updates)
+	return t.accessToken, nil
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r oauthTokenResponse) lifetime() time.Duration {
+	if r.ExpiresIn <= 0 {
+		return defaultTokenLifetime
+	}
+	return time.Duration(r.ExpiresIn) * time.Second
+}
+
+// requestOAuth2Token sends the configured grant to the token endpoint. Supported grants are
+// client_credentials (default), refresh_token and jwt_bearer (RFC 7523); the client
+// authenticates with client_secret_post (default), client_secret_basic, private_key_jwt or
+// none.
+func (e *ActionExecutor) requestOAuth2Token(ctx context.Context, integration database.Integration, creds map[string]string, refreshToken string) (oauthTokenResponse, error) {
+	executorLog.DebugContext(ctx, "Refreshing OAuth2 token", logging.FieldIntegration, integration.Name, "grant", creds["grant_type"])
+	var token oauthTokenResponse
+	if integration.TokenEndpoint == "" {
+		return token, fmt.Errorf("no token endpoint configured")
+	}
+
+	form := url.Values{}
+	switch grant := strings.ToLower(creds["grant_type"]); grant {
+	case "", oauthGrantClientCredentials:
+		form.Set("grant_type", oauthGrantClientCredentials)
+	case oauthGrantRefreshToken:
+		if refreshToken == "" {
+			refreshToken = creds["refresh_token"]
+		}
+		if refreshToken == "" {
+			return token, fmt.Errorf("the refresh_token grant needs a refresh_token in the credentials")
+		}
+		form.Set("grant_type", oauthGrantRefreshToken)
+		form.Set("refresh_token", refreshToken)
+	case oauthGrantJWTBearer, jwtBearerGrantType:
+		subject := creds["subject"]
+		if subject == "" {
+			subject = creds["client_id"]
+		}
+		issuer := creds["issuer"]
+		if issuer == "" {
+			issuer = creds["client_id"]
+		}
+		assertion, err := e.signAssertion(creds, issuer, subject, integration.TokenEndpoint)
+		if err != nil {
+			return token, err
+		}
+		form.Set("grant_type", jwtBearerGrantType)
+		form.Set("assertion", assertion)
+	default:
+		return token, fmt.Errorf("unsupported grant_type %q (use client_credentials, refresh_token or jwt_bearer)", grant)
+	}
+	if scope := oauthScope(creds["scope"]); scope != "" {
+		form.Set("scope", scope)
+	}
+	if audience := creds["audience"]; audience != "" {
+		form.Set("audience", audience)
+	}
+
+	basicAuth := false
+	switch method := strings.ToLower(creds["client_auth"]); method {
+	case "", oauthAuthSecretPost:
+		form.Set("client_id", creds["client_id"])
+		if creds["client_secret"] != "" {
+			form.Set("client_secret", creds["client_secret"])
+		}
+	case oauthAuthSecretBasic, "basic":
+		basicAuth = true
+	case oauthAuthPrivateKey:
+		assertion, err := e.signAssertion(creds, creds["client_id"], creds["client_id"], integration.TokenEndpoint)
+		if err != nil {
+			return token, err
+		}
+		form.Set("client_id", creds["client_id"])
+		form.Set("client_assertion_type", jwtBearerAssertionType)
+		form.Set("client_assertion", assertion)
+	case oauthAuthNone:
+		if creds["client_id"] != "" {
+			form.Set("client_id", creds["client_id"])
+		}
+	default:
+		return token, fmt.Errorf("unsupported client_auth %q (use client_secret_post, client_secret_basic, private_key_jwt or none)", method)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", integration.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basicAuth {
+		// RFC 6749 section 2.3.1: both parts are form-encoded before Base64
+		req.SetBasicAuth(url.QueryEscape(creds["client_id"]), url.QueryEscape(creds["client_secret"]))
+	}
+
+	client, err := e.httpClient(integration)
+	if err != nil {
+		return token, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return token, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return token, fmt.Errorf("auth server returned %d: %s", resp.StatusCode, security.RedactText(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return token, fmt.Errorf("invalid token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return token, fmt.Errorf("token response has no access_token")
+	}
+	return token, nil
+}
+
+// oauthScope accepts scopes separated by spaces or commas
+func oauthScope(raw string) string {
+	return strings.Join(strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == ' ' }), " ")
+}
+
+// signAssertion builds a JWT signed with the private_key credential, for private_key_jwt
+// client authentication and the jwt_bearer grant. RSA keys sign with RS256 and EC keys with
+// ES256/384/512. key_id sets the kid header; a PEM certificate adds the x5t thumbprint that
+// Microsoft Entra requires.
+func (e *ActionExecutor) signAssertion(creds map[string]string, issuer, subject, audience string) (string, error) {
+	key, method, err := parseSigningKey(creds["private_key"])
+	if err != nil {
+		return "", err
+	}
+	now := e.now()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
+		"iss": issuer,
+		"sub": subject,
+		"aud": audience,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(assertionLifetime).Unix(),
+		"jti": uuid.New().String(),
+	})
+	if creds["key_id"] != "" {
+		token.Header["kid"] = creds["key_id"]
+	}
+	if creds["certificate"] != "" {
+		block, _ := pem.Decode([]byte(creds["certificate"]))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return "", fmt.Errorf("the certificate credential is not a PEM certificate")
+		}
+		thumbprint := sha1.Sum(block.Bytes)
+		token.Header["x5t"] = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the assertion: %v", err)
+	}
+	return signed, nil
+}
+
+func parseSigningKey(keyPEM string) (interface{}, jwt.SigningMethod, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, nil, fmt.Errorf("private_key is not a PEM private key")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported private_key type %s", block.Type)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid private_key: %v", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return k, jwt.SigningMethodES256, nil
+		case 384:
+			return k, jwt.SigningMethodES384, nil
+		case 521:
+			return k, jwt.SigningMethodES512, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("private_key must be an RSA or EC (P-256, P-384, P-521) key")
+}
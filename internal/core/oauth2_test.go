@@ -0,0 +1,221 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"remediation-engine/internal/database"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOAuthServer issues numbered tokens at /token and accepts API calls at /api carrying
+// one of the tokens in valid
+type testOAuthServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request // Token requests, with parsed forms
+	issued   atomic.Int32
+	valid    func(token string) bool
+	apiCalls []string
+	refresh  func(n int32) string // Refresh token to return with token n
+}
+
+func newTestOAuthServer(t *testing.T) *testOAuthServer {
+	s := &testOAuthServer{valid: func(string) bool { return true }}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			s.mu.Lock()
+			s.requests = append(s.requests, r)
+			s.mu.Unlock()
+			n := s.issued.Add(1)
+			resp := map[string]interface{}{"access_token": fmt.Sprintf("token-%d", n), "expires_in": 3600, "token_type": "Bearer"}
+			if s.refresh != nil {
+				resp["refresh_token"] = s.refresh(n)
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/api":
+			body, _ := io.ReadAll(r.Body)
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			s.mu.Lock()
+			s.apiCalls = append(s.apiCalls, token+" "+string(body))
+			s.mu.Unlock()
+			if !s.valid(token) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+var oauthIntegrations atomic.Int32
+
+func oauthIntegration(t *testing.T, srv *testOAuthServer, creds map[string]string) database.Integration {
+	raw, _ := json.Marshal(creds)
+	integ := database.Integration{
+		Name: fmt.Sprintf("OAuth2 App %d", oauthIntegrations.Add(1)), Type: "REST", BaseURL: srv.URL, AuthType: "oauth2", TokenEndpoint: srv.URL + "/token",
+		Credentials: string(raw), Enabled: true, TenantID: 1,
+	}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	require.NoError(t, database.DB.First(&integ, integ.ID).Error)
+	return integ
+}
+
+var oauthAction = database.ActionDefinition{Name: "Suspend", Method: "POST", PathTemplate: "/api", BodyTemplate: `{"user": "jdoe"}`, RetryCount: 1}
+
+func TestOAuth2_ClientCredentialsSingleFlight(t *testing.T) {
+	setupTestDB()
+	srv := newTestOAuthServer(t)
+	integ := oauthIntegration(t, srv, map[string]string{
+		"client_id": "engine app", "client_secret": "s3cr3t&more", "client_auth": "client_secret_basic",
+		"scope": "okta.users.manage, okta.groups.manage", "audience": "api://default",
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, code, err := NewActionExecutor().Execute(integ, oauthAction, map[string]interface{}{})
+			assert.NoError(t, err)
+			assert.Equal(t, 200, code)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, srv.requests, 1, "concurrent workers share one token request")
+	req := srv.requests[0]
+	assert.Equal(t, "client_credentials", req.PostForm.Get("grant_type"))
+	assert.Equal(t, "okta.users.manage okta.groups.manage", req.PostForm.Get("scope"))
+	assert.Equal(t, "api://default", req.PostForm.Get("audience"))
+	assert.Empty(t, req.PostForm.Get("client_secret"), "the secret goes in the header")
+	id, secret, ok := req.BasicAuth()
+	require.True(t, ok)
+	assert.Equal(t, "engine+app", id, "form-encoded before Base64")
+	assert.Equal(t, "s3cr3t%26more", secret)
+
+	var stored database.Integration
+	database.DB.First(&stored, integ.ID)
+	assert.Equal(t, "token-1", stored.OAuthToken)
+}
+
+func TestOAuth2_PrivateKeyJWTAndJWTBearer(t *testing.T) {
+	setupTestDB()
+	pki := newTestPKI(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	verify := func(assertion string) (jwt.MapClaims, map[string]interface{}) {
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(assertion, claims, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil },
+			jwt.WithValidMethods([]string{"ES256"}))
+		require.NoError(t, err)
+		return claims, token.Header
+	}
+
+	srv := newTestOAuthServer(t)
+	integ := oauthIntegration(t, srv, map[string]string{
+		"client_id": "0oa1client", "client_auth": "private_key_jwt", "private_key": keyPEM, "key_id": "k1",
+		"certificate": pki.clientCert, "scope": "https://graph.microsoft.com/.default",
+	})
+	_, _, err = NewActionExecutor().Execute(integ, oauthAction, map[string]interface{}{})
+	require.NoError(t, err)
+	form := srv.requests[0].PostForm
+	assert.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", form.Get("client_assertion_type"))
+	assert.Equal(t, "0oa1client", form.Get("client_id"))
+	claims, header := verify(form.Get("client_assertion"))
+	assert.Equal(t, "0oa1client", claims["iss"])
+	assert.Equal(t, "0oa1client", claims["sub"])
+	assert.Equal(t, srv.URL+"/token", claims["aud"])
+	assert.Equal(t, "k1", header["kid"])
+	assert.NotEmpty(t, header["x5t"])
+
+	// jwt_bearer exchanges an assertion for the configured subject
+	integ = oauthIntegration(t, srv, map[string]string{
+		"grant_type": "jwt_bearer", "client_auth": "none", "client_id": "svc@corp", "subject": "admin@corp", "private_key": keyPEM,
+	})
+	_, _, err = NewActionExecutor().Execute(integ, oauthAction, map[string]interface{}{})
+	require.NoError(t, err)
+	form = srv.requests[1].PostForm
+	assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", form.Get("grant_type"))
+	claims, _ = verify(form.Get("assertion"))
+	assert.Equal(t, "svc@corp", claims["iss"])
+	assert.Equal(t, "admin@corp", claims["sub"])
+}
+
+func TestOAuth2_RefreshTokenRotationAnd401(t *testing.T) {
+	setupTestDB()
+	srv := newTestOAuthServer(t)
+	srv.refresh = func(n int32) string { return fmt.Sprintf("refresh-%d", n) }
+	// The first token is revoked before it expires
+	srv.valid = func(token string) bool { return token != "token-1" }
+	integ := oauthIntegration(t, srv, map[string]string{
+		"grant_type": "refresh_token", "refresh_token": "refresh-0", "client_id": "entra-app", "client_secret": "pw",
+	})
+
+	executor := NewActionExecutor()
+	_, code, err := executor.Execute(integ, oauthAction, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+
+	require.Len(t, srv.requests, 2)
+	assert.Equal(t, "refresh-0", srv.requests[0].PostForm.Get("refresh_token"))
+	assert.Equal(t, "refresh-1", srv.requests[1].PostForm.Get("refresh_token"), "the rotated refresh token is used")
+	assert.Equal(t, []string{`token-1 {"user": "jdoe"}`, `token-2 {"user": "jdoe"}`}, srv.apiCalls, "the body is sent again")
+
+	var stored database.Integration
+	database.DB.First(&stored, integ.ID)
+	assert.Equal(t, "token-2", stored.OAuthToken)
+	assert.Equal(t, "refresh-2", stored.OAuthRefreshToken)
+	var raw struct{ OAuthRefreshToken string }
+	database.DB.Raw("SELECT o_auth_refresh_token FROM integrations WHERE id = ?", integ.ID).Scan(&raw)
+	assert.NotEmpty(t, raw.OAuthRefreshToken)
+	assert.NotEqual(t, "refresh-2", raw.OAuthRefreshToken, "refresh tokens are encrypted at rest")
+
+	// A token that keeps being rejected is not retried forever
+	srv.valid = func(string) bool { return false }
+	_, code, err = executor.Execute(integ, oauthAction, map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Equal(t, 401, code)
+	assert.Len(t, srv.requests, 3)
+}
+
+func TestOAuth2_Rejects(t *testing.T) {
+	setupTestDB()
+	srv := newTestOAuthServer(t)
+	for creds, want := range map[string]string{
+		`{"grant_type": "password"}`:                      `unsupported grant_type "password"`,
+		`{"client_auth": "tls_client_auth"}`:              `unsupported client_auth "tls_client_auth"`,
+		`{"grant_type": "refresh_token"}`:                 "the refresh_token grant needs a refresh_token",
+		`{"client_auth": "private_key_jwt"}`:              "private_key is not a PEM private key",
+		`{"grant_type": "jwt_bearer", "private_key": ""}`: "private_key is not a PEM private key",
+	} {
+		var c map[string]string
+		json.Unmarshal([]byte(creds), &c)
+		integ := oauthIntegration(t, srv, c)
+		_, _, err := NewActionExecutor().Execute(integ, oauthAction, map[string]interface{}{})
+		assert.ErrorContains(t, err, "oauth2 refresh failed: "+want)
+		assert.Equal(t, ErrorClassAuth, ClassifyError(0, err))
+	}
+	assert.Empty(t, srv.requests)
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxErrorSummary bounds how much of the error stream is repeated in the step error message;
+// the full stream is kept in the step response
+const maxErrorSummary = 500
+
+// PowerShellError is returned when a script exits with a non-zero code or writes to its
+// error stream (throw, Write-Error, failed cmdlets)
+type PowerShellError struct {
+	ExitCode int
+	Stderr   string
+}
+
+func (e *PowerShellError) Error() string {
+	summary := summarizeStderr(e.Stderr)
+	if e.ExitCode != 0 {
+		if summary == "" {
+			return fmt.Sprintf("powershell exited with code %d", e.ExitCode)
+		}
+		return fmt.Sprintf("powershell exited with code %d: %s", e.ExitCode, summary)
+	}
+	return "powershell reported an error: " + summary
+}
+
+// commandResult is the step response recorded for failed scripts and remote commands
+type commandResult struct {
+	ExitCode int             `json:"exit_code"`
+	Signal   string          `json:"signal,omitempty"`
+	Stderr   string          `json:"stderr,omitempty"`
+	Stdout   string          `json:"stdout,omitempty"`
+	Output   json.RawMessage `json:"output,omitempty"` // JSON found in stdout
+}
+
+// powerShellOutcome turns a finished command into the step response. A successful script
+// that printed JSON (e.g. via ConvertTo-Json) responds with that JSON, like a REST body;
+// otherwise stdout is returned as is. Failures respond with exit code, stderr and stdout.
+func powerShellOutcome(exitCode int, stdout []byte, stderr []byte) ([]byte, error) {
+	errText := decodeCLIXML(string(stderr))
+	output, isJSON := extractJSON(stdout)
+
+	if exitCode == 0 && strings.TrimSpace(errText) == "" {
+		if isJSON {
+			return output, nil
+		}
+		return stdout, nil
+	}
+
+	result := commandResult{ExitCode: exitCode, Stderr: errText}
+	if isJSON {
+		result.Output = output
+	} else {
+		result.Stdout = string(stdout)
+	}
+	body, _ := json.Marshal(result)
+	return body, &PowerShellError{ExitCode: exitCode, Stderr: errText}
+}
+
+// extractJSON returns stdout as compact JSON when it is a JSON document, or when it ends in
+// one that starts on its own line (warnings or host output before ConvertTo-Json output)
+func extractJSON(stdout []byte) (json.RawMessage, bool) {
+	trimmed := bytes.TrimSpace(stdout)
+	if len(trimmed) == 0 {
+		return nil, false
+	}
+
+	candidates := [][]byte{trimmed}
+	for i := 0; i < len(trimmed); i++ {
+		if (trimmed[i] == '{' || trimmed[i] == '[') && i > 0 && trimmed[i-1] == '\n' {
+			candidates = append(candidates, trimmed[i:])
+		}
+	}
+	for _, c := range candidates {
+		if c[0] != '{' && c[0] != '[' {
+			continue
+		}
+		var buf bytes.Buffer
+		if json.Compact(&buf, c) == nil {
+			return buf.Bytes(), true
+		}
+	}
+	return nil, false
+}
+
+// summarizeStderr returns the first non-empty line of the error stream, truncated
+func summarizeStderr(stderr string) string {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) > maxErrorSummary {
+			line = line[:maxErrorSummary] + "..."
+		}
+		return line
+	}
+	return ""
+}
+
+var clixmlEscape = regexp.MustCompile(`_x([0-9A-Fa-f]{4})_`)
+
+// decodeCLIXML extracts the error records from PowerShell's serialized stderr
+// ("#< CLIXML" followed by <Objs>). Progress and other non-error records are dropped.
+// Plain text is returned unchanged.
+func decodeCLIXML(stderr string) string {
+	const header = "#< CLIXML"
+	trimmed := strings.TrimSpace(stderr)
+	if !strings.HasPrefix(trimmed, header) {
+		return stderr
+	}
+
+	var objs struct {
+		Strings []struct {
+			Stream string `xml:"S,attr"`
+			Text   string `xml:",chardata"`
+		} `xml:"S"`
+	}
+	if err := xml.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(trimmed, header))), &objs); err != nil {
+		return stderr
+	}
+
+	var b strings.Builder
+	for _, s := range objs.Strings {
+		if s.Stream != "Error" {
+			continue
+		}
+		b.WriteString(clixmlEscape.ReplaceAllStringFunc(s.Text, func(m string) string {
+			code, _ := strconv.ParseUint(m[2:6], 16, 32)
+			return string(rune(code))
+		}))
+	}
+	return strings.ReplaceAll(b.String(), "\r\n", "\n")
+}
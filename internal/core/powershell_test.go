@@ -0,0 +1,66 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowerShellOutcome_Success(t *testing.T) {
+	// ConvertTo-Json output becomes the step response
+	resp, err := powerShellOutcome(0, []byte("{\r\n    \"SamAccountName\":  \"jdoe\",\r\n    \"Enabled\":  false\r\n}\r\n"), nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"SamAccountName":"jdoe","Enabled":false}`, string(resp))
+
+	// Host output before the JSON is skipped
+	resp, err = powerShellOutcome(0, []byte("WARNING: account already disabled\n[\n  1,\n  2\n]\n"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, `[1,2]`, string(resp))
+
+	// Plain text is returned unchanged
+	resp, err = powerShellOutcome(0, []byte("Account disabled\n"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Account disabled\n", string(resp))
+}
+
+func TestPowerShellOutcome_Failures(t *testing.T) {
+	// throw: non-zero exit code with the error record on stderr
+	stderr := `#< CLIXML
+<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj S="progress" RefId="0"><TN RefId="0"><T>System.Management.Automation.PSCustomObject</T></TN></Obj><S S="Error">Cannot find an object with identity: 'jdoe'._x000D__x000A_</S><S S="Error">At line:1 char:1_x000D__x000A_</S></Objs>`
+	resp, err := powerShellOutcome(1, []byte("partial\n"), []byte(stderr))
+	require.Error(t, err)
+
+	var psErr *PowerShellError
+	require.True(t, errors.As(err, &psErr))
+	assert.Equal(t, 1, psErr.ExitCode)
+	assert.Equal(t, "powershell exited with code 1: Cannot find an object with identity: 'jdoe'.", err.Error())
+
+	var result commandResult
+	require.NoError(t, json.Unmarshal(resp, &result))
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Equal(t, "Cannot find an object with identity: 'jdoe'.\nAt line:1 char:1\n", result.Stderr)
+	assert.Equal(t, "partial\n", result.Stdout)
+
+	// Write-Error: exit code 0 but the error stream is not empty
+	resp, err = powerShellOutcome(0, []byte(`{"done":false}`), []byte("Write-Error: quota exceeded\n"))
+	require.Error(t, err)
+	assert.Equal(t, "powershell reported an error: Write-Error: quota exceeded", err.Error())
+	require.NoError(t, json.Unmarshal(resp, &result))
+	assert.JSONEq(t, `{"done":false}`, string(result.Output))
+
+	// Exit code without any error text
+	_, err = powerShellOutcome(3, nil, nil)
+	assert.EqualError(t, err, "powershell exited with code 3")
+}
+
+func TestDecodeCLIXML_ProgressOnlyIsNotAnError(t *testing.T) {
+	stderr := `#< CLIXML
+<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04"><Obj S="progress" RefId="0"><TN RefId="0"><T>System.Management.Automation.PSCustomObject</T></TN><MS><I64 N="SourceId">1</I64></MS></Obj></Objs>`
+	assert.Equal(t, "", decodeCLIXML(stderr))
+
+	_, err := powerShellOutcome(0, []byte("ok"), []byte(stderr))
+	assert.NoError(t, err)
+}
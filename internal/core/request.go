@@ -0,0 +1,147 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const textEscaper = "_text"
+
+// textLanguage renders header and query parameter values as plain text, so missing values
+// are empty rather than "<no value>". Encoding is applied to the whole rendered value.
+type textLanguage struct{ output string }
+
+func (l textLanguage) noun() string { return l.output }
+
+func (textLanguage) describe(int) string { return "text" }
+
+func (textLanguage) scan(text []byte, state int) int { return state }
+
+func (textLanguage) escaper(int, string) (string, string) { return textEscaper, "" }
+
+// Headers that actions cannot set: the content type has its own field so the body is
+// escaped for it, and the others are managed by the HTTP client
+var reservedHeaders = map[string]string{
+	"Content-Type":      "use content_type instead",
+	"Content-Length":    "it is set from the body",
+	"Host":              "it is set from the integration's base URL",
+	"Transfer-Encoding": "it is set by the HTTP client",
+}
+
+// validHeaderName reports whether name is an RFC 7230 token
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func checkHeaderName(name string) error {
+	if !validHeaderName(name) {
+		return fmt.Errorf("%q is not a valid header name", name)
+	}
+	if reason, ok := reservedHeaders[http.CanonicalHeaderKey(name)]; ok {
+		return fmt.Errorf("header %s cannot be set: %s", http.CanonicalHeaderKey(name), reason)
+	}
+	return nil
+}
+
+// sortedKeys keeps rendered headers and query strings in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LintRequestTemplates checks the header names and the header and query parameter
+// templates of an HTTP action
+func LintRequestTemplates(headers, queryParams map[string]string) error {
+	for _, name := range sortedKeys(headers) {
+		if err := checkHeaderName(name); err != nil {
+			return err
+		}
+		if _, _, err := parseEscapedTemplate(headers[name], textLanguage{"header"}); err != nil {
+			return fmt.Errorf("header %s: %v", name, err)
+		}
+	}
+	for _, name := range sortedKeys(queryParams) {
+		if name == "" {
+			return fmt.Errorf("query parameter names cannot be empty")
+		}
+		if _, _, err := parseEscapedTemplate(queryParams[name], textLanguage{"query parameter"}); err != nil {
+			return fmt.Errorf("query parameter %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// renderQuery appends the rendered query parameters to rawURL. Names and values are
+// URL-encoded; parameters that render empty are left out so optional values can be
+// skipped.
+func (e *ActionExecutor) renderQuery(rawURL string, params map[string]string, data interface{}) (string, error) {
+	var pairs []string
+	for _, name := range sortedKeys(params) {
+		value, _, err := executeEscaped(params[name], textLanguage{"query parameter"}, data)
+		if err != nil {
+			return "", fmt.Errorf("failed to render query parameter %s: %v", name, err)
+		}
+		if value == "" {
+			continue
+		}
+		pairs = append(pairs, url.QueryEscape(name)+"="+url.QueryEscape(value))
+	}
+	if len(pairs) == 0 {
+		return rawURL, nil
+	}
+
+	fragment := ""
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		rawURL, fragment = rawURL[:i], rawURL[i:]
+	}
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+		if strings.HasSuffix(rawURL, "?") || strings.HasSuffix(rawURL, "&") {
+			sep = ""
+		}
+	}
+	return rawURL + sep + strings.Join(pairs, "&") + fragment, nil
+}
+
+// renderHeaders sets the rendered headers on req. Headers that render empty are left out;
+// values with line breaks are rejected so a parameter cannot add headers of its own.
+func (e *ActionExecutor) renderHeaders(req *http.Request, headers map[string]string, data interface{}) error {
+	for _, name := range sortedKeys(headers) {
+		if err := checkHeaderName(name); err != nil {
+			return fmt.Errorf("failed to render header: %v", err)
+		}
+		value, _, err := executeEscaped(headers[name], textLanguage{"header"}, data)
+		if err != nil {
+			return fmt.Errorf("failed to render header %s: %v", name, err)
+		}
+		value = strings.TrimSpace(value)
+		if strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("failed to render header %s: the value contains a line break", name)
+		}
+		if value == "" {
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"remediation-engine/internal/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteREST_HeadersQueryAndContentType(t *testing.T) {
+	setupTestDB()
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got, body = r, string(b)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	integ := database.Integration{Name: "Vendor", Type: "REST", BaseURL: srv.URL, AuthType: "none", Enabled: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	def := database.ActionDefinition{
+		Name: "Close Ticket", Method: "PATCH", PathTemplate: "/tickets/{{.Ticket}}?sysparm_display_value=true",
+		ContentType:  "application/x-www-form-urlencoded",
+		BodyTemplate: "state=closed&note={{.Note}}",
+		Headers: map[string]string{
+			"Accept":      "application/vnd.vendor.v2+json",
+			"If-Match":    `{{index .Steps "Get Ticket" "etag"}}`,
+			"X-Tenant-Id": "{{.Tenant}}",
+			"X-Optional":  "{{.Missing}}",
+		},
+		QueryParams: map[string]string{
+			"user":   "{{.UserEmail}}",
+			"filter": "{{.Filter}}",
+			"skip":   "{{.Missing}}",
+		},
+	}
+	data := map[string]interface{}{
+		"Ticket": "INC001", "Note": "a&b=c", "Tenant": "acme", "UserEmail": "j.doe+admin@corp.com", "Filter": "name eq \"x\" & y",
+		"Steps": map[string]interface{}{"Get Ticket": map[string]interface{}{"etag": `W/"3"`}},
+	}
+
+	_, code, err := NewActionExecutor().Execute(integ, def, data)
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+
+	assert.Equal(t, "/tickets/INC001", got.URL.Path)
+	assert.Equal(t, "sysparm_display_value=true&filter=name+eq+%22x%22+%26+y&user=j.doe%2Badmin%40corp.com", got.URL.RawQuery)
+	query := got.URL.Query()
+	assert.Equal(t, "j.doe+admin@corp.com", query.Get("user"))
+	assert.Equal(t, `name eq "x" & y`, query.Get("filter"))
+	assert.NotContains(t, query, "skip", "empty values are left out")
+
+	assert.Equal(t, "application/x-www-form-urlencoded", got.Header.Get("Content-Type"))
+	assert.Equal(t, "application/vnd.vendor.v2+json", got.Header.Get("Accept"))
+	assert.Equal(t, `W/"3"`, got.Header.Get("If-Match"))
+	assert.Equal(t, "acme", got.Header.Get("X-Tenant-Id"))
+	assert.NotContains(t, got.Header, "X-Optional")
+	assert.Equal(t, "state=closed&note=a%26b%3Dc", body)
+}
+
+func TestExecuteREST_RejectsHeaderInjection(t *testing.T) {
+	setupTestDB()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls++ }))
+	defer srv.Close()
+
+	integ := database.Integration{Name: "Vendor", Type: "REST", BaseURL: srv.URL, AuthType: "none", Enabled: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	def := database.ActionDefinition{Name: "Lookup", Method: "GET", PathTemplate: "/users", RetryCount: 1,
+		Headers: map[string]string{"X-User": "{{.UserEmail}}"}}
+	_, _, err := NewActionExecutor().Execute(integ, def, map[string]interface{}{"UserEmail": "x\r\nX-Admin: true"})
+	assert.ErrorContains(t, err, "failed to render header X-User: the value contains a line break")
+	assert.Equal(t, ErrorClassTemplate, ClassifyError(0, err))
+	assert.Zero(t, calls)
+}
+
+func TestLintRequestTemplates(t *testing.T) {
+	assert.NoError(t, LintRequestTemplates(map[string]string{"If-Match": "{{.ETag}}"}, map[string]string{"q": "{{.UserEmail | default \"x\"}}"}))
+	for _, tc := range []struct {
+		headers, query map[string]string
+		want           string
+	}{
+		{headers: map[string]string{"content-type": "text/plain"}, want: "header Content-Type cannot be set: use content_type instead"},
+		{headers: map[string]string{"Bad Header": "x"}, want: `"Bad Header" is not a valid header name`},
+		{headers: map[string]string{"X-A": "{{.A"}, want: "header X-A:"},
+		{query: map[string]string{"": "x"}, want: "query parameter names cannot be empty"},
+		{query: map[string]string{"q": "{{end}}"}, want: "query parameter q:"},
+	} {
+		assert.ErrorContains(t, LintRequestTemplates(tc.headers, tc.query), tc.want)
+	}
+}
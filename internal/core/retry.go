@@ -0,0 +1,181 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"remediation-engine/internal/database"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryAfter bounds how long a single Retry-After header can hold a worker
+const maxRetryAfter = 10 * time.Minute
+
+var defaultRetryStatuses = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooEarly,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// HTTPStatusError is returned for responses with a status code >= 400.
+// It keeps the headers so retry logic can honor Retry-After.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       []byte
+	Header     http.Header
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, string(e.Body))
+}
+
+// retryPolicy is a RetryPolicy with defaults applied
+type retryPolicy struct {
+	statuses        map[int]bool
+	networkErrors   bool
+	nonIdempotentOK bool
+	initial         time.Duration
+	maxBackoff      time.Duration
+	multiplier      float64
+	jitter          float64
+	maxElapsed      time.Duration
+}
+
+func resolveRetryPolicy(p database.RetryPolicy) retryPolicy {
+	r := retryPolicy{
+		statuses:        make(map[int]bool),
+		networkErrors:   true,
+		nonIdempotentOK: p.RetryNonIdempotent,
+		initial:         time.Second,
+		maxBackoff:      30 * time.Second,
+		multiplier:      2,
+		jitter:          0.2,
+		maxElapsed:      time.Duration(p.MaxElapsedSeconds) * time.Second,
+	}
+
+	statuses := p.RetryOnStatus
+	if len(statuses) == 0 {
+		statuses = defaultRetryStatuses
+	}
+	for _, s := range statuses {
+		r.statuses[s] = true
+	}
+
+	if p.RetryNetworkErrors != nil {
+		r.networkErrors = *p.RetryNetworkErrors
+	}
+	if p.InitialBackoffMs > 0 {
+		r.initial = time.Duration(p.InitialBackoffMs) * time.Millisecond
+	}
+	if p.MaxBackoffMs > 0 {
+		r.maxBackoff = time.Duration(p.MaxBackoffMs) * time.Millisecond
+	}
+	if p.Multiplier >= 1 {
+		r.multiplier = p.Multiplier
+	}
+	if p.Jitter != nil && *p.Jitter >= 0 && *p.Jitter <= 1 {
+		r.jitter = *p.Jitter
+	}
+	return r
+}
+
+// isIdempotentMethod reports whether repeating the call cannot cause duplicate side effects
+func isIdempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE", "TRACE":
+		return true
+	case ldapOpSearch, ldapOpModify, ldapOpRemoveMember:
+		// LDAP modifications set attributes to a target state; re-adding an existing value
+		// fails with a conflict rather than duplicating it
+		return true
+	case scimOpFindUser, scimOpDeactivateUser, scimOpDeleteUser:
+		return true
+	}
+	return false
+}
+
+// isNetworkError reports whether err came from the transport rather than the remote service
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryable decides whether a failed attempt should be repeated
+func (p retryPolicy) retryable(method string, code int, err error) bool {
+	if !isIdempotentMethod(method) && !p.nonIdempotentOK {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return p.statuses[statusErr.StatusCode]
+	}
+	if code != 0 {
+		return p.statuses[code]
+	}
+	return p.networkErrors && isNetworkError(err)
+}
+
+// backoff returns the delay before the given retry (1-based). Jitter is applied before the
+// cap, so no delay exceeds maxBackoff.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	d := float64(p.initial)
+	for i := 1; i < retry; i++ {
+		d *= p.multiplier
+		if d >= float64(p.maxBackoff) {
+			break
+		}
+	}
+	if d > float64(p.maxBackoff) {
+		d = float64(p.maxBackoff)
+	}
+	if p.jitter > 0 {
+		d += d * p.jitter * (2*rand.Float64() - 1)
+	}
+	if d > float64(p.maxBackoff) {
+		d = float64(p.maxBackoff)
+	}
+	return time.Duration(d)
+}
+
+// retryAfter extracts a server-requested delay from a 429/503 response
+func retryAfter(err error, now time.Time) (time.Duration, bool) {
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.Header == nil {
+		return 0, false
+	}
+	if statusErr.StatusCode != http.StatusTooManyRequests && statusErr.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	return parseRetryAfter(statusErr.Header.Get("Retry-After"), now)
+}
+
+// parseRetryAfter accepts both delta-seconds and HTTP-date forms (RFC 9110 10.2.3)
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
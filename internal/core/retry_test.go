@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/metrics"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sequenceExecutor returns an executor that replays the given responses and records sleeps.
+// Its clock only advances when it sleeps.
+func sequenceExecutor(t *testing.T, responses []*http.Response) (*ActionExecutor, *int, *[]time.Duration) {
+	calls := 0
+	var sleeps []time.Duration
+	executor := NewActionExecutor()
+	clock := time.Now()
+	executor.now = func() time.Time { return clock }
+	executor.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		clock = clock.Add(d)
+		return nil
+	}
+	executor.Client.Transport = &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			resp := responses[calls]
+			if calls < len(responses)-1 {
+				calls++
+			}
+			return resp, nil
+		},
+	}
+	return executor, &calls, &sleeps
+}
+
+func response(code int, headers map[string]string) *http.Response {
+	h := make(http.Header)
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return &http.Response{StatusCode: code, Body: io.NopCloser(bytes.NewBufferString("{}")), Header: h}
+}
+
+func retryTestIntegration(t *testing.T) database.Integration {
+	integ := database.Integration{Name: "Retry Target", BaseURL: "http://retry", IsAvailable: true, TenantID: 1, CircuitFailureThreshold: 100}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	return integ
+}
+
+func TestRetry_HonorsRetryAfter(t *testing.T) {
+	setupTestDB()
+	executor, _, sleeps := sequenceExecutor(t, []*http.Response{
+		response(429, map[string]string{"Retry-After": "7"}),
+		response(200, nil),
+	})
+	retries := metrics.Retries.WithLabelValues("1", "Retry Target", "Get")
+	before := testutil.ToFloat64(retries)
+
+	_, code, err := executor.Execute(retryTestIntegration(t), database.ActionDefinition{Name: "Get", Method: "GET"}, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	require.Len(t, *sleeps, 1)
+	assert.Equal(t, 7*time.Second, (*sleeps)[0])
+	assert.Equal(t, before+1, testutil.ToFloat64(retries))
+}
+
+func TestRetry_DoesNotRetryValidationErrors(t *testing.T) {
+	setupTestDB()
+	executor, _, sleeps := sequenceExecutor(t, []*http.Response{response(400, nil), response(200, nil)})
+
+	_, code, err := executor.Execute(retryTestIntegration(t), database.ActionDefinition{Name: "Get", Method: "GET"}, map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Equal(t, 400, code)
+	assert.Empty(t, *sleeps)
+}
+
+func TestRetry_NonIdempotentRequiresOptIn(t *testing.T) {
+	setupTestDB()
+	integ := retryTestIntegration(t)
+
+	executor, _, sleeps := sequenceExecutor(t, []*http.Response{response(503, nil), response(200, nil)})
+	_, _, err := executor.Execute(integ, database.ActionDefinition{Name: "Post", Method: "POST"}, map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Empty(t, *sleeps)
+
+	executor, _, sleeps = sequenceExecutor(t, []*http.Response{response(503, nil), response(200, nil)})
+	safe := database.ActionDefinition{Name: "Post", Method: "POST", RetryPolicy: database.RetryPolicy{RetryNonIdempotent: true}}
+	_, _, err = executor.Execute(integ, safe, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Len(t, *sleeps, 1)
+}
+
+func TestRetry_CustomStatusesAndElapsedBudget(t *testing.T) {
+	setupTestDB()
+	noJitter := 0.0
+	def := database.ActionDefinition{
+		Name: "Get", Method: "GET", RetryCount: 5,
+		RetryPolicy: database.RetryPolicy{
+			RetryOnStatus:     []int{409},
+			InitialBackoffMs:  1000,
+			MaxBackoffMs:      1500,
+			Jitter:            &noJitter,
+			MaxElapsedSeconds: 3,
+		},
+	}
+
+	executor, _, sleeps := sequenceExecutor(t, []*http.Response{response(409, nil)})
+
+	_, _, err := executor.Execute(retryTestIntegration(t), def, map[string]interface{}{})
+	assert.Error(t, err)
+	// 1s, then capped at 1.5s; a third delay would exceed the 3s budget
+	assert.Equal(t, []time.Duration{time.Second, 1500 * time.Millisecond}, *sleeps)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	d, ok := parseRetryAfter("120", now)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, d)
+
+	d, ok = parseRetryAfter("Wed, 01 May 2024 12:00:30 GMT", now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, d)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+}
+
+func TestRetryPolicy_BackoffJitterBounds(t *testing.T) {
+	p := resolveRetryPolicy(database.RetryPolicy{InitialBackoffMs: 1000, MaxBackoffMs: 4000})
+	for retry := 1; retry <= 6; retry++ {
+		d := p.backoff(retry)
+		assert.LessOrEqual(t, d, 4*time.Second)
+		assert.GreaterOrEqual(t, d, time.Duration(float64(time.Second)*0.8))
+	}
+
+	// At the cap, jitter only shortens the delay
+	for i := 0; i < 100; i++ {
+		d := p.backoff(5)
+		assert.LessOrEqual(t, d, 4*time.Second)
+		assert.GreaterOrEqual(t, d, time.Duration(float64(4*time.Second)*0.8))
+	}
+}
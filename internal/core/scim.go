@@ -0,0 +1,316 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/security"
+	"strings"
+)
+
+// SCIM operations, selected by the action definition's Method. REMOVE_MEMBER is shared
+// with LDAP.
+const (
+	scimOpFindUser       = "FIND_USER"
+	scimOpDeactivateUser = "DEACTIVATE_USER"
+	scimOpDeleteUser     = "DELETE_USER"
+)
+
+const (
+	scimContentType   = "application/scim+json"
+	scimPatchOpSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimErrorSchema   = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// scimRequest is the rendered body of a SCIM action. The user is addressed by user_id or
+// looked up by user_name or email, which must match exactly one user. Groups work the same
+// way with group_id or group_name.
+type scimRequest struct {
+	UserID    string `json:"user_id"`
+	UserName  string `json:"user_name"`
+	Email     string `json:"email"`
+	GroupID   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+}
+
+func (r scimRequest) validate(op string) error {
+	switch op {
+	case scimOpFindUser, scimOpDeactivateUser, scimOpDeleteUser:
+	case ldapOpRemoveMember:
+		if r.GroupID == "" && r.GroupName == "" {
+			return fmt.Errorf("scim: remove_member needs a group_id or group_name")
+		}
+	default:
+		return fmt.Errorf("scim: unsupported operation %q (use FIND_USER, DEACTIVATE_USER, REMOVE_MEMBER or DELETE_USER)", op)
+	}
+	if r.UserID == "" && r.UserName == "" && r.Email == "" {
+		return fmt.Errorf("scim: %s needs a user_id, user_name or email", strings.ToLower(op))
+	}
+	return nil
+}
+
+// scimFilter builds an "attr eq value" filter; SCIM filter values are JSON strings
+func scimFilter(attr, value string) string {
+	quoted, _ := json.Marshal(value)
+	return attr + " eq " + string(quoted)
+}
+
+// scimError is the error body defined by RFC 7644 section 3.12
+type scimError struct {
+	Schemas  []string    `json:"schemas"`
+	Status   interface{} `json:"status"` // A string per the RFC; some providers send a number
+	ScimType string      `json:"scimType"`
+	Detail   string      `json:"detail"`
+}
+
+// scimClient sends SCIM requests for one action with the integration's auth
+type scimClient struct {
+	e           *ActionExecutor
+	integration database.Integration
+	baseURL     string
+	status      int // Status of the last response
+}
+
+func (e *ActionExecutor) executeSCIM(ctx context.Context, integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, int, error) {
+	op := strings.ToUpper(definition.Method)
+
+	// 1. Resolve the request, escaping every value for JSON
+	body, warnings, err := e.renderBody("application/json", definition.BodyTemplate, contextData)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to render scim request: %v", err)
+	}
+	for _, w := range warnings {
+		executorLog.WarnContext(ctx, "Body template lint", "action", definition.Name, "warning", w)
+	}
+	var req scimRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return nil, 0, fmt.Errorf("failed to render scim request: not valid JSON: %v", err)
+	}
+	if err := req.validate(op); err != nil {
+		return nil, 0, err
+	}
+
+	c := &scimClient{e: e, integration: integration, baseURL: strings.TrimRight(integration.BaseURL, "/")}
+	resp, err := c.run(ctx, op, req)
+	return resp, c.status, err
+}
+
+func (c *scimClient) run(ctx context.Context, op string, req scimRequest) ([]byte, error) {
+	user, err := c.findUser(ctx, req)
+	if op == scimOpDeleteUser && isNotFound(err) {
+		// Already deprovisioned
+		return json.Marshal(map[string]interface{}{"id": req.UserID, "deleted": false})
+	}
+	if err != nil {
+		return nil, err
+	}
+	userID, _ := user["id"].(string)
+
+	switch op {
+	case scimOpFindUser:
+		return json.Marshal(user)
+
+	case scimOpDeactivateUser:
+		patch := map[string]interface{}{
+			"schemas":    []string{scimPatchOpSchema},
+			"Operations": []map[string]interface{}{{"op": "replace", "value": map[string]interface{}{"active": false}}},
+		}
+		if _, err := c.do(ctx, http.MethodPatch, "/Users/"+url.PathEscape(userID), nil, patch); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]interface{}{"id": userID, "userName": user["userName"], "active": false})
+
+	case ldapOpRemoveMember:
+		groupID, err := c.findGroupID(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		quoted, _ := json.Marshal(userID)
+		patch := map[string]interface{}{
+			"schemas":    []string{scimPatchOpSchema},
+			"Operations": []map[string]interface{}{{"op": "remove", "path": "members[value eq " + string(quoted) + "]"}},
+		}
+		_, err = c.do(ctx, http.MethodPatch, "/Groups/"+url.PathEscape(groupID), nil, patch)
+		// noTarget means the user is not a member any more, which is the desired end state
+		var scimErr *SCIMError
+		if errors.As(err, &scimErr) && scimErr.ScimType == "noTarget" {
+			return json.Marshal(map[string]interface{}{"group_id": groupID, "user_id": userID, "removed": false})
+		}
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]interface{}{"group_id": groupID, "user_id": userID, "removed": true})
+
+	case scimOpDeleteUser:
+		_, err := c.do(ctx, http.MethodDelete, "/Users/"+url.PathEscape(userID), nil, nil)
+		if isNotFound(err) {
+			return json.Marshal(map[string]interface{}{"id": userID, "deleted": false})
+		}
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]interface{}{"id": userID, "deleted": true})
+	}
+	return nil, fmt.Errorf("scim: unsupported operation %q", op)
+}
+
+// findUser returns the user resource by id, or the single user matching user_name or email
+func (c *scimClient) findUser(ctx context.Context, req scimRequest) (map[string]interface{}, error) {
+	if req.UserID != "" {
+		body, err := c.do(ctx, http.MethodGet, "/Users/"+url.PathEscape(req.UserID), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var user map[string]interface{}
+		if err := json.Unmarshal(body, &user); err != nil {
+			return nil, fmt.Errorf("scim: invalid user resource: %v", err)
+		}
+		return user, nil
+	}
+
+	filter := scimFilter("userName", req.UserName)
+	if req.UserName == "" {
+		filter = scimFilter("emails.value", req.Email)
+	}
+	return c.findOne(ctx, "/Users", filter)
+}
+
+func (c *scimClient) findGroupID(ctx context.Context, req scimRequest) (string, error) {
+	if req.GroupID != "" {
+		return req.GroupID, nil
+	}
+	group, err := c.findOne(ctx, "/Groups", scimFilter("displayName", req.GroupName))
+	if err != nil {
+		return "", err
+	}
+	id, _ := group["id"].(string)
+	return id, nil
+}
+
+// findOne runs a filtered list request that must match exactly one resource
+func (c *scimClient) findOne(ctx context.Context, path, filter string) (map[string]interface{}, error) {
+	query := url.Values{"filter": {filter}, "count": {"2"}}
+	if path == "/Groups" {
+		// Avoid transferring every member of large groups
+		query.Set("excludedAttributes", "members")
+	}
+	body, err := c.do(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		TotalResults int                      `json:"totalResults"`
+		Resources    []map[string]interface{} `json:"Resources"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("scim: invalid list response from %s: %v", path, err)
+	}
+	switch {
+	case len(list.Resources) == 0:
+		msg := fmt.Sprintf("no resource in %s matches %s", path, filter)
+		return nil, fmt.Errorf("scim: %s: %w", msg, &HTTPStatusError{StatusCode: http.StatusNotFound, Body: []byte(msg)})
+	case len(list.Resources) > 1 || list.TotalResults > 1:
+		msg := fmt.Sprintf("more than one resource in %s matches %s", path, filter)
+		return nil, fmt.Errorf("scim: %s: %w", msg, &HTTPStatusError{StatusCode: http.StatusConflict, Body: []byte(msg)})
+	}
+	if id, _ := list.Resources[0]["id"].(string); id == "" {
+		return nil, fmt.Errorf("scim: the resource in %s matching %s has no id", path, filter)
+	}
+	return list.Resources[0], nil
+}
+
+// do sends one SCIM request and turns error responses into *SCIMError
+func (c *scimClient) do(ctx context.Context, method, path string, query url.Values, payload interface{}) ([]byte, error) {
+	fullURL := c.baseURL + path
+	if len(query) > 0 {
+		fullURL += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		executorLog.DebugContext(ctx, "Request payload", "method", method, "url", fullURL, "body", security.Redact(string(b)))
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", scimContentType+", application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", scimContentType)
+	}
+	if err := c.e.applyAuth(req, c.integration); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.e.do(ctx, c.integration, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	c.e.observeRateLimit(c.integration, resp)
+	c.status = resp.StatusCode
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return respBody, newSCIMError(method, path, resp, respBody)
+	}
+	return respBody, nil
+}
+
+// SCIMError is a failed SCIM request. It wraps the HTTP status so retries and dead-letter
+// classification treat it like any REST failure.
+type SCIMError struct {
+	Method   string
+	Path     string
+	ScimType string
+	Detail   string
+	Status   *HTTPStatusError
+}
+
+func newSCIMError(method, path string, resp *http.Response, body []byte) *SCIMError {
+	e := &SCIMError{Method: method, Path: path,
+		Status: &HTTPStatusError{StatusCode: resp.StatusCode, Body: body, Header: resp.Header}}
+	var parsed scimError
+	if json.Unmarshal(body, &parsed) == nil {
+		for _, s := range parsed.Schemas {
+			if s == scimErrorSchema {
+				e.ScimType, e.Detail = parsed.ScimType, parsed.Detail
+			}
+		}
+	}
+	return e
+}
+
+func (e *SCIMError) Error() string {
+	msg := fmt.Sprintf("scim: %s %s failed with HTTP %d", e.Method, e.Path, e.Status.StatusCode)
+	if e.ScimType != "" {
+		msg += " (" + e.ScimType + ")"
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	} else if len(e.Status.Body) > 0 {
+		msg += ": " + string(e.Status.Body)
+	}
+	return msg
+}
+
+func (e *SCIMError) Unwrap() error { return e.Status }
+
+// isNotFound reports whether err is a 404 from the service or a lookup that matched nothing
+func isNotFound(err error) bool {
+	var statusErr *HTTPStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
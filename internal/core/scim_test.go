@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"remediation-engine/internal/database"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSCIMServer is a minimal SCIM 2.0 service with eq filters on userName, emails.value
+// and displayName, PatchOp for active and members, and DELETE
+type testSCIMServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	users    map[string]map[string]interface{}
+	members  map[string][]string // group id -> user ids
+	groups   map[string]string   // group id -> displayName
+	requests []string
+}
+
+var scimFilterPattern = regexp.MustCompile(`^(\w+(?:\.\w+)?) eq (".*")$`)
+
+func startSCIMServer(t *testing.T) *testSCIMServer {
+	t.Helper()
+	s := &testSCIMServer{
+		users: map[string]map[string]interface{}{
+			"u1": {"id": "u1", "userName": "jdoe@corp.example.com", "active": true, "emails": []interface{}{map[string]interface{}{"value": "jdoe@corp.example.com"}}},
+			"u2": {"id": "u2", "userName": "jroe", "active": true, "emails": []interface{}{map[string]interface{}{"value": "shared@corp.example.com"}}},
+			"u3": {"id": "u3", "userName": "jroe2", "active": true, "emails": []interface{}{map[string]interface{}{"value": "shared@corp.example.com"}}},
+		},
+		groups:  map[string]string{"g1": "Admins"},
+		members: map[string][]string{"g1": {"u1", "u2"}},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func scimFail(w http.ResponseWriter, status int, scimType, detail string) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schemas": []string{scimErrorSchema}, "status": http.StatusText(status), "scimType": scimType, "detail": detail,
+	})
+}
+
+func (s *testSCIMServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	if r.Header.Get("Authorization") != "Bearer scim-token" {
+		scimFail(w, http.StatusUnauthorized, "", "invalid token")
+		return
+	}
+	w.Header().Set("Content-Type", scimContentType)
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/scim/v2"), "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1:
+		m := scimFilterPattern.FindStringSubmatch(r.URL.Query().Get("filter"))
+		if m == nil {
+			scimFail(w, http.StatusBadRequest, "invalidFilter", "unsupported filter")
+			return
+		}
+		var want string
+		json.Unmarshal([]byte(m[2]), &want)
+		var found []interface{}
+		if parts[0] == "Groups" {
+			for id, name := range s.groups {
+				if m[1] == "displayName" && name == want {
+					found = append(found, map[string]interface{}{"id": id, "displayName": name})
+				}
+			}
+		} else {
+			for _, u := range s.users {
+				if (m[1] == "userName" && u["userName"] == want) ||
+					(m[1] == "emails.value" && u["emails"].([]interface{})[0].(map[string]interface{})["value"] == want) {
+					found = append(found, u)
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"totalResults": len(found), "Resources": found})
+
+	case len(parts) == 2 && parts[0] == "Users":
+		user, ok := s.users[parts[1]]
+		if !ok {
+			scimFail(w, http.StatusNotFound, "", "user not found")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(user)
+		case http.MethodPatch:
+			var patch struct {
+				Operations []struct {
+					Op    string                 `json:"op"`
+					Value map[string]interface{} `json:"value"`
+				}
+			}
+			json.NewDecoder(r.Body).Decode(&patch)
+			for k, v := range patch.Operations[0].Value {
+				user[k] = v
+			}
+			json.NewEncoder(w).Encode(user)
+		case http.MethodDelete:
+			delete(s.users, parts[1])
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case len(parts) == 2 && parts[0] == "Groups" && r.Method == http.MethodPatch:
+		var patch struct {
+			Operations []struct {
+				Op   string `json:"op"`
+				Path string `json:"path"`
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&patch)
+		var member string
+		json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(patch.Operations[0].Path, "members[value eq "), "]")), &member)
+		kept := []string{}
+		for _, id := range s.members[parts[1]] {
+			if id != member {
+				kept = append(kept, id)
+			}
+		}
+		if len(kept) == len(s.members[parts[1]]) {
+			scimFail(w, http.StatusBadRequest, "noTarget", "not a member")
+			return
+		}
+		s.members[parts[1]] = kept
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		scimFail(w, http.StatusNotFound, "", "no such endpoint")
+	}
+}
+
+func (s *testSCIMServer) integration() database.Integration {
+	return database.Integration{Name: "App", Type: "SCIM", BaseURL: s.URL + "/scim/v2/", AuthType: "bearer", Credentials: `{"token":"scim-token"}`}
+}
+
+func TestExecuteSCIM_Deprovision(t *testing.T) {
+	srv := startSCIMServer(t)
+	executor := NewActionExecutor()
+	integ := srv.integration()
+	data := map[string]interface{}{"UserEmail": "jdoe@corp.example.com"}
+	run := func(method, body string) ([]byte, int, error) {
+		return executor.executeSCIM(context.Background(), integ, database.ActionDefinition{Name: method, Method: method, BodyTemplate: body}, data)
+	}
+
+	resp, code, err := run("FIND_USER", `{"user_name": "{{.UserEmail}}"}`)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	var user map[string]interface{}
+	require.NoError(t, json.Unmarshal(resp, &user))
+	assert.Equal(t, "u1", user["id"])
+	assert.Contains(t, srv.requests[0], `filter=userName+eq+%22jdoe%40corp.example.com%22`)
+
+	resp, _, err = run("DEACTIVATE_USER", `{"email": "{{.UserEmail}}"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "u1", "userName": "jdoe@corp.example.com", "active": false}`, string(resp))
+	assert.Equal(t, false, srv.users["u1"]["active"])
+
+	resp, _, err = run("REMOVE_MEMBER", `{"user_id": "u1", "group_name": "Admins"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"group_id": "g1", "user_id": "u1", "removed": true}`, string(resp))
+	assert.Equal(t, []string{"u2"}, srv.members["g1"])
+
+	// Not a member any more is success
+	resp, _, err = run("REMOVE_MEMBER", `{"user_id": "u1", "group_id": "g1"}`)
+	require.NoError(t, err)
+	assert.Contains(t, string(resp), `"removed":false`)
+
+	resp, _, err = run("DELETE_USER", `{"user_id": "u1"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "u1", "deleted": true}`, string(resp))
+
+	// Deleting again finds nothing, which is the desired end state
+	resp, _, err = run("DELETE_USER", `{"user_id": "u1"}`)
+	require.NoError(t, err)
+	assert.Contains(t, string(resp), `"deleted":false`)
+}
+
+func TestExecuteSCIM_Errors(t *testing.T) {
+	srv := startSCIMServer(t)
+	executor := NewActionExecutor()
+	run := func(integ database.Integration, method, body string, data map[string]interface{}) error {
+		_, _, err := executor.executeSCIM(context.Background(), integ, database.ActionDefinition{Name: method, Method: method, BodyTemplate: body}, data)
+		return err
+	}
+
+	// Quotes in the identity stay inside the filter value
+	err := run(srv.integration(), "DEACTIVATE_USER", `{"user_name": "{{.UserEmail}}"}`, map[string]interface{}{"UserEmail": `x" or userName pr or "`})
+	require.Error(t, err)
+	assert.Equal(t, ErrorClassNotFound, ClassifyError(0, err))
+
+	err = run(srv.integration(), "DEACTIVATE_USER", `{"email": "shared@corp.example.com"}`, nil)
+	assert.ErrorContains(t, err, "more than one resource")
+
+	// SCIM error bodies are surfaced with their status
+	integ := srv.integration()
+	integ.Credentials = `{"token":"wrong"}`
+	err = run(integ, "FIND_USER", `{"user_id": "u1"}`, nil)
+	require.Error(t, err)
+	assert.Equal(t, "scim: GET /Users/u1 failed with HTTP 401: invalid token", err.Error())
+	assert.Equal(t, ErrorClassAuth, ClassifyError(0, err))
+
+	err = run(srv.integration(), "REMOVE_MEMBER", `{"user_id": "u1"}`, nil)
+	assert.ErrorContains(t, err, "needs a group_id or group_name")
+	err = run(srv.integration(), "SUSPEND_USER", `{"user_id": "u1"}`, nil)
+	assert.ErrorContains(t, err, "unsupported operation")
+}
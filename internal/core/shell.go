@@ -0,0 +1,620 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// SSH command templates are escaped for the POSIX shell that runs them:
+//
+//	'{{.UserName}}'   single-quoted word: ' becomes '\''
+//	"{{.UserName}}"   double-quoted word: $ ` " and \ are backslash-escaped
+//	{{.UserName}}     bare: the value is emitted as a single-quoted word
+//	# {{.UserName}}   comment: emitted as a single-quoted word; values with line breaks
+//	                  are rejected so they cannot end the comment
+//	<<EOF ... EOF     here-document: $ ` and \ are backslash-escaped, and nothing in a
+//	                  <<'EOF' document; values with line breaks are rejected
+//
+// Values inside `...` command substitution, or inside $(...) within a double-quoted
+// string, are rejected: that text is parsed as a command again, so neither the string's
+// escaping nor quoting applies. Values are also rejected where they would name the
+// command to run, such as at the start of a line or after ; | or &&.
+//
+// {{raw .X}} opts a value out and is reported by the linter.
+
+// Shell states. The low bits hold the quoting; the bits above record where the current
+// word sits in its command and the here-document being read, if any.
+const (
+	shBare = iota
+	shSingleQuoted
+	shDoubleQuoted
+	shComment
+	shBacktick       // `...` in bare command text
+	shDoubleBacktick // `...` inside "..." or an unquoted here-document
+
+	// shDoubleSubstitution and above are inside $(...) within "..." or an unquoted
+	// here-document; see shSubstitution
+	shDoubleSubstitution = 16
+
+	shQuotingMask = 1<<16 - 1
+)
+
+// Command position of bare command text. Zero means the next word names the command.
+const (
+	shCommandWord  = 1 << 16 // In the word that names the command
+	shAssignment   = 2 << 16 // In a NAME=value word before the command
+	shArguments    = 3 << 16 // After the command word
+	shPositionMask = 3 << 16
+)
+
+// Here-documents. The bits from shHereDocShift hold the index + 1 of the document in
+// posixShell.hereDocs; without shHereDocBody the rest of the line with the << is read.
+const (
+	shHereDocBody      = 1 << 18
+	shHereDocLineStart = 1 << 19
+	shHereDocShift     = 20
+)
+
+// shSubstitution encodes the nesting depth (from 1) of the parentheses of a $(...) inside
+// a double-quoted string and the quoting inside it: 0, shSingleQuoted or shDoubleQuoted
+func shSubstitution(depth, quote int) int {
+	return shDoubleSubstitution + (depth-1)<<2 | quote
+}
+
+func shWithQuoting(state, quoting int) int {
+	return state&^shQuotingMask | quoting
+}
+
+func shWithPosition(state, position int) int {
+	return state&^shPositionMask | position
+}
+
+// Escapers inserted by the auto-escaper
+const (
+	shSingleEscaper  = "_sh_single"
+	shDoubleEscaper  = "_sh_double"
+	shBareEscaper    = "_sh_bare"
+	shCommentEscaper = "_sh_comment"
+	shHereDocEscaper = "_sh_heredoc"
+	shLiteralEscaper = "_sh_heredoc_literal"
+)
+
+// shEscapeSingle escapes a value for use inside '...' by closing, escaping and reopening
+// the quotes around every single quote
+func shEscapeSingle(value interface{}) string {
+	return strings.ReplaceAll(templateString(value), "'", `'\''`)
+}
+
+// shEscapeDouble escapes a value for use inside "..." so nothing expands or ends the word
+func shEscapeDouble(value interface{}) string {
+	s := templateString(value)
+	var b strings.Builder
+	for _, r := range s {
+		if r == '$' || r == '`' || r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// shQuote returns the value as a single shell word
+func shQuote(value interface{}) string {
+	return "'" + shEscapeSingle(value) + "'"
+}
+
+// shCommentValue renders a value inside a comment as a single-quoted word, and fails for values
+// with a line break, which would end the comment
+func shCommentValue(value interface{}) (string, error) {
+	s := templateString(value)
+	if strings.ContainsAny(s, "\r\n") {
+		return "", fmt.Errorf("a value in a command comment cannot contain a line break")
+	}
+	return shQuote(s), nil
+}
+
+// shHereDocValue escapes a value for an unquoted here-document, where quotes are literal
+// text but $ ` and \ are still special. Line breaks are rejected so the value cannot end
+// the document.
+func shHereDocValue(value interface{}) (string, error) {
+	s, err := shHereDocLiteral(value)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if r == '$' || r == '`' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+// shHereDocLiteral checks a value for a quoted here-document, which is copied as is
+func shHereDocLiteral(value interface{}) (string, error) {
+	s := templateString(value)
+	if strings.ContainsAny(s, "\r\n") {
+		return "", fmt.Errorf("a value in a here-document cannot contain a line break")
+	}
+	return s, nil
+}
+
+// unixUser extracts and validates a POSIX login name. It accepts "jdoe",
+// "CORP\jdoe" or "jdoe@corp.example.com" and fails the render for anything that is not a
+// portable user name, so a crafted identity never reaches the command line.
+func unixUser(value interface{}) (string, error) {
+	name := strings.TrimSpace(templateString(value))
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+
+	switch {
+	case name == "":
+		return "", fmt.Errorf("unixuser: empty user name")
+	case utf8.RuneCountInString(name) > 32:
+		return "", fmt.Errorf("unixuser: %q is longer than 32 characters", name)
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && (r == '-' || r == '.' || (r >= '0' && r <= '9')):
+		case r == '$' && i == len(name)-1 && i > 0:
+		default:
+			return "", fmt.Errorf("unixuser: %q contains the invalid character %q", name, r)
+		}
+	}
+	return name, nil
+}
+
+// shHereDoc is a here-document started by <<WORD, <<'WORD' or <<-WORD
+type shHereDoc struct {
+	delimiter string
+	quoted    bool // The body is literal text
+	stripTabs bool // <<-: leading tabs are removed from the delimiter line
+}
+
+// posixShell is the templateLanguage of SSH command templates. States refer to the
+// here-documents of the template by index, so each parse needs its own newPosixShell.
+type posixShell struct {
+	hereDocs *[]shHereDoc
+}
+
+func newPosixShell() posixShell {
+	return posixShell{hereDocs: new([]shHereDoc)}
+}
+
+// hereDocState returns the state bits for a here-document, reusing the index of an equal
+// one so both branches of an {{if}} can end in the same state
+func (l posixShell) hereDocState(doc shHereDoc) int {
+	for i, d := range *l.hereDocs {
+		if d == doc {
+			return (i + 1) << shHereDocShift
+		}
+	}
+	*l.hereDocs = append(*l.hereDocs, doc)
+	return len(*l.hereDocs) << shHereDocShift
+}
+
+func (l posixShell) hereDoc(state int) (shHereDoc, bool) {
+	i := state >> shHereDocShift
+	if i == 0 {
+		return shHereDoc{}, false
+	}
+	return (*l.hereDocs)[i-1], true
+}
+
+// inHereDocBody reports whether state is in the text of a here-document body, rather than
+// in a substitution inside it
+func inHereDocBody(state int) bool {
+	return state&shHereDocBody != 0 && state&shQuotingMask == shBare
+}
+
+func (posixShell) noun() string { return "command" }
+
+func (l posixShell) describe(state int) string {
+	if inHereDocBody(state) {
+		if doc, _ := l.hereDoc(state); doc.quoted {
+			return "a quoted here-document"
+		}
+		return "an unquoted here-document"
+	}
+	switch quoting := state & shQuotingMask; {
+	case quoting == shSingleQuoted:
+		return "a single-quoted string"
+	case quoting == shDoubleQuoted:
+		return "a double-quoted string"
+	case quoting == shComment:
+		return "a comment"
+	case quoting == shBacktick, quoting == shDoubleBacktick:
+		return "a `...` command substitution"
+	case quoting >= shDoubleSubstitution:
+		return "a $(...) command substitution in a double-quoted string"
+	}
+	if state>>shHereDocShift != 0 {
+		return "the line that starts a here-document"
+	}
+	return "bare command text"
+}
+
+// scan tracks quoting, comments, command substitution, here-documents and the position of
+// the command word through literal command text. A backslash escapes the next character
+// outside quotes and inside "..." and `...`; nothing is special inside '...' except the
+// closing quote. $(...) outside quotes stays bare command text, since quoted words are
+// safe there, but starts a new command.
+func (l posixShell) scan(text []byte, state int) int {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		next := byte(0)
+		if i+1 < len(text) {
+			next = text[i+1]
+		}
+
+		if inHereDocBody(state) {
+			doc, _ := l.hereDoc(state)
+			if state&shHereDocLineStart != 0 {
+				if n, ok := hereDocEnds(text[i:], doc); ok {
+					state, i = shBare, i+n
+					continue
+				}
+				state &^= shHereDocLineStart
+			}
+			switch {
+			case c == '\n':
+				state |= shHereDocLineStart
+			case doc.quoted:
+			case c == '\\':
+				i++
+			case c == '`':
+				state = shWithQuoting(state, shDoubleBacktick)
+			case c == '$' && next == '(':
+				state, i = shWithQuoting(state, shSubstitution(1, 0)), i+1
+			}
+			continue
+		}
+
+		switch quoting, position := state&shQuotingMask, state&shPositionMask; {
+		case quoting == shBare:
+			switch {
+			case c == ' ' || c == '\t':
+				if position == shCommandWord {
+					state = shWithPosition(state, shArguments)
+				} else if position == shAssignment {
+					state = shWithPosition(state, 0)
+				}
+			case c == '\n':
+				state = shNewline(state)
+			case c == ';' || c == '(':
+				state = shWithPosition(state, 0)
+			case (c == '&' || c == '|') && i > 0 && (text[i-1] == '>' || text[i-1] == '<'), c == '&' && next == '>':
+				// A redirection such as 2>&1, >| or &>
+			case c == '&' || c == '|':
+				state = shWithPosition(state, 0)
+			case c == ')':
+				state = shWithPosition(state, shArguments)
+			case c == '<' && next == '<' && (i+2 >= len(text) || text[i+2] != '<'):
+				if doc, n := parseHereDoc(text[i+2:]); n > 0 {
+					state, i = state&(1<<shHereDocShift-1)|l.hereDocState(doc), i+1+n
+				}
+			case c == '$' && next == '(' && (i+2 >= len(text) || text[i+2] != '('):
+				state, i = shWithPosition(state, 0), i+1
+			case c == '#' && shWordStart(text, i):
+				state = shWithQuoting(state, shComment)
+			default:
+				if position == 0 {
+					var skip int
+					position, skip = shCommandStart(text, i)
+					state = shWithPosition(state, position)
+					if skip > 0 {
+						i += skip - 1
+						continue
+					}
+				}
+				switch c {
+				case '\\':
+					i++
+				case '\'':
+					state = shWithQuoting(state, shSingleQuoted)
+				case '"':
+					state = shWithQuoting(state, shDoubleQuoted)
+				case '`':
+					state = shWithQuoting(state, shBacktick)
+				}
+			}
+		case quoting == shSingleQuoted:
+			if c == '\'' {
+				state = shWithQuoting(state, shBare)
+			}
+		case quoting == shDoubleQuoted:
+			switch {
+			case c == '\\':
+				i++
+			case c == '"':
+				state = shWithQuoting(state, shBare)
+			case c == '`':
+				state = shWithQuoting(state, shDoubleBacktick)
+			case c == '$' && next == '(':
+				state, i = shWithQuoting(state, shSubstitution(1, 0)), i+1
+			}
+		case quoting == shComment:
+			if c == '\n' {
+				state = shNewline(shWithQuoting(state, shBare))
+			}
+		case quoting == shBacktick, quoting == shDoubleBacktick:
+			switch c {
+			case '\\':
+				i++
+			case '`':
+				state = shWithQuoting(state, shClosedSubstitution(state))
+			}
+		default:
+			v := quoting - shDoubleSubstitution
+			depth, quote := v>>2+1, v&3
+			switch {
+			case quote == shSingleQuoted:
+				if c == '\'' {
+					quote = 0
+				}
+			case c == '\\':
+				i++
+			case quote == shDoubleQuoted:
+				if c == '"' {
+					quote = 0
+				}
+			case c == '\'':
+				quote = shSingleQuoted
+			case c == '"':
+				quote = shDoubleQuoted
+			case c == '(':
+				depth++
+			case c == ')':
+				depth--
+			}
+			if depth > 0 {
+				state = shWithQuoting(state, shSubstitution(depth, quote))
+			} else {
+				state = shWithQuoting(state, shClosedSubstitution(state))
+			}
+		}
+	}
+	return state
+}
+
+// shNewline starts a new command after a line break in bare command text, or the body of a
+// here-document started on the line
+func shNewline(state int) int {
+	state = shWithPosition(state, 0)
+	if state>>shHereDocShift != 0 {
+		state |= shHereDocBody | shHereDocLineStart
+	}
+	return state
+}
+
+// shClosedSubstitution is the quoting after a substitution in bare text, a double-quoted
+// string or a here-document ends
+func shClosedSubstitution(state int) int {
+	if state&shQuotingMask == shBacktick || state&shHereDocBody != 0 {
+		return shBare
+	}
+	return shDoubleQuoted
+}
+
+// parseHereDoc reads the delimiter after <<, returning the here-document and how many bytes
+// of text it used, or 0 if text holds no delimiter
+func parseHereDoc(text []byte) (shHereDoc, int) {
+	var doc shHereDoc
+	i := 0
+	if i < len(text) && text[i] == '-' {
+		doc.stripTabs = true
+		i++
+	}
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+	var word []byte
+	var quote byte
+	for ; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word = append(word, c)
+			}
+		case c == '\'' || c == '"':
+			quote, doc.quoted = c, true
+		case c == '\\' && i+1 < len(text):
+			i++
+			word, doc.quoted = append(word, text[i]), true
+		case strings.IndexByte(" \t\n;&|()<>", c) >= 0:
+			doc.delimiter = string(word)
+			return doc, i
+		default:
+			word = append(word, c)
+		}
+	}
+	if quote != 0 || len(word) == 0 {
+		return shHereDoc{}, 0
+	}
+	doc.delimiter = string(word)
+	return doc, i
+}
+
+// hereDocEnds reports whether the line at the start of text closes the here-document, and
+// the length of the line
+func hereDocEnds(text []byte, doc shHereDoc) (int, bool) {
+	line := text
+	if i := bytes.IndexByte(text, '\n'); i >= 0 {
+		line = text[:i]
+	}
+	trimmed := line
+	if doc.stripTabs {
+		trimmed = bytes.TrimLeft(line, "\t")
+	}
+	return len(line), string(trimmed) == doc.delimiter
+}
+
+// Reserved words after which a command name is still expected, and those that end a
+// compound command
+var (
+	shCommandKeywords = map[string]bool{"if": true, "then": true, "else": true, "elif": true, "do": true, "while": true, "until": true, "!": true, "{": true, "time": true}
+	shClosingKeywords = map[string]bool{"fi": true, "done": true, "esac": true, "}": true, "for": true, "case": true, "select": true}
+)
+
+// shCommandStart classifies the word at i, where a command name is expected. It returns the
+// position for the word and how many bytes to skip for reserved words and the NAME= part
+// of assignments, which come before the command.
+func shCommandStart(text []byte, i int) (position int, skip int) {
+	end := i
+	for end < len(text) && strings.IndexByte(" \t\n;&|()<>'\"\\`$", text[end]) < 0 {
+		end++
+	}
+	word := string(text[i:end])
+	if end < len(text) && strings.IndexByte(" \t\n;&|()<>", text[end]) >= 0 {
+		if shCommandKeywords[word] {
+			return 0, len(word)
+		}
+		if shClosingKeywords[word] {
+			return shArguments, len(word)
+		}
+	}
+	if eq := strings.IndexByte(word, '='); eq > 0 && shValidName(word[:eq]) {
+		return shAssignment, eq + 1
+	}
+	return shCommandWord, 0
+}
+
+func shValidName(name string) bool {
+	for i, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// shWordStart reports whether the byte at i starts a word, where # begins a comment. Text
+// at the start of a node may follow a value, so it is treated as a word start.
+func shWordStart(text []byte, i int) bool {
+	return i == 0 || strings.IndexByte(" \t\n;&|()", text[i-1]) >= 0
+}
+
+func (l posixShell) escaper(state int, last string) (string, string) {
+	quoting := state & shQuotingMask
+	var warning string
+	if last == "shquote" {
+		if quoting == shBare && !inHereDocBody(state) {
+			return "", ""
+		}
+		warning = "shquote used inside " + l.describe(state) + "; the value will carry extra quotes"
+	}
+
+	if inHereDocBody(state) {
+		if doc, _ := l.hereDoc(state); doc.quoted {
+			return shLiteralEscaper, warning
+		}
+		return shHereDocEscaper, warning
+	}
+	switch quoting {
+	case shSingleQuoted:
+		return shSingleEscaper, warning
+	case shDoubleQuoted:
+		return shDoubleEscaper, warning
+	case shComment:
+		return shCommentEscaper, warning
+	}
+	return shBareEscaper, warning
+}
+
+func (l posixShell) reject(state int) string {
+	quoting, position := state&shQuotingMask, state&shPositionMask
+	switch {
+	case inHereDocBody(state):
+		return ""
+	case quoting == shBacktick || quoting == shDoubleBacktick || quoting >= shDoubleSubstitution:
+		return "a value cannot be placed inside " + l.describe(state) + "; use $(...) outside double quotes, or assign the value to a variable first"
+	case quoting != shComment && (position == 0 || position == shCommandWord):
+		return "a value cannot name the command to run; use a fixed command name and pass the value as an argument"
+	}
+	return ""
+}
+
+// A command may end in a comment, and anywhere in bare text outside a here-document
+func (posixShell) canEnd(state int) bool {
+	quoting := state & shQuotingMask
+	return state>>shHereDocShift == 0 && (quoting == shBare || quoting == shComment)
+}
+
+// merge lets {{if}} branches end at different positions in a command. The result is the
+// position where values are checked the most strictly.
+func (posixShell) merge(a, b int) (int, bool) {
+	if a&^shPositionMask != b&^shPositionMask {
+		return 0, false
+	}
+	rank := map[int]int{0: 0, shCommandWord: 1, shAssignment: 2, shArguments: 3}
+	if rank[b&shPositionMask] < rank[a&shPositionMask] {
+		return b, true
+	}
+	return a, true
+}
+
+// LintShellTemplate reports values that reach an SSH command without escaping and other
+// quoting mistakes. An error means the template cannot be rendered at all.
+func LintShellTemplate(tplStr string) ([]string, error) {
+	_, warnings, err := parseEscapedTemplate(tplStr, newPosixShell())
+	return warnings, err
+}
+
+// renderShell renders a command template with auto-escaping
+func (e *ActionExecutor) renderShell(tplStr string, data interface{}) (string, []string, error) {
+	return executeEscaped(tplStr, newPosixShell(), data)
+}
+
+// CommandError is returned when a remote command exits with a non-zero code or is killed
+// by a signal
+type CommandError struct {
+	ExitCode int
+	Signal   string
+	Stderr   string
+}
+
+func (e *CommandError) Error() string {
+	summary := summarizeStderr(e.Stderr)
+	msg := fmt.Sprintf("command exited with code %d", e.ExitCode)
+	if e.Signal != "" {
+		msg = "command killed by signal " + e.Signal
+	}
+	if summary != "" {
+		msg += ": " + summary
+	}
+	return msg
+}
+
+// commandOutcome turns a finished remote command into the step response. Like PowerShell,
+// a successful command that printed JSON responds with that JSON and otherwise with stdout;
+// stderr alone does not fail it. Failures respond with exit code, signal, stderr and stdout.
+func commandOutcome(exitCode int, signal string, stdout []byte, stderr []byte) ([]byte, error) {
+	output, isJSON := extractJSON(stdout)
+	if exitCode == 0 && signal == "" {
+		if isJSON {
+			return output, nil
+		}
+		return stdout, nil
+	}
+
+	result := commandResult{ExitCode: exitCode, Signal: signal, Stderr: string(stderr)}
+	if isJSON {
+		result.Output = output
+	} else {
+		result.Stdout = string(stdout)
+	}
+	body, _ := json.Marshal(result)
+	return body, &CommandError{ExitCode: exitCode, Signal: signal, Stderr: string(stderr)}
+}
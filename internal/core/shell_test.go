@@ -0,0 +1,172 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderShell_EscapesByContext(t *testing.T) {
+	executor := NewActionExecutor()
+	data := map[string]interface{}{"User": `a'b"$c`}
+
+	cases := []struct {
+		tpl  string
+		want string
+	}{
+		{`usermod -L '{{.User}}'`, `usermod -L 'a'\''b"$c'`},
+		{`usermod -L "{{.User}}"`, `usermod -L "a'b\"\$c"`},
+		{`usermod -L {{.User}}`, `usermod -L 'a'\''b"$c'`},
+		{`usermod -L {{shquote .User}}`, `usermod -L 'a'\''b"$c'`},
+		// Escaped quotes in the template text do not open a string
+		{`echo it\'s {{.User}}`, `echo it\'s 'a'\''b"$c'`},
+	}
+	for _, c := range cases {
+		got, warnings, err := executor.renderShell(c.tpl, data)
+		require.NoError(t, err, c.tpl)
+		assert.Empty(t, warnings, c.tpl)
+		assert.Equal(t, c.want, got, c.tpl)
+	}
+}
+
+func TestUnixUser(t *testing.T) {
+	for in, want := range map[string]string{
+		"jdoe":                  "jdoe",
+		"jdoe@corp.example.com": "jdoe",
+		`CORP\svc_backup`:       "svc_backup",
+		"host01$":               "host01$",
+	} {
+		got, err := unixUser(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, bad := range []string{"", "-rf", "j doe", "a;b", "$(id)", "1abc", "averyveryveryveryverylongusername1"} {
+		_, err := unixUser(bad)
+		assert.ErrorContains(t, err, "unixuser", bad)
+	}
+}
+
+func TestLintShellTemplate(t *testing.T) {
+	warnings, err := LintShellTemplate(`pkill -KILL -u {{unixuser .UserEmail}}`)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	warnings, err = LintShellTemplate(`sed -i '/{{raw .Key}}/d' ~/.ssh/authorized_keys`)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "raw value reaches the command unescaped")
+
+	warnings, err = LintShellTemplate(`echo "{{shquote .X}}`)
+	require.NoError(t, err)
+	assert.Len(t, warnings, 2)
+}
+
+func TestRenderShell_CommentsAndSubstitution(t *testing.T) {
+	executor := NewActionExecutor()
+
+	// A line break in a commented value would start a live command on the next line
+	tpl := "# lock {{.U}}\nusermod -L {{.U}}"
+	_, _, err := executor.renderShell(tpl, map[string]interface{}{"U": "x\nrm -rf /tmp/pwn #"})
+	assert.ErrorContains(t, err, "cannot contain a line break")
+	got, warnings, err := executor.renderShell(tpl, map[string]interface{}{"U": "jdoe"})
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, "# lock 'jdoe'\nusermod -L 'jdoe'", got)
+
+	// # inside a word is not a comment, and a command may end in one
+	got, _, err = executor.renderShell("grep a#b {{.U}}; true # for {{.U}}", map[string]interface{}{"U": "jdoe"})
+	require.NoError(t, err)
+	assert.Equal(t, "grep a#b 'jdoe'; true # for 'jdoe'", got)
+
+	// $(...) outside quotes is parsed as a command, where quoted words are safe
+	got, _, err = executor.renderShell(`id -u $(getent passwd {{.U}} | cut -d: -f1) "$(date)" "{{.U}}"`, map[string]interface{}{"U": "a b;$c"})
+	require.NoError(t, err)
+	assert.Equal(t, `id -u $(getent passwd 'a b;$c' | cut -d: -f1) "$(date)" "a b;\$c"`, got)
+}
+
+func TestLintShellTemplate_Substitution(t *testing.T) {
+	for _, tpl := range []string{
+		"echo `id {{.U}}`",
+		"echo \"`id {{.U}}`\"",
+		`echo "$(id {{.U}})"`,
+		`echo "$(id "$(getent passwd x)" {{.U}})"`,
+		`echo "$(printf ')' {{raw .U}})"`,
+	} {
+		_, err := LintShellTemplate(tpl)
+		assert.ErrorContains(t, err, "cannot be placed inside", tpl)
+	}
+
+	// The string resumes after the substitution
+	warnings, err := LintShellTemplate("echo \"$(id -u) `date` {{.U}}\" \\`{{.U}}")
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestRenderShell_HereDocuments(t *testing.T) {
+	executor := NewActionExecutor()
+	data := map[string]interface{}{"N": `x"$(whoami)"` + "`id`\\"}
+
+	// Quotes are literal text in an unquoted here-document, so expansions are escaped instead
+	got, warnings, err := executor.renderShell("cat <<EOF > /etc/motd\nlocked: {{.N}}\nEOF\nusermod -L {{.N}}", data)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, "cat <<EOF > /etc/motd\nlocked: x\"\\$(whoami)\"\\`id\\`\\\\\nEOF\nusermod -L 'x\"$(whoami)\"`id`\\'", got)
+
+	// A quoted delimiter makes the body literal
+	got, _, err = executor.renderShell("cat <<'EOF'\n{{.N}}\nEOF", data)
+	require.NoError(t, err)
+	assert.Equal(t, "cat <<'EOF'\nx\"$(whoami)\"`id`\\\nEOF", got)
+
+	// <<- allows the delimiter to be indented with tabs; the document ends there
+	got, _, err = executor.renderShell("if true; then\n\tcat <<-\"END\"\n\t{{.N}}\n\tEND\n\techo {{.N}}\nfi", data)
+	require.NoError(t, err)
+	assert.Equal(t, "if true; then\n\tcat <<-\"END\"\n\tx\"$(whoami)\"`id`\\\n\tEND\n\techo 'x\"$(whoami)\"`id`\\'\nfi", got)
+
+	// A line break would let the value end the document and run what follows
+	for _, tpl := range []string{"cat <<EOF\n{{.N}}\nEOF", "cat <<'EOF'\n{{.N}}\nEOF"} {
+		_, _, err = executor.renderShell(tpl, map[string]interface{}{"N": "x\nEOF\nreboot"})
+		assert.ErrorContains(t, err, "a value in a here-document cannot contain a line break", tpl)
+	}
+
+	_, err = LintShellTemplate("cat <<EOF\n$(id {{.N}})\nEOF")
+	assert.ErrorContains(t, err, "cannot be placed inside")
+	warnings, err = LintShellTemplate("cat <<EOF\n{{.N}}")
+	require.NoError(t, err)
+	assert.Contains(t, warnings[0], "command ends inside an unquoted here-document")
+}
+
+func TestLintShellTemplate_CommandPosition(t *testing.T) {
+	for _, tpl := range []string{
+		"{{.X}} -L jdoe",
+		"id\n{{.X}}",
+		"true; {{.X}}",
+		"id | {{.X}}",
+		"id && {{.X}}",
+		"id || '{{.X}}'",
+		`echo $({{.X}})`,
+		`/usr/sbin/{{.X}} jdoe`,
+		`"{{.X}}" jdoe`,
+		`PATH=/tmp {{.X}}`,
+		"if {{.X}}; then true; fi",
+		"if true; then {{.X}}; fi",
+		"{{if .Y}}sudo {{end}}{{.X}}",
+	} {
+		_, err := LintShellTemplate(tpl)
+		assert.ErrorContains(t, err, "a value cannot name the command to run", tpl)
+	}
+
+	for _, tpl := range []string{
+		"usermod -L {{.X}}",
+		"id {{.X}} 2>&1 | grep -q {{.X}} && echo {{.X}}",
+		`USER={{.X}} HOME="/home/{{.X}}" passwd -l "$USER"`,
+		"for u in {{.X}}; do passwd -l \"$u\"; done",
+		"{{if .Y}}sudo {{end}}usermod -L {{.X}}",
+		"{{range .Users}}usermod -L {{.}}\n{{end}}",
+	} {
+		warnings, err := LintShellTemplate(tpl)
+		assert.NoError(t, err, tpl)
+		assert.Empty(t, warnings, tpl)
+	}
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"remediation-engine/internal/database"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signingTime is the request time of the AWS Signature Version 4 test suite
+var signingTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+var awsTestCreds = map[string]string{
+	"access_key_id": "AKIDEXAMPLE", "secret_access_key": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	"region": "us-east-1", "service": "service",
+}
+
+func TestSignAWSV4_TestSuiteVectors(t *testing.T) {
+	for _, tc := range []struct {
+		name, method, url, contentType, body string
+		creds                                map[string]string
+		want                                 string
+	}{
+		{
+			name: "get-vanilla", method: "GET", url: "https://example.amazonaws.com/", creds: awsTestCreds,
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name: "get-vanilla-query-order-key-case", method: "GET", url: "https://example.amazonaws.com/?Param2=value2&Param1=value1", creds: awsTestCreds,
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			// Services other than S3 encode the escaped path again, so the canonical URI is
+			// /example%2520space/ as the AWS SDKs sign it
+			name: "escaped-path", method: "GET", url: "https://example.amazonaws.com/example%20space/", creds: awsTestCreds,
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=446b817944c553435b35e813c261ff4e161fff982d1bacdef1c87f6785dd1662",
+		},
+		{
+			name: "post-x-www-form-urlencoded", method: "POST", url: "https://example.amazonaws.com/", creds: awsTestCreds,
+			contentType: "application/x-www-form-urlencoded", body: "Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+		{
+			// EC2-style numbered parameters sort by name before value, so InstanceId.1 comes
+			// before InstanceId.10; the expected signature was computed with a reference signer
+			name: "numbered-parameters", method: "GET",
+			url:   "https://ec2.us-east-1.amazonaws.com/?Version=2016-11-15&InstanceId.10=i-b&Action=DescribeInstances&InstanceId.2=i-c&InstanceId.1=i-a",
+			creds: map[string]string{"access_key_id": "AKIDEXAMPLE", "secret_access_key": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+			want:  "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/ec2/aws4_request, SignedHeaders=host;x-amz-date, Signature=9fa95e972da3a5c5c46d7f1b0d13349a5fa9c3aeddb0dcae4da567e04b78c383",
+		},
+		{
+			// The IAM example from the Signature Version 4 documentation; the region and
+			// service come from the global endpoint's host name
+			name: "iam-list-users", method: "GET", url: "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			creds:       map[string]string{"access_key_id": "AKIDEXAMPLE", "secret_access_key": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+			contentType: "application/x-www-form-urlencoded; charset=utf-8",
+			want:        "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			require.NoError(t, signAWSV4(req, tc.creds, signingTime))
+			assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			assert.Equal(t, tc.want, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestCanonicalQuery_SortsByNameThenValue(t *testing.T) {
+	u, _ := url.Parse("https://example.com/?InstanceId.10=b&InstanceId.1=a&tag=z&tag=y&a-b=1&a=2")
+	assert.Equal(t, "InstanceId.1=a&InstanceId.10=b&a=2&a-b=1&tag=y&tag=z", canonicalQuery(u))
+}
+
+func TestSignHMAC_KnownVector(t *testing.T) {
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest("POST", "https://api.internal/api/v1/users/jdoe%2Fx/suspend?b=two+words&a=1", strings.NewReader(`{"user": "jdoe"}`))
+		require.NoError(t, err)
+		return req
+	}
+
+	req := newRequest()
+	require.NoError(t, signHMAC(req, map[string]string{"key_id": "engine", "secret": "topsecret"}, signingTime))
+	assert.Equal(t, "1440938160", req.Header.Get("X-Timestamp"))
+	assert.Equal(t, "44d28757e76d4a1fd26dcaac8b36522fd62e965396415bb1cd00f1b07da2b0cf", req.Header.Get("X-Content-SHA256"))
+	assert.Equal(t, "HMAC-SHA256 KeyId=engine, Signature=92c829f8d89da423471ed8755cbd4de55d13b883029cbb327cfd67ce74f88da5", req.Header.Get("Authorization"))
+
+	req = newRequest()
+	require.NoError(t, signHMAC(req, map[string]string{
+		"secret": "topsecret", "encoding": "base64", "signature_header": "X-Signature", "timestamp_header": "X-Request-Time",
+	}, signingTime))
+	assert.Equal(t, "1440938160", req.Header.Get("X-Request-Time"))
+	assert.Equal(t, "ksgp+NidpCNHHth1XL1N5V0TuIMCnLsyfP1nznT4jaU=", req.Header.Get("X-Signature"))
+	assert.Empty(t, req.Header.Get("Authorization"))
+
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, `{"user": "jdoe"}`, string(body), "signing leaves the body to be sent")
+}
+
+func TestExecuteREST_SignedRequests(t *testing.T) {
+	setupTestDB()
+	var got []*http.Request
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r)
+		bodies = append(bodies, string(body))
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	executor := NewActionExecutor()
+	executor.now = func() time.Time { return signingTime }
+	create := func(authType string, creds map[string]string) database.Integration {
+		raw, _ := json.Marshal(creds)
+		integ := database.Integration{Name: fmt.Sprintf("Signed %s %d", authType, oauthIntegrations.Add(1)), Type: "REST", BaseURL: srv.URL, AuthType: authType, Credentials: string(raw), Enabled: true, TenantID: 1}
+		require.NoError(t, database.DB.Create(&integ).Error)
+		var stored struct{ Credentials string }
+		database.DB.Raw("SELECT credentials FROM integrations WHERE id = ?", integ.ID).Scan(&stored)
+		assert.NotContains(t, stored.Credentials, "secret", "signing keys are encrypted at rest")
+		require.NoError(t, database.DB.First(&integ, integ.ID).Error)
+		return integ
+	}
+	def := database.ActionDefinition{
+		Name: "Disable Key", Method: "POST", PathTemplate: "/keys/{{.key_id}}/disable", BodyTemplate: `{"reason": "{{.reason}}"}`, RetryCount: 1,
+	}
+	params := map[string]interface{}{"key_id": "AKIA123", "reason": "leaked"}
+
+	integ := create("aws_sigv4", map[string]string{
+		"access_key_id": "AKIDEXAMPLE", "secret_access_key": "secret-key", "session_token": "session", "region": "eu-west-1", "service": "iam",
+	})
+	_, code, err := executor.Execute(integ, def, params)
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	req := got[0]
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "Credential=AKIDEXAMPLE/20150830/eu-west-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token, Signature=")
+
+	// The server recomputes the signature over what it received
+	check, _ := http.NewRequest(req.Method, srv.URL+req.URL.RequestURI(), strings.NewReader(bodies[0]))
+	check.Header.Set("Content-Type", req.Header.Get("Content-Type"))
+	require.NoError(t, signAWSV4(check, map[string]string{
+		"access_key_id": "AKIDEXAMPLE", "secret_access_key": "secret-key", "session_token": "session", "region": "eu-west-1", "service": "iam",
+	}, signingTime))
+	assert.Equal(t, check.Header.Get("Authorization"), req.Header.Get("Authorization"))
+
+	integ = create("hmac", map[string]string{"key_id": "engine", "secret": "secret-key"})
+	_, _, err = executor.Execute(integ, def, params)
+	require.NoError(t, err)
+	req = got[1]
+	check, _ = http.NewRequest(req.Method, srv.URL+req.URL.RequestURI(), strings.NewReader(bodies[1]))
+	require.NoError(t, signHMAC(check, map[string]string{"key_id": "engine", "secret": "secret-key"}, signingTime))
+	assert.Equal(t, check.Header.Get("Authorization"), req.Header.Get("Authorization"))
+	assert.Equal(t, `{"reason": "leaked"}`, bodies[1])
+
+	// Missing keys fail before anything is sent
+	integ = create("hmac", map[string]string{"key_id": "engine"})
+	_, _, err = executor.Execute(integ, def, params)
+	assert.ErrorContains(t, err, "request signing failed: hmac needs a secret in the integration credentials")
+	assert.Equal(t, ErrorClassAuth, ClassifyError(0, err))
+	assert.Len(t, got, 2)
+}
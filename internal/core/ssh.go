@@ -0,0 +1,330 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/tracing"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultSSHPort = 22
+
+// sshTarget is the host an SSH integration's BaseURL points at
+type sshTarget struct {
+	Host string
+	Port int
+	User string // From ssh://user@host; the credentials username takes precedence
+}
+
+func (t sshTarget) Addr() string {
+	return net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+}
+
+func (t sshTarget) String() string {
+	return "ssh://" + t.Addr()
+}
+
+// parseSSHTarget accepts "host", "host:port" or "ssh://[user@]host[:port]"
+func parseSSHTarget(baseURL string) (sshTarget, error) {
+	raw := strings.TrimSpace(baseURL)
+	if raw == "" {
+		return sshTarget{}, fmt.Errorf("ssh: base URL (host) is empty")
+	}
+
+	var t sshTarget
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return sshTarget{}, fmt.Errorf("ssh: invalid base URL %q: %v", baseURL, err)
+		}
+		if !strings.EqualFold(u.Scheme, "ssh") {
+			return sshTarget{}, fmt.Errorf("ssh: unsupported scheme %q (use ssh)", u.Scheme)
+		}
+		if u.User != nil {
+			t.User = u.User.Username()
+		}
+		raw = u.Host
+	}
+
+	host, portStr, err := net.SplitHostPort(raw)
+	if err != nil {
+		// No port given
+		t.Host = strings.Trim(raw, "[]")
+		t.Port = defaultSSHPort
+		return t, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return sshTarget{}, fmt.Errorf("ssh: invalid port %q", portStr)
+	}
+	t.Host, t.Port = host, port
+	return t, nil
+}
+
+// sshHostKeyCallback pins the server's host key. Each line of KnownHosts is either a
+// known_hosts entry (as printed by ssh-keyscan) or a "SHA256:..." key fingerprint.
+// Without pins, connections are refused unless InsecureSkipVerify is set.
+func sshHostKeyCallback(integration database.Integration) (ssh.HostKeyCallback, error) {
+	if strings.TrimSpace(integration.KnownHosts) == "" {
+		if integration.InsecureSkipVerify {
+			return ssh.InsecureIgnoreHostKey(), nil
+		}
+		return nil, fmt.Errorf("ssh: known_hosts is empty; pin the host key (ssh-keyscan output or a SHA256 fingerprint) or enable insecure_skip_verify for testing")
+	}
+
+	var fingerprints, entries []string
+	for _, line := range strings.Split(integration.KnownHosts, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "SHA256:"):
+			fingerprints = append(fingerprints, line)
+		default:
+			if _, _, _, _, _, err := ssh.ParseKnownHosts([]byte(line)); err != nil {
+				return nil, fmt.Errorf("ssh: invalid known_hosts line %q: %v", line, err)
+			}
+			entries = append(entries, line)
+		}
+	}
+
+	var fromFile ssh.HostKeyCallback
+	if len(entries) > 0 {
+		// knownhosts only reads files; the file is parsed up front and can go right away
+		f, err := os.CreateTemp("", "known_hosts")
+		if err != nil {
+			return nil, fmt.Errorf("ssh: failed to load known_hosts: %v", err)
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(strings.Join(entries, "\n") + "\n")
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ssh: failed to load known_hosts: %v", err)
+		}
+		if fromFile, err = knownhosts.New(f.Name()); err != nil {
+			return nil, fmt.Errorf("ssh: invalid known_hosts: %v", err)
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		for _, want := range fingerprints {
+			if want == fingerprint {
+				return nil
+			}
+		}
+		if fromFile != nil {
+			return fromFile(hostname, remote, key)
+		}
+		return &knownhosts.KeyError{}
+	}, nil
+}
+
+// sshAuthMethods builds the client auth for the integration's auth type: "password"
+// (or "basic") sends the password, also answering keyboard-interactive prompts; "sshkey"
+// uses private_key with an optional passphrase. Without an auth type both are tried.
+func sshAuthMethods(authType string, creds map[string]string) ([]ssh.AuthMethod, error) {
+	useKey, usePassword := false, false
+	switch strings.ToLower(authType) {
+	case "sshkey", "publickey":
+		useKey = true
+	case "password", "basic":
+		usePassword = true
+	case "", "none":
+		useKey, usePassword = creds["private_key"] != "", creds["password"] != ""
+	default:
+		return nil, fmt.Errorf("ssh: unsupported auth type %q (use password or sshkey)", authType)
+	}
+
+	var methods []ssh.AuthMethod
+	if useKey {
+		if creds["private_key"] == "" {
+			return nil, fmt.Errorf("ssh: sshkey auth needs a private_key in the credentials")
+		}
+		var signer ssh.Signer
+		var err error
+		if creds["passphrase"] != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(creds["private_key"]), []byte(creds["passphrase"]))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(creds["private_key"]))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ssh: cannot parse private_key: %v", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if usePassword {
+		password := creds["password"]
+		methods = append(methods, ssh.Password(password),
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range questions {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("ssh: the credentials need a password or a private_key")
+	}
+	return methods, nil
+}
+
+func (e *ActionExecutor) executeSSH(ctx context.Context, integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, error) {
+	// 1. Resolve the command (BodyTemplate serves as the Command Template), quoting every
+	// value for the shell
+	command, warnings, err := e.renderShell(definition.BodyTemplate, contextData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render ssh command: %v", err)
+	}
+	for _, w := range warnings {
+		executorLog.WarnContext(ctx, "Shell template lint", "action", definition.Name, "warning", w)
+	}
+
+	// 2. Parse Credentials
+	var creds map[string]string
+	if err := json.Unmarshal([]byte(integration.Credentials), &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse integration credentials: %v", err)
+	}
+
+	// 3. Resolve the host, auth and host key pins
+	target, err := parseSSHTarget(integration.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := sshAuthMethods(integration.AuthType, creds)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := sshHostKeyCallback(integration)
+	if err != nil {
+		return nil, err
+	}
+	user := creds["username"]
+	if user == "" {
+		user = target.User
+	}
+
+	// 4. Connect and run within the integration's timeout
+	timeout := commandTimeout(integration)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, span := tracing.Start(ctx, "SSH Run",
+		attribute.String("server.address", target.Host),
+		attribute.Int("server.port", target.Port))
+	stdout, stderr, exitCode, signal, err := runSSH(runCtx, target, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         timeout,
+	}, command)
+	if err != nil && runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	if err = describeSSHError(err, target, timeout); err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	// 5. A non-zero exit code or a signal fails the step
+	span.SetAttributes(attribute.Int("process.exit.code", exitCode))
+	resp, err := commandOutcome(exitCode, signal, stdout, stderr)
+	tracing.End(span, err)
+	return resp, err
+}
+
+// runSSH runs one command in a new session. A command that exits non-zero or is killed is
+// reported through exitCode and signal, not err.
+func runSSH(ctx context.Context, target sshTarget, config *ssh.ClientConfig, command string) (stdout, stderr []byte, exitCode int, signal string, err error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", target.Addr())
+	if err != nil {
+		return nil, nil, 0, "", err
+	}
+	// Closing the connection unblocks the handshake and the session on cancellation
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, target.Addr(), config)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, nil, 0, "", ctx.Err()
+		}
+		return nil, nil, 0, "", err
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, nil, 0, "", err
+	}
+	defer session.Close()
+
+	var out, errOut bytes.Buffer
+	session.Stdout = &out
+	session.Stderr = &errOut
+	err = session.Run(command)
+	if ctx.Err() != nil {
+		return out.Bytes(), errOut.Bytes(), 0, "", ctx.Err()
+	}
+
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		return out.Bytes(), errOut.Bytes(), 0, "", nil
+	case errors.As(err, &exitErr):
+		return out.Bytes(), errOut.Bytes(), exitErr.ExitStatus(), exitErr.Signal(), nil
+	}
+	return out.Bytes(), errOut.Bytes(), 0, "", err
+}
+
+// describeSSHError turns connection failures into messages that name the likely fix.
+// Host key and credential failures are surfaced as a 401 so they are not retried.
+func describeSSHError(err error, target sshTarget, timeout time.Duration) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		keyErr *knownhosts.KeyError
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+	msg := err.Error()
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return fmt.Errorf("ssh: %s did not finish within %s (raise timeout_seconds for long-running commands): %w", target, timeout, err)
+	case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+		return fmt.Errorf("ssh: the host key presented by %s does not match known_hosts; the host was reinstalled or the connection is intercepted: %w", target,
+			&HTTPStatusError{StatusCode: http.StatusUnauthorized, Body: []byte(msg)})
+	case errors.As(err, &keyErr):
+		return fmt.Errorf("ssh: the host key presented by %s is not pinned in known_hosts; add the output of ssh-keyscan: %w", target,
+			&HTTPStatusError{StatusCode: http.StatusUnauthorized, Body: []byte(msg)})
+	case strings.Contains(msg, "unable to authenticate"):
+		return fmt.Errorf("ssh: %s rejected the credentials; check the username, password or private_key: %w", target,
+			&HTTPStatusError{StatusCode: http.StatusUnauthorized, Body: []byte(msg)})
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("ssh: cannot resolve host %q: %w", target.Host, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("ssh: connection refused by %s; check that sshd listens on that port: %w", target, err)
+	}
+	return fmt.Errorf("ssh execution failed: %w", err)
+}
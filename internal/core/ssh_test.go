@@ -0,0 +1,244 @@
+package core
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os/exec"
+	"remediation-engine/internal/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestParseSSHTarget(t *testing.T) {
+	cases := []struct {
+		in   string
+		want sshTarget
+	}{
+		{"jump01", sshTarget{Host: "jump01", Port: 22}},
+		{"10.0.0.5:2222", sshTarget{Host: "10.0.0.5", Port: 2222}},
+		{"ssh://root@jump01", sshTarget{Host: "jump01", Port: 22, User: "root"}},
+		{"ssh://[::1]:2222", sshTarget{Host: "::1", Port: 2222}},
+	}
+	for _, c := range cases {
+		got, err := parseSSHTarget(c.in)
+		require.NoError(t, err, c.in)
+		assert.Equal(t, c.want, got, c.in)
+	}
+
+	for _, bad := range []string{"", "https://jump01", "jump01:0"} {
+		_, err := parseSSHTarget(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+// testSSHServer is an in-process sshd that runs exec requests with sh -c
+type testSSHServer struct {
+	addr    string
+	hostKey ssh.Signer
+}
+
+func startSSHServer(t *testing.T, password string, clientKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pw []byte) (*ssh.Permissions, error) {
+			if c.User() == "svc" && string(pw) == password {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if clientKey != nil && c.User() == "svc" && string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSHConn(conn, config)
+		}
+	}()
+	return &testSSHServer{addr: ln.Addr().String(), hostKey: hostKey}
+}
+
+func serveSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+				req.Reply(true, nil)
+
+				cmd := exec.Command("sh", "-c", payload.Command)
+				cmd.Stdout = channel
+				cmd.Stderr = channel.Stderr()
+				status := 0
+				if err := cmd.Run(); err != nil {
+					var exitErr *exec.ExitError
+					if errors.As(err, &exitErr) {
+						status = exitErr.ExitCode()
+					} else {
+						status = 127
+					}
+				}
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+				return
+			}
+		}()
+	}
+}
+
+func (s *testSSHServer) integration(creds string) database.Integration {
+	return database.Integration{
+		Name:        "Jump",
+		Type:        "SSH",
+		BaseURL:     s.addr,
+		Credentials: creds,
+		KnownHosts:  ssh.FingerprintSHA256(s.hostKey.PublicKey()),
+	}
+}
+
+func TestExecuteSSH_QuotesValuesAndCapturesOutput(t *testing.T) {
+	srv := startSSHServer(t, "pw", nil)
+	executor := NewActionExecutor()
+	integ := srv.integration(`{"username":"svc","password":"pw"}`)
+
+	hostile := `x'; echo pwned; '$(id)` + "`id`" + `"\`
+	def := database.ActionDefinition{Name: "Echo", BodyTemplate: `printf '%s\n' '{{.User}}' "{{.User}}" {{.User}}`}
+	resp, err := executor.executeSSH(context.Background(), integ, def, map[string]interface{}{"User": hostile})
+	require.NoError(t, err)
+	assert.Equal(t, hostile+"\n"+hostile+"\n"+hostile+"\n", string(resp))
+
+	// JSON on stdout becomes the response
+	def.BodyTemplate = `echo 'locking'; printf '{"user": "%s", "locked": true}\n' {{unixuser .User}}`
+	resp, err = executor.executeSSH(context.Background(), integ, def, map[string]interface{}{"User": "jdoe@corp.example.com"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"user":"jdoe","locked":true}`, string(resp))
+
+	// Non-zero exit codes fail the step with stdout and stderr captured
+	def.BodyTemplate = `echo partial; echo "usermod: user 'nobody2' does not exist" >&2; exit 6`
+	resp, err = executor.executeSSH(context.Background(), integ, def, map[string]interface{}{})
+	require.Error(t, err)
+	var cmdErr *CommandError
+	require.True(t, errors.As(err, &cmdErr))
+	assert.Equal(t, 6, cmdErr.ExitCode)
+	assert.Equal(t, "command exited with code 6: usermod: user 'nobody2' does not exist", err.Error())
+
+	var result commandResult
+	require.NoError(t, json.Unmarshal(resp, &result))
+	assert.Equal(t, 6, result.ExitCode)
+	assert.Equal(t, "partial\n", result.Stdout)
+	assert.Contains(t, result.Stderr, "does not exist")
+}
+
+func TestExecuteSSH_KeyAuthAndKnownHosts(t *testing.T) {
+	_, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	require.NoError(t, err)
+	clientSigner, err := ssh.NewSignerFromKey(clientPriv)
+	require.NoError(t, err)
+
+	srv := startSSHServer(t, "", clientSigner.PublicKey())
+	executor := NewActionExecutor()
+	creds, _ := json.Marshal(map[string]string{"username": "svc", "private_key": string(pem.EncodeToMemory(block))})
+	def := database.ActionDefinition{Name: "Whoami", BodyTemplate: "echo ok"}
+	run := func(integ database.Integration) error {
+		_, err := executor.executeSSH(context.Background(), integ, def, map[string]interface{}{})
+		return err
+	}
+
+	// known_hosts line as printed by ssh-keyscan
+	integ := srv.integration(string(creds))
+	integ.AuthType = "sshkey"
+	integ.KnownHosts = knownhosts.Line([]string{srv.addr}, srv.hostKey.PublicKey())
+	require.NoError(t, run(integ))
+
+	// A different key pinned for the host is a mismatch and is not retried
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := ssh.NewSignerFromKey(otherPriv)
+	integ.KnownHosts = knownhosts.Line([]string{srv.addr}, otherKey.PublicKey())
+	err = run(integ)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match known_hosts")
+	assert.Equal(t, ErrorClassAuth, ClassifyError(0, err))
+
+	// Fingerprints that do not match leave the host unpinned
+	integ.KnownHosts = ssh.FingerprintSHA256(otherKey.PublicKey())
+	assert.ErrorContains(t, run(integ), "not pinned in known_hosts")
+
+	// No pins at all is refused unless verification is explicitly skipped
+	integ.KnownHosts = ""
+	assert.ErrorContains(t, run(integ), "known_hosts is empty")
+	integ.InsecureSkipVerify = true
+	assert.NoError(t, run(integ))
+}
+
+func TestExecuteSSH_FailureModes(t *testing.T) {
+	srv := startSSHServer(t, "pw", nil)
+	executor := NewActionExecutor()
+	def := database.ActionDefinition{Name: "Lock", BodyTemplate: "sleep 5"}
+
+	err := func() error {
+		_, err := executor.executeSSH(context.Background(), srv.integration(`{"username":"svc","password":"wrong"}`), def, map[string]interface{}{})
+		return err
+	}()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rejected the credentials")
+	assert.Equal(t, ErrorClassAuth, ClassifyError(0, err))
+
+	_, err = executor.executeSSH(context.Background(), srv.integration(`{"username":"svc"}`), def, map[string]interface{}{})
+	assert.ErrorContains(t, err, "need a password or a private_key")
+
+	integ := srv.integration(`{"username":"svc","password":"pw"}`)
+	integ.TimeoutSeconds = 1
+	start := time.Now()
+	_, err = executor.executeSSH(context.Background(), integ, def, map[string]interface{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did not finish within 1s")
+	assert.Less(t, time.Since(start), 4*time.Second)
+}
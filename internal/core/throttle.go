@@ -0,0 +1,299 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/logging"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var throttleLog = logging.For(logging.ComponentThrottle)
+
+const (
+	// minEffectiveRate is the floor the limiter backs off to (one request every 10s)
+	minEffectiveRate = 0.1
+	// defaultThrottledRate is used when an unlimited integration starts returning 429s
+	defaultThrottledRate = 1.0
+	// unlimitedRecoveryCeiling returns an unlimited integration to unlimited once reached
+	unlimitedRecoveryCeiling = 50.0
+	// recoveryInterval is the minimum time between gradual rate increases
+	recoveryInterval = 10 * time.Second
+	// recoveryFactor is applied to the effective rate at each recovery step
+	recoveryFactor = 1.25
+	// defaultThrottlePause is used for a 429 without Retry-After or reset headers
+	defaultThrottlePause = time.Second
+)
+
+var (
+	limiters   = make(map[uint]*adaptiveLimiter)
+	limitersMu sync.Mutex
+)
+
+// adaptiveLimiter is a per-integration token bucket whose rate drops when the vendor
+// signals pressure (429 or rate-limit headers) and recovers gradually afterwards.
+type adaptiveLimiter struct {
+	mu          sync.Mutex
+	configured  float64 // Integration.RateLimit; 0 = unlimited
+	effective   float64 // Current rate; 0 = unlimited
+	bucket      *rate.Limiter
+	pausedUntil time.Time
+	lastChange  time.Time
+}
+
+func newAdaptiveLimiter(rateLimit float64) *adaptiveLimiter {
+	l := &adaptiveLimiter{configured: rateLimit, effective: rateLimit, bucket: rate.NewLimiter(rate.Inf, 1)}
+	l.applyRate(time.Now())
+	return l
+}
+
+func (e *ActionExecutor) getLimiter(id uint, rateLimit float64) *adaptiveLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	if l, ok := limiters[id]; ok {
+		// Update limit if it changed in DB
+		l.configure(rateLimit)
+		return l
+	}
+
+	l := newAdaptiveLimiter(rateLimit)
+	limiters[id] = l
+	return l
+}
+
+// applyRate pushes the effective rate into the token bucket (burst of 1). Caller holds mu.
+func (l *adaptiveLimiter) applyRate(now time.Time) {
+	if l.effective <= 0 {
+		l.bucket.SetLimitAt(now, rate.Inf)
+		return
+	}
+	l.bucket.SetLimitAt(now, rate.Limit(l.effective))
+}
+
+// configure picks up a changed Integration.RateLimit. An active throttle is kept if it is stricter.
+func (l *adaptiveLimiter) configure(rateLimit float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.configured == rateLimit {
+		return
+	}
+	throttled := l.effective != l.configured
+	l.configured = rateLimit
+	if !throttled || (rateLimit > 0 && (l.effective <= 0 || rateLimit < l.effective)) {
+		l.effective = rateLimit
+	}
+	l.applyRate(time.Now())
+}
+
+// wait blocks until the integration is out of any vendor-requested pause and a token is available.
+// A pause longer than maxRetryAfter fails fast instead of holding the worker.
+func (l *adaptiveLimiter) wait(ctx context.Context, now time.Time, sleep func(context.Context, time.Duration) error) (time.Duration, error) {
+	l.mu.Lock()
+	pausedUntil := l.pausedUntil
+	l.mu.Unlock()
+
+	var waited time.Duration
+	if d := pausedUntil.Sub(now); d > 0 {
+		if d > maxRetryAfter {
+			return 0, fmt.Errorf("throttled by the vendor until %s", pausedUntil.Format(time.RFC3339))
+		}
+		if err := sleep(ctx, d); err != nil {
+			return d, err
+		}
+		waited += d
+		now = now.Add(d)
+	}
+
+	r := l.bucket.ReserveN(now, 1)
+	if d := r.DelayFrom(now); d > 0 {
+		if err := sleep(ctx, d); err != nil {
+			r.CancelAt(now)
+			return waited, err
+		}
+		waited += d
+	}
+	return waited, nil
+}
+
+// snapshot returns the current effective rate and pause deadline
+func (l *adaptiveLimiter) snapshot() (float64, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.effective, l.pausedUntil
+}
+
+// observe adjusts the limiter from a vendor response. It reports whether the state changed.
+func (l *adaptiveLimiter) observe(status int, h http.Header, now time.Time) bool {
+	if h == nil {
+		h = http.Header{}
+	}
+	remaining, hasRemaining := headerInt(h, "X-Rate-Limit-Remaining", "X-RateLimit-Remaining", "RateLimit-Remaining")
+	limit, hasLimit := headerInt(h, "X-Rate-Limit-Limit", "X-RateLimit-Limit", "RateLimit-Limit")
+	reset, hasReset := parseRateLimitReset(h, now)
+	retry, hasRetry := parseRetryAfter(h.Get("Retry-After"), now)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	before, beforePause := l.effective, l.pausedUntil
+
+	switch {
+	case status == http.StatusTooManyRequests || (status == http.StatusServiceUnavailable && hasRetry):
+		pause := defaultThrottlePause
+		if hasRetry {
+			pause = retry
+		} else if hasReset && reset.After(now) {
+			pause = reset.Sub(now)
+		}
+		l.pauseUntil(now.Add(pause))
+
+		base := l.effective
+		if base <= 0 {
+			base = defaultThrottledRate
+			if hasLimit && hasReset && reset.After(now) {
+				base = float64(limit) / reset.Sub(now).Seconds()
+			}
+		}
+		l.setEffective(base/2, now)
+
+	case hasRemaining && remaining <= 0 && hasReset && reset.After(now):
+		l.pauseUntil(reset)
+
+	case hasRemaining && hasLimit && limit > 0 && remaining*10 < limit && hasReset && reset.After(now):
+		// Spread the remaining budget over the rest of the window
+		target := float64(remaining) / reset.Sub(now).Seconds()
+		if l.effective <= 0 || target < l.effective {
+			l.setEffective(target, now)
+		}
+
+	default:
+		l.recover(now)
+	}
+
+	return before != l.effective || !beforePause.Equal(l.pausedUntil)
+}
+
+// pauseUntil extends the pause deadline. Caller holds mu.
+func (l *adaptiveLimiter) pauseUntil(t time.Time) {
+	if t.After(l.pausedUntil) {
+		l.pausedUntil = t
+	}
+}
+
+// setEffective lowers or raises the rate, never below the floor. Caller holds mu.
+func (l *adaptiveLimiter) setEffective(r float64, now time.Time) {
+	if r > 0 && r < minEffectiveRate {
+		r = minEffectiveRate
+	}
+	l.effective = r
+	l.lastChange = now
+	l.applyRate(now)
+}
+
+// recover raises a throttled rate step by step back to the configured value. Caller holds mu.
+func (l *adaptiveLimiter) recover(now time.Time) {
+	if l.effective == l.configured || now.Sub(l.lastChange) < recoveryInterval || now.Before(l.pausedUntil) {
+		return
+	}
+
+	next := l.effective * recoveryFactor
+	switch {
+	case l.configured > 0 && next >= l.configured:
+		next = l.configured
+	case l.configured <= 0 && next >= unlimitedRecoveryCeiling:
+		next = 0
+	}
+	l.setEffective(next, now)
+}
+
+// observeRateLimit feeds a vendor response into the integration's limiter and
+// persists the effective rate so it is visible on the integration.
+func (e *ActionExecutor) observeRateLimit(integration database.Integration, resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	l := e.getLimiter(integration.ID, integration.RateLimit)
+	if !l.observe(resp.StatusCode, resp.Header, e.now()) {
+		return
+	}
+
+	effective, pausedUntil := l.snapshot()
+	ctx := context.Background()
+	if resp.Request != nil {
+		ctx = resp.Request.Context() // Carries the job's correlation fields
+	}
+	throttleLog.WarnContext(ctx, "Adjusted rate limit to vendor throttling", logging.FieldIntegration, integration.Name,
+		"effective_rate", formatRate(effective), "configured_rate", formatRate(integration.RateLimit), "paused_until", pausedUntil.Format(time.RFC3339))
+
+	var until *time.Time
+	if pausedUntil.After(e.now()) {
+		until = &pausedUntil
+	}
+	database.DB.Model(&database.Integration{}).Where("id = ?", integration.ID).UpdateColumns(map[string]interface{}{
+		"effective_rate_limit": roundRate(effective),
+		"throttled_until":      until,
+	})
+}
+
+func formatRate(r float64) string {
+	if r <= 0 {
+		return "unlimited"
+	}
+	return strconv.FormatFloat(r, 'f', 2, 64) + " req/s"
+}
+
+func headerInt(h http.Header, names ...string) (int, bool) {
+	for _, name := range names {
+		v := strings.TrimSpace(h.Get(name))
+		if v == "" {
+			continue
+		}
+		// IETF RateLimit headers may carry parameters ("100;w=60")
+		if i := strings.IndexAny(v, ";,"); i >= 0 {
+			v = v[:i]
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// parseRateLimitReset reads the reset header as either epoch seconds (Okta, GitHub)
+// or seconds until reset (IETF RateLimit-Reset and several vendors).
+func parseRateLimitReset(h http.Header, now time.Time) (time.Time, bool) {
+	v, ok := headerInt(h, "X-Rate-Limit-Reset", "X-RateLimit-Reset", "RateLimit-Reset")
+	if !ok || v < 0 {
+		return time.Time{}, false
+	}
+	if v > 1_000_000_000 {
+		return time.Unix(int64(v), 0), true
+	}
+	return now.Add(time.Duration(v) * time.Second), true
+}
+
+// roundRate keeps persisted rates readable
+func roundRate(r float64) float64 {
+	return math.Round(r*100) / 100
+}
+
+// sleepContext is the default ActionExecutor.sleep: a sleep that ends early when ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
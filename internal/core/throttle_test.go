@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"remediation-engine/internal/database"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveLimiter_BacksOffAndRecovers(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newAdaptiveLimiter(10)
+
+	h := http.Header{}
+	h.Set("Retry-After", "5")
+	assert.True(t, l.observe(429, h, now))
+
+	effective, pausedUntil := l.snapshot()
+	assert.Equal(t, 5.0, effective)
+	assert.Equal(t, now.Add(5*time.Second), pausedUntil)
+
+	// No recovery while paused or before the recovery interval has passed
+	assert.False(t, l.observe(200, nil, now.Add(6*time.Second)))
+
+	var rates []float64
+	for i := 1; i <= 4; i++ {
+		l.observe(200, nil, now.Add(time.Duration(i)*recoveryInterval))
+		effective, _ = l.snapshot()
+		rates = append(rates, effective)
+	}
+	assert.Equal(t, []float64{6.25, 7.8125, 9.765625, 10}, rates)
+}
+
+func TestAdaptiveLimiter_UnlimitedIntegration(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newAdaptiveLimiter(0)
+
+	// Okta-style headers: 100 requests per 60s window
+	h := http.Header{}
+	h.Set("X-Rate-Limit-Limit", "100")
+	h.Set("X-Rate-Limit-Reset", strconv.FormatInt(now.Add(60*time.Second).Unix(), 10))
+	l.observe(429, h, now)
+
+	effective, pausedUntil := l.snapshot()
+	assert.InDelta(t, 100.0/60/2, effective, 0.001)
+	assert.Equal(t, now.Add(60*time.Second), pausedUntil)
+
+	// Recovers all the way back to unlimited
+	for i := 1; effective != 0 && i < 100; i++ {
+		l.observe(200, nil, now.Add(time.Duration(i)*time.Minute))
+		effective, _ = l.snapshot()
+	}
+	assert.Equal(t, 0.0, effective)
+}
+
+func TestAdaptiveLimiter_RemainingBudget(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("exhausted window pauses until reset", func(t *testing.T) {
+		l := newAdaptiveLimiter(20)
+		h := http.Header{}
+		h.Set("X-RateLimit-Remaining", "0")
+		h.Set("X-RateLimit-Reset", "30")
+		assert.True(t, l.observe(200, h, now))
+
+		effective, pausedUntil := l.snapshot()
+		assert.Equal(t, 20.0, effective)
+		assert.Equal(t, now.Add(30*time.Second), pausedUntil)
+	})
+
+	t.Run("low remaining spreads calls over the window", func(t *testing.T) {
+		l := newAdaptiveLimiter(20)
+		h := http.Header{}
+		h.Set("X-Rate-Limit-Limit", "600")
+		h.Set("X-Rate-Limit-Remaining", "20")
+		h.Set("X-Rate-Limit-Reset", strconv.FormatInt(now.Add(40*time.Second).Unix(), 10))
+		assert.True(t, l.observe(200, h, now))
+
+		effective, _ := l.snapshot()
+		assert.Equal(t, 0.5, effective)
+	})
+
+	t.Run("plenty remaining leaves the rate alone", func(t *testing.T) {
+		l := newAdaptiveLimiter(20)
+		h := http.Header{}
+		h.Set("X-Rate-Limit-Limit", "600")
+		h.Set("X-Rate-Limit-Remaining", "500")
+		h.Set("X-Rate-Limit-Reset", "40")
+		assert.False(t, l.observe(200, h, now))
+	})
+}
+
+func TestAdaptiveLimiter_LongPauseFailsFast(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newAdaptiveLimiter(0)
+	h := http.Header{}
+	h.Set("Retry-After", "3600")
+	l.observe(429, h, now)
+
+	slept := false
+	_, err := l.wait(context.Background(), now, func(context.Context, time.Duration) error { slept = true; return nil })
+	assert.ErrorContains(t, err, "throttled by the vendor")
+	assert.False(t, slept)
+}
+
+func TestExecute_ThrottlesAfter429AndPersistsRate(t *testing.T) {
+	setupTestDB()
+	integ := database.Integration{Name: "Throttled", BaseURL: "http://throttled", IsAvailable: true, TenantID: 1, RateLimit: 4}
+	require.NoError(t, database.DB.Create(&integ).Error)
+
+	executor, _, sleeps := sequenceExecutor(t, []*http.Response{
+		response(429, map[string]string{"Retry-After": "3"}),
+		response(200, nil),
+	})
+
+	_, code, err := executor.Execute(integ, database.ActionDefinition{Name: "Get", Method: "GET"}, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	// The retry waits out Retry-After, which also covers the limiter pause
+	assert.Equal(t, []time.Duration{3 * time.Second}, *sleeps)
+
+	var stored database.Integration
+	require.NoError(t, database.DB.First(&stored, integ.ID).Error)
+	assert.Equal(t, 2.0, stored.EffectiveRateLimit)
+	require.NotNil(t, stored.ThrottledUntil)
+
+	// The next call is paced at the lowered rate (one token every 500ms)
+	_, _, err = executor.Execute(integ, database.ActionDefinition{Name: "Get", Method: "GET"}, map[string]interface{}{})
+	require.NoError(t, err)
+	require.Len(t, *sleeps, 2)
+	assert.Equal(t, 500*time.Millisecond, (*sleeps)[1])
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"net/http"
+	"remediation-engine/internal/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRunWorkflow_TracesStepsAndPropagates(t *testing.T) {
+	setupTestDB()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	originalProvider, originalPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(originalProvider)
+		otel.SetTextMapPropagator(originalPropagator)
+	}()
+
+	var traceparent string
+	executor := NewActionExecutor()
+	executor.Client.Transport = &MockTransport{
+		RoundTripFunc: func(req *http.Request) (*http.Response, error) {
+			traceparent = req.Header.Get("traceparent")
+			return response(200, nil), nil
+		},
+	}
+	originalFunc := NewExecutorFunc
+	defer func() { NewExecutorFunc = originalFunc }()
+	NewExecutorFunc = func() Executor { return executor }
+
+	wf := database.Workflow{Name: "Traced Workflow", Enabled: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&wf).Error)
+	integ := database.Integration{Name: "Traced Integ", BaseURL: "http://vendor", Enabled: true, IsAvailable: true, TenantID: 1}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	action := database.ActionDefinition{Name: "Disable User", Method: "POST", IntegrationID: integ.ID, TenantID: 1}
+	require.NoError(t, database.DB.Create(&action).Error)
+	require.NoError(t, database.DB.Create(&database.WorkflowStep{WorkflowID: wf.ID, ActionDefinitionID: action.ID, Order: 1, ParameterMapping: "{}"}).Error)
+	database.DB.Preload("Steps").First(&wf, wf.ID)
+
+	job := NewEngine().ExecuteWorkflow(wf, map[string]interface{}{"TenantID": uint(1), "IssueID": "trace-1"})
+	require.NotNil(t, job)
+
+	spans := recorder.Ended()
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name()
+		assert.Equal(t, job.TraceID, s.SpanContext().TraceID().String(), s.Name())
+	}
+	assert.ElementsMatch(t, []string{"rate limit wait", "concurrency slot wait", "HTTP POST", "Execute attempt", "step Disable User", "RunWorkflow"}, names)
+
+	var stored database.Job
+	require.NoError(t, database.DB.First(&stored, job.ID).Error)
+	assert.Len(t, stored.TraceID, 32)
+	assert.NotContains(t, stored.TriggerContext, "_ctx")
+
+	// The vendor sees the HTTP client span as the parent
+	require.NotEmpty(t, traceparent)
+	assert.Contains(t, traceparent, stored.TraceID)
+}
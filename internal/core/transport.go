@@ -0,0 +1,52 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"remediation-engine/internal/config"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/integrations"
+)
+
+// transportSettings collects the integration's TLS and proxy options
+func transportSettings(integration database.Integration) integrations.TransportSettings {
+	return integrations.TransportSettings{
+		CACert:             integration.CACert,
+		ClientCert:         integration.ClientCert,
+		ClientKey:          integration.ClientKey,
+		MinVersion:         integration.TLSMinVersion,
+		ServerName:         integration.TLSServerName,
+		InsecureSkipVerify: integration.InsecureSkipVerify,
+		ProxyURL:           integration.ProxyURL,
+	}
+}
+
+// httpClient returns the client for HTTP calls to the integration. Integrations with TLS or
+// proxy settings get their own cached transport; the others share e.Client's. The timeout
+// is e.Client.Timeout when set, and otherwise executor.http_timeout, read on every call so
+// a config reload applies to the next request.
+func (e *ActionExecutor) httpClient(integration database.Integration) (*http.Client, error) {
+	client := *e.Client
+	if client.Timeout == 0 {
+		client.Timeout = config.Current().Executor.HTTPTimeout.Std()
+	}
+	settings := transportSettings(integration)
+	if settings.IsZero() {
+		return &client, nil
+	}
+	transport, err := integrations.TransportFor(integration.ID, settings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport settings: %v", err)
+	}
+	client.Transport = transport
+	return &client, nil
+}
+
+// ValidateTransportSettings reports TLS or proxy settings that cannot be used, such as a
+// client key that does not match its certificate
+func ValidateTransportSettings(integration database.Integration) error {
+	if _, err := transportSettings(integration).NewTransport(); err != nil {
+		return fmt.Errorf("invalid transport settings: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"remediation-engine/internal/config"
+	"remediation-engine/internal/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPKI is a private CA with a server certificate for api.internal and a client certificate
+type testPKI struct {
+	caPEM                 string
+	server                tls.Certificate
+	clientCert, clientKey string
+	otherCert, otherKey   string // Signed by a different CA
+	clientPool            *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	newCA := func(name string) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: name},
+			NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+			IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+	issue := func(ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64, name string, usage x509.ExtKeyUsage) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial), Subject: pkix.Name{CommonName: name}, DNSNames: []string{name},
+			NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+			KeyUsage: x509.KeyUsageDigitalSignature, ExtKeyUsage: []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+			string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	}
+
+	ca, caKey, caPEM := newCA("Test CA")
+	other, otherKey, _ := newCA("Other CA")
+	p := testPKI{caPEM: string(caPEM), clientPool: x509.NewCertPool()}
+	p.clientPool.AddCert(ca)
+
+	serverCert, serverKey := issue(ca, caKey, 2, "api.internal", x509.ExtKeyUsageServerAuth)
+	var err error
+	p.server, err = tls.X509KeyPair([]byte(serverCert), []byte(serverKey))
+	require.NoError(t, err)
+	p.clientCert, p.clientKey = issue(ca, caKey, 3, "remediation-engine", x509.ExtKeyUsageClientAuth)
+	p.otherCert, p.otherKey = issue(other, otherKey, 4, "intruder", x509.ExtKeyUsageClientAuth)
+	return p
+}
+
+func TestExecuteREST_MutualTLSWithPrivateCA(t *testing.T) {
+	setupTestDB()
+	pki := newTestPKI(t)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"client": %q, "sni": %q}`, r.TLS.PeerCertificates[0].Subject.CommonName, r.TLS.ServerName)
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.clientPool,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	integ := database.Integration{
+		Name: "ServiceNow (on-prem)", Type: "REST", BaseURL: srv.URL, AuthType: "none", Enabled: true, TenantID: 1,
+		CACert: pki.caPEM, ClientCert: pki.clientCert, ClientKey: pki.clientKey,
+		TLSServerName: "api.internal", // The certificate does not cover 127.0.0.1
+		TLSMinVersion: "1.3",
+	}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	require.NoError(t, database.DB.First(&integ, integ.ID).Error)
+	def := database.ActionDefinition{Name: "Ping", Method: "GET", PathTemplate: "/ping", RetryCount: 1}
+
+	executor := NewActionExecutor()
+	executor.sleep = func(context.Context, time.Duration) error { return nil }
+	resp, code, err := executor.Execute(integ, def, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"client": "remediation-engine", "sni": "api.internal"}`, string(resp))
+
+	first, err := executor.httpClient(integ)
+	require.NoError(t, err)
+	second, err := executor.httpClient(integ)
+	require.NoError(t, err)
+	assert.Same(t, first.Transport, second.Transport, "one transport per integration")
+
+	// A certificate from another CA is refused by the server
+	intruder := integ
+	intruder.ID = integ.ID + 1
+	intruder.ClientCert, intruder.ClientKey = pki.otherCert, pki.otherKey
+	_, _, err = executor.Execute(intruder, def, map[string]interface{}{})
+	assert.Error(t, err)
+
+	// Without the private CA the server certificate is not trusted
+	untrusted := integ
+	untrusted.ID = integ.ID + 2
+	untrusted.CACert = ""
+	_, _, err = executor.Execute(untrusted, def, map[string]interface{}{})
+	assert.ErrorContains(t, err, "certificate")
+
+	// Settings that cannot be used fail without a request
+	broken := integ
+	broken.ClientKey = pki.otherKey
+	_, _, err = executor.Execute(broken, def, map[string]interface{}{})
+	assert.ErrorContains(t, err, "invalid transport settings: client_cert and client_key are not a valid PEM key pair")
+	assert.Equal(t, ErrorClassConfiguration, ClassifyError(0, err))
+}
+
+func TestExecuteREST_ThroughAuthenticatedProxy(t *testing.T) {
+	setupTestDB()
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Method+" "+r.URL.String()+" "+r.Header.Get("Proxy-Authorization"))
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer proxy.Close()
+
+	integ := database.Integration{
+		Name: "Vendor via proxy", Type: "REST", BaseURL: "http://vendor.example.com/api", AuthType: "none", Enabled: true,
+		TenantID: 1, ProxyURL: "http://svc:pa55@" + proxy.Listener.Addr().String(),
+	}
+	require.NoError(t, database.DB.Create(&integ).Error)
+	var stored struct{ ProxyURL string }
+	database.DB.Raw("SELECT proxy_url FROM integrations WHERE id = ?", integ.ID).Scan(&stored)
+	assert.NotEmpty(t, stored.ProxyURL)
+	assert.NotContains(t, stored.ProxyURL, "pa55", "proxy credentials are encrypted at rest")
+	require.NoError(t, database.DB.First(&integ, integ.ID).Error)
+
+	_, code, err := NewActionExecutor().Execute(integ, database.ActionDefinition{Name: "Lookup", Method: "GET", PathTemplate: "/users/1"}, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, []string{"GET http://vendor.example.com/api/users/1 Basic c3ZjOnBhNTU="}, proxied)
+}
+
+func TestHTTPClient_TimeoutFollowsConfigReload(t *testing.T) {
+	defer config.Set(*config.Current())
+	executor := NewActionExecutor()
+	integ := database.Integration{ID: 1, BaseURL: "https://api.internal"}
+
+	cfg := *config.Current()
+	cfg.Executor.HTTPTimeout = config.Duration(3 * time.Second)
+	config.Set(cfg)
+	client, err := executor.httpClient(integ)
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, client.Timeout)
+
+	// A reload applies to the next request of an existing executor
+	cfg.Executor.HTTPTimeout = config.Duration(40 * time.Second)
+	config.Set(cfg)
+	client, err = executor.httpClient(integ)
+	require.NoError(t, err)
+	assert.Equal(t, 40*time.Second, client.Timeout)
+
+	integ.ProxyURL = "http://proxy.internal:3128"
+	client, err = executor.httpClient(integ)
+	require.NoError(t, err)
+	assert.Equal(t, 40*time.Second, client.Timeout)
+	assert.NotNil(t, client.Transport)
+
+	// A timeout set on the executor's client wins
+	executor.Client = &http.Client{Timeout: time.Second}
+	client, err = executor.httpClient(integ)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, client.Timeout)
+}
@@ -0,0 +1,275 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/tracing"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/masterzen/winrm"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	winrmHTTPPort  = 5985
+	winrmHTTPSPort = 5986
+	// defaultCommandTimeout bounds a whole remote command when the integration sets no timeout
+	defaultCommandTimeout = 60 * time.Second
+	defaultKrb5Conf       = "/etc/krb5.conf"
+)
+
+// winrmTarget is the listener an integration's BaseURL points at
+type winrmTarget struct {
+	Host  string
+	Port  int
+	HTTPS bool
+}
+
+func (t winrmTarget) String() string {
+	scheme := "http"
+	if t.HTTPS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, t.Host, t.Port)
+}
+
+// parseWinRMTarget accepts "host", "host:port", "http://host[:port]" or "https://host[:port]".
+// Without a scheme, port 5986 selects HTTPS; everything else stays on HTTP.
+func parseWinRMTarget(baseURL string) (winrmTarget, error) {
+	raw := strings.TrimSpace(baseURL)
+	if raw == "" {
+		return winrmTarget{}, fmt.Errorf("winrm: base URL (host) is empty")
+	}
+
+	var t winrmTarget
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return winrmTarget{}, fmt.Errorf("winrm: invalid base URL %q: %v", baseURL, err)
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			t.HTTPS = true
+		case "http":
+		default:
+			return winrmTarget{}, fmt.Errorf("winrm: unsupported scheme %q (use http or https)", u.Scheme)
+		}
+		raw = u.Host
+	}
+
+	host, portStr, err := net.SplitHostPort(raw)
+	if err != nil {
+		// No port given
+		host = strings.Trim(raw, "[]")
+		t.Host = host
+		t.Port = winrmHTTPPort
+		if t.HTTPS {
+			t.Port = winrmHTTPSPort
+		}
+		return t, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return winrmTarget{}, fmt.Errorf("winrm: invalid port %q", portStr)
+	}
+	t.Host, t.Port = host, port
+	if !strings.Contains(baseURL, "://") && port == winrmHTTPSPort {
+		t.HTTPS = true
+	}
+	return t, nil
+}
+
+// commandTimeout is the per-integration limit for one WinRM or SSH command or LDAP operation
+func commandTimeout(integration database.Integration) time.Duration {
+	if integration.TimeoutSeconds > 0 {
+		return time.Duration(integration.TimeoutSeconds) * time.Second
+	}
+	return defaultCommandTimeout
+}
+
+// newWinRMClient builds a client for the integration's auth type. Supported types are
+// "basic", "ntlm" and "kerberos". Over plain HTTP, NTLM uses message encryption so that
+// listeners with AllowUnencrypted=false accept it.
+func newWinRMClient(integration database.Integration, target winrmTarget, creds map[string]string) (*winrm.Client, error) {
+	var caCert []byte
+	if integration.CACert != "" {
+		caCert = []byte(integration.CACert)
+		if !x509.NewCertPool().AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("winrm: ca_cert does not contain a valid PEM certificate")
+		}
+	}
+
+	timeout := commandTimeout(integration)
+	endpoint := winrm.NewEndpoint(target.Host, target.Port, target.HTTPS, integration.InsecureSkipVerify, caCert, nil, nil, timeout)
+
+	params := winrm.NewParameters(fmt.Sprintf("PT%dS", int(timeout.Seconds())), "en-US", 153600)
+	authType := strings.ToLower(integration.AuthType)
+	switch authType {
+	case "", "basic", "none":
+		// Default transport sends basic credentials
+	case "ntlm":
+		if target.HTTPS {
+			params.TransportDecorator = func() winrm.Transporter { return &winrm.ClientNTLM{} }
+		} else {
+			enc, err := winrm.NewEncryption("ntlm")
+			if err != nil {
+				return nil, fmt.Errorf("winrm: ntlm message encryption: %w", err)
+			}
+			params.TransportDecorator = func() winrm.Transporter { return enc }
+		}
+	case "kerberos":
+		realm := creds["realm"]
+		if realm == "" {
+			return nil, fmt.Errorf("winrm: kerberos auth needs a realm in the credentials")
+		}
+		krbConf := creds["krb5_conf"]
+		if krbConf == "" {
+			krbConf = defaultKrb5Conf
+		}
+		proto := "http"
+		if target.HTTPS {
+			proto = "https"
+		}
+		settings := &winrm.Settings{
+			WinRMUsername: creds["username"],
+			WinRMPassword: creds["password"],
+			WinRMHost:     target.Host,
+			WinRMPort:     target.Port,
+			WinRMProto:    proto,
+			WinRMInsecure: integration.InsecureSkipVerify,
+			KrbRealm:      realm,
+			KrbConfig:     krbConf,
+			KrbSpn:        creds["spn"],
+		}
+		params.TransportDecorator = func() winrm.Transporter { return winrm.NewClientKerberos(settings) }
+	default:
+		return nil, fmt.Errorf("winrm: unsupported auth type %q (use basic, ntlm or kerberos)", integration.AuthType)
+	}
+
+	client, err := winrm.NewClientWithParameters(endpoint, creds["username"], creds["password"], params)
+	if err != nil {
+		return nil, fmt.Errorf("winrm: failed to configure transport: %v", err)
+	}
+	return client, nil
+}
+
+func (e *ActionExecutor) executeWinRM(ctx context.Context, integration database.Integration, definition database.ActionDefinition, contextData map[string]interface{}) ([]byte, error) {
+	// 1. Resolve PowerShell Script (BodyTemplate serves as the Script Template), escaping
+	// every value for where it lands in the script
+	script, warnings, err := e.renderPowerShell(definition.BodyTemplate, contextData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render ps script: %v", err)
+	}
+	for _, w := range warnings {
+		executorLog.WarnContext(ctx, "PowerShell template lint", "action", definition.Name, "warning", w)
+	}
+
+	// 2. Parse Credentials
+	var creds map[string]string
+	if err := json.Unmarshal([]byte(integration.Credentials), &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse integration credentials: %v", err)
+	}
+
+	// 3. Resolve the listener and build a client for the auth type
+	target, err := parseWinRMTarget(integration.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newWinRMClient(integration, target, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Run PowerShell within the integration's timeout
+	timeout := commandTimeout(integration)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	_, span := tracing.Start(ctx, "WinRM Run",
+		attribute.String("server.address", target.Host),
+		attribute.Int("server.port", target.Port),
+		attribute.String("winrm.auth", strings.ToLower(integration.AuthType)))
+	exitCode, err := client.RunWithContext(runCtx, winrm.Powershell(script), &stdout, &stderr)
+	if err != nil && runCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	}
+	if err = describeWinRMError(err, target, integration.AuthType, timeout); err != nil {
+		tracing.End(span, err)
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("%w; stderr: %s", err, decodeCLIXML(stderr.String()))
+		}
+		return nil, err
+	}
+
+	// 5. A non-zero exit code or anything on the error stream fails the step
+	span.SetAttributes(attribute.Int("process.exit.code", exitCode))
+	resp, err := powerShellOutcome(exitCode, stdout.Bytes(), stderr.Bytes())
+	tracing.End(span, err)
+	return resp, err
+}
+
+// describeWinRMError turns transport failures into messages that name the likely fix.
+// The original error stays wrapped so retry classification still sees network errors.
+func describeWinRMError(err error, target winrmTarget, authType string, timeout time.Duration) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidCert      x509.CertificateInvalidError
+		recordHeaderErr  tls.RecordHeaderError
+		dnsErr           *net.DNSError
+		netErr           net.Error
+	)
+	msg := err.Error()
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return fmt.Errorf("winrm: %s did not finish within %s (raise timeout_seconds for long-running scripts): %w", target, timeout, err)
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("winrm: the certificate presented by %s is not signed by a trusted CA; add the issuing CA to ca_cert: %w", target, err)
+	case errors.As(err, &hostnameErr):
+		return fmt.Errorf("winrm: the certificate presented by %s is not valid for host %q; connect using a name listed in the certificate: %w", target, target.Host, err)
+	case errors.As(err, &invalidCert):
+		return fmt.Errorf("winrm: the certificate presented by %s is invalid (expired or not valid for server auth): %w", target, err)
+	case errors.As(err, &recordHeaderErr) || strings.Contains(msg, "server gave HTTP response to HTTPS client"):
+		return fmt.Errorf("winrm: %s did not answer with TLS; it is probably an HTTP listener (use http:// or port 5985): %w", target, err)
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("winrm: cannot resolve host %q: %w", target.Host, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("winrm: connection refused by %s; check that a WinRM listener is enabled on that port (HTTPS listeners use 5986): %w", target, err)
+	case strings.Contains(msg, "http error 401") || strings.Contains(msg, "returned: 401") || strings.Contains(msg, "http response error: 401"):
+		hint := "check the username and password"
+		if !target.HTTPS && !strings.EqualFold(authType, "ntlm") && !strings.EqualFold(authType, "kerberos") {
+			hint = "the server refuses basic credentials over plain HTTP; use https:// or auth type ntlm or kerberos"
+		}
+		// Surface as a 401 so it is classified as an auth failure and not retried
+		return fmt.Errorf("winrm: %s rejected the %s credentials; %s: %w", target, winrmAuthName(authType), hint,
+			&HTTPStatusError{StatusCode: http.StatusUnauthorized, Body: []byte(msg)})
+	case strings.Contains(msg, "SPNego") || strings.Contains(msg, "KRB") || strings.Contains(msg, "kerberos") || strings.Contains(msg, "krb5"):
+		return fmt.Errorf("winrm: kerberos authentication to %s failed (check realm, krb5_conf, spn and the KDC): %w", target, err)
+	}
+	return fmt.Errorf("winrm execution failed: %w", err)
+}
+
+func winrmAuthName(authType string) string {
+	if authType == "" || strings.EqualFold(authType, "none") {
+		return "basic"
+	}
+	return strings.ToLower(authType)
+}
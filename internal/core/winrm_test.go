@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"remediation-engine/internal/database"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWinRMTarget(t *testing.T) {
+	cases := []struct {
+		in   string
+		want winrmTarget
+	}{
+		{"dc01", winrmTarget{Host: "dc01", Port: 5985}},
+		{"192.168.1.50:5985", winrmTarget{Host: "192.168.1.50", Port: 5985}},
+		{"dc01:5986", winrmTarget{Host: "dc01", Port: 5986, HTTPS: true}},
+		{"https://dc01", winrmTarget{Host: "dc01", Port: 5986, HTTPS: true}},
+		{"https://dc01:8443", winrmTarget{Host: "dc01", Port: 8443, HTTPS: true}},
+		{"http://dc01:5986", winrmTarget{Host: "dc01", Port: 5986}},
+	}
+	for _, c := range cases {
+		got, err := parseWinRMTarget(c.in)
+		require.NoError(t, err, c.in)
+		assert.Equal(t, c.want, got, c.in)
+	}
+
+	for _, bad := range []string{"", "ftp://dc01", "dc01:notaport"} {
+		_, err := parseWinRMTarget(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestNewWinRMClient_RejectsBadSettings(t *testing.T) {
+	target := winrmTarget{Host: "dc01", Port: 5986, HTTPS: true}
+
+	_, err := newWinRMClient(database.Integration{AuthType: "kerberos"}, target, map[string]string{"username": "svc"})
+	assert.ErrorContains(t, err, "realm")
+
+	_, err = newWinRMClient(database.Integration{AuthType: "digest"}, target, nil)
+	assert.ErrorContains(t, err, "unsupported auth type")
+
+	_, err = newWinRMClient(database.Integration{AuthType: "ntlm", CACert: "not a pem"}, target, nil)
+	assert.ErrorContains(t, err, "ca_cert")
+}
+
+func TestNewWinRMClient_NTLMOverHTTPUsesEncryption(t *testing.T) {
+	client, err := newWinRMClient(database.Integration{AuthType: "ntlm"}, winrmTarget{Host: "dc01", Port: 5985}, map[string]string{"username": `CORP\svc`})
+	require.NoError(t, err)
+	require.NotNil(t, client)
+}
+
+// winrmIntegration points a WinRM integration at a test server
+func winrmIntegration(srv *httptest.Server, scheme string) database.Integration {
+	return database.Integration{
+		Name:        "DC",
+		Type:        "WINRM",
+		BaseURL:     scheme + "://" + strings.TrimPrefix(strings.TrimPrefix(srv.URL, "https://"), "http://"),
+		AuthType:    "basic",
+		Credentials: `{"username":"svc","password":"pw"}`,
+	}
+}
+
+func TestExecuteWinRM_FailureModes(t *testing.T) {
+	unauthorized := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	tlsSrv := httptest.NewTLSServer(unauthorized)
+	defer tlsSrv.Close()
+	plainSrv := httptest.NewServer(unauthorized)
+	defer plainSrv.Close()
+
+	executor := NewActionExecutor()
+	def := database.ActionDefinition{Name: "Disable", BodyTemplate: "Disable-ADAccount -Identity svc"}
+	run := func(integ database.Integration) error {
+		_, err := executor.executeWinRM(context.Background(), integ, def, map[string]interface{}{})
+		return err
+	}
+
+	// Self-signed certificate without a CA bundle
+	err := run(winrmIntegration(tlsSrv, "https"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not signed by a trusted CA")
+
+	// Trusting the server's CA gets through TLS to the authentication failure
+	integ := winrmIntegration(tlsSrv, "https")
+	integ.CACert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw}))
+	err = run(integ)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "check the username and password")
+	assert.Equal(t, ErrorClassAuth, ClassifyError(0, err))
+
+	integ = winrmIntegration(tlsSrv, "https")
+	integ.InsecureSkipVerify = true
+	assert.ErrorContains(t, run(integ), "HTTP 401")
+
+	// Basic over plain HTTP gets the encryption hint
+	err = run(winrmIntegration(plainSrv, "http"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "refuses basic credentials over plain HTTP")
+
+	// HTTPS against an HTTP listener
+	err = run(winrmIntegration(plainSrv, "https"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did not answer with TLS")
+}
+
+func TestExecuteWinRM_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer slow.Close()
+	defer close(done)
+
+	integ := winrmIntegration(slow, "http")
+	integ.TimeoutSeconds = 1
+	start := time.Now()
+	_, err := NewActionExecutor().executeWinRM(context.Background(), integ, database.ActionDefinition{BodyTemplate: "Start-Sleep 10"}, map[string]interface{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did not finish within 1s")
+	assert.Less(t, time.Since(start), 4*time.Second)
+}
@@ -0,0 +1,31 @@
+package database
+
+import "time"
+
+// Rerun batch states
+const (
+	RerunBatchRunning     = "running"
+	RerunBatchCompleted   = "completed"
+	RerunBatchCancelled   = "cancelled"
+	RerunBatchInterrupted = "interrupted"
+)
+
+// RerunBatch tracks a bulk rerun of jobs selected by a filter
+type RerunBatch struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+
+	TenantID    uint   `gorm:"index" json:"tenant_id"` // 0 = global (multi-tenant admin view)
+	RequestedBy string `json:"requested_by"`
+	Filter      string `json:"filter"` // JSON of the filter that selected the jobs
+	Concurrency int    `json:"concurrency"`
+	Status      string `gorm:"index" json:"status"`
+
+	// Progress: Succeeded + Failed + Skipped reaches Total when the batch is done
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// Defaults applied when an integration leaves its breaker settings at zero
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitCooldownSeconds  = 300
+)
+
+// CircuitEvent records a circuit breaker state change for an integration
+type CircuitEvent struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	IntegrationID uint      `gorm:"index" json:"integration_id"`
+	TenantID      uint      `gorm:"index" json:"tenant_id"`
+	Timestamp     time.Time `gorm:"index" json:"timestamp"`
+
+	FromState           string `json:"from_state"`
+	ToState             string `json:"to_state"`
+	Reason              string `json:"reason"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+// EffectiveCircuitState returns the breaker state, deriving it from IsAvailable for legacy rows
+func (i *Integration) EffectiveCircuitState() string {
+	if i.CircuitState != "" {
+		return i.CircuitState
+	}
+	if i.IsAvailable {
+		return CircuitClosed
+	}
+	return CircuitOpen
+}
+
+// CircuitThreshold returns the number of consecutive failures that trips the breaker
+func (i *Integration) CircuitThreshold() int {
+	if i.CircuitFailureThreshold > 0 {
+		return i.CircuitFailureThreshold
+	}
+	return DefaultCircuitFailureThreshold
+}
+
+// CircuitCooldown returns how long the breaker stays open before allowing a trial request
+func (i *Integration) CircuitCooldown() time.Duration {
+	if i.CircuitCooldownSeconds > 0 {
+		return time.Duration(i.CircuitCooldownSeconds) * time.Second
+	}
+	return DefaultCircuitCooldownSeconds * time.Second
+}
+
+// CircuitProbeDue reports whether an open (or stuck half-open) breaker may let a trial through
+func (i *Integration) CircuitProbeDue(now time.Time) bool {
+	state := i.EffectiveCircuitState()
+	if state == CircuitClosed {
+		return false
+	}
+	if i.CircuitChangedAt == nil {
+		return true
+	}
+	return !now.Before(i.CircuitChangedAt.Add(i.CircuitCooldown()))
+}
+
+// SetCircuitState moves the breaker from one state to another and records the change.
+// The update only applies if the breaker has not changed since integ was loaded,
+// so exactly one caller wins the open -> half_open race and gets to send the trial request.
+func SetCircuitState(db *gorm.DB, integ *Integration, to string, reason string) (bool, error) {
+	from := integ.EffectiveCircuitState()
+	now := time.Now()
+
+	updates := map[string]interface{}{
+		"circuit_state":      to,
+		"circuit_changed_at": now,
+		"is_available":       to == CircuitClosed,
+	}
+	if to == CircuitClosed {
+		updates["consecutive_failures"] = 0
+	}
+
+	won := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Integration{}).Where("id = ?", integ.ID)
+		if integ.CircuitState == "" {
+			query = query.Where("(circuit_state = '' OR circuit_state IS NULL)")
+		} else {
+			query = query.Where("circuit_state = ?", integ.CircuitState)
+		}
+		if integ.CircuitChangedAt == nil {
+			query = query.Where("circuit_changed_at IS NULL")
+		} else {
+			query = query.Where("circuit_changed_at = ?", *integ.CircuitChangedAt)
+		}
+
+		result := query.UpdateColumns(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		won = true
+
+		return tx.Create(&CircuitEvent{
+			IntegrationID:       integ.ID,
+			TenantID:            integ.TenantID,
+			Timestamp:           now,
+			FromState:           from,
+			ToState:             to,
+			Reason:              reason,
+			ConsecutiveFailures: integ.ConsecutiveFailures,
+		}).Error
+	})
+	if err != nil || !won {
+		return false, err
+	}
+
+	integ.CircuitState = to
+	integ.CircuitChangedAt = &now
+	integ.IsAvailable = to == CircuitClosed
+	if to == CircuitClosed {
+		integ.ConsecutiveFailures = 0
+	}
+	return true, nil
+}
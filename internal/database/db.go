@@ -41,11 +41,14 @@ func InitDB(dbPath string) {
 		&AuditLog{},
         &Tenant{},
 		&Integration{},
+		&CircuitEvent{},
 		&ActionDefinition{},
 		&Workflow{},
 		&WorkflowStep{},
 		&Job{},
 		&JobLog{},
+		&JobEvent{},
+		&RerunBatch{},
 		&ProcessedEvent{},
 		&StateStore{},
 		&MessageTemplate{},
@@ -69,6 +72,14 @@ func InitDB(dbPath string) {
         log.Printf("[Database] Warning: Job log migration failed: %v", err)
     }
 
+	if err := MigrateCircuitStates(DB); err != nil {
+		log.Printf("[Database] Warning: Circuit breaker migration failed: %v", err)
+	}
+
+	if err := MigrateJobStatuses(DB); err != nil {
+		log.Printf("[Database] Warning: Job status migration failed: %v", err)
+	}
+
 	log.Println("Database initialized and schema migrated successfully.")
 
 	// Seed Default Admin
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job lifecycle states
+const (
+	JobStatusPending               = "pending"
+	JobStatusRunning               = "running"
+	JobStatusSucceeded             = "succeeded"
+	JobStatusSucceededWithWarnings = "succeeded_with_warnings"
+	JobStatusFailed                = "failed"
+	JobStatusCancelled             = "cancelled"
+	JobStatusInterrupted           = "interrupted"
+	JobStatusResolvedManually      = "resolved_manually"
+)
+
+// ErrInvalidJobTransition is returned when a status change is not allowed by the lifecycle
+var ErrInvalidJobTransition = errors.New("invalid job state transition")
+
+// ErrStaleJobState is returned when the job changed status concurrently
+var ErrStaleJobState = errors.New("job state changed concurrently")
+
+// jobTransitions lists the states reachable from each state. Terminal states have no entry.
+var jobTransitions = map[string][]string{
+	JobStatusPending: {JobStatusRunning, JobStatusCancelled, JobStatusInterrupted},
+	JobStatusRunning: {JobStatusSucceeded, JobStatusSucceededWithWarnings, JobStatusFailed, JobStatusCancelled, JobStatusInterrupted},
+}
+
+// jobTriageTransitions are the moves an operator can make on a finished job from the dead-letter queue
+var jobTriageTransitions = map[string][]string{
+	JobStatusFailed:      {JobStatusResolvedManually},
+	JobStatusInterrupted: {JobStatusResolvedManually},
+}
+
+// JobStatuses returns every known job status in lifecycle order
+func JobStatuses() []string {
+	return []string{
+		JobStatusPending, JobStatusRunning,
+		JobStatusSucceeded, JobStatusSucceededWithWarnings, JobStatusFailed,
+		JobStatusCancelled, JobStatusInterrupted, JobStatusResolvedManually,
+	}
+}
+
+// ActiveJobStatuses are the non-terminal states a job can be in once it has started
+func ActiveJobStatuses() []string {
+	return []string{JobStatusPending, JobStatusRunning}
+}
+
+// DeadLetterStatuses are the outcomes that need an operator decision (rerun or resolve)
+func DeadLetterStatuses() []string {
+	return []string{JobStatusFailed, JobStatusInterrupted}
+}
+
+// IsTerminalJobStatus reports whether execution of the job is over.
+// Dead-letter triage (see jobTriageTransitions) may still resolve a terminal job.
+func IsTerminalJobStatus(status string) bool {
+	_, ok := jobTransitions[status]
+	return !ok
+}
+
+// CanTransitionJob reports whether a job may move from one status to another
+func CanTransitionJob(from, to string) bool {
+	for _, s := range jobTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	for _, s := range jobTriageTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// JobEvent records a single status transition in a job's history
+type JobEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	JobID     uint      `gorm:"index" json:"job_id"`
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+
+	FromStatus string `json:"from_status"` // Empty for the initial event
+	ToStatus   string `json:"to_status"`
+	Actor      string `json:"actor"` // "engine", "system", "user:<id>"
+	Reason     string `json:"reason"`
+}
+
+// RecordJobEvent appends an entry to the job's history without changing its status.
+// Used for the initial event when a job is created.
+func RecordJobEvent(db *gorm.DB, jobID uint, from, to, actor, reason string) error {
+	return db.Create(&JobEvent{
+		JobID:      jobID,
+		Timestamp:  time.Now(),
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+	}).Error
+}
+
+// TransitionJob moves a job to a new status and records the change.
+// The update is conditional on the job still being in its current status,
+// so concurrent transitions (e.g. a cancel racing the engine) do not overwrite each other.
+func TransitionJob(db *gorm.DB, job *Job, to, actor, reason string) error {
+	from := job.Status
+	if !CanTransitionJob(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidJobTransition, from, to)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Job{}).Where("id = ? AND status = ?", job.ID, from).Update("status", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStaleJobState
+		}
+		return RecordJobEvent(tx, job.ID, from, to, actor, reason)
+	})
+	if err != nil {
+		return err
+	}
+
+	job.Status = to
+	return nil
+}
+
+// JobStateDurations sums the time spent in each state from an event history.
+// The current state of a non-terminal job is measured up to now.
+func JobStateDurations(events []JobEvent, now time.Time) map[string]time.Duration {
+	sorted := make([]JobEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	durations := make(map[string]time.Duration)
+	for i, ev := range sorted {
+		var end time.Time
+		if i+1 < len(sorted) {
+			end = sorted[i+1].Timestamp
+		} else if !IsTerminalJobStatus(ev.ToStatus) {
+			end = now
+		} else {
+			continue
+		}
+		durations[ev.ToStatus] += end.Sub(ev.Timestamp)
+	}
+	return durations
+}
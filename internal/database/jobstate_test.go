@@ -0,0 +1,86 @@
+package database
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanTransitionJob(t *testing.T) {
+	assert.True(t, CanTransitionJob(JobStatusPending, JobStatusRunning))
+	assert.True(t, CanTransitionJob(JobStatusRunning, JobStatusSucceededWithWarnings))
+	assert.True(t, CanTransitionJob(JobStatusPending, JobStatusCancelled))
+	assert.False(t, CanTransitionJob(JobStatusSucceeded, JobStatusRunning))
+	assert.False(t, CanTransitionJob(JobStatusFailed, JobStatusSucceeded))
+	assert.False(t, CanTransitionJob(JobStatusPending, JobStatusSucceeded))
+
+	// Only dead-letter outcomes can be resolved by an operator
+	assert.True(t, CanTransitionJob(JobStatusFailed, JobStatusResolvedManually))
+	assert.True(t, CanTransitionJob(JobStatusInterrupted, JobStatusResolvedManually))
+	assert.False(t, CanTransitionJob(JobStatusSucceeded, JobStatusResolvedManually))
+	assert.False(t, CanTransitionJob(JobStatusResolvedManually, JobStatusFailed))
+
+	for _, s := range []string{JobStatusSucceeded, JobStatusSucceededWithWarnings, JobStatusFailed, JobStatusCancelled, JobStatusInterrupted, JobStatusResolvedManually} {
+		assert.True(t, IsTerminalJobStatus(s), s)
+	}
+}
+
+func TestTransitionJob(t *testing.T) {
+	os.Setenv("ENCRYPTION_KEY", "12345678901234567890123456789012")
+	InitDB(":memory:")
+
+	tenant := Tenant{Name: "State Tenant"}
+	require.NoError(t, DB.Create(&tenant).Error)
+	wf := Workflow{Name: "State WF", TenantID: tenant.ID}
+	require.NoError(t, DB.Create(&wf).Error)
+
+	job := Job{TenantID: tenant.ID, WorkflowID: wf.ID, AuthMindIssueID: "1", Status: JobStatusPending}
+	require.NoError(t, DB.Create(&job).Error)
+
+	require.NoError(t, TransitionJob(DB, &job, JobStatusRunning, "engine", "started"))
+	assert.Equal(t, JobStatusRunning, job.Status)
+
+	// Invalid transition is rejected and leaves the row untouched
+	err := TransitionJob(DB, &job, JobStatusPending, "engine", "rewind")
+	assert.ErrorIs(t, err, ErrInvalidJobTransition)
+
+	// A stale in-memory copy cannot overwrite a concurrent change
+	stale := job
+	require.NoError(t, TransitionJob(DB, &job, JobStatusCancelled, "user:1", "operator cancel"))
+	err = TransitionJob(DB, &stale, JobStatusSucceeded, "engine", "done")
+	assert.ErrorIs(t, err, ErrStaleJobState)
+
+	var reloaded Job
+	DB.First(&reloaded, job.ID)
+	assert.Equal(t, JobStatusCancelled, reloaded.Status)
+
+	var events []JobEvent
+	DB.Where("job_id = ?", job.ID).Order("id").Find(&events)
+	require.Len(t, events, 2)
+	assert.Equal(t, JobStatusPending, events[0].FromStatus)
+	assert.Equal(t, JobStatusRunning, events[0].ToStatus)
+	assert.Equal(t, "user:1", events[1].Actor)
+	assert.Equal(t, "operator cancel", events[1].Reason)
+}
+
+func TestJobStateDurations(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []JobEvent{
+		{ToStatus: JobStatusPending, Timestamp: start},
+		{FromStatus: JobStatusPending, ToStatus: JobStatusRunning, Timestamp: start.Add(2 * time.Second)},
+	}
+
+	d := JobStateDurations(events, start.Add(10*time.Second))
+	assert.Equal(t, 2*time.Second, d[JobStatusPending])
+	assert.Equal(t, 8*time.Second, d[JobStatusRunning]) // Open-ended
+
+	// A terminal final state is not counted
+	events = append(events, JobEvent{FromStatus: JobStatusRunning, ToStatus: JobStatusSucceeded, Timestamp: start.Add(6 * time.Second)})
+	d = JobStateDurations(events, start.Add(time.Hour))
+	assert.Equal(t, 4*time.Second, d[JobStatusRunning])
+	_, ok := d[JobStatusSucceeded]
+	assert.False(t, ok)
+}
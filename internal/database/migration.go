@@ -77,6 +77,32 @@ func MigrateJobLogs(db *gorm.DB) error {
     return nil
 }
 
+// MigrateCircuitStates opens the breaker for integrations tripped before breaker states existed
+func MigrateCircuitStates(db *gorm.DB) error {
+	result := db.Model(&Integration{}).
+		Where("is_available = ? AND (circuit_state = ? OR circuit_state = '' OR circuit_state IS NULL)", false, CircuitClosed).
+		UpdateColumn("circuit_state", CircuitOpen)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[Database] Marked %d unavailable integrations as circuit '%s'.", result.RowsAffected, CircuitOpen)
+	}
+	return nil
+}
+
+// MigrateJobStatuses maps pre-lifecycle job statuses onto the current state machine
+func MigrateJobStatuses(db *gorm.DB) error {
+	result := db.Model(&Job{}).Where("status = ?", "completed").Update("status", JobStatusSucceeded)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[Database] Migrated %d legacy 'completed' jobs to '%s'.", result.RowsAffected, JobStatusSucceeded)
+	}
+	return nil
+}
+
 // MigrateLegacyCredentials upgrades legacy encrypted data to the new format
 func MigrateLegacyCredentials(db *gorm.DB) error {
 	log.Println("[Database] Checking for legacy encryption...")
@@ -32,16 +32,39 @@ type Integration struct {
 
 	Type            string `json:"type"`      // e.g., "REST", "SLACK", "EMAIL"
 	BaseURL         string `json:"base_url"`  // Encrypted if sensitive
-	AuthType        string `json:"auth_type"` // "none", "basic", "bearer", "apikey", "oauth2"
+	AuthType        string `json:"auth_type"` // "none", "basic", "bearer", "apikey", "oauth2", "aws_sigv4", "hmac"
 	Credentials     string `json:"credentials"`
 	Enabled         bool   `json:"enabled"`
 	PollingInterval int    `json:"polling_interval"` // In seconds
 	RateLimit       float64 `json:"rate_limit"`       // Requests per second (0 = unlimited)
-
-	// OAuth2 specific fields
-	TokenEndpoint  string     `json:"token_endpoint"`
-	OAuthToken     string     `json:"-"`
-	OAuthExpiresAt *time.Time `json:"-"`
+	MaxConcurrency  int     `json:"max_concurrency"`  // Max in-flight calls across all workers (0 = unlimited)
+
+	// Adaptive throttling (maintained by the executor from vendor rate-limit headers)
+	EffectiveRateLimit float64    `json:"effective_rate_limit"` // Current rate after backing off (0 = unlimited)
+	ThrottledUntil     *time.Time `json:"throttled_until"`      // Set while the vendor asked us to pause
+
+	// Transport security and timeouts (WinRM, SSH, LDAP, Email, HTTP)
+	CACert             string `json:"ca_cert"`              // PEM bundle used to verify the server certificate
+	KnownHosts         string `json:"known_hosts"`          // SSH host key pins: known_hosts lines or SHA256 fingerprints
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // Skip certificate or host key verification (testing only)
+	StartTLS           bool   `json:"start_tls"`            // LDAP: upgrade ldap:// with StartTLS before binding; Email: require STARTTLS
+	TimeoutSeconds     int    `json:"timeout_seconds"`      // Per-command timeout (0 = 60s)
+
+	// Mutual TLS and proxy for outbound connections. CACert and InsecureSkipVerify above apply
+	// to HTTP integrations and the AuthMind poller as well. ClientKey and ProxyURL are
+	// encrypted like Credentials.
+	ClientCert    string `json:"client_cert"`     // PEM certificate chain presented to the server
+	ClientKey     string `json:"client_key"`      // PEM private key of ClientCert
+	TLSMinVersion string `json:"tls_min_version"` // "1.2" (default) or "1.3"
+	TLSServerName string `json:"tls_server_name"` // Host name verified and sent as SNI instead of the URL's
+	ProxyURL      string `json:"proxy_url"`       // HTTP calls only: http(s) or socks5 proxy, optionally with user:password
+
+	// OAuth2 specific fields. Grant, client authentication, scope and audience are read from
+	// the credentials; the refresh token is the latest one issued and is encrypted.
+	TokenEndpoint     string     `json:"token_endpoint"`
+	OAuthToken        string     `json:"-"`
+	OAuthExpiresAt    *time.Time `json:"-"`
+	OAuthRefreshToken string     `json:"-"`
 
 	LastRotatedAt    *time.Time `json:"last_rotated_at"`
 	RotationInterval int        `json:"rotation_interval_days"`
@@ -49,20 +72,39 @@ type Integration struct {
 	// Circuit Breaker fields
 	ConsecutiveFailures int  `gorm:"default:0" json:"consecutive_failures"`
 	IsAvailable         bool `gorm:"default:true" json:"is_available"`
+
+	CircuitState            string     `gorm:"default:'closed'" json:"circuit_state"` // "closed", "open", "half_open"
+	CircuitChangedAt        *time.Time `json:"circuit_changed_at"`
+	CircuitFailureThreshold int        `json:"circuit_failure_threshold"` // 0 = DefaultCircuitFailureThreshold
+	CircuitCooldownSeconds  int        `json:"circuit_cooldown_seconds"`  // 0 = DefaultCircuitCooldownSeconds
+	HealthCheckPath         string     `json:"health_check_path"`         // Optional GET probe used while the breaker is open
 }
 
-// BeforeSave hook to encrypt credentials
+// secrets are the fields of an integration that are stored encrypted
+func (i *Integration) secrets() []*string {
+	return []*string{&i.Credentials, &i.ClientKey, &i.ProxyURL, &i.OAuthRefreshToken}
+}
+
+// BeforeSave hook to encrypt credentials, the client key, the proxy URL and the refresh token
 func (i *Integration) BeforeSave(tx *gorm.DB) (err error) {
-	if i.Credentials != "" {
-		i.Credentials, err = security.Encrypt(i.Credentials)
+	for _, field := range i.secrets() {
+		if *field != "" {
+			if *field, err = security.Encrypt(*field); err != nil {
+				return err
+			}
+		}
 	}
 	return
 }
 
-// AfterFind hook to decrypt credentials
+// AfterFind hook to decrypt credentials, the client key, the proxy URL and the refresh token
 func (i *Integration) AfterFind(tx *gorm.DB) (err error) {
-	if i.Credentials != "" {
-		i.Credentials, err = security.Decrypt(i.Credentials)
+	for _, field := range i.secrets() {
+		if *field != "" {
+			if *field, err = security.Decrypt(*field); err != nil {
+				return err
+			}
+		}
 	}
 	return
 }
@@ -107,11 +149,37 @@ type ActionDefinition struct {
 	Method       string `json:"method"`
 	PathTemplate string `json:"path_template"`
 	BodyTemplate string `json:"body_template"`
+	// ContentType of the request body; values in the body template are escaped for it
+	// (JSON, form-encoded or XML). Empty means application/json.
+	ContentType string `json:"content_type"`
+	// Headers and QueryParams are extra request headers and query parameters whose values
+	// are templates. Query parameters are URL-encoded, and values that render empty are
+	// left out.
+	Headers     map[string]string `gorm:"serializer:json" json:"headers"`
+	QueryParams map[string]string `gorm:"serializer:json" json:"query_params"`
 
 	SuccessField string `json:"success_field"`
 
 	// Optional specific retry count for this action
 	RetryCount int `gorm:"default:3" json:"retry_count"`
+
+	// RetryPolicy controls which failures are retried and how long to back off
+	RetryPolicy RetryPolicy `gorm:"serializer:json" json:"retry_policy"`
+
+	// Warnings holds template lint findings returned when the action is saved
+	Warnings []string `gorm:"-" json:"warnings,omitempty"`
+}
+
+// RetryPolicy configures retries for an ActionDefinition. Zero values fall back to executor defaults.
+type RetryPolicy struct {
+	RetryOnStatus      []int   `json:"retry_on_status,omitempty"`      // HTTP codes to retry; default 408, 425, 429, 500, 502, 503, 504
+	RetryNetworkErrors *bool   `json:"retry_network_errors,omitempty"` // Retry connection/timeout errors; default true
+	RetryNonIdempotent bool    `json:"retry_non_idempotent"`           // Mark POST/PATCH/script actions as safe to retry
+	InitialBackoffMs   int     `json:"initial_backoff_ms,omitempty"`   // Default 1000
+	MaxBackoffMs       int     `json:"max_backoff_ms,omitempty"`       // Cap per delay; default 30000
+	Multiplier         float64 `json:"multiplier,omitempty"`           // Default 2
+	Jitter             *float64 `json:"jitter,omitempty"`              // Fraction of the delay randomised (0-1); default 0.2
+	MaxElapsedSeconds  int     `json:"max_elapsed_seconds,omitempty"`  // Give up once retries would exceed this; 0 = no limit
 }
 
 // Workflow defines a remediation process
@@ -158,7 +226,7 @@ type Job struct {
     Tenant     Tenant   `gorm:"foreignKey:TenantID" json:"tenant"`
 	WorkflowID uint     `gorm:"index:idx_wf_issue,unique" json:"workflow_id"`
 	Workflow   Workflow `gorm:"foreignKey:WorkflowID" json:"workflow"`
-	Status     string   `json:"status"` // "pending", "running", "completed", "failed"
+	Status     string   `gorm:"index" json:"status"` // See JobStatus* constants in jobstate.go
 
 	// AuthMindIssueID tracks which specific incident this job processed
 	AuthMindIssueID string `gorm:"index:idx_wf_issue,unique" json:"authmind_issue_id"`
@@ -166,7 +234,19 @@ type Job struct {
 	// TriggerContext stores the JSON serialized contextData for reruns
 	TriggerContext string `json:"trigger_context"`
 
-	Logs []JobLog `gorm:"foreignKey:JobID" json:"logs"`
+	// Dead-letter triage: where a failed job broke and what an operator has done about it
+	FailedIntegrationID uint       `gorm:"index" json:"failed_integration_id,omitempty"`
+	FailedAction        string     `json:"failed_action,omitempty"`
+	ErrorClass          string     `gorm:"index" json:"error_class,omitempty"` // See core.ErrorClass* constants
+	AcknowledgedAt      *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy      string     `json:"acknowledged_by,omitempty"`
+	AssignedTo          string     `json:"assigned_to,omitempty"`
+	RerunAt             *time.Time `json:"rerun_at,omitempty"` // Set once the job has been retried; it then leaves the dead-letter queue
+	RerunBatchID        *uint      `gorm:"index" json:"rerun_batch_id,omitempty"` // Bulk rerun that created this job
+	TraceID             string     `gorm:"index" json:"trace_id,omitempty"`       // OpenTelemetry trace of the run, empty when tracing is off
+
+	Logs   []JobLog   `gorm:"foreignKey:JobID" json:"logs"`
+	Events []JobEvent `gorm:"foreignKey:JobID" json:"events,omitempty"`
 }
 
 // JobLog stores detailed execution steps
@@ -181,6 +261,7 @@ type JobLog struct {
     StepName     string `json:"step_name"`
     StatusCode   int    `json:"status_code"`
     ResponseBody string `json:"response_body"`
+    QueueWaitMs  int64  `json:"queue_wait_ms"` // Time spent waiting for an integration concurrency slot
 }
 
 // ProcessedEvent tracks every event seen by the system for throughput metrics
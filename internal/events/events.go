@@ -0,0 +1,159 @@
+// Package events forwards job lifecycle changes and step results to SIEM destinations.
+//
+// Sinks are configured under events.sinks in the config file. Each one formats events as
+// CEF or LEEF (sent as syslog) or as JSON lines, and writes them over UDP, TCP, TLS or to
+// a file. Every sink has its own buffer and writer, so a slow or unreachable SIEM never
+// holds up workflows; events that do not fit the buffer are dropped and counted in
+// remediation_events_total{outcome="dropped"}.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"remediation-engine/internal/config"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/logging"
+	"remediation-engine/internal/security"
+	"sync"
+	"time"
+)
+
+var eventsLog = logging.For(logging.ComponentEvents)
+
+// Event types
+const (
+	TypeJobStatus  = "job.status"
+	TypeStepResult = "step.result"
+)
+
+// Step outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeSkipped = "skipped"
+)
+
+// Event is a job status change or a step result. Response, Error and Reason are redacted
+// by Emit.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	TenantID   uint      `json:"tenant_id"`
+	JobID      uint      `json:"job_id"`
+	WorkflowID uint      `json:"workflow_id"`
+	Workflow   string    `json:"workflow,omitempty"`
+	IssueID    string    `json:"issue_id,omitempty"`
+	User       string    `json:"user,omitempty"`
+	TraceID    string    `json:"trace_id,omitempty"`
+
+	// job.status
+	Status         string `json:"status,omitempty"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	Actor          string `json:"actor,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+
+	// step.result
+	Step        string `json:"step,omitempty"`
+	Integration string `json:"integration,omitempty"`
+	Outcome     string `json:"outcome,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ErrorClass  string `json:"error_class,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"duration_ms,omitempty"`
+	Response    string `json:"response,omitempty"`
+}
+
+// ForJob returns an event of the given type with the job's identifying fields set
+func ForJob(job *database.Job, eventType string) Event {
+	var trigger struct {
+		UserEmail string `json:"UserEmail"`
+	}
+	json.Unmarshal([]byte(job.TriggerContext), &trigger)
+	return Event{
+		Time:       time.Now().UTC(),
+		Type:       eventType,
+		TenantID:   job.TenantID,
+		JobID:      job.ID,
+		WorkflowID: job.WorkflowID,
+		Workflow:   job.Workflow.Name,
+		IssueID:    job.AuthMindIssueID,
+		User:       trigger.UserEmail,
+		TraceID:    job.TraceID,
+	}
+}
+
+// JobStatusChanged is the event for a job that moved from one status to its current one
+func JobStatusChanged(job *database.Job, from, actor, reason string) Event {
+	ev := ForJob(job, TypeJobStatus)
+	ev.Status, ev.PreviousStatus, ev.Actor, ev.Reason = job.Status, from, actor, reason
+	return ev
+}
+
+var (
+	mu    sync.RWMutex
+	sinks []*sink
+)
+
+// Init starts a writer for every configured sink. The returned func stops accepting events
+// and waits, until ctx is done, for the buffers to drain.
+func Init(cfg config.EventsConfig) (func(context.Context) error, error) {
+	started := make([]*sink, 0, len(cfg.Sinks))
+	for _, sc := range cfg.Sinks {
+		s, err := newSink(sc)
+		if err != nil {
+			for _, prev := range started {
+				prev.close()
+			}
+			return nil, fmt.Errorf("events sink %s: %v", sc.Name, err)
+		}
+		started = append(started, s)
+	}
+	for _, s := range started {
+		go s.run()
+	}
+
+	mu.Lock()
+	previous := sinks
+	sinks = started
+	mu.Unlock()
+	for _, s := range previous {
+		s.close()
+	}
+
+	return func(ctx context.Context) error {
+		mu.Lock()
+		sinks = nil
+		mu.Unlock()
+		for _, s := range started {
+			s.close()
+		}
+		var firstErr error
+		for _, s := range started {
+			if err := s.wait(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}, nil
+}
+
+// Emit queues the event on every sink routed to its tenant. It never blocks.
+func Emit(ev Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(sinks) == 0 {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	ev.Response = security.RedactText(ev.Response) // JSON through security.Redact, text by pattern
+	ev.Error = security.RedactText(ev.Error)
+	ev.Reason = security.RedactText(ev.Reason)
+	for _, s := range sinks {
+		if s.routes(ev.TenantID) {
+			s.enqueue(ev)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"remediation-engine/internal/config"
+	"remediation-engine/internal/database"
+	"remediation-engine/internal/metrics"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTime = time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+func failedJobEvent() Event {
+	job := &database.Job{ID: 42, TenantID: 1, WorkflowID: 7, Workflow: database.Workflow{Name: "Compromised | User"},
+		AuthMindIssueID: "1001", Status: database.JobStatusFailed, TriggerContext: `{"UserEmail":"jdoe@corp.example.com"}`}
+	ev := JobStatusChanged(job, database.JobStatusRunning, "engine", "step failed")
+	ev.Time = testTime
+	return ev
+}
+
+func TestFormatCEF(t *testing.T) {
+	assert.Equal(t, `CEF:0|AuthMind|Remediation Engine|1.0|job.failed|Job failed|7|rt=1792315800000 cat=job.status `+
+		`externalId=42 cn3Label=tenantId cn3=1 flexNumber1Label=workflowId flexNumber1=7 cs1Label=workflow cs1=Compromised | User `+
+		`cs2Label=issueId cs2=1001 suser=jdoe@corp.example.com act=failed flexString1Label=previousStatus flexString1=running `+
+		`suid=engine reason=step failed`, string(formatCEF(failedJobEvent())))
+
+	// Header and extension values cannot break the record
+	ev := Event{Time: testTime, Type: TypeStepResult, Step: `Disable|User\`, Outcome: OutcomeFailure, Error: "a=b\nCEF:0|forged"}
+	assert.Equal(t, `CEF:0|AuthMind|Remediation Engine|1.0|step.failure|Step Disable\|User\\ failure|7|rt=1792315800000 `+
+		`cat=step.result act=failure cs3Label=step cs3=Disable|User\\ outcome=failure msg=a\=b\nCEF:0|forged`, string(formatCEF(ev)))
+}
+
+func TestFormatLEEF(t *testing.T) {
+	ev := failedJobEvent()
+	ev.Reason = "line one\tline two\nline three"
+	assert.Equal(t, "LEEF:1.0|AuthMind|Remediation Engine|1.0|job.failed|devTime=1792315800000\tcat=job.status\tsev=7"+
+		"\tjobId=42\ttenantId=1\tworkflowId=7\tworkflow=Compromised | User\tissueId=1001\tusrName=jdoe@corp.example.com"+
+		"\tstatus=failed\tpreviousStatus=running\tactor=engine\treason=line one line two line three", string(formatLEEF(ev)))
+}
+
+func TestSinkMessage_SyslogFraming(t *testing.T) {
+	s, err := newSink(config.SinkConfig{Name: "qradar", Format: "leef", Transport: "tcp", Address: "siem:514", Framing: "octet", Facility: "local4"})
+	require.NoError(t, err)
+	s.hostname = "engine01"
+	msg := string(s.message(failedJobEvent()))
+	length, rest, _ := strings.Cut(msg, " ")
+	assert.Equal(t, strconv.Itoa(len(rest)), length, "octet counting")
+	// local4 (20) * 8 + err (3)
+	assert.True(t, strings.HasPrefix(rest, "<163>1 2026-10-18T09:30:00.000Z engine01 remediation-engine - job.status - LEEF:1.0|"), rest)
+
+	s, err = newSink(config.SinkConfig{Name: "file", Format: "cef", Transport: "file", Path: "events.log"})
+	require.NoError(t, err)
+	msg = string(s.message(failedJobEvent()))
+	assert.True(t, strings.HasPrefix(msg, "CEF:0|"), "files get the bare record")
+	assert.True(t, strings.HasSuffix(msg, "\n"))
+}
+
+func TestEmit_RoutesRedactsAndDelivers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	received := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			received <- scanner.Text()
+		}
+	}()
+
+	path := filepath.Join(t.TempDir(), "events.cef")
+	stop, err := Init(config.EventsConfig{Sinks: []config.SinkConfig{
+		{Name: "soc-json", Format: "json", Transport: "tcp", Address: ln.Addr().String(), Tenants: []uint{1}},
+		{Name: "archive", Format: "cef", Transport: "file", Path: path},
+	}})
+	require.NoError(t, err)
+
+	step := failedJobEvent()
+	step.Type, step.Status, step.PreviousStatus, step.Actor, step.Reason = TypeStepResult, "", "", "", ""
+	step.Step, step.Integration, step.Outcome, step.StatusCode = "Revoke Sessions", "Okta", OutcomeFailure, 401
+	step.Response = `{"error":"invalid_token","access_token":"s3cr3t"}`
+	step.Error = "HTTP 401: Authorization: Bearer abc.def.ghi"
+	Emit(step)
+
+	other := failedJobEvent()
+	other.TenantID = 2
+	Emit(other)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, stop(ctx))
+
+	// Tenant 1 only on the JSON sink, with secrets masked
+	var line string
+	select {
+	case line = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received over TCP")
+	}
+	var got Event
+	require.NoError(t, json.Unmarshal([]byte(line), &got))
+	assert.Equal(t, "Revoke Sessions", got.Step)
+	assert.Equal(t, uint(1), got.TenantID)
+	assert.NotContains(t, line, "s3cr3t")
+	assert.NotContains(t, line, "abc.def")
+	assert.JSONEq(t, `{"error":"invalid_token","access_token":"******"}`, got.Response)
+	select {
+	case extra := <-received:
+		t.Fatalf("tenant 2 event routed to a tenant 1 sink: %s", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Both tenants in the file
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "step.failure")
+	assert.NotContains(t, lines[0], "s3cr3t", "CEF and LEEF leave the response out")
+	assert.Contains(t, lines[1], "cn3=2")
+
+	// Emitting after shutdown is a no-op
+	Emit(step)
+}
+
+func TestSink_BuffersWhileDestinationIsDown(t *testing.T) {
+	// Reserve an address, then free it so the first writes fail
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	stop, err := Init(config.EventsConfig{Sinks: []config.SinkConfig{
+		{Name: "down", Format: "json", Transport: "tcp", Address: addr, Buffer: 2},
+	}})
+	require.NoError(t, err)
+	dropped := testutil.ToFloat64(metrics.Events.WithLabelValues("down", "dropped"))
+
+	ev := failedJobEvent()
+	Emit(ev) // Taken by the writer, which starts retrying
+	require.Eventually(t, func() bool {
+		mu.RLock()
+		defer mu.RUnlock()
+		return len(sinks[0].queue) == 0
+	}, time.Second, 5*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		Emit(ev) // Two fit the buffer, one is dropped
+	}
+	assert.Equal(t, dropped+1, testutil.ToFloat64(metrics.Events.WithLabelValues("down", "dropped")))
+
+	// The destination comes back and receives everything that was buffered
+	ln, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer ln.Close()
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, stop(ctx))
+	assert.Eventually(t, func() bool { return len(lines) == 3 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestInit_RejectsUnreadableCA(t *testing.T) {
+	_, err := Init(config.EventsConfig{Sinks: []config.SinkConfig{
+		{Name: "tls", Format: "cef", Transport: "tls", Address: "siem:6514", CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+	}})
+	assert.ErrorContains(t, err, "events sink tls: failed to read ca_file")
+}
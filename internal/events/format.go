@@ -0,0 +1,176 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"remediation-engine/internal/database"
+	"strconv"
+	"strings"
+)
+
+// Device fields of the CEF and LEEF headers
+const (
+	deviceVendor  = "AuthMind"
+	deviceProduct = "Remediation Engine"
+	deviceVersion = "1.0"
+)
+
+// syslogSeverity is the RFC 5424 severity of an event: error for failures, warning for
+// partial outcomes and informational otherwise
+func syslogSeverity(ev Event) int {
+	switch {
+	case ev.Status == database.JobStatusFailed, ev.Status == database.JobStatusInterrupted, ev.Outcome == OutcomeFailure:
+		return 3
+	case ev.Status == database.JobStatusSucceededWithWarnings, ev.Status == database.JobStatusCancelled, ev.Outcome == OutcomeSkipped:
+		return 4
+	}
+	return 6
+}
+
+// siemSeverity is the 0-10 severity used by CEF and LEEF
+func siemSeverity(ev Event) int {
+	switch syslogSeverity(ev) {
+	case 3:
+		return 7
+	case 4:
+		return 4
+	}
+	return 2
+}
+
+// eventID and eventName identify the kind of event, such as job.failed or step.success
+func eventID(ev Event) string {
+	if ev.Type == TypeStepResult {
+		return "step." + ev.Outcome
+	}
+	return "job." + ev.Status
+}
+
+func eventName(ev Event) string {
+	if ev.Type == TypeStepResult {
+		return fmt.Sprintf("Step %s %s", ev.Step, ev.Outcome)
+	}
+	return "Job " + strings.ReplaceAll(ev.Status, "_", " ")
+}
+
+// field is a key/value pair of a CEF or LEEF extension; empty values are left out
+type field struct{ key, value string }
+
+func extensionFields(ev Event) []field {
+	num := func(n int64) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	return []field{
+		{"jobId", num(int64(ev.JobID))},
+		{"tenantId", num(int64(ev.TenantID))},
+		{"workflowId", num(int64(ev.WorkflowID))},
+		{"workflow", ev.Workflow},
+		{"issueId", ev.IssueID},
+		{"user", ev.User},
+		{"status", ev.Status},
+		{"previousStatus", ev.PreviousStatus},
+		{"actor", ev.Actor},
+		{"reason", ev.Reason},
+		{"step", ev.Step},
+		{"integration", ev.Integration},
+		{"outcome", ev.Outcome},
+		{"statusCode", num(int64(ev.StatusCode))},
+		{"errorClass", ev.ErrorClass},
+		{"durationMs", num(ev.DurationMs)},
+		{"error", ev.Error},
+		{"traceId", ev.TraceID},
+	}
+}
+
+// cefKeys maps extension fields to CEF dictionary keys, using labeled custom fields for
+// the rest
+var cefKeys = map[string]string{
+	"jobId": "externalId", "user": "suser", "actor": "suid", "status": "act",
+	"reason": "reason", "outcome": "outcome", "error": "msg",
+}
+
+var cefCustom = map[string][2]string{
+	"workflow":       {"cs1", "cs1Label"},
+	"issueId":        {"cs2", "cs2Label"},
+	"step":           {"cs3", "cs3Label"},
+	"integration":    {"cs4", "cs4Label"},
+	"errorClass":     {"cs5", "cs5Label"},
+	"traceId":        {"cs6", "cs6Label"},
+	"statusCode":     {"cn1", "cn1Label"},
+	"durationMs":     {"cn2", "cn2Label"},
+	"tenantId":       {"cn3", "cn3Label"},
+	"previousStatus": {"flexString1", "flexString1Label"},
+	"workflowId":     {"flexNumber1", "flexNumber1Label"},
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// formatCEF renders an ArcSight Common Event Format record
+func formatCEF(ev Event) []byte {
+	var b strings.Builder
+	b.WriteString("CEF:0")
+	for _, h := range []string{deviceVendor, deviceProduct, deviceVersion, eventID(ev), eventName(ev)} {
+		b.WriteString("|" + cefHeaderEscaper.Replace(h))
+	}
+	b.WriteString("|" + strconv.Itoa(siemSeverity(ev)) + "|")
+
+	b.WriteString("rt=" + strconv.FormatInt(ev.Time.UnixMilli(), 10))
+	b.WriteString(" cat=" + cefExtensionEscaper.Replace(ev.Type))
+	if ev.Type == TypeStepResult {
+		b.WriteString(" act=" + cefExtensionEscaper.Replace(ev.Outcome))
+	}
+	for _, f := range extensionFields(ev) {
+		if f.value == "" {
+			continue
+		}
+		value := cefExtensionEscaper.Replace(f.value)
+		if key, ok := cefKeys[f.key]; ok {
+			b.WriteString(" " + key + "=" + value)
+		} else if custom, ok := cefCustom[f.key]; ok {
+			b.WriteString(" " + custom[1] + "=" + f.key + " " + custom[0] + "=" + value)
+		}
+	}
+	return []byte(b.String())
+}
+
+var (
+	leefHeaderEscaper = strings.NewReplacer(`|`, `\|`, "\r", " ", "\n", " ")
+	leefValueEscaper  = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+// formatLEEF renders an IBM QRadar LEEF 1.0 record with tab-separated attributes
+func formatLEEF(ev Event) []byte {
+	var b strings.Builder
+	b.WriteString("LEEF:1.0")
+	for _, h := range []string{deviceVendor, deviceProduct, deviceVersion, eventID(ev)} {
+		b.WriteString("|" + leefHeaderEscaper.Replace(h))
+	}
+	b.WriteString("|")
+
+	b.WriteString("devTime=" + strconv.FormatInt(ev.Time.UnixMilli(), 10))
+	b.WriteString("\tcat=" + ev.Type)
+	b.WriteString("\tsev=" + strconv.Itoa(siemSeverity(ev)))
+	for _, f := range extensionFields(ev) {
+		if f.value == "" {
+			continue
+		}
+		key := f.key
+		if key == "user" {
+			key = "usrName"
+		}
+		b.WriteString("\t" + key + "=" + leefValueEscaper.Replace(f.value))
+	}
+	return []byte(b.String())
+}
+
+// formatJSON renders the event as one JSON line, including the step response
+func formatJSON(ev Event) []byte {
+	b, _ := json.Marshal(ev)
+	return b
+}
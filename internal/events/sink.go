@@ -0,0 +1,236 @@
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"remediation-engine/internal/config"
+	"remediation-engine/internal/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBuffer = 1000
+	dialTimeout   = 10 * time.Second
+	writeTimeout  = 10 * time.Second
+	maxBackoff    = 30 * time.Second
+)
+
+// sink is one destination with its own buffer and writer goroutine
+type sink struct {
+	cfg       config.SinkConfig
+	tenants   map[uint]bool
+	format    func(Event) []byte
+	facility  int
+	hostname  string
+	tlsConfig *tls.Config
+
+	queue     chan Event
+	closeOnce sync.Once
+	stop      chan struct{} // Closed when shutdown gives up on a destination that is down
+	stopOnce  sync.Once
+	done      chan struct{} // Closed when the writer exits
+
+	conn io.WriteCloser // Owned by the writer goroutine
+}
+
+func newSink(cfg config.SinkConfig) (*sink, error) {
+	s := &sink{
+		cfg:      cfg,
+		facility: config.SyslogFacilities["local0"],
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	switch cfg.Format {
+	case "cef":
+		s.format = formatCEF
+	case "leef":
+		s.format = formatLEEF
+	case "json":
+		s.format = formatJSON
+	default:
+		return nil, fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+	if f, ok := config.SyslogFacilities[cfg.Facility]; ok {
+		s.facility = f
+	}
+	if len(cfg.Tenants) > 0 {
+		s.tenants = make(map[uint]bool, len(cfg.Tenants))
+		for _, id := range cfg.Tenants {
+			s.tenants[id] = true
+		}
+	}
+	s.hostname, _ = os.Hostname()
+	if s.hostname == "" {
+		s.hostname = "-"
+	}
+
+	if cfg.Transport == "tls" {
+		host, _, err := net.SplitHostPort(cfg.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", cfg.Address, err)
+		}
+		s.tlsConfig = &tls.Config{ServerName: host, InsecureSkipVerify: cfg.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read ca_file: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("ca_file %s does not contain a valid PEM certificate", cfg.CAFile)
+			}
+			s.tlsConfig.RootCAs = pool
+		}
+	}
+
+	buffer := cfg.Buffer
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	s.queue = make(chan Event, buffer)
+	return s, nil
+}
+
+// routes reports whether the sink receives events of the tenant
+func (s *sink) routes(tenantID uint) bool {
+	return s.tenants == nil || s.tenants[tenantID]
+}
+
+// enqueue buffers the event, or drops it when the buffer is full
+func (s *sink) enqueue(ev Event) {
+	select {
+	case s.queue <- ev:
+	default:
+		metrics.Events.WithLabelValues(s.cfg.Name, "dropped").Inc()
+	}
+}
+
+// close stops accepting events; the writer drains what is buffered
+func (s *sink) close() {
+	s.closeOnce.Do(func() { close(s.queue) })
+}
+
+// wait blocks until the buffer is drained or ctx is done, in which case the writer
+// drops what is left
+func (s *sink) wait(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		s.stopOnce.Do(func() { close(s.stop) })
+		return fmt.Errorf("events sink %s: %d events not delivered: %w", s.cfg.Name, len(s.queue), ctx.Err())
+	}
+}
+
+// run writes buffered events in order. A failed write is retried with backoff after
+// reconnecting, so a destination that is down holds events in the buffer.
+func (s *sink) run() {
+	defer close(s.done)
+	defer s.disconnect()
+
+	failing := false
+	for ev := range s.queue {
+		msg := s.message(ev)
+		for attempt := 0; ; attempt++ {
+			err := s.write(msg)
+			if err == nil {
+				metrics.Events.WithLabelValues(s.cfg.Name, "sent").Inc()
+				if failing {
+					eventsLog.Info("Event sink recovered", "sink", s.cfg.Name)
+					failing = false
+				}
+				break
+			}
+			s.disconnect()
+			if !failing {
+				eventsLog.Warn("Event sink unavailable, buffering events", "sink", s.cfg.Name, "transport", s.cfg.Transport, "error", err)
+				failing = true
+			}
+			if !s.pause(backoff(attempt)) {
+				metrics.Events.WithLabelValues(s.cfg.Name, "dropped").Inc()
+				break
+			}
+		}
+	}
+}
+
+// pause sleeps for d; it returns false when shutdown has given up on the destination
+func (s *sink) pause(d time.Duration) bool {
+	select {
+	case <-s.stop:
+		return false
+	default:
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+func backoff(attempt int) time.Duration {
+	d := 100 * time.Millisecond << min(attempt, 9)
+	return min(d, maxBackoff)
+}
+
+// message formats the event for the transport: CEF and LEEF sent over the network get a
+// syslog (RFC 5424) header, and stream transports add framing
+func (s *sink) message(ev Event) []byte {
+	msg := s.format(ev)
+	if s.cfg.Format != "json" && s.cfg.Transport != "file" {
+		header := fmt.Sprintf("<%d>1 %s %s remediation-engine - %s - ",
+			s.facility*8+syslogSeverity(ev), ev.Time.UTC().Format("2006-01-02T15:04:05.000Z"), s.hostname, ev.Type)
+		msg = append([]byte(header), msg...)
+	}
+	switch {
+	case s.cfg.Transport == "udp":
+		return msg
+	case s.cfg.Framing == "octet":
+		return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	return append(msg, '\n')
+}
+
+func (s *sink) write(msg []byte) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if c, ok := s.conn.(net.Conn); ok {
+		c.SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+func (s *sink) dial() (io.WriteCloser, error) {
+	switch s.cfg.Transport {
+	case "udp", "tcp":
+		return net.DialTimeout(s.cfg.Transport, s.cfg.Address, dialTimeout)
+	case "tls":
+		return tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", s.cfg.Address, s.tlsConfig)
+	case "file":
+		return os.OpenFile(s.cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	}
+	return nil, fmt.Errorf("unsupported transport %q", s.cfg.Transport)
+}
+
+func (s *sink) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
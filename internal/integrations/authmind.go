@@ -1,10 +1,12 @@
 package integrations
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"remediation-engine/internal/tracing"
 	"time"
 )
 
@@ -101,6 +103,11 @@ func (d *IssueDetails) RiskScore() string {
 // --- SDK Methods ---
 
 func (s *AuthMindSDK) GetIssues(issueType string, sinceID string) ([]Issue, error) {
+	return s.GetIssuesContext(context.Background(), issueType, sinceID)
+}
+
+// GetIssuesContext is GetIssues with a context for cancellation and trace propagation
+func (s *AuthMindSDK) GetIssuesContext(ctx context.Context, issueType string, sinceID string) ([]Issue, error) {
 	twoMonthsAgo := time.Now().AddDate(0, -2, 0).Format("2006-01-02 15:04:05")
 	
 	params := url.Values{}
@@ -116,7 +123,7 @@ func (s *AuthMindSDK) GetIssues(issueType string, sinceID string) ([]Issue, erro
 
 	fullURL := fmt.Sprintf("%s/getIssues?%s", s.BaseURL, params.Encode())
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +131,9 @@ func (s *AuthMindSDK) GetIssues(issueType string, sinceID string) ([]Issue, erro
 	req.Header.Set("Authorization", "Bearer "+s.Token)
 	req.Header.Set("Content-Type", "application/json")
 
+	req, span := tracing.StartHTTP(ctx, req)
 	resp, err := s.Client.Do(req)
+	tracing.EndHTTP(span, resp, err)
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +156,11 @@ func (s *AuthMindSDK) GetIssues(issueType string, sinceID string) ([]Issue, erro
 }
 
 func (s *AuthMindSDK) GetIssueDetails(issueID string) (*IssueDetails, error) {
+	return s.GetIssueDetailsContext(context.Background(), issueID)
+}
+
+// GetIssueDetailsContext is GetIssueDetails with a context for cancellation and trace propagation
+func (s *AuthMindSDK) GetIssueDetailsContext(ctx context.Context, issueID string) (*IssueDetails, error) {
 	params := url.Values{}
 	params.Add("issue_id", issueID)
 	params.Add("sort_order", "ASC")
@@ -156,7 +170,7 @@ func (s *AuthMindSDK) GetIssueDetails(issueID string) (*IssueDetails, error) {
 
 	fullURL := fmt.Sprintf("%s/getIssueDetails?%s", s.BaseURL, params.Encode())
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -164,7 +178,9 @@ func (s *AuthMindSDK) GetIssueDetails(issueID string) (*IssueDetails, error) {
 	req.Header.Set("Authorization", "Bearer "+s.Token)
 	req.Header.Set("Content-Type", "application/json")
 
+	req, span := tracing.StartHTTP(ctx, req)
 	resp, err := s.Client.Do(req)
+	tracing.EndHTTP(span, resp, err)
 	if err != nil {
 		return nil, err
 	}
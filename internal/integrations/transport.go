@@ -0,0 +1,127 @@
+package integrations
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// TransportSettings are the TLS and proxy options of one integration's outbound
+// connections. The zero value means system roots, TLS 1.2 and the environment's proxy.
+type TransportSettings struct {
+	CACert             string // PEM bundle that replaces the system roots
+	ClientCert         string // PEM certificate chain presented for mutual TLS
+	ClientKey          string // PEM private key of ClientCert
+	MinVersion         string // "1.2" (default) or "1.3"
+	ServerName         string // Host name to verify and send as SNI instead of the URL's
+	InsecureSkipVerify bool
+	ProxyURL           string // http, https or socks5 URL; empty uses HTTPS_PROXY and NO_PROXY
+}
+
+// IsZero reports whether no option is set, so the default transport can be shared
+func (s TransportSettings) IsZero() bool {
+	return s == TransportSettings{}
+}
+
+// TLSVersions maps the accepted MinVersion values to their protocol versions
+var TLSVersions = map[string]uint16{
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig builds the client TLS configuration. host is verified when ServerName is
+// empty; pass "" to let the HTTP transport use the request's host.
+func (s TransportSettings) TLSConfig(host string) (*tls.Config, error) {
+	version, ok := TLSVersions[s.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("tls_min_version %q must be 1.2 or 1.3", s.MinVersion)
+	}
+	cfg := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+		MinVersion:         version,
+	}
+	if s.ServerName != "" {
+		cfg.ServerName = s.ServerName
+	}
+	if s.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(s.CACert)) {
+			return nil, fmt.Errorf("ca_cert does not contain a valid PEM certificate")
+		}
+		cfg.RootCAs = pool
+	}
+	if s.ClientCert != "" || s.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(s.ClientCert), []byte(s.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("client_cert and client_key are not a valid PEM key pair: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// NewTransport builds an HTTP transport with the default pooling and timeouts and the
+// settings' TLS and proxy options
+func (s TransportSettings) NewTransport() (*http.Transport, error) {
+	tlsConfig, err := s.TLSConfig("")
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsConfig
+	if s.ProxyURL != "" {
+		proxy, err := url.Parse(s.ProxyURL)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("proxy_url is not a valid URL")
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("proxy_url scheme %q must be http, https or socks5", proxy.Scheme)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	return t, nil
+}
+
+type cachedTransport struct {
+	settings  TransportSettings
+	transport *http.Transport
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[uint]cachedTransport)
+)
+
+// TransportFor returns the transport of an integration, building it on first use and
+// again when the settings change, so connections are pooled per integration.
+// Integrations without settings share http.DefaultTransport.
+func TransportFor(integrationID uint, s TransportSettings) (http.RoundTripper, error) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	cached, ok := transports[integrationID]
+	if ok && cached.settings == s {
+		return cached.transport, nil
+	}
+	if ok {
+		cached.transport.CloseIdleConnections()
+		delete(transports, integrationID)
+	}
+	if s.IsZero() {
+		return http.DefaultTransport, nil
+	}
+
+	t, err := s.NewTransport()
+	if err != nil {
+		return nil, err
+	}
+	transports[integrationID] = cachedTransport{settings: s, transport: t}
+	return t, nil
+}
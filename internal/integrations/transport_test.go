@@ -0,0 +1,53 @@
+package integrations
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportFor_CachesPerIntegration(t *testing.T) {
+	shared, err := TransportFor(9001, TransportSettings{})
+	require.NoError(t, err)
+	assert.Same(t, http.DefaultTransport, shared, "integrations without settings share the default transport")
+
+	settings := TransportSettings{ProxyURL: "http://proxy.corp:3128", MinVersion: "1.3"}
+	first, err := TransportFor(9001, settings)
+	require.NoError(t, err)
+	again, err := TransportFor(9001, settings)
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	other, err := TransportFor(9002, settings)
+	require.NoError(t, err)
+	assert.NotSame(t, first, other, "each integration has its own pool")
+
+	settings.ProxyURL = "http://proxy2.corp:3128"
+	changed, err := TransportFor(9001, settings)
+	require.NoError(t, err)
+	assert.NotSame(t, first, changed, "changed settings rebuild the transport")
+
+	req, _ := http.NewRequest("GET", "https://vendor.example.com", nil)
+	proxy, err := changed.(*http.Transport).Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, "proxy2.corp:3128", proxy.Host)
+	assert.Equal(t, uint16(0x0304), changed.(*http.Transport).TLSClientConfig.MinVersion)
+}
+
+func TestTransportSettings_Rejects(t *testing.T) {
+	for _, c := range []struct {
+		settings TransportSettings
+		want     string
+	}{
+		{TransportSettings{MinVersion: "1.0"}, `tls_min_version "1.0" must be 1.2 or 1.3`},
+		{TransportSettings{CACert: "not a certificate"}, "ca_cert does not contain a valid PEM certificate"},
+		{TransportSettings{ClientCert: "-----BEGIN CERTIFICATE-----"}, "client_cert and client_key are not a valid PEM key pair"},
+		{TransportSettings{ProxyURL: "ftp://proxy:21"}, `proxy_url scheme "ftp" must be http, https or socks5`},
+		{TransportSettings{ProxyURL: "proxy.corp:3128"}, "proxy_url is not a valid URL"},
+	} {
+		_, err := c.settings.NewTransport()
+		assert.ErrorContains(t, err, c.want)
+	}
+}
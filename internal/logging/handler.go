@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"remediation-engine/internal/security"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "******"
+
+// handler filters by component level, adds correlation fields and redacts secrets
+// before passing records to the configured output handler
+type handler struct {
+	component string
+	ops       []func(slog.Handler) slog.Handler // WithAttrs/WithGroup calls, replayed on the current output
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levelFor(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, security.RedactText(r.Message), r.PC)
+	if h.component != "" {
+		out.AddAttrs(slog.String("component", h.component))
+	}
+	for _, a := range Fields(ctx) {
+		out.AddAttrs(redactAttr(a))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		out.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+
+	next := *base.Load()
+	for _, op := range h.ops {
+		next = op(next)
+	}
+	return next.Handle(ctx, out)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	safe := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		safe[i] = redactAttr(a)
+	}
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(safe) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{component: h.component, ops: append(ops, op)}
+}
+
+// redactAttr masks attributes whose key looks sensitive and secrets inside string values
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if security.IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, security.RedactText(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		safe := make([]any, len(group))
+		for i, g := range group {
+			safe[i] = redactAttr(g)
+		}
+		return slog.Group(a.Key, safe...)
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, security.RedactText(err.Error()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,201 @@
+// Package logging provides structured logging on top of log/slog.
+//
+// Every logger belongs to a component (engine, executor, api, ...) whose level can be
+// changed at runtime. Correlation fields attached to a context with WithFields are added
+// to every record logged with that context, and messages and attributes are redacted
+// before they reach the output.
+//
+// Configuration:
+//   - LOG_FORMAT: "text" (default) or "json"
+//   - LOG_LEVEL: default level (debug, info, warn, error); DEBUG=true implies debug
+//   - LOG_LEVELS: per-component overrides, e.g. "executor=debug,api=warn"
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Components with their own log level
+const (
+	ComponentEngine   = "engine"
+	ComponentExecutor = "executor"
+	ComponentCircuit  = "circuit"
+	ComponentThrottle = "throttle"
+	ComponentAPI      = "api"
+	ComponentServer   = "server"
+	ComponentEvents   = "events"
+)
+
+// Correlation field names shared by all components
+const (
+	FieldTenantID    = "tenant_id"
+	FieldJobID       = "job_id"
+	FieldWorkflow    = "workflow"
+	FieldStep        = "step"
+	FieldIntegration = "integration"
+	FieldRequestID   = "request_id"
+)
+
+var (
+	base         atomic.Pointer[slog.Handler]
+	defaultLevel = new(slog.LevelVar)
+
+	levelsMu sync.RWMutex
+	levels   = make(map[string]*slog.LevelVar)
+)
+
+func init() {
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	base.Store(&h)
+}
+
+// Init configures the output format and levels from the environment and routes the
+// standard library logger through the same handler.
+func Init() error {
+	return Configure(os.Stderr, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"), os.Getenv("LOG_LEVELS"), os.Getenv("DEBUG") == "true")
+}
+
+// Configure sets the output, format and levels. It is split from Init for tests.
+func Configure(w io.Writer, format string, level string, componentLevels string, debug bool) error {
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug} // Filtering happens per component
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown LOG_FORMAT %q (expected text or json)", format)
+	}
+
+	if level == "" && debug {
+		level = "debug"
+	}
+	if level != "" {
+		if err := SetLevel("", level); err != nil {
+			return err
+		}
+	}
+	for _, pair := range strings.Split(componentLevels, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		component, lvl, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid LOG_LEVELS entry %q (expected component=level)", pair)
+		}
+		if err := SetLevel(strings.TrimSpace(component), strings.TrimSpace(lvl)); err != nil {
+			return err
+		}
+	}
+
+	base.Store(&h)
+	slog.SetDefault(slog.New(&handler{}))
+	return nil
+}
+
+// For returns the logger of a component
+func For(component string) *slog.Logger {
+	return slog.New(&handler{component: component})
+}
+
+// SetLevel changes the level of a component at runtime; "" or "default" changes the default level
+func SetLevel(component string, level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	if component == "" || component == "default" {
+		defaultLevel.Set(l)
+		return nil
+	}
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	v, ok := levels[component]
+	if !ok {
+		v = new(slog.LevelVar)
+		levels[component] = v
+	}
+	v.Set(l)
+	return nil
+}
+
+// ResetLevel makes a component follow the default level again
+func ResetLevel(component string) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	delete(levels, component)
+}
+
+// Levels returns the default level and every component override
+func Levels() map[string]string {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+
+	out := map[string]string{"default": strings.ToLower(defaultLevel.Level().String())}
+	for component, v := range levels {
+		out[component] = strings.ToLower(v.Level().String())
+	}
+	return out
+}
+
+// Components lists the known components in a stable order
+func Components() []string {
+	c := []string{ComponentEngine, ComponentExecutor, ComponentCircuit, ComponentThrottle, ComponentAPI, ComponentServer, ComponentEvents}
+	sort.Strings(c)
+	return c
+}
+
+func levelFor(component string) slog.Level {
+	if component != "" {
+		levelsMu.RLock()
+		v, ok := levels[component]
+		levelsMu.RUnlock()
+		if ok {
+			return v.Level()
+		}
+	}
+	return defaultLevel.Level()
+}
+
+type fieldsKey struct{}
+
+// WithFields returns a context whose log records carry the given key/value pairs
+// (in addition to any fields already on ctx). Later values replace earlier ones.
+func WithFields(ctx context.Context, args ...any) context.Context {
+	existing := Fields(ctx)
+	added := slog.Group("", args...).Value.Group()
+
+	merged := make([]slog.Attr, 0, len(existing)+len(added))
+	for _, a := range existing {
+		replaced := false
+		for _, b := range added {
+			if a.Key == b.Key {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, a)
+		}
+	}
+	return context.WithValue(ctx, fieldsKey{}, append(merged, added...))
+}
+
+// Fields returns the correlation fields on ctx
+func Fields(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return attrs
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m), line)
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestLogger_JSONWithCorrelationFieldsAndRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Configure(&buf, "json", "info", "", false))
+	defer ResetLevel(ComponentExecutor)
+
+	ctx := WithFields(context.Background(), FieldTenantID, 3, FieldJobID, 42, FieldWorkflow, "Disable User")
+	ctx = WithFields(ctx, FieldStep, "Suspend", FieldIntegration, "Okta")
+
+	For(ComponentExecutor).WarnContext(ctx, "refresh failed: client_secret=abc123",
+		"api_token", "t0k3n", "error", errors.New(`auth server said {"access_token": "xyz"}`))
+
+	lines := decodeLines(t, &buf)
+	require.Len(t, lines, 1)
+	rec := lines[0]
+	assert.Equal(t, "WARN", rec["level"])
+	assert.Equal(t, "executor", rec["component"])
+	assert.Equal(t, float64(3), rec["tenant_id"])
+	assert.Equal(t, float64(42), rec["job_id"])
+	assert.Equal(t, "Disable User", rec["workflow"])
+	assert.Equal(t, "Suspend", rec["step"])
+	assert.Equal(t, "Okta", rec["integration"])
+	assert.Equal(t, "refresh failed: client_secret=******", rec["msg"])
+	assert.Equal(t, "******", rec["api_token"])
+	assert.Equal(t, `auth server said {"access_token": "******"}`, rec["error"])
+}
+
+func TestLogger_PerComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Configure(&buf, "json", "warn", "executor=debug", false))
+	defer ResetLevel(ComponentExecutor)
+
+	For(ComponentEngine).Info("hidden")
+	For(ComponentExecutor).Debug("shown")
+	assert.Len(t, decodeLines(t, &buf), 1)
+
+	// Runtime change
+	require.NoError(t, SetLevel(ComponentEngine, "info"))
+	defer ResetLevel(ComponentEngine)
+	For(ComponentEngine).Info("now shown")
+	assert.Len(t, decodeLines(t, &buf), 2)
+
+	assert.Equal(t, map[string]string{"default": "warn", "engine": "info", "executor": "debug"}, Levels())
+	assert.Error(t, SetLevel(ComponentEngine, "loud"))
+	assert.Error(t, Configure(&buf, "xml", "", "", false))
+	assert.Error(t, Configure(&buf, "json", "", "executor", false))
+	require.NoError(t, Configure(&buf, "json", "info", "", false))
+}
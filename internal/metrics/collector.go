@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"remediation-engine/internal/database"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobsDesc = prometheus.NewDesc(namespace+"_jobs", "Jobs by tenant and status.",
+		[]string{"tenant", "status"}, nil)
+	circuitDesc = prometheus.NewDesc(namespace+"_circuit_breaker_state",
+		"Integrations in each circuit breaker state (1 for the current state of a single integration).",
+		[]string{"tenant", "integration", "state"}, nil)
+)
+
+// dbCollector reads job and circuit breaker state from the database at scrape time
+// so the values stay correct across restarts and API-driven changes
+type dbCollector struct{}
+
+func (dbCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobsDesc
+	ch <- circuitDesc
+}
+
+func (dbCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.DB == nil {
+		return
+	}
+	collectJobs(ch)
+	collectCircuits(ch)
+}
+
+func collectJobs(ch chan<- prometheus.Metric) {
+	var rows []struct {
+		TenantID uint
+		Status   string
+		Count    int64
+	}
+	if err := database.DB.Model(&database.Job{}).Select("tenant_id, status, COUNT(*) AS count").
+		Group("tenant_id, status").Scan(&rows).Error; err != nil {
+		ch <- prometheus.NewInvalidMetric(jobsDesc, err)
+		return
+	}
+
+	// Tenants past the label limit share a series, so sum before emitting
+	counts := make(map[[2]string]int64)
+	for _, r := range rows {
+		counts[[2]string{Tenant(r.TenantID), r.Status}] += r.Count
+	}
+	for labels, n := range counts {
+		ch <- prometheus.MustNewConstMetric(jobsDesc, prometheus.GaugeValue, float64(n), labels[0], labels[1])
+	}
+}
+
+func collectCircuits(ch chan<- prometheus.Metric) {
+	var integs []database.Integration
+	if err := database.DB.Select("id", "tenant_id", "name", "circuit_state", "is_available").Find(&integs).Error; err != nil {
+		ch <- prometheus.NewInvalidMetric(circuitDesc, err)
+		return
+	}
+
+	states := []string{database.CircuitClosed, database.CircuitOpen, database.CircuitHalfOpen}
+	counts := make(map[[3]string]int)
+	for _, i := range integs {
+		tenant, name := Tenant(i.TenantID), Integration(i.Name)
+		current := i.EffectiveCircuitState()
+		for _, s := range states {
+			key := [3]string{tenant, name, s}
+			if s == current {
+				counts[key]++
+			} else if _, ok := counts[key]; !ok {
+				counts[key] = 0
+			}
+		}
+	}
+	for labels, n := range counts {
+		ch <- prometheus.MustNewConstMetric(circuitDesc, prometheus.GaugeValue, float64(n), labels[0], labels[1], labels[2])
+	}
+}
@@ -0,0 +1,191 @@
+// Package metrics exposes engine, executor and API metrics in Prometheus format.
+//
+// Every tenant, integration and action label goes through a bounded label set: once
+// METRICS_MAX_LABEL_VALUES distinct values have been seen for a label, further values
+// are reported as "other". METRICS_TENANT_LABEL=false collapses all tenants into "all".
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "remediation"
+
+	// OverflowLabel replaces label values beyond the cardinality limit
+	OverflowLabel = "other"
+	// AllTenantsLabel is reported for every tenant when tenant labels are disabled
+	AllTenantsLabel = "all"
+	// NoTenantLabel is reported for API requests without an authenticated, existing tenant
+	NoTenantLabel = "none"
+
+	defaultMaxLabelValues = 200
+)
+
+// Registry holds every metric served on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	tenantLabelEnabled = os.Getenv("METRICS_TENANT_LABEL") != "false"
+	maxLabelValues     = envInt("METRICS_MAX_LABEL_VALUES", defaultMaxLabelValues)
+
+	tenants      = newLabelSet(maxLabelValues)
+	integrations = newLabelSet(maxLabelValues)
+	actions      = newLabelSet(maxLabelValues)
+)
+
+// latencyBuckets cover fast API calls up to slow vendor calls with retries (5ms - 2min)
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+var (
+	// QueueDepth is set by the engine to report its polling task queue
+	QueueDepth = func() float64 { return 0 }
+
+	PollDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "poll_duration_seconds",
+		Help:      "Duration of AuthMind GetIssues polls.",
+		Buckets:   latencyBuckets,
+	}, []string{"tenant", "integration", "outcome"})
+
+	IssuesFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "issues_fetched_total",
+		Help:      "Issues returned by AuthMind polls.",
+	}, []string{"tenant", "integration"})
+
+	StepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "step_duration_seconds",
+		Help:      "Duration of workflow steps, including retries and waits.",
+		Buckets:   latencyBuckets,
+	}, []string{"tenant", "integration", "action", "outcome"})
+
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "action_retries_total",
+		Help:      "Retried action attempts.",
+	}, []string{"tenant", "integration", "action"})
+
+	RateLimitWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_wait_seconds",
+		Help:      "Time action attempts spent waiting for the integration rate limiter.",
+		Buckets:   latencyBuckets,
+	}, []string{"tenant", "integration"})
+
+	TokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oauth_token_refreshes_total",
+		Help:      "OAuth2 access token refreshes.",
+	}, []string{"tenant", "integration", "outcome"})
+
+	Events = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_total",
+		Help:      "Job and step events by SIEM sink and outcome (sent, dropped).",
+	}, []string{"sink", "outcome"})
+
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of HTTP API requests by route template.",
+		Buckets:   latencyBuckets,
+	}, []string{"tenant", "method", "route", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "task_queue_depth",
+			Help:      "Polling tasks waiting for a worker.",
+		}, func() float64 { return QueueDepth() }),
+		PollDuration, IssuesFetched, StepDuration, Retries, RateLimitWait, TokenRefreshes, Events, APIRequestDuration,
+		dbCollector{},
+	)
+}
+
+// Handler serves the registry. When METRICS_TOKEN is set, scrapers must send it as a bearer token.
+func Handler() http.Handler {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	token := os.Getenv("METRICS_TOKEN")
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Tenant returns the bounded label value for a tenant
+func Tenant(id uint) string {
+	if !tenantLabelEnabled {
+		return AllTenantsLabel
+	}
+	return tenants.value(strconv.FormatUint(uint64(id), 10))
+}
+
+// Integration returns the bounded label value for an integration name
+func Integration(name string) string {
+	return integrations.value(name)
+}
+
+// Action returns the bounded label value for an action name
+func Action(name string) string {
+	return actions.value(name)
+}
+
+// Outcome is the outcome label for an error
+func Outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// labelSet admits the first max distinct values and maps the rest to OverflowLabel
+type labelSet struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newLabelSet(max int) *labelSet {
+	return &labelSet{max: max, seen: make(map[string]struct{})}
+}
+
+func (s *labelSet) value(v string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.seen[v]; ok {
+		return v
+	}
+	if len(s.seen) >= s.max {
+		return OverflowLabel
+	}
+	s.seen[v] = struct{}{}
+	return v
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"remediation-engine/internal/database"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelSet_BoundsCardinality(t *testing.T) {
+	s := newLabelSet(2)
+	assert.Equal(t, "a", s.value("a"))
+	assert.Equal(t, "b", s.value("b"))
+	assert.Equal(t, OverflowLabel, s.value("c"))
+	// Values admitted earlier keep their own series
+	assert.Equal(t, "a", s.value("a"))
+}
+
+func TestDBCollector_JobsAndCircuits(t *testing.T) {
+	database.InitDB(":memory:")
+	database.DB.FirstOrCreate(&database.Tenant{ID: 1, Name: "Default Tenant"})
+	wf := database.Workflow{Name: "Metrics", TenantID: 1}
+	require.NoError(t, database.DB.Create(&wf).Error)
+	require.NoError(t, database.DB.Create(&database.Integration{Name: "Okta", TenantID: 1, IsAvailable: true, CircuitState: database.CircuitOpen}).Error)
+	for i, status := range []string{database.JobStatusFailed, database.JobStatusFailed, database.JobStatusSucceeded} {
+		require.NoError(t, database.DB.Create(&database.Job{TenantID: 1, WorkflowID: wf.ID, Status: status, AuthMindIssueID: fmt.Sprintf("issue-%d", i)}).Error)
+	}
+
+	expected := `
+# HELP remediation_circuit_breaker_state Integrations in each circuit breaker state (1 for the current state of a single integration).
+# TYPE remediation_circuit_breaker_state gauge
+remediation_circuit_breaker_state{integration="Okta",state="closed",tenant="1"} 0
+remediation_circuit_breaker_state{integration="Okta",state="half_open",tenant="1"} 0
+remediation_circuit_breaker_state{integration="Okta",state="open",tenant="1"} 1
+# HELP remediation_jobs Jobs by tenant and status.
+# TYPE remediation_jobs gauge
+remediation_jobs{status="failed",tenant="1"} 2
+remediation_jobs{status="succeeded",tenant="1"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(dbCollector{}, strings.NewReader(expected)))
+}
+
+func TestHandler_RequiresTokenWhenConfigured(t *testing.T) {
+	t.Setenv("METRICS_TOKEN", "scrape-secret")
+	h := Handler()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-secreT")
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape-secret")
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "remediation_task_queue_depth")
+}
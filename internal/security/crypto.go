@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
 	"strings"
 )
 
@@ -116,14 +117,26 @@ func decryptRaw(base64Text string) (string, error) {
 	return string(plaintext), nil
 }
 
+// sensitiveKeys are matched as substrings of lower-cased field names
+var sensitiveKeys = []string{"token", "password", "secret", "api_key", "credentials", "client_secret", "authorization", "oauth", "api_token", "apikey"}
+
+// IsSensitiveKey reports whether a field name looks like it holds a secret
+func IsSensitiveKey(key string) bool {
+	lowerK := strings.ToLower(key)
+	for _, sk := range sensitiveKeys {
+		if strings.Contains(lowerK, sk) {
+			return true
+		}
+	}
+	return false
+}
+
 // Redact masks sensitive keys in JSON content. Falls back to original string if not valid JSON.
 func Redact(input string) string {
 	if input == "" {
 		return ""
 	}
 
-	sensitiveKeys := []string{"token", "password", "secret", "api_key", "credentials", "client_secret", "authorization", "oauth", "api_token", "apikey"}
-
 	var data interface{}
 	if err := json.Unmarshal([]byte(input), &data); err != nil {
 		return input
@@ -139,15 +152,7 @@ func redactRecursive(data interface{}, sensitiveKeys []string) interface{} {
 	case map[string]interface{}:
 		newMap := make(map[string]interface{})
 		for k, val := range v {
-			isSensitive := false
-			lowerK := strings.ToLower(k)
-			for _, sk := range sensitiveKeys {
-				if strings.Contains(lowerK, sk) {
-					isSensitive = true
-					break
-				}
-			}
-			if isSensitive {
+			if IsSensitiveKey(k) {
 				newMap[k] = "******"
 			} else {
 				newMap[k] = redactRecursive(val, sensitiveKeys)
@@ -163,4 +168,30 @@ func redactRecursive(data interface{}, sensitiveKeys []string) interface{} {
 	default:
 		return v
 	}
-}
\ No newline at end of file
+}
+var (
+	// key=value, key: value and "key": "value" pairs whose key looks sensitive
+	sensitivePairPattern = regexp.MustCompile(`(?i)("?[a-z0-9_\-]*(?:` + strings.Join(sensitiveKeys, "|") + `)[a-z0-9_\-]*"?\s*[:=]\s*"?)([^"\s,&;}]+)`)
+	// Authorization header values
+	authSchemePattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[a-z0-9\-._~+/]+=*`)
+	// Compact JWS/JWT tokens
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+)
+
+// RedactText masks secrets in free text such as log messages: JSON documents are
+// handled like Redact, otherwise key/value pairs, Authorization values and JWTs are masked.
+func RedactText(input string) string {
+	if input == "" {
+		return ""
+	}
+	if trimmed := strings.TrimSpace(input); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var data interface{}
+		if json.Unmarshal([]byte(trimmed), &data) == nil {
+			return Redact(trimmed)
+		}
+	}
+
+	out := jwtPattern.ReplaceAllString(input, "******")
+	out = authSchemePattern.ReplaceAllString(out, "$1 ******")
+	return sensitivePairPattern.ReplaceAllString(out, "${1}******")
+}
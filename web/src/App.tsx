@@ -11,6 +11,7 @@ import ActionTemplates from './pages/ActionTemplates';
 import Dashboard from './pages/Dashboard';
 import TenantManagement from './pages/TenantManagement';
 import AuditLog from './pages/AuditLog';
+import DeadLetter from './pages/DeadLetter';
 import { BrowserRouter, Routes, Route } from 'react-router-dom';
 import { TenantProvider } from './context/TenantContext';
 import { AuthProvider, useAuth } from './context/AuthContext';
@@ -75,6 +76,7 @@ export default function App() {
                       <Route path="/workflows" element={<ProtectedRoute><Workflows /></ProtectedRoute>} />
                       <Route path="/workflows/:id" element={<ProtectedRoute><WorkflowEditor /></ProtectedRoute>} />
                       <Route path="/audit" element={<ProtectedRoute><AuditLog /></ProtectedRoute>} />
+                      <Route path="/dead-letter" element={<ProtectedRoute><DeadLetter /></ProtectedRoute>} />
                   </Routes>
               </BrowserRouter>
           </TenantProvider>
@@ -18,6 +18,7 @@ import AccountTreeIcon from '@mui/icons-material/AccountTree';
 import ListAltIcon from '@mui/icons-material/ListAlt';
 import CorporateFareIcon from '@mui/icons-material/CorporateFare';
 import HistoryIcon from '@mui/icons-material/History';
+import ReportProblemIcon from '@mui/icons-material/ReportProblem';
 import LogoutIcon from '@mui/icons-material/Logout';
 import AccountCircleIcon from '@mui/icons-material/AccountCircle';
 
@@ -56,6 +57,7 @@ export default function Layout({ children }: LayoutProps) {
       { text: 'Integrations', icon: <IntegrationInstructionsIcon sx={{ fontSize: 20 }} />, path: '/integrations' },
       { text: 'Action Templates', icon: <ListAltIcon sx={{ fontSize: 20 }} />, path: '/actions' },
       { text: 'Workflows', icon: <AccountTreeIcon sx={{ fontSize: 20 }} />, path: '/workflows' },
+      { text: 'Dead Letter', icon: <ReportProblemIcon sx={{ fontSize: 20 }} />, path: '/dead-letter' },
       { text: 'Audit Logs', icon: <HistoryIcon sx={{ fontSize: 20 }} />, path: '/audit' },
   ];
 
@@ -29,6 +29,7 @@ import {
   Alert
 } from '@mui/material';
 import EditIcon from '@mui/icons-material/Edit';
+import DeleteIcon from '@mui/icons-material/Delete';
 import InfoIcon from '@mui/icons-material/Info';
 import AddIcon from '@mui/icons-material/Add';
 import SearchIcon from '@mui/icons-material/Search';
@@ -46,9 +47,13 @@ interface ActionDefinition {
   method: string;
   path_template: string;
   body_template: string;
+  content_type?: string;
+  headers?: Record<string, string>;
+  query_params?: Record<string, string>;
   integration_id: number;
   success_field?: string;
   retry_count?: number;
+  warnings?: string[];
 }
 
 interface Integration {
@@ -76,11 +81,82 @@ const TEMPLATE_VARIABLES = [
     { label: '.RemediationTitle', detail: 'Remediation Title', documentation: 'Title of the recommended remediation.' },
     { label: '.RemediationDescription', detail: 'Remediation Desc', documentation: 'High-level description of the remediation.' },
     { label: '.RemediationSteps', detail: 'Remediation Steps', documentation: 'Markdown-formatted steps for remediation.' },
+    { label: '.Steps', detail: 'Earlier Step Results', documentation: 'Usage: {{index .Steps "Find SCIM User" "id"}}. Responses of the steps that ran before this one, keyed by action name. JSON responses are parsed.' },
     { label: '.RemediationURL', detail: 'Remediation URL', documentation: 'Link to official AuthMind remediation documentation.' },
     { label: '| default', detail: 'Fallback Helper', documentation: 'Usage: {{.Variable | default "my fallback"}}. Useful for missing Detail fields.' },
-    { label: '| jsonescape', detail: 'JSON Safety Helper', documentation: 'Usage: {{.Variable | jsonescape}}. Essential for strings inside JSON payloads to handle quotes and newlines.' },
+    { label: '| jsonescape', detail: 'JSON Safety Helper', documentation: 'Usage: {{.Variable | jsonescape}}. Values in JSON, form and XML bodies are escaped automatically for the content type, so this is only kept for older templates.' },
+    { label: 'psquote', detail: 'PowerShell Literal', documentation: 'Usage: -Identity {{psquote .UserEmail}}. Emits the value as a complete single-quoted PowerShell string. PowerShell scripts are escaped automatically; this just makes the quoting explicit.' },
+    { label: 'samaccountname', detail: 'AD Logon Name', documentation: "Usage: '{{samaccountname .UserEmail}}'. Strips DOMAIN\\ and @domain and fails the step if the result is not a valid sAMAccountName." },
+    { label: 'shquote', detail: 'Shell Word', documentation: 'Usage: usermod -L {{shquote .UserName}}. Emits the value as a single-quoted POSIX shell word. SSH commands are escaped automatically; this just makes the quoting explicit.' },
+    { label: 'unixuser', detail: 'Unix Login Name', documentation: "Usage: pkill -KILL -u {{unixuser .UserEmail}}. Strips DOMAIN\\ and @domain and fails the step if the result is not a valid user name." },
+    { label: 'ldapfilter', detail: 'LDAP Filter Value', documentation: "Usage: (mail={{ldapfilter .UserEmail}}). Hex-escapes ( ) * \\ so the value cannot change the filter. Applied automatically inside \"filter\" strings of LDAP actions." },
+    { label: 'ldapdn', detail: 'LDAP DN Value', documentation: "Usage: CN={{ldapdn .Name}},OU=Users. Escapes , + \" \\ < > ; in a DN attribute value. Applied automatically inside dn, base_dn and group_dn strings." },
+    { label: 'html', detail: 'HTML Text', documentation: 'Usage: <b>{{html .UserName}}</b>. Escapes < > & \' " so the value cannot add markup. Applied automatically inside the "html" string of email actions.' },
+    { label: 'raw', detail: 'Unescaped Value (unsafe)', documentation: 'Usage: {{raw .Variable}}. Inserts the value into a request body, SSH command or PowerShell script without escaping. Saving reports a lint warning.' },
 ];
 
+// Body content types; values in the payload template are escaped for the selected type
+const CONTENT_TYPES = [
+    { value: 'application/json', label: 'JSON' },
+    { value: 'application/x-www-form-urlencoded', label: 'Form (URL-encoded)' },
+    { value: 'application/xml', label: 'XML' },
+    { value: 'text/xml', label: 'XML (text/xml)' },
+    { value: 'text/plain', label: 'Plain Text (unescaped)' },
+];
+
+const editorLanguage = (contentType?: string) =>
+    contentType?.includes('xml') ? 'xml' : (!contentType || contentType.includes('json')) ? 'json' : 'plaintext';
+
+// Editable name/value rows for templated headers and query parameters
+function KeyValueEditor({ label, namePlaceholder, valuePlaceholder, value, onChange }: {
+    label: string;
+    namePlaceholder: string;
+    valuePlaceholder: string;
+    value?: Record<string, string>;
+    onChange: (value: Record<string, string>) => void;
+}) {
+    const [rows, setRows] = useState<[string, string][]>(Object.entries(value || {}));
+
+    const update = (next: [string, string][]) => {
+        setRows(next);
+        onChange(Object.fromEntries(next.filter(([name]) => name.trim() !== '')));
+    };
+
+    return (
+        <Box>
+            <Box sx={{ display: 'flex', alignItems: 'center', mb: 1 }}>
+                <Typography variant="subtitle2" sx={{ fontWeight: 700, flexGrow: 1 }}>{label}</Typography>
+                <Button size="small" startIcon={<AddIcon />} onClick={() => update([...rows, ['', '']])}>Add</Button>
+            </Box>
+            {rows.map(([name, val], i) => (
+                <Box key={i} sx={{ display: 'flex', gap: 1, mb: 1 }}>
+                    <TextField
+                        size="small"
+                        placeholder={namePlaceholder}
+                        value={name}
+                        onChange={(e) => update(rows.map((r, j) => j === i ? [e.target.value, r[1]] : r))}
+                        sx={{ width: '35%' }}
+                    />
+                    <TextField
+                        size="small"
+                        fullWidth
+                        placeholder={valuePlaceholder}
+                        value={val}
+                        onChange={(e) => update(rows.map((r, j) => j === i ? [r[0], e.target.value] : r))}
+                        InputProps={{ sx: { fontFamily: 'monospace' } }}
+                    />
+                    <IconButton size="small" onClick={() => update(rows.filter((_r, j) => j !== i))}>
+                        <DeleteIcon fontSize="small" />
+                    </IconButton>
+                </Box>
+            ))}
+            {rows.length === 0 && (
+                <Typography variant="caption" color="text.secondary">None</Typography>
+            )}
+        </Box>
+    );
+}
+
 export default function ActionTemplates() {
   const { selectedTenant } = useTenant();
   const [actions, setActions] = useState<ActionDefinition[]>([]);
@@ -102,10 +178,15 @@ export default function ActionTemplates() {
       method: 'POST',
       path_template: '',
       body_template: '{}',
+      content_type: 'application/json',
+      headers: {},
+      query_params: {},
       retry_count: 3
   });
   const [importJson, setImportJson] = useState('');
   const [importError, setImportError] = useState('');
+  const [lintWarnings, setLintWarnings] = useState<string[]>([]);
+  const [saveError, setSaveError] = useState('');
 
   const monaco = useMonaco();
 
@@ -189,20 +270,23 @@ export default function ActionTemplates() {
         body = JSON.stringify(parsed, null, 4);
     } catch (e) {}
     
-    setFormData({ ...action, body_template: body });
+    setFormData({ ...action, body_template: body, content_type: action.content_type || 'application/json' });
+    setSaveError('');
     setOpen(true);
   };
 
   const handleSave = async () => {
     if (!formData) return;
     try {
-      await client.put('/actions', formData, {
+      const res = await client.put('/actions', formData, {
           headers: { 'X-Tenant-ID': selectedTenant.toString() }
       });
+      setLintWarnings(res.data?.warnings || []);
       setOpen(false);
       fetchActions();
-    } catch (error) {
+    } catch (error: any) {
       console.error("Save failed", error);
+      setSaveError(error.response?.data?.error || 'Failed to save action. Check console for details.');
     }
   };
 
@@ -220,9 +304,10 @@ export default function ActionTemplates() {
       }
 
       try {
-          await client.post('/actions', payload, {
+          const res = await client.post('/actions', payload, {
               headers: { 'X-Tenant-ID': selectedTenant.toString() }
           });
+          setLintWarnings(res.data?.warnings || []);
           setCreateOpen(false);
           fetchActions();
           // Reset form
@@ -233,12 +318,15 @@ export default function ActionTemplates() {
               method: 'POST',
               path_template: '',
               body_template: '{}',
+              content_type: 'application/json',
+              headers: {},
+              query_params: {},
               retry_count: 3
           });
           setImportJson('');
-      } catch (error) {
+      } catch (error: any) {
           console.error("Create failed", error);
-          setImportError('Failed to create action. Check console for details.');
+          setImportError(error.response?.data?.error || 'Failed to create action. Check console for details.');
       }
   };
 
@@ -261,6 +349,15 @@ export default function ActionTemplates() {
         </Button>
       </Box>
 
+      {lintWarnings.length > 0 && (
+        <Alert severity="warning" onClose={() => setLintWarnings([])} sx={{ mb: 2 }}>
+            <Typography variant="subtitle2">Saved with template warnings:</Typography>
+            {lintWarnings.map((w, i) => (
+                <Typography key={i} variant="body2" sx={{ fontFamily: 'monospace' }}>{w}</Typography>
+            ))}
+        </Alert>
+      )}
+
       <Box sx={{ display: 'flex', gap: 2, mb: 3 }}>
         <TextField
             size="small"
@@ -384,6 +481,12 @@ export default function ActionTemplates() {
                                 <MenuItem value="PUT">PUT</MenuItem>
                                 <MenuItem value="PATCH">PATCH</MenuItem>
                                 <MenuItem value="DELETE">DELETE</MenuItem>
+                                <MenuItem value="SEARCH">LDAP SEARCH</MenuItem>
+                                <MenuItem value="MODIFY">LDAP MODIFY</MenuItem>
+                                <MenuItem value="REMOVE_MEMBER">LDAP/SCIM REMOVE_MEMBER</MenuItem>
+                                <MenuItem value="FIND_USER">SCIM FIND_USER</MenuItem>
+                                <MenuItem value="DEACTIVATE_USER">SCIM DEACTIVATE_USER</MenuItem>
+                                <MenuItem value="DELETE_USER">SCIM DELETE_USER</MenuItem>
                             </Select>
                         </FormControl>
                     </Grid>
@@ -396,12 +499,44 @@ export default function ActionTemplates() {
                             onChange={(e) => setNewAction({ ...newAction, path_template: e.target.value })}
                         />
                     </Grid>
+                    <Grid item xs={12} md={4}>
+                        <FormControl fullWidth variant="filled">
+                            <InputLabel>Content Type</InputLabel>
+                            <Select
+                                value={newAction.content_type || 'application/json'}
+                                onChange={(e) => setNewAction({ ...newAction, content_type: e.target.value })}
+                            >
+                                {CONTENT_TYPES.map(ct => (
+                                    <MenuItem key={ct.value} value={ct.value}>{ct.label}</MenuItem>
+                                ))}
+                            </Select>
+                        </FormControl>
+                    </Grid>
+                    <Grid item xs={12} md={8} />
+                    <Grid item xs={12} md={6}>
+                        <KeyValueEditor
+                            label="Headers"
+                            namePlaceholder="X-Correlation-Id"
+                            valuePlaceholder="{{.IssueID}}"
+                            value={newAction.headers}
+                            onChange={(headers) => setNewAction(prev => ({ ...prev, headers }))}
+                        />
+                    </Grid>
+                    <Grid item xs={12} md={6}>
+                        <KeyValueEditor
+                            label="Query Parameters"
+                            namePlaceholder="filter"
+                            valuePlaceholder='email eq "{{.UserEmail}}"'
+                            value={newAction.query_params}
+                            onChange={(query_params) => setNewAction(prev => ({ ...prev, query_params }))}
+                        />
+                    </Grid>
                     <Grid item xs={12}>
                         <Typography variant="subtitle2" sx={{ fontWeight: 700, mb: 1 }}>Payload Template</Typography>
                         <Paper variant="outlined" sx={{ border: '1px solid #e2e8f0', borderRadius: 1, overflow: 'hidden' }}>
                             <Editor
                                 height="300px"
-                                defaultLanguage="json"
+                                language={editorLanguage(newAction.content_type)}
                                 theme="vs-dark"
                                 value={newAction.body_template}
                                 onChange={(value) => setNewAction({ ...newAction, body_template: value || '{}' })}
@@ -443,6 +578,7 @@ export default function ActionTemplates() {
       <Dialog open={open} onClose={() => setOpen(false)} maxWidth="lg" fullWidth scroll="paper">
         <DialogTitle sx={{ fontWeight: 700 }}>Edit Action Template: {selected?.name}</DialogTitle>
         <DialogContent dividers>
+          {saveError && <Alert severity="error" sx={{ mb: 2 }}>{saveError}</Alert>}
           <Grid container spacing={3} sx={{ mt: 0 }}>
             <Grid item xs={12} md={3}>
               <TextField
@@ -463,9 +599,50 @@ export default function ActionTemplates() {
                 helperText="Variables allowed: {{.UserEmail}}"
               />
             </Grid>
+            <Grid item xs={12} md={3}>
+              <FormControl fullWidth variant="filled">
+                <InputLabel>Content Type</InputLabel>
+                <Select
+                  value={formData?.content_type || 'application/json'}
+                  onChange={(e) => setFormData(prev => prev ? { ...prev, content_type: e.target.value } : null)}
+                >
+                  {CONTENT_TYPES.map(ct => (
+                    <MenuItem key={ct.value} value={ct.value}>{ct.label}</MenuItem>
+                  ))}
+                  {formData?.content_type && !CONTENT_TYPES.some(ct => ct.value === formData.content_type) && (
+                    <MenuItem value={formData.content_type}>{formData.content_type}</MenuItem>
+                  )}
+                </Select>
+              </FormControl>
+            </Grid>
+            <Grid item xs={12} md={9}>
+              <Typography variant="caption" color="text.secondary">
+                Header and query parameter values are templates. Query parameters are URL-encoded, and any that render empty are left out.
+              </Typography>
+            </Grid>
+            <Grid item xs={12} md={6}>
+              <KeyValueEditor
+                key={`headers-${selected?.id}`}
+                label="Headers"
+                namePlaceholder="X-Correlation-Id"
+                valuePlaceholder="{{.IssueID}}"
+                value={formData?.headers}
+                onChange={(headers) => setFormData(prev => prev ? { ...prev, headers } : null)}
+              />
+            </Grid>
+            <Grid item xs={12} md={6}>
+              <KeyValueEditor
+                key={`query-${selected?.id}`}
+                label="Query Parameters"
+                namePlaceholder="filter"
+                valuePlaceholder='email eq "{{.UserEmail}}"'
+                value={formData?.query_params}
+                onChange={(query_params) => setFormData(prev => prev ? { ...prev, query_params } : null)}
+              />
+            </Grid>
             <Grid item xs={12}>
               <Box sx={{ display: 'flex', alignItems: 'center', mb: 1, gap: 1 }}>
-                <Typography variant="subtitle2" sx={{ fontWeight: 700 }}>Payload Template (JSON)</Typography>
+                <Typography variant="subtitle2" sx={{ fontWeight: 700 }}>Payload Template ({CONTENT_TYPES.find(ct => ct.value === formData?.content_type)?.label || formData?.content_type || 'JSON'})</Typography>
                 <Tooltip title="View Template Documentation">
                   <IconButton size="small" color="primary" onClick={() => setDocOpen(true)}>
                     <InfoIcon fontSize="small" />
@@ -475,7 +652,7 @@ export default function ActionTemplates() {
               <Paper variant="outlined" sx={{ border: '1px solid #e2e8f0', borderRadius: 1, overflow: 'hidden' }}>
                 <Editor
                     height="400px"
-                    defaultLanguage="json"
+                    language={editorLanguage(formData?.content_type)}
                     theme="vs-dark"
                     value={formData?.body_template}
                     onChange={(value) => setFormData(prev => prev ? { ...prev, body_template: value || '' } : null)}
@@ -558,24 +735,24 @@ export default function ActionTemplates() {
   "blocks": [
     {
       "type": "header",
-      "text": { "type": "plain_text", "text": "🛡️ Security Issue: {{.IssueType | jsonescape}}" }
+      "text": { "type": "plain_text", "text": "🛡️ Security Issue: {{.IssueType}}" }
     },
     {
       "type": "section",
-      "text": { "type": "mrkdwn", "text": "*Description:*\\n{{.IssueMessage | jsonescape}}" }
+      "text": { "type": "mrkdwn", "text": "*Description:*\\n{{.IssueMessage}}" }
     },
     {
       "type": "section",
       "fields": [
-        { "type": "mrkdwn", "text": "*Risk Level:*\\n{{.Risk | jsonescape}}" },
+        { "type": "mrkdwn", "text": "*Risk Level:*\\n{{.Risk}}" },
         { "type": "mrkdwn", "text": "*Total Flows:*\\n{{.FlowCount}}" }
       ]
     },
     {
       "type": "section",
       "fields": [
-        { "type": "mrkdwn", "text": "*Identity:*\\n👤 {{.UserEmail | jsonescape}}" },
-        { "type": "mrkdwn", "text": "*Asset:*\\n🖥️ {{index .IssueKeys \"asset_name\" | default \"N/A\" | jsonescape}}" }
+        { "type": "mrkdwn", "text": "*Identity:*\\n👤 {{.UserEmail}}" },
+        { "type": "mrkdwn", "text": "*Asset:*\\n🖥️ {{index .IssueKeys \"asset_name\" | default \"N/A\"}}" }
       ]
     },
     {
@@ -584,7 +761,7 @@ export default function ActionTemplates() {
         {
           "type": "button",
           "text": { "type": "plain_text", "text": "View in Console" },
-          "url": "https://console.authmind.com/issues?q=id%3A{{.IssueID | jsonescape}}",
+          "url": "https://console.authmind.com/issues?q=id%3A{{.IssueID}}",
           "style": "primary"
         }
       ]
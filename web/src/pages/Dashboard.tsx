@@ -26,6 +26,7 @@ import {
   TablePagination,
   TextField,
   InputAdornment,
+  Button,
   Stepper,
   Step,
   StepLabel,
@@ -52,6 +53,7 @@ import SettingsIcon from '@mui/icons-material/Settings';
 import CorporateFareIcon from '@mui/icons-material/CorporateFare';
 import SearchIcon from '@mui/icons-material/Search';
 import FilterListIcon from '@mui/icons-material/FilterList';
+import ReplayIcon from '@mui/icons-material/Replay';
 import CloseIcon from '@mui/icons-material/Close';
 import TerminalIcon from '@mui/icons-material/Terminal';
 import { useTenant } from '../context/TenantContext';
@@ -92,6 +94,7 @@ interface JobLog {
     step_name: string;
     status_code: number;
     response_body: string;
+    queue_wait_ms?: number;
 }
 
 interface Job {
@@ -103,6 +106,7 @@ interface Job {
     status: string;
     authmind_issue_id: string;
     trigger_context: string; 
+    trace_id?: string;
 }
 
 const FlowConnector = styled(StepConnector)(({ theme }) => ({
@@ -180,6 +184,23 @@ function FlowStepIcon(props: StepIconProps & { status: string }) {
   );
 }
 
+function statusColor(status: string): 'success' | 'warning' | 'error' | 'default' | 'info' {
+  switch (status) {
+    case 'succeeded':
+      return 'success';
+    case 'succeeded_with_warnings':
+    case 'interrupted':
+      return 'warning';
+    case 'failed':
+      return 'error';
+    case 'cancelled':
+    case 'resolved_manually':
+      return 'default';
+    default:
+      return 'info';
+  }
+}
+
 function Row(props: { job: Job, onRerun: () => void, isGlobal: boolean, tenants: Tenant[] }) {
   const { job, onRerun, isGlobal, tenants } = props;
   const [open, setOpen] = useState(false);
@@ -276,7 +297,7 @@ function Row(props: { job: Job, onRerun: () => void, isGlobal: boolean, tenants:
                 label={job.status.toUpperCase()} 
                 size="small" 
                 sx={{ fontWeight: 700, borderRadius: 1 }}
-                color={job.status === 'completed' ? 'success' : job.status === 'failed' ? 'error' : 'info'}
+                color={statusColor(job.status)}
             />
             <Tooltip title="Rerun this workflow">
                 <IconButton size="small" color="primary" onClick={handleRerun}>
@@ -411,11 +432,23 @@ function Row(props: { job: Job, onRerun: () => void, isGlobal: boolean, tenants:
                                         color={getLogStatusColor(selectedLog) === 'error' ? 'error' : getLogStatusColor(selectedLog) === 'warning' ? 'warning' : 'success'} 
                                         sx={{ fontWeight: 700 }}
                                     />
+                                    {!!selectedLog.queue_wait_ms && (
+                                        <Chip label={`Queued ${(selectedLog.queue_wait_ms / 1000).toFixed(1)}s`} variant="outlined" size="small" sx={{ fontWeight: 600 }} />
+                                    )}
                                     {selectedLog.response_body && (
                                         <Chip label="Response Captured" variant="outlined" size="small" sx={{ fontWeight: 600 }} />
                                     )}
                                 </Box>
                             </Box>
+
+                            {job.trace_id && (
+                                <Box sx={{ mt: 2 }}>
+                                    <Typography variant="overline" color="text.secondary" sx={{ fontWeight: 800 }}>Trace ID</Typography>
+                                    <Typography variant="body2" sx={{ fontFamily: 'monospace', wordBreak: 'break-all' }}>
+                                        {job.trace_id}
+                                    </Typography>
+                                </Box>
+                            )}
                         </Box>
 
                         <Divider />
@@ -506,6 +539,21 @@ export default function Dashboard() {
     }
   };
 
+  // Bulk rerun of every job matching the current filters: count first, then confirm
+  const handleBulkRerun = async () => {
+      const headers = selectedTenant === 0 ? {} : { 'X-Tenant-ID': selectedTenant.toString() };
+      const filter = { search, status: statusFilter };
+      try {
+          const dryRun = await client.post('/jobs/rerun', { ...filter, dry_run: true }, { headers });
+          const count = dryRun.data.count;
+          if (count === 0 || !window.confirm(`Rerun ${count} job(s) matching the current filters?`)) return;
+          await client.post('/jobs/rerun', filter, { headers });
+          setRerunTriggered(true);
+      } catch (e) {
+          console.error("Bulk rerun failed", e);
+      }
+  };
+
   const handleChangePage = (_event: unknown, newPage: number) => {
     setPage(newPage);
   };
@@ -719,11 +767,25 @@ export default function Dashboard() {
                 sx={{ minWidth: 150 }}
             >
                 <MenuItem value="">All Statuses</MenuItem>
-                <MenuItem value="completed">Completed</MenuItem>
+                <MenuItem value="succeeded">Succeeded</MenuItem>
+                <MenuItem value="succeeded_with_warnings">Succeeded with Warnings</MenuItem>
                 <MenuItem value="failed">Failed</MenuItem>
                 <MenuItem value="running">Running</MenuItem>
                 <MenuItem value="pending">Pending</MenuItem>
+                <MenuItem value="cancelled">Cancelled</MenuItem>
+                <MenuItem value="interrupted">Interrupted</MenuItem>
+                <MenuItem value="resolved_manually">Resolved Manually</MenuItem>
             </Select>
+
+            <Button
+                size="small"
+                variant="outlined"
+                startIcon={<ReplayIcon />}
+                disabled={!search && !statusFilter}
+                onClick={handleBulkRerun}
+            >
+                Rerun Matching
+            </Button>
         </Box>
       </Box>
 
@@ -0,0 +1,224 @@
+import { useEffect, useState } from 'react';
+import client from '../api/client';
+import {
+  Box,
+  Typography,
+  Paper,
+  Table,
+  TableBody,
+  TableCell,
+  TableContainer,
+  TableHead,
+  TableRow,
+  Chip,
+  Checkbox,
+  Button,
+  TextField,
+  alpha,
+  useTheme
+} from '@mui/material';
+import ReportProblemIcon from '@mui/icons-material/ReportProblem';
+import ReplayIcon from '@mui/icons-material/Replay';
+import DoneAllIcon from '@mui/icons-material/DoneAll';
+import VisibilityIcon from '@mui/icons-material/Visibility';
+import PersonAddIcon from '@mui/icons-material/PersonAdd';
+
+interface DeadLetterGroup {
+    integration_id: number;
+    integration_name: string;
+    action: string;
+    error_class: string;
+    count: number;
+    unacknowledged: number;
+    latest_at: string;
+}
+
+interface DeadLetterJob {
+    id: number;
+    created_at: string;
+    status: string;
+    authmind_issue_id: string;
+    workflow: { name: string };
+    failed_action?: string;
+    error_class?: string;
+    acknowledged_at?: string;
+    assigned_to?: string;
+}
+
+const groupKey = (g: DeadLetterGroup) => `${g.integration_id}|${g.action}|${g.error_class}`;
+
+export default function DeadLetter() {
+    const [groups, setGroups] = useState<DeadLetterGroup[]>([]);
+    const [selectedGroup, setSelectedGroup] = useState<DeadLetterGroup | null>(null);
+    const [jobs, setJobs] = useState<DeadLetterJob[]>([]);
+    const [selectedJobs, setSelectedJobs] = useState<number[]>([]);
+    const [assignee, setAssignee] = useState('');
+    const [reason, setReason] = useState('');
+    const theme = useTheme();
+
+    const fetchGroups = async () => {
+        try {
+            const res = await client.get('/dead-letter');
+            setGroups(res.data.groups);
+        } catch (err) {
+            console.error("Failed to fetch dead-letter groups", err);
+        }
+    };
+
+    const fetchJobs = async (group: DeadLetterGroup) => {
+        try {
+            const res = await client.get('/dead-letter/jobs', {
+                params: { integration_id: group.integration_id, action: group.action, error_class: group.error_class, pageSize: 100 }
+            });
+            setJobs(res.data.data || []);
+        } catch (err) {
+            console.error("Failed to fetch dead-letter jobs", err);
+        }
+    };
+
+    useEffect(() => {
+        fetchGroups();
+    }, []);
+
+    const selectGroup = (group: DeadLetterGroup) => {
+        setSelectedGroup(group);
+        setSelectedJobs([]);
+        fetchJobs(group);
+    };
+
+    const toggleJob = (id: number) => {
+        setSelectedJobs(prev => prev.includes(id) ? prev.filter(j => j !== id) : [...prev, id]);
+    };
+
+    const runTriage = async (operation: 'acknowledge' | 'assign' | 'rerun' | 'resolve') => {
+        if (selectedJobs.length === 0) return;
+        try {
+            await client.post(`/dead-letter/${operation}`, { job_ids: selectedJobs, assignee, reason });
+            setSelectedJobs([]);
+            await fetchGroups();
+            if (selectedGroup) await fetchJobs(selectedGroup);
+        } catch (err) {
+            console.error(`Failed to ${operation} jobs`, err);
+        }
+    };
+
+    return (
+        <Box>
+            <Box sx={{ mb: 4 }}>
+                <Typography variant="h4" sx={{ fontWeight: 800, display: 'flex', alignItems: 'center', gap: 2 }}>
+                    <ReportProblemIcon sx={{ fontSize: 40, color: 'error.main' }} /> Dead-Letter Queue
+                </Typography>
+                <Typography variant="body1" color="text.secondary" sx={{ mt: 1 }}>
+                    Failed jobs that still need a decision, grouped by integration, action and error class.
+                </Typography>
+            </Box>
+
+            <TableContainer component={Paper} variant="outlined" sx={{ borderRadius: 3, mb: 4, border: '1px solid rgba(115, 131, 143, 0.1)' }}>
+                <Table size="small">
+                    <TableHead sx={{ bgcolor: alpha(theme.palette.secondary.main, 0.02) }}>
+                        <TableRow>
+                            <TableCell sx={{ fontWeight: 800 }}>Integration</TableCell>
+                            <TableCell sx={{ fontWeight: 800 }}>Action</TableCell>
+                            <TableCell sx={{ fontWeight: 800 }}>Error Class</TableCell>
+                            <TableCell sx={{ fontWeight: 800 }} align="right">Failed Jobs</TableCell>
+                            <TableCell sx={{ fontWeight: 800 }} align="right">Unacknowledged</TableCell>
+                            <TableCell sx={{ fontWeight: 800 }}>Latest</TableCell>
+                        </TableRow>
+                    </TableHead>
+                    <TableBody>
+                        {groups.length === 0 && (
+                            <TableRow>
+                                <TableCell colSpan={6} sx={{ color: 'text.secondary', textAlign: 'center', py: 4 }}>
+                                    No unhandled failures.
+                                </TableCell>
+                            </TableRow>
+                        )}
+                        {groups.map((g) => (
+                            <TableRow
+                                key={groupKey(g)}
+                                hover
+                                selected={selectedGroup !== null && groupKey(selectedGroup) === groupKey(g)}
+                                onClick={() => selectGroup(g)}
+                                sx={{ cursor: 'pointer' }}
+                            >
+                                <TableCell sx={{ fontWeight: 700 }}>{g.integration_name || 'Unknown'}</TableCell>
+                                <TableCell>{g.action || '-'}</TableCell>
+                                <TableCell>
+                                    <Chip label={g.error_class || 'unknown'} size="small" color="error" variant="outlined" sx={{ fontWeight: 700 }} />
+                                </TableCell>
+                                <TableCell align="right">{g.count}</TableCell>
+                                <TableCell align="right">{g.unacknowledged}</TableCell>
+                                <TableCell sx={{ fontSize: '0.8rem', color: 'text.secondary' }}>
+                                    {g.latest_at ? new Date(g.latest_at).toLocaleString() : '-'}
+                                </TableCell>
+                            </TableRow>
+                        ))}
+                    </TableBody>
+                </Table>
+            </TableContainer>
+
+            {selectedGroup && (
+                <Box>
+                    <Box sx={{ display: 'flex', flexWrap: 'wrap', alignItems: 'center', gap: 1, mb: 2 }}>
+                        <Button size="small" variant="outlined" startIcon={<VisibilityIcon />} disabled={selectedJobs.length === 0} onClick={() => runTriage('acknowledge')}>
+                            Acknowledge
+                        </Button>
+                        <TextField size="small" label="Assignee" value={assignee} onChange={(e) => setAssignee(e.target.value)} />
+                        <Button size="small" variant="outlined" startIcon={<PersonAddIcon />} disabled={selectedJobs.length === 0} onClick={() => runTriage('assign')}>
+                            Assign
+                        </Button>
+                        <Button size="small" variant="contained" startIcon={<ReplayIcon />} disabled={selectedJobs.length === 0} onClick={() => runTriage('rerun')}>
+                            Rerun
+                        </Button>
+                        <TextField size="small" label="Resolution note" value={reason} onChange={(e) => setReason(e.target.value)} />
+                        <Button size="small" variant="outlined" color="success" startIcon={<DoneAllIcon />} disabled={selectedJobs.length === 0} onClick={() => runTriage('resolve')}>
+                            Resolve Manually
+                        </Button>
+                    </Box>
+
+                    <TableContainer component={Paper} variant="outlined" sx={{ borderRadius: 3, border: '1px solid rgba(115, 131, 143, 0.1)' }}>
+                        <Table size="small">
+                            <TableHead>
+                                <TableRow>
+                                    <TableCell padding="checkbox">
+                                        <Checkbox
+                                            checked={jobs.length > 0 && selectedJobs.length === jobs.length}
+                                            indeterminate={selectedJobs.length > 0 && selectedJobs.length < jobs.length}
+                                            onChange={(e) => setSelectedJobs(e.target.checked ? jobs.map(j => j.id) : [])}
+                                        />
+                                    </TableCell>
+                                    <TableCell sx={{ fontWeight: 800 }}>Job</TableCell>
+                                    <TableCell sx={{ fontWeight: 800 }}>Workflow</TableCell>
+                                    <TableCell sx={{ fontWeight: 800 }}>Issue</TableCell>
+                                    <TableCell sx={{ fontWeight: 800 }}>Status</TableCell>
+                                    <TableCell sx={{ fontWeight: 800 }}>Assigned To</TableCell>
+                                    <TableCell sx={{ fontWeight: 800 }}>Failed At</TableCell>
+                                </TableRow>
+                            </TableHead>
+                            <TableBody>
+                                {jobs.map((job) => (
+                                    <TableRow key={job.id} hover>
+                                        <TableCell padding="checkbox">
+                                            <Checkbox checked={selectedJobs.includes(job.id)} onChange={() => toggleJob(job.id)} />
+                                        </TableCell>
+                                        <TableCell>#{job.id}</TableCell>
+                                        <TableCell>{job.workflow?.name}</TableCell>
+                                        <TableCell sx={{ fontFamily: 'monospace', fontSize: '0.75rem' }}>{job.authmind_issue_id}</TableCell>
+                                        <TableCell>
+                                            <Chip label={job.status.toUpperCase()} size="small" color="error" sx={{ fontWeight: 700, mr: 1 }} />
+                                            {job.acknowledged_at && <Chip label="ACK" size="small" variant="outlined" />}
+                                        </TableCell>
+                                        <TableCell>{job.assigned_to || '-'}</TableCell>
+                                        <TableCell sx={{ fontSize: '0.8rem', color: 'text.secondary' }}>
+                                            {new Date(job.created_at).toLocaleString()}
+                                        </TableCell>
+                                    </TableRow>
+                                ))}
+                            </TableBody>
+                        </Table>
+                    </TableContainer>
+                </Box>
+            )}
+        </Box>
+    );
+}